@@ -0,0 +1,240 @@
+// Package apitest runs the API end to end for tests: the real routes,
+// middleware and repositories over a throwaway Postgres schema. Requests are
+// signed in with tokens the harness mints in place of Clerk's, so tests can
+// act as any user.
+package apitest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/auth"
+	"github.com/theHoracle/whatstore-api/app/middleware"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/routes"
+	"github.com/theHoracle/whatstore-api/db/migrations"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Harness is the API serving one test
+type Harness struct {
+	T   *testing.T
+	DB  *gorm.DB
+	App *fiber.App
+}
+
+// New starts the API on a fresh schema. The test is skipped when no
+// database is configured, see Database.
+func New(t *testing.T) *Harness {
+	t.Helper()
+	db := Database(t)
+	useTestTokens.Do(func() { middleware.UseAuthenticator(auth.AuthenticatorFunc(verifyTestToken)) })
+
+	app := fiber.New()
+	routes.API(app, db, routes.NewHandlers(db))
+	return &Harness{T: t, DB: db, App: app}
+}
+
+// Database connects to the Postgres in TEST_DATABASE_URL, or DATABASE_URL,
+// and migrates a schema of its own that is dropped when the test ends. The
+// test is skipped when neither is set.
+func Database(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		dsn = os.Getenv("DATABASE_URL")
+	}
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("connecting to the test database: %v", err)
+	}
+	schema := fmt.Sprintf("apitest_%d_%s", time.Now().Unix(), randomHex(4))
+	// Extensions belong to the database, so they are kept out of test schemas
+	// that are dropped while other tests use them
+	for _, statement := range []string{
+		"CREATE EXTENSION IF NOT EXISTS pg_trgm SCHEMA public",
+		"CREATE EXTENSION IF NOT EXISTS btree_gist SCHEMA public",
+		"CREATE SCHEMA " + schema,
+	} {
+		if err := admin.Exec(statement).Error; err != nil {
+			t.Fatalf("preparing the test schema: %v", err)
+		}
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	db, err := gorm.Open(postgres.Open(withSearchPath(dsn, schema+",public")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("connecting to the test schema: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if _, err := migrations.Up(context.Background(), sqlDB, 0); err != nil {
+		t.Fatalf("migrating the test schema: %v", err)
+	}
+	return db
+}
+
+// withSearchPath adds a search_path to a URL or keyword/value connection string
+func withSearchPath(dsn, path string) string {
+	if !strings.Contains(dsn, "://") {
+		return dsn + " search_path=" + path
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&search_path=" + path
+	}
+	return dsn + "?search_path=" + path
+}
+
+var (
+	useTestTokens sync.Once
+	tokens        sync.Map // Clerk ID by token
+
+	othersMu sync.Mutex
+	others   auth.Authenticator // Checks tokens the harness did not mint
+)
+
+var errUnknownToken = fmt.Errorf("%w: token was not minted by the test harness", auth.ErrInvalidToken)
+
+func verifyTestToken(ctx context.Context, token string) (*auth.Identity, error) {
+	clerkID, ok := tokens.Load(token)
+	if !ok {
+		othersMu.Lock()
+		a := others
+		othersMu.Unlock()
+		if a != nil {
+			return a.Authenticate(ctx, token)
+		}
+		return nil, errUnknownToken
+	}
+	return &auth.Identity{Subject: clerkID.(string)}, nil
+}
+
+// AcceptTokens has tokens the harness did not mint checked by a, such as an
+// OIDC provider the test runs, until the test ends
+func (h *Harness) AcceptTokens(a auth.Authenticator) {
+	othersMu.Lock()
+	others = a
+	othersMu.Unlock()
+	h.T.Cleanup(func() {
+		othersMu.Lock()
+		others = nil
+		othersMu.Unlock()
+	})
+}
+
+// Token mints a bearer token that signs requests in as user
+func (h *Harness) Token(user *models.User) string {
+	token := "test_" + randomHex(16)
+	tokens.Store(token, user.ClerkID)
+	return token
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Response is the API's answer to a request
+type Response struct {
+	t      *testing.T
+	label  string
+	Status int
+	Body   []byte
+}
+
+// Request sends body as JSON to the API, signed in as user or anonymously
+// when user is nil
+func (h *Harness) Request(method, path string, user *models.User, body any) *Response {
+	h.T.Helper()
+	token := ""
+	if user != nil {
+		token = h.Token(user)
+	}
+	return h.RequestWithToken(method, path, token, body)
+}
+
+// RequestWithToken sends body as JSON to the API with token as its bearer
+// token, or anonymously when token is empty
+func (h *Harness) RequestWithToken(method, path, token string, body any) *Response {
+	h.T.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			h.T.Fatal(err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Host = "localhost"
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := h.App.Test(req, -1)
+	if err != nil {
+		h.T.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		h.T.Fatal(err)
+	}
+	return &Response{t: h.T, label: method + " " + path, Status: resp.StatusCode, Body: raw}
+}
+
+// Expect fails the test unless the response has status, and decodes the
+// body into out when it is set
+func (r *Response) Expect(status int, out any) *Response {
+	r.t.Helper()
+	if r.Status != status {
+		r.t.Fatalf("%s: status %d, want %d: %s", r.label, r.Status, status, r.Body)
+	}
+	if out != nil {
+		if err := json.Unmarshal(r.Body, out); err != nil {
+			r.t.Fatalf("%s: decoding %s: %v", r.label, r.Body, err)
+		}
+	}
+	return r
+}
+
+// Error returns the error message of a failed request
+func (r *Response) Error() string {
+	var body struct {
+		Error string `json:"error"`
+	}
+	json.Unmarshal(r.Body, &body)
+	return body.Error
+}
@@ -0,0 +1,74 @@
+package apitest_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/apitest"
+	"github.com/theHoracle/whatstore-api/app/auth"
+	"github.com/theHoracle/whatstore-api/app/models"
+)
+
+func TestOIDCSignInCreatesUser(t *testing.T) {
+	h := apitest.New(t)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "test", Use: "sig", Algorithm: string(jose.RS256)},
+		}})
+	}))
+	defer jwks.Close()
+	oidc, err := auth.NewOIDC(context.Background(), "https://id.example.com", "whatstore", jwks.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.AcceptTokens(oidc)
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := func(subject string, profile map[string]any) string {
+		now := time.Now()
+		claims := jwt.Claims{Subject: subject, Issuer: "https://id.example.com", Audience: jwt.Audience{"whatstore"},
+			IssuedAt: jwt.NewNumericDate(now), Expiry: jwt.NewNumericDate(now.Add(time.Hour))}
+		raw, err := jwt.Signed(signer).Claims(claims).Claims(profile).CompactSerialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+
+	// A subject the API has never seen becomes a buyer, once
+	ada := token("oidc|ada", map[string]any{"email": "ada@example.com", "email_verified": true, "name": "Ada Obi"})
+	var me, again models.User
+	h.RequestWithToken("GET", "/api/v1/users/me", ada, nil).Expect(fiber.StatusOK, &me)
+	if me.ClerkID != "oidc|ada" || me.Email != "ada@example.com" || me.Name != "Ada Obi" || me.Role != models.RoleBuyer {
+		t.Errorf("created user %+v", me)
+	}
+	h.RequestWithToken("GET", "/api/v1/users/me", ada, nil).Expect(fiber.StatusOK, &again)
+	if again.ID != me.ID {
+		t.Errorf("signed in again as %d, want %d", again.ID, me.ID)
+	}
+
+	// Without an address to go on, or with one taken, nobody is created
+	noEmail := token("oidc|bola", map[string]any{"name": "Bola"})
+	if msg := h.RequestWithToken("GET", "/api/v1/users/me", noEmail, nil).Expect(fiber.StatusUnauthorized, nil).Error(); msg != "User not found in database" {
+		t.Errorf("no email: %q", msg)
+	}
+	taken := token("oidc|imposter", map[string]any{"email": "ada@example.com"})
+	h.RequestWithToken("GET", "/api/v1/users/me", taken, nil).Expect(fiber.StatusConflict, nil)
+}
@@ -0,0 +1,110 @@
+package apitest_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/apitest"
+	"github.com/theHoracle/whatstore-api/app/booking"
+	"github.com/theHoracle/whatstore-api/app/escrow"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/orderflow"
+	"github.com/theHoracle/whatstore-api/app/payments"
+)
+
+var escrowHooks sync.Once
+
+// useEscrow registers the escrow hooks once for every test that needs them
+func useEscrow() {
+	escrowHooks.Do(func() {
+		payments.OnPaid(escrow.HoldPayment)
+		booking.OnTransition(escrow.BookingHook)
+		orderflow.OnTransition(escrow.OrderHook(24 * time.Hour))
+	})
+}
+
+func TestBookingPaymentEscrow(t *testing.T) {
+	h := apitest.New(t)
+	useEscrow()
+	provider := newFakeProvider("fake-bookings")
+	ada := h.Vendor("Ada")
+	buyer := h.Buyer("Chidi")
+	store := h.Store(ada, "adas")
+
+	service := models.Service{StoreID: store.ID, Name: "Haircut", Rate: 50, Currency: "NGN", SlotMinutes: 60}
+	if err := h.DB.Create(&service).Error; err != nil {
+		t.Fatal(err)
+	}
+	book := func(start time.Time) *models.Booking {
+		b := &models.Booking{ServiceID: service.ID, StoreID: store.ID, UserID: buyer.ID, StartsAt: start, EndsAt: start.Add(time.Hour),
+			Status: models.BookingStatusRequested, Price: service.Rate, Currency: service.Currency}
+		if err := h.DB.Create(b).Error; err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	pay := func(b *models.Booking) {
+		var payment models.Payment
+		h.Request("POST", "/api/v1/bookings/"+id(b.ID)+"/pay", buyer, models.InitializePaymentRequest{Provider: provider.name}).
+			Expect(fiber.StatusCreated, &payment)
+		if _, err := payments.Confirm(context.Background(), h.DB, provider, payment.Reference); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hold := func(b *models.Booking) models.EscrowHold {
+		var hold models.EscrowHold
+		if err := h.DB.Where("booking_id = ?", b.ID).First(&hold).Error; err != nil {
+			t.Fatalf("hold of booking %d: %v", b.ID, err)
+		}
+		return hold
+	}
+
+	// A paid booking is held until the service is completed
+	done := book(time.Now().Add(-2 * time.Hour))
+	pay(done)
+	if got := hold(done); got.Status != models.EscrowStatusHeld || got.Amount != 50 {
+		t.Fatalf("hold %+v", got)
+	}
+	if err := booking.Transition(h.DB, done, models.BookingStatusConfirmed, models.OrderActorVendor); err != nil {
+		t.Fatal(err)
+	}
+	if err := booking.Transition(h.DB, done, models.BookingStatusCompleted, models.OrderActorVendor); err != nil {
+		t.Fatal(err)
+	}
+	if got := hold(done); got.Status != models.EscrowStatusReleased {
+		t.Errorf("hold after completion %+v", got)
+	}
+
+	// Cancelling a paid booking, as expiring an unconfirmed one does, refunds it
+	expired := book(time.Now().Add(-time.Hour))
+	pay(expired)
+	if err := booking.Transition(h.DB, expired, models.BookingStatusCancelled, models.OrderActorSystem); err != nil {
+		t.Fatal(err)
+	}
+	if got := hold(expired); got.Status != models.EscrowStatusRefundPending {
+		t.Errorf("hold after cancellation %+v", got)
+	}
+
+	// A payment that completes after the booking was cancelled goes back too
+	late := book(time.Now().Add(time.Hour))
+	var payment models.Payment
+	h.Request("POST", "/api/v1/bookings/"+id(late.ID)+"/pay", buyer, models.InitializePaymentRequest{Provider: provider.name}).
+		Expect(fiber.StatusCreated, &payment)
+	if err := booking.Transition(h.DB, late, models.BookingStatusCancelled, models.OrderActorBuyer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := payments.Confirm(context.Background(), h.DB, provider, payment.Reference); err != nil {
+		t.Fatal(err)
+	}
+	if got := hold(late); got.Status != models.EscrowStatusRefundPending {
+		t.Errorf("hold of late payment %+v", got)
+	}
+
+	reconciliation, err := escrow.Reconcile(h.DB)
+	if err != nil || !reconciliation.Balanced {
+		t.Errorf("reconciliation %+v, %v", reconciliation, err)
+	}
+}
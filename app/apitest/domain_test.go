@@ -0,0 +1,75 @@
+package apitest_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/apitest"
+	"github.com/theHoracle/whatstore-api/app/controllers"
+	"github.com/theHoracle/whatstore-api/app/domains"
+	"github.com/theHoracle/whatstore-api/app/models"
+)
+
+// fakeDNS serves the TXT records tests publish
+type fakeDNS struct {
+	mu      sync.Mutex
+	records map[string][]string
+}
+
+func (d *fakeDNS) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.records[name], nil
+}
+
+func (d *fakeDNS) publish(name, value string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.records[name] = append(d.records[name], value)
+}
+
+func TestDomainClaims(t *testing.T) {
+	h := apitest.New(t)
+	dns := &fakeDNS{records: map[string][]string{}}
+	domains.Use(dns)
+	ada, bola, chidi := h.Vendor("Ada"), h.Vendor("Bola"), h.Vendor("Chidi")
+	adas, bolas, chidis := h.Store(ada, "adas"), h.Store(bola, "bolas"), h.Store(chidi, "chidis")
+	path := func(store *models.Store) string { return "/api/v1/stores/" + id(store.ID) + "/domains" }
+	input := models.AddStoreDomainRequest{Domain: "Shop.Example.com"}
+
+	// Both stores may claim the domain while neither has verified it
+	var adaSetup, bolaSetup controllers.StoreDomainSetup
+	h.Request("POST", path(adas), ada, input).Expect(fiber.StatusCreated, &adaSetup)
+	h.Request("POST", path(bolas), bola, input).Expect(fiber.StatusCreated, &bolaSetup)
+	if adaSetup.NextCheckAt == nil || adaSetup.NextCheckAt.After(time.Now()) {
+		t.Errorf("first check of a new domain at %v", adaSetup.NextCheckAt)
+	}
+	h.Request("POST", path(adas), ada, input).Expect(fiber.StatusConflict, nil)
+
+	// Bola publishes their record first and gets the domain
+	dns.publish(bolaSetup.RecordName, bolaSetup.RecordValue)
+	var verified controllers.StoreDomainSetup
+	h.Request("POST", path(bolas)+"/"+id(bolaSetup.ID)+"/verify", bola, nil).Expect(fiber.StatusOK, &verified)
+	if verified.Status != models.DomainStatusVerified {
+		t.Fatalf("bola's domain %+v", verified.StoreDomain)
+	}
+
+	var lost models.StoreDomain
+	if err := h.DB.First(&lost, adaSetup.ID).Error; err != nil || lost.Status != models.DomainStatusFailed {
+		t.Errorf("ada's claim %+v, %v", lost, err)
+	}
+
+	// Ada's record showing up later does not take the domain over
+	dns.publish(adaSetup.RecordName, adaSetup.RecordValue)
+	var retried controllers.StoreDomainSetup
+	h.Request("POST", path(adas)+"/"+id(adaSetup.ID)+"/verify", ada, nil).Expect(fiber.StatusOK, &retried)
+	if retried.Status != models.DomainStatusFailed {
+		t.Errorf("ada's retried claim %+v", retried.StoreDomain)
+	}
+
+	// Once verified, nobody else can claim it
+	h.Request("POST", path(chidis), chidi, input).Expect(fiber.StatusConflict, nil)
+}
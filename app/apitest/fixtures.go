@@ -0,0 +1,76 @@
+package apitest
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/repository"
+)
+
+// Buyer adds a user who signed up with Clerk and has bought nothing yet
+func (h *Harness) Buyer(name string) *models.User {
+	h.T.Helper()
+	user := &models.User{
+		ClerkID:  "user_" + name,
+		Name:     name,
+		Email:    strings.ToLower(name) + "@example.com",
+		Username: strings.ToLower(name),
+		Role:     models.RoleBuyer,
+	}
+	if err := h.DB.Create(user).Error; err != nil {
+		h.T.Fatalf("adding buyer %s: %v", name, err)
+	}
+	return user
+}
+
+// Vendor adds a user with a vendor account and no stores
+func (h *Harness) Vendor(name string) *models.User {
+	h.T.Helper()
+	user := h.Buyer(name)
+	vendor := &models.Vendor{UserID: user.ID, IsActive: true, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := h.DB.Create(vendor).Error; err != nil {
+		h.T.Fatalf("adding vendor %s: %v", name, err)
+	}
+	if err := h.DB.Model(user).Update("role", models.RoleVendor).Error; err != nil {
+		h.T.Fatal(err)
+	}
+	user.Vendor = vendor
+	return user
+}
+
+// Store adds a store owned by a user added with Vendor
+func (h *Harness) Store(owner *models.User, url string) *models.Store {
+	h.T.Helper()
+	now := time.Now()
+	store := &models.Store{
+		VendorID:             owner.Vendor.ID,
+		Name:                 url,
+		StoreUrl:             url,
+		StoreAddress:         "1 Broad Street, Lagos",
+		StoreWhatsappContact: "+2348012345678",
+		Timezone:             "Africa/Lagos",
+	}
+	err := repository.NewStores(h.DB).Create(context.Background(), store, &models.StoreMember{
+		Email:      owner.Email,
+		UserID:     &owner.ID,
+		Role:       models.StoreRoleOwner,
+		Status:     models.MemberStatusActive,
+		AcceptedAt: &now,
+	})
+	if err != nil {
+		h.T.Fatalf("adding store %s: %v", url, err)
+	}
+	return store
+}
+
+// Product adds a product to a store with stock units at price
+func (h *Harness) Product(store *models.Store, name string, price float64, stock int) *models.Product {
+	h.T.Helper()
+	product := &models.Product{StoreID: store.ID, Name: name, Price: price, Stock: stock, Currency: "NGN"}
+	if err := repository.NewProducts(h.DB).Create(context.Background(), product, nil); err != nil {
+		h.T.Fatalf("adding product %s: %v", name, err)
+	}
+	return product
+}
@@ -0,0 +1,128 @@
+package apitest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/apitest"
+	"github.com/theHoracle/whatstore-api/app/inventory"
+	"github.com/theHoracle/whatstore-api/app/models"
+)
+
+func TestOrderCreation(t *testing.T) {
+	h := apitest.New(t)
+	ada := h.Vendor("Ada")
+	buyer := h.Buyer("Chidi")
+	store := h.Store(ada, "adas")
+	sneakers := h.Product(store, "Sneakers", 100, 5)
+	socks := h.Product(store, "Socks", 10, 1)
+
+	items := []models.OrderItem{
+		{ProductID: sneakers.ID, Quantity: 2},
+		{ProductID: socks.ID, Quantity: 1},
+	}
+	var order models.Order
+	h.Request("POST", "/api/v1/orders", buyer, models.CreateOrderRequest{Items: items}).Expect(fiber.StatusCreated, &order)
+	if order.StoreID != store.ID || order.Status != models.OrderStatusPending || order.TotalAmount != 210 || len(order.Items) != 2 {
+		t.Fatalf("order %+v", order)
+	}
+
+	// Stock was reserved for the order
+	var product models.Product
+	h.Request("GET", "/api/v1/products/"+id(sneakers.ID), nil, nil).Expect(fiber.StatusOK, &product)
+	if product.Stock != 3 {
+		t.Errorf("sneakers stock %d, want 3", product.Stock)
+	}
+
+	// The socks are gone, and nothing is reserved for an order that cannot be filled
+	var short struct {
+		Error string `json:"error"`
+		Items []struct {
+			ProductID uint `json:"product_id"`
+		} `json:"items"`
+	}
+	h.Request("POST", "/api/v1/orders", buyer, models.CreateOrderRequest{Items: items}).Expect(fiber.StatusConflict, &short)
+	if len(short.Items) != 1 || short.Items[0].ProductID != socks.ID {
+		t.Errorf("short %+v", short)
+	}
+	h.Request("GET", "/api/v1/products/"+id(sneakers.ID), nil, nil).Expect(fiber.StatusOK, &product)
+	if product.Stock != 3 {
+		t.Errorf("sneakers stock after failed order %d, want 3", product.Stock)
+	}
+
+	path := "/api/v1/orders/" + id(order.ID)
+	h.Request("GET", path, buyer, nil).Expect(fiber.StatusOK, nil)
+	h.Request("GET", path, ada, nil).Expect(fiber.StatusNotFound, nil)
+
+	var storeOrders []models.Order
+	h.Request("GET", "/api/v1/orders/store/"+id(store.ID), ada, nil).Expect(fiber.StatusOK, &storeOrders)
+	if len(storeOrders) != 1 || storeOrders[0].ID != order.ID {
+		t.Errorf("store orders %+v", storeOrders)
+	}
+
+	h.Request("PUT", path+"/status", buyer, models.UpdateOrderStatusRequest{Status: "confirmed"}).Expect(fiber.StatusForbidden, nil)
+	h.Request("PUT", path+"/status", ada, models.UpdateOrderStatusRequest{Status: "confirmed"}).Expect(fiber.StatusOK, &order)
+	if order.Status != models.OrderStatusConfirmed {
+		t.Errorf("status %s", order.Status)
+	}
+
+	var history []models.OrderStatusHistory
+	h.Request("GET", path+"/history", buyer, nil).Expect(fiber.StatusOK, &history)
+	if len(history) != 2 || history[0].ToStatus != models.OrderStatusPending || history[1].ActorRole != models.OrderActorVendor {
+		t.Errorf("history %+v", history)
+	}
+}
+
+func TestExpireUnpaidOrders(t *testing.T) {
+	h := apitest.New(t)
+	provider := newFakeProvider("fake-expiry")
+	ada := h.Vendor("Ada")
+	buyer := h.Buyer("Chidi")
+	store := h.Store(ada, "adas")
+	sneakers := h.Product(store, "Sneakers", 100, 5)
+
+	place := func() models.Order {
+		var order models.Order
+		h.Request("POST", "/api/v1/orders", buyer, models.CreateOrderRequest{Items: []models.OrderItem{{ProductID: sneakers.ID, Quantity: 1}}}).
+			Expect(fiber.StatusCreated, &order)
+		return order
+	}
+	abandoned, paying := place(), place()
+	var payment models.Payment
+	h.Request("POST", "/api/v1/orders/"+id(paying.ID)+"/pay", buyer, models.InitializePaymentRequest{Provider: provider.name}).
+		Expect(fiber.StatusCreated, &payment)
+
+	// Both orders were placed long ago, but one has a payment going through
+	old := time.Now().Add(-2 * time.Hour)
+	if err := h.DB.Model(&models.Order{}).Where("id IN ?", []uint{abandoned.ID, paying.ID}).Update("created_at", old).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := inventory.ExpireUnpaid(h.DB, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	status := func(order models.Order) models.OrderStatus {
+		var current models.Order
+		if err := h.DB.First(&current, order.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return current.Status
+	}
+	if got := status(abandoned); got != models.OrderStatusCancelled {
+		t.Errorf("abandoned order %s, want cancelled", got)
+	}
+	if got := status(paying); got != models.OrderStatusPending {
+		t.Errorf("order being paid %s, want pending", got)
+	}
+
+	// A payment left pending as long no longer holds the order
+	if err := h.DB.Model(&payment).Update("created_at", old).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := inventory.ExpireUnpaid(h.DB, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(paying); got != models.OrderStatusCancelled {
+		t.Errorf("order with a stale payment %s, want cancelled", got)
+	}
+}
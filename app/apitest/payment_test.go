@@ -0,0 +1,158 @@
+package apitest_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/apitest"
+	"github.com/theHoracle/whatstore-api/app/escrow"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/orderflow"
+	"github.com/theHoracle/whatstore-api/app/payments"
+)
+
+// fakeProvider takes every payment it is asked to verify as paid in full
+type fakeProvider struct {
+	name    string
+	mu      sync.Mutex
+	amounts map[string]float64
+	refunds []string
+}
+
+func newFakeProvider(name string) *fakeProvider {
+	provider := &fakeProvider{name: name, amounts: map[string]float64{}}
+	payments.Register(provider)
+	return provider
+}
+
+func (p *fakeProvider) Name() string { return p.name }
+
+func (p *fakeProvider) InitializeTransaction(ctx context.Context, req payments.InitializeRequest) (*payments.Transaction, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.amounts[req.Reference] = req.Amount
+	return &payments.Transaction{Reference: req.Reference, AuthorizationURL: "https://pay.example.com/" + req.Reference}, nil
+}
+
+func (p *fakeProvider) VerifyTransaction(ctx context.Context, reference string) (*payments.Transaction, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return &payments.Transaction{Reference: reference, ProviderID: "tx-" + reference, Status: models.PaymentStatusSuccess, Amount: p.amounts[reference], Currency: "NGN"}, nil
+}
+
+func (p *fakeProvider) Refund(ctx context.Context, reference string, amount float64) (*payments.Refund, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refunds = append(p.refunds, reference)
+	return &payments.Refund{Reference: reference, Amount: amount, Status: "processed"}, nil
+}
+
+func (p *fakeProvider) ParseWebhook(header http.Header, body []byte) (*payments.WebhookEvent, error) {
+	return nil, payments.ErrInvalidSignature
+}
+
+func TestPayOrderOnce(t *testing.T) {
+	h := apitest.New(t)
+	first, second := newFakeProvider("fake-first"), newFakeProvider("fake-second")
+	ada := h.Vendor("Ada")
+	buyer := h.Buyer("Chidi")
+	sneakers := h.Product(h.Store(ada, "adas"), "Sneakers", 100, 5)
+
+	var order models.Order
+	h.Request("POST", "/api/v1/orders", buyer, models.CreateOrderRequest{Items: []models.OrderItem{{ProductID: sneakers.ID, Quantity: 1}}}).
+		Expect(fiber.StatusCreated, &order)
+	pay := "/api/v1/orders/" + id(order.ID) + "/pay"
+
+	// Paying again with the same provider carries on with the same payment
+	var started, again models.Payment
+	h.Request("POST", pay, buyer, models.InitializePaymentRequest{Provider: first.name}).Expect(fiber.StatusCreated, &started)
+	h.Request("POST", pay, buyer, models.InitializePaymentRequest{Provider: first.name}).Expect(fiber.StatusOK, &again)
+	if again.Reference != started.Reference || again.AuthorizationURL == "" {
+		t.Fatalf("payment %+v, then %+v", started, again)
+	}
+
+	// Switching provider cancels the first attempt
+	var switched models.Payment
+	h.Request("POST", pay, buyer, models.InitializePaymentRequest{Provider: second.name}).Expect(fiber.StatusCreated, &switched)
+	var cancelled models.Payment
+	if err := h.DB.First(&cancelled, started.ID).Error; err != nil || cancelled.Status != models.PaymentStatusCancelled {
+		t.Fatalf("first attempt %+v, %v", cancelled, err)
+	}
+
+	// The buyer pays through both links anyway, the second payment is refunded
+	ctx := context.Background()
+	paid, err := payments.Confirm(ctx, h.DB, first, started.Reference)
+	if err != nil || paid.Status != models.PaymentStatusSuccess {
+		t.Fatalf("first confirmation %+v, %v", paid, err)
+	}
+	duplicate, err := payments.Confirm(ctx, h.DB, second, switched.Reference)
+	if err != nil || duplicate.Status != models.PaymentStatusRefunded {
+		t.Fatalf("second confirmation %+v, %v", duplicate, err)
+	}
+	if len(second.refunds) != 1 || second.refunds[0] != switched.Reference || len(first.refunds) != 0 {
+		t.Errorf("refunds %v, %v", first.refunds, second.refunds)
+	}
+
+	if err := h.DB.First(&order, order.ID).Error; err != nil || order.PaymentID == nil || *order.PaymentID != started.Reference {
+		t.Errorf("order paid with %v, %v", order.PaymentID, err)
+	}
+	h.Request("POST", pay, buyer, models.InitializePaymentRequest{Provider: first.name}).Expect(fiber.StatusConflict, nil)
+}
+
+func TestRefundPartOfCheckout(t *testing.T) {
+	h := apitest.New(t)
+	useEscrow()
+	provider := newFakeProvider("fake-checkout")
+	ada, bola := h.Vendor("Ada"), h.Vendor("Bola")
+	buyer := h.Buyer("Chidi")
+	sneakers := h.Product(h.Store(ada, "adas"), "Sneakers", 100, 5)
+	hats := h.Product(h.Store(bola, "bolas"), "Hat", 20, 5)
+
+	var group models.CheckoutGroup
+	h.Request("POST", "/api/v1/checkouts", buyer, models.CreateCheckoutRequest{Items: []models.OrderItem{
+		{ProductID: sneakers.ID, Quantity: 1},
+		{ProductID: hats.ID, Quantity: 1},
+	}}).Expect(fiber.StatusCreated, &group)
+	if len(group.Orders) != 2 {
+		t.Fatalf("checkout %+v", group)
+	}
+	var payment models.Payment
+	h.Request("POST", "/api/v1/checkouts/"+id(group.ID)+"/pay", buyer, models.InitializePaymentRequest{Provider: provider.name}).
+		Expect(fiber.StatusCreated, &payment)
+	if _, err := payments.Confirm(context.Background(), h.DB, provider, payment.Reference); err != nil {
+		t.Fatal(err)
+	}
+
+	reject := func(order models.Order) {
+		if err := h.DB.First(&order, order.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := orderflow.Transition(h.DB, &order, models.OrderStatusRejected, models.OrderActorVendor, nil, "Out of stock"); err != nil {
+			t.Fatal(err)
+		}
+		escrow.ProcessRefunds(h.DB)
+	}
+	status := func() models.PaymentStatus {
+		var current models.Payment
+		if err := h.DB.First(&current, payment.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return current.Status
+	}
+
+	// Refunding one store's order leaves the other's share with its store
+	reject(group.Orders[0])
+	if got := status(); got != models.PaymentStatusPartiallyRefunded {
+		t.Errorf("payment after one refund %s, want partially refunded", got)
+	}
+	reject(group.Orders[1])
+	if got := status(); got != models.PaymentStatusRefunded {
+		t.Errorf("payment after both refunds %s, want refunded", got)
+	}
+	if len(provider.refunds) != 2 {
+		t.Errorf("provider refunds %v", provider.refunds)
+	}
+}
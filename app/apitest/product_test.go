@@ -0,0 +1,61 @@
+package apitest_test
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/apitest"
+	"github.com/theHoracle/whatstore-api/app/models"
+)
+
+func TestProductCRUD(t *testing.T) {
+	h := apitest.New(t)
+	ada := h.Vendor("Ada")
+	bola := h.Vendor("Bola")
+	buyer := h.Buyer("Chidi")
+	store := h.Store(ada, "adas")
+	products := "/api/v1/stores/" + id(store.ID) + "/products"
+
+	input := models.CreateProductRequest{Name: "Sneakers", Description: "White", Price: 150, Stock: 4}
+	h.Request("POST", products, buyer, input).Expect(fiber.StatusForbidden, nil)
+	h.Request("POST", products, bola, input).Expect(fiber.StatusForbidden, nil)
+
+	var product models.Product
+	h.Request("POST", products, ada, input).Expect(fiber.StatusCreated, &product)
+	if product.ID == 0 || product.StoreID != store.ID || product.Stock != 4 {
+		t.Fatalf("created %+v", product)
+	}
+
+	var got models.Product
+	h.Request("GET", "/api/v1/products/"+id(product.ID), nil, nil).Expect(fiber.StatusOK, &got)
+	if got.Name != "Sneakers" || got.Price != 150 {
+		t.Fatalf("product %+v", got)
+	}
+
+	stock := 10
+	h.Request("PUT", products+"/"+id(product.ID), ada, models.UpdateProductRequest{Price: 175, Stock: &stock}).
+		Expect(fiber.StatusOK, &got)
+	if got.Price != 175 || got.Stock != 10 || got.Name != "Sneakers" {
+		t.Fatalf("updated %+v", got)
+	}
+
+	// Stock changes are kept in the store's inventory ledger
+	var ledger struct {
+		Data []models.InventoryLedgerEntry `json:"data"`
+	}
+	h.Request("GET", "/api/v1/stores/"+id(store.ID)+"/inventory", ada, nil).Expect(fiber.StatusOK, &ledger)
+	if len(ledger.Data) != 2 {
+		t.Errorf("ledger %+v", ledger.Data)
+	}
+
+	missing := uint(999999)
+	msg := h.Request("POST", products, ada, models.CreateProductRequest{Name: "Boots", Price: 1, CategoryID: &missing}).
+		Expect(fiber.StatusBadRequest, nil).Error()
+	if msg != "Category not found" {
+		t.Errorf("missing category: %q", msg)
+	}
+
+	h.Request("DELETE", products+"/"+id(product.ID), bola, nil).Expect(fiber.StatusForbidden, nil)
+	h.Request("DELETE", products+"/"+id(product.ID), ada, nil).Expect(fiber.StatusOK, nil)
+	h.Request("GET", "/api/v1/products/"+id(product.ID), nil, nil).Expect(fiber.StatusNotFound, nil)
+}
@@ -0,0 +1,68 @@
+package apitest_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/apitest"
+	"github.com/theHoracle/whatstore-api/app/models"
+)
+
+func id(n uint) string {
+	return strconv.FormatUint(uint64(n), 10)
+}
+
+func TestStoreCRUD(t *testing.T) {
+	h := apitest.New(t)
+	ada := h.Vendor("Ada")
+	bola := h.Vendor("Bola")
+	store := h.Store(ada, "adas")
+	h.Product(store, "Sneakers", 100, 3)
+	path := "/api/v1/stores/" + id(store.ID)
+
+	var got models.Store
+	h.Request("GET", path, ada, nil).Expect(fiber.StatusOK, &got)
+	if got.Name != "adas" || len(got.Products) != 1 {
+		t.Fatalf("store %+v", got)
+	}
+
+	update := models.UpdateStoreRequest{
+		Name:                 "Ada's Shoes",
+		StoreUrl:             "ada-shoes",
+		StoreAddress:         "2 Broad Street, Lagos",
+		StoreWhatsappContact: "+2348012345678",
+	}
+	h.Request("PUT", path, bola, update).Expect(fiber.StatusForbidden, nil)
+	h.Request("PUT", path, ada, update).Expect(fiber.StatusOK, &got)
+	if got.Name != "Ada's Shoes" || got.StoreUrl != "ada-shoes" {
+		t.Fatalf("updated %+v", got)
+	}
+
+	// The old URL redirects to the store and is not free for others
+	var check struct {
+		Available bool `json:"available"`
+	}
+	h.Request("GET", "/api/v1/stores/check-url?url=adas", bola, nil).Expect(fiber.StatusOK, &check)
+	if check.Available {
+		t.Error("previous store URL is available")
+	}
+	var redirect struct {
+		StoreURL string `json:"store_url"`
+	}
+	h.Request("GET", "/api/v1/storefronts/adas", nil, nil).Expect(fiber.StatusMovedPermanently, &redirect)
+	if redirect.StoreURL != "ada-shoes" {
+		t.Errorf("redirect to %q", redirect.StoreURL)
+	}
+
+	h.Request("DELETE", path, bola, nil).Expect(fiber.StatusForbidden, nil)
+	h.Request("DELETE", path, ada, nil).Expect(fiber.StatusNoContent, nil)
+	h.Request("GET", path, ada, nil).Expect(fiber.StatusNotFound, nil)
+
+	// Closing their only store leaves the vendor inactive
+	var vendor models.Vendor
+	h.Request("GET", "/api/v1/vendors/"+id(ada.Vendor.ID), ada, nil).Expect(fiber.StatusOK, &vendor)
+	if vendor.IsActive {
+		t.Error("vendor is still active")
+	}
+}
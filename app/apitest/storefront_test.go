@@ -0,0 +1,83 @@
+package apitest_test
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/apitest"
+	"github.com/theHoracle/whatstore-api/app/controllers"
+	"github.com/theHoracle/whatstore-api/app/models"
+)
+
+func TestPersonalizedReads(t *testing.T) {
+	h := apitest.New(t)
+	ada := h.Vendor("Ada")
+	buyer := h.Buyer("Chidi")
+	store := h.Store(ada, "adas")
+	sneakers := h.Product(store, "Sneakers", 100, 5)
+	socks := h.Product(store, "Socks", 10, 5)
+	product := "/api/v1/products/" + id(sneakers.ID)
+
+	var anonymous controllers.Storefront
+	h.Request("GET", "/api/v1/storefronts/adas", nil, nil).Expect(fiber.StatusOK, &anonymous)
+	if anonymous.Viewer != nil {
+		t.Errorf("anonymous storefront viewer %+v", anonymous.Viewer)
+	}
+
+	// Sending a bad token is an error, not a way to read anonymously
+	h.RequestWithToken("GET", "/api/v1/storefronts/adas", "not-a-token", nil).Expect(fiber.StatusUnauthorized, nil)
+	h.RequestWithToken("GET", product, "not-a-token", nil).Expect(fiber.StatusUnauthorized, nil)
+
+	var owner controllers.ProductResponse
+	h.Request("GET", product, ada, nil).Expect(fiber.StatusOK, &owner)
+	if owner.Viewer == nil || owner.Viewer.StoreRole != models.StoreRoleOwner || !owner.Viewer.CanEdit {
+		t.Errorf("owner product viewer %+v", owner.Viewer)
+	}
+
+	var order models.Order
+	h.Request("POST", "/api/v1/orders", buyer, models.CreateOrderRequest{Items: []models.OrderItem{{ProductID: sneakers.ID, Quantity: 1}}}).
+		Expect(fiber.StatusCreated, &order)
+	h.Request("PUT", "/api/v1/orders/"+id(order.ID)+"/status", ada, models.UpdateOrderStatusRequest{Status: "confirmed"}).
+		Expect(fiber.StatusOK, nil)
+
+	var bought controllers.ProductResponse
+	h.Request("GET", product, buyer, nil).Expect(fiber.StatusOK, &bought)
+	if bought.Viewer == nil || !bought.Viewer.Purchased || bought.Viewer.CanEdit {
+		t.Errorf("buyer product viewer %+v", bought.Viewer)
+	}
+
+	// Listings and searches relate each product to the user too
+	var listed struct {
+		Data []controllers.ProductResponse `json:"data"`
+	}
+	h.Request("GET", "/api/v1/products", buyer, nil).Expect(fiber.StatusOK, &listed)
+	if len(listed.Data) != 2 {
+		t.Fatalf("listed products %+v", listed.Data)
+	}
+	for _, p := range listed.Data {
+		if p.Viewer == nil || p.Viewer.Purchased != (p.ID == sneakers.ID) {
+			t.Errorf("buyer viewer of listed %s %+v", p.Name, p.Viewer)
+		}
+	}
+	var found struct {
+		Data []controllers.ProductHit `json:"data"`
+	}
+	h.Request("GET", "/api/v1/products/search?q=sneakers", ada, nil).Expect(fiber.StatusOK, &found)
+	if len(found.Data) != 1 || found.Data[0].Viewer == nil || !found.Data[0].Viewer.CanEdit {
+		t.Errorf("owner search hits %+v", found.Data)
+	}
+	var anonymousFound struct {
+		Data []controllers.ProductHit `json:"data"`
+	}
+	h.Request("GET", "/api/v1/products/search?q=sneakers", nil, nil).Expect(fiber.StatusOK, &anonymousFound)
+	if len(anonymousFound.Data) != 1 || anonymousFound.Data[0].Viewer != nil {
+		t.Errorf("anonymous search hits %+v", anonymousFound.Data)
+	}
+
+	var storefront controllers.Storefront
+	h.Request("GET", "/api/v1/storefronts/adas", buyer, nil).Expect(fiber.StatusOK, &storefront)
+	viewer := storefront.Viewer
+	if viewer == nil || viewer.Orders != 1 || len(viewer.PurchasedProductIDs) != 1 || viewer.PurchasedProductIDs[0] != sneakers.ID {
+		t.Errorf("buyer storefront viewer %+v, socks %d", viewer, socks.ID)
+	}
+}
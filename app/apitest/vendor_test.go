@@ -0,0 +1,89 @@
+package apitest_test
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/apitest"
+	"github.com/theHoracle/whatstore-api/app/models"
+)
+
+func TestVendorOnboarding(t *testing.T) {
+	h := apitest.New(t)
+	ada := h.Buyer("Ada")
+	store := models.CreateStoreRequest{
+		StoreName:            "Ada's Shoes",
+		StoreUrl:             "Ada-Shoes",
+		StoreAddress:         "1 Broad Street, Lagos",
+		StoreWhatsappContact: "+2348012345678",
+	}
+
+	h.Request("POST", "/api/v1/vendors", nil, nil).Expect(fiber.StatusUnauthorized, nil)
+	// Buyers have to become vendors before opening a store
+	h.Request("POST", "/api/v1/stores", ada, store).Expect(fiber.StatusForbidden, nil)
+
+	var vendor models.Vendor
+	h.Request("POST", "/api/v1/vendors", ada, nil).Expect(fiber.StatusCreated, &vendor)
+	if vendor.UserID != ada.ID || !vendor.IsActive {
+		t.Fatalf("vendor %+v", vendor)
+	}
+
+	var me models.User
+	h.Request("GET", "/api/v1/users/me", ada, nil).Expect(fiber.StatusOK, &me)
+	if me.Role != models.RoleVendor || me.Vendor == nil || me.Vendor.ID != vendor.ID {
+		t.Fatalf("profile after onboarding %+v", me)
+	}
+
+	var created models.Store
+	h.Request("POST", "/api/v1/stores", ada, store).Expect(fiber.StatusCreated, &created)
+	if created.VendorID != vendor.ID || created.StoreUrl != "ada-shoes" || created.Timezone != "Africa/Lagos" {
+		t.Fatalf("store %+v", created)
+	}
+
+	// The vendor owns the store they opened
+	var memberships []models.StoreMember
+	h.Request("GET", "/api/v1/users/me/memberships", ada, nil).Expect(fiber.StatusOK, &memberships)
+	if len(memberships) != 1 || memberships[0].StoreID != created.ID || memberships[0].Role != models.StoreRoleOwner {
+		t.Fatalf("memberships %+v", memberships)
+	}
+
+	var stores []models.Store
+	h.Request("GET", "/api/v1/stores/vendor/"+id(vendor.ID), nil, nil).Expect(fiber.StatusUnauthorized, nil)
+	h.Request("GET", "/api/v1/stores/vendor/"+id(vendor.ID), ada, nil).Expect(fiber.StatusOK, &stores)
+	if len(stores) != 1 {
+		t.Errorf("vendor stores %+v", stores)
+	}
+}
+
+func TestVendorOnboardingRejectsInvalidTokens(t *testing.T) {
+	h := apitest.New(t)
+
+	res := h.Request("GET", "/api/v1/users/me", nil, nil).Expect(fiber.StatusUnauthorized, nil)
+	if res.Error() != "Missing Authorization header" {
+		t.Errorf("no token: %q", res.Error())
+	}
+
+	// Signed in as someone who never reached the database
+	ghost := &models.User{ClerkID: "user_ghost"}
+	if msg := h.Request("GET", "/api/v1/users/me", ghost, nil).Expect(fiber.StatusUnauthorized, nil).Error(); msg != "User not found in database" {
+		t.Errorf("unknown user: %q", msg)
+	}
+}
+
+func TestUpdateVendorKeepsItsRow(t *testing.T) {
+	h := apitest.New(t)
+	ada, bola := h.Vendor("Ada"), h.Vendor("Bola")
+
+	// Ids in the body do not point the update at someone else's vendor
+	var updated models.Vendor
+	body := map[string]any{"id": bola.Vendor.ID, "user_id": bola.ID, "is_active": false}
+	h.Request("PUT", "/api/v1/vendors/"+id(ada.Vendor.ID), ada, body).Expect(fiber.StatusOK, &updated)
+	if updated.ID != ada.Vendor.ID || updated.UserID != ada.ID || updated.IsActive {
+		t.Errorf("updated vendor %+v", updated)
+	}
+
+	var untouched models.Vendor
+	if err := h.DB.First(&untouched, bola.Vendor.ID).Error; err != nil || untouched.UserID != bola.ID || !untouched.IsActive {
+		t.Errorf("bola's vendor %+v, %v", untouched, err)
+	}
+}
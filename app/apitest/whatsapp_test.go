@@ -0,0 +1,56 @@
+package apitest_test
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/apitest"
+	"github.com/theHoracle/whatstore-api/app/handlers"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/whatsapp"
+)
+
+func TestWhatsappCommandsApplyOnce(t *testing.T) {
+	h := apitest.New(t)
+	client := whatsapp.NewClient("token", "phone", "secret", "")
+	h.App.Post("/webhooks/whatsapp", handlers.WhatsappWebhookHandler(h.DB, client))
+	ada := h.Vendor("Ada")
+	buyer := h.Buyer("Chidi")
+	store := h.Store(ada, "adas")
+	sneakers := h.Product(store, "Sneakers", 100, 5)
+
+	var order models.Order
+	h.Request("POST", "/api/v1/orders", buyer, models.CreateOrderRequest{Items: []models.OrderItem{{ProductID: sneakers.ID, Quantity: 1}}}).
+		Expect(fiber.StatusCreated, &order)
+
+	// The store confirms the order from its WhatsApp number, and Meta
+	// delivers the webhook twice
+	from := whatsapp.PhoneDigits(store.StoreWhatsappContact)
+	body := []byte(fmt.Sprintf(`{"entry":[{"changes":[{"field":"messages","value":{"messages":[
+		{"id":"wamid.confirm","from":%q,"type":"text","text":{"body":"CONFIRM %d"}}]}}]}]}`, from, order.ID))
+	mac := hmac.New(sha256.New, []byte(client.AppSecret))
+	mac.Write(body)
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/webhooks/whatsapp", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		resp, err := h.App.Test(req, -1)
+		if err != nil || resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("delivery %d: %v, %v", i+1, resp, err)
+		}
+	}
+
+	if err := h.DB.First(&order, order.ID).Error; err != nil || order.Status != models.OrderStatusConfirmed {
+		t.Fatalf("order %+v, %v", order, err)
+	}
+	var replies int64
+	if err := h.DB.Model(&models.WhatsappMessage{}).Where(`"to" = ?`, from).Count(&replies).Error; err != nil || replies != 1 {
+		t.Errorf("%d replies, want 1: %v", replies, err)
+	}
+}
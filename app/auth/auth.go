@@ -0,0 +1,45 @@
+// Package auth checks the bearer tokens requests are signed in with. Tokens
+// can come from Clerk, from any OpenID Connect provider that publishes its
+// keys as a JWKS, or be issued locally with a shared secret or RSA key so the
+// API runs without an identity provider.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed, badly signed,
+// expired or meant for someone else. Other errors mean the token could not
+// be checked, such as when the provider's keys could not be fetched.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Leeway is how far clocks may drift between the token issuer and the API
+const Leeway = time.Minute
+
+// Identity is who a token was issued to. Subject is the users' clerk_id.
+// Email and Name are set when the token carries them, as OIDC ID tokens do,
+// so users can be created the first time they sign in.
+type Identity struct {
+	Subject string
+	Email   string
+	Name    string
+}
+
+// Authenticator checks a bearer token and returns who it was issued to
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*Identity, error)
+}
+
+// AuthenticatorFunc lets an ordinary function be used as an Authenticator
+type AuthenticatorFunc func(ctx context.Context, token string) (*Identity, error)
+
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	return f(ctx, token)
+}
+
+func invalidf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidToken, fmt.Sprintf(format, args...))
+}
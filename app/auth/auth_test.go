@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+)
+
+var secret = []byte("0123456789abcdef0123456789abcdef")
+
+func claimsFor(subject, issuer string, ttl time.Duration) jwt.Claims {
+	now := time.Now()
+	return jwt.Claims{Subject: subject, Issuer: issuer, IssuedAt: jwt.NewNumericDate(now), Expiry: jwt.NewNumericDate(now.Add(ttl))}
+}
+
+func mustSign(t *testing.T, algorithm jose.SignatureAlgorithm, kid string, key any, claims jwt.Claims, extra ...any) string {
+	t.Helper()
+	token, err := sign(algorithm, kid, key, claims, extra...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func expectInvalid(t *testing.T, a Authenticator, token, why string) {
+	t.Helper()
+	if identity, err := a.Authenticate(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("%s: got %+v, %v, want ErrInvalidToken", why, identity, err)
+	}
+}
+
+func TestLocalHS256(t *testing.T) {
+	local, err := NewLocalHS256("", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := local.Issue("user_ada", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity, err := local.Authenticate(context.Background(), token)
+	if err != nil || identity.Subject != "user_ada" {
+		t.Fatalf("got %+v, %v", identity, err)
+	}
+
+	parts := strings.Split(token, ".")
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"user_admin","iss":"whatstore-local","exp":4102444800}`))
+	expectInvalid(t, local, parts[0]+"."+forged+"."+parts[2], "changed claims")
+	expectInvalid(t, local, parts[0]+"."+parts[1]+".", "no signature")
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	expectInvalid(t, local, unsigned+"."+parts[1]+".", "alg none")
+	expectInvalid(t, local, "not-a-token", "malformed")
+
+	expectInvalid(t, local, mustSign(t, jose.HS256, "", []byte("another secret that is long enough"), claimsFor("user_ada", DefaultLocalIssuer, time.Hour)), "other secret")
+	expectInvalid(t, local, mustSign(t, jose.HS256, "", secret, claimsFor("user_ada", DefaultLocalIssuer, -2*Leeway)), "expired")
+	expectInvalid(t, local, mustSign(t, jose.HS256, "", secret, claimsFor("user_ada", "someone-else", time.Hour)), "other issuer")
+	expectInvalid(t, local, mustSign(t, jose.HS256, "", secret, claimsFor("", DefaultLocalIssuer, time.Hour)), "no subject")
+	expectInvalid(t, local, mustSign(t, jose.HS256, "", secret, jwt.Claims{Subject: "user_ada", Issuer: DefaultLocalIssuer}), "no expiry")
+
+	// Within the leeway for clock drift
+	if _, err := local.Authenticate(context.Background(), mustSign(t, jose.HS256, "", secret, claimsFor("user_ada", DefaultLocalIssuer, -Leeway/2))); err != nil {
+		t.Errorf("token just past expiry: %v", err)
+	}
+
+	if _, err := NewLocalHS256("", []byte("short")); err == nil {
+		t.Error("short secret accepted")
+	}
+}
+
+func TestLocalRS256(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewLocalRS256("dev", nil, private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker, err := NewLocalRS256("dev", &private.PublicKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := signer.Issue("user_ada", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity, err := checker.Authenticate(context.Background(), token); err != nil || identity.Subject != "user_ada" {
+		t.Fatalf("got %+v, %v", identity, err)
+	}
+	if _, err := checker.Issue("user_ada", time.Hour); err == nil {
+		t.Error("issued a token without a private key")
+	}
+
+	// An HS256 token keyed with the public key must not pass as RS256
+	publicBytes := private.PublicKey.N.Bytes()
+	expectInvalid(t, checker, mustSign(t, jose.HS256, "", publicBytes, claimsFor("user_ada", "dev", time.Hour)), "algorithm confusion")
+}
+
+// provider is an OIDC provider whose signing keys can be rotated
+type provider struct {
+	*httptest.Server
+	mu      sync.Mutex
+	keys    map[string]*rsa.PrivateKey
+	fetches int
+}
+
+func newProvider(t *testing.T) *provider {
+	p := &provider{keys: map[string]*rsa.PrivateKey{}}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{"issuer": p.URL, "jwks_uri": p.URL + "/jwks"})
+		case "/jwks":
+			p.fetches++
+			var set jose.JSONWebKeySet
+			for kid, key := range p.keys {
+				set.Keys = append(set.Keys, jose.JSONWebKey{Key: &key.PublicKey, KeyID: kid, Use: "sig", Algorithm: string(jose.RS256)})
+			}
+			w.Header().Set("Cache-Control", "public, max-age=3600")
+			json.NewEncoder(w).Encode(set)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *provider) rotate(t *testing.T, kid string) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys = map[string]*rsa.PrivateKey{kid: key}
+	return key
+}
+
+func TestOIDC(t *testing.T) {
+	p := newProvider(t)
+	first := p.rotate(t, "first")
+
+	if _, err := NewOIDC(context.Background(), p.URL, "", ""); err == nil {
+		t.Error("OIDC set up without an audience")
+	}
+	oidc, err := NewOIDC(context.Background(), p.URL, "whatstore", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	oidc.keys.now = func() time.Time { return now }
+
+	claims := claimsFor("user_ada", p.URL, time.Hour)
+	claims.Audience = jwt.Audience{"whatstore", "other"}
+	if identity, err := oidc.Authenticate(context.Background(), mustSign(t, jose.RS256, "first", first, claims)); err != nil || identity.Subject != "user_ada" {
+		t.Fatalf("got %+v, %v", identity, err)
+	}
+	if _, err := oidc.Authenticate(context.Background(), mustSign(t, jose.RS256, "first", first, claims)); err != nil {
+		t.Fatal(err)
+	}
+	if p.fetches != 1 {
+		t.Errorf("keys fetched %d times, want 1", p.fetches)
+	}
+
+	// The profile claims come along, but not an address the provider has
+	// not verified
+	identity, err := oidc.Authenticate(context.Background(), mustSign(t, jose.RS256, "first", first, claims,
+		map[string]any{"email": "ada@example.com", "email_verified": true, "name": "Ada Obi"}))
+	if err != nil || *identity != (Identity{Subject: "user_ada", Email: "ada@example.com", Name: "Ada Obi"}) {
+		t.Errorf("identity %+v, %v", identity, err)
+	}
+	identity, err = oidc.Authenticate(context.Background(), mustSign(t, jose.RS256, "first", first, claims,
+		map[string]any{"email": "ada@example.com", "email_verified": false}))
+	if err != nil || identity.Email != "" {
+		t.Errorf("identity with an unverified email %+v, %v", identity, err)
+	}
+
+	wrongAudience := claims
+	wrongAudience.Audience = jwt.Audience{"other"}
+	expectInvalid(t, oidc, mustSign(t, jose.RS256, "first", first, wrongAudience), "other audience")
+	noAudience := claims
+	noAudience.Audience = nil
+	expectInvalid(t, oidc, mustSign(t, jose.RS256, "first", first, noAudience), "no audience")
+	expectInvalid(t, oidc, mustSign(t, jose.HS256, "first", secret, claims), "HS256")
+
+	// Tokens signed with a rotated key fetch the new keys
+	now = now.Add(2 * minRefetch)
+	second := p.rotate(t, "second")
+	if _, err := oidc.Authenticate(context.Background(), mustSign(t, jose.RS256, "second", second, claims)); err != nil {
+		t.Fatalf("rotated key: %v", err)
+	}
+	if p.fetches != 2 {
+		t.Errorf("keys fetched %d times, want 2", p.fetches)
+	}
+	expectInvalid(t, oidc, mustSign(t, jose.RS256, "first", first, claims), "retired key")
+
+	// Unknown keys do not fetch the keys again on every request
+	for i := 0; i < 3; i++ {
+		expectInvalid(t, oidc, mustSign(t, jose.RS256, "unknown", second, claims), "unknown key")
+	}
+	if p.fetches != 2 {
+		t.Errorf("keys fetched %d times, want 2", p.fetches)
+	}
+}
+
+func TestKeyCacheKeepsKeysWhileProviderIsDown(t *testing.T) {
+	down := false
+	cache := newKeyCache(func(context.Context) (map[string]string, time.Duration, error) {
+		if down {
+			return nil, 0, errors.New("provider is down")
+		}
+		return map[string]string{"a": "key a"}, time.Minute, nil
+	})
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	if key, err := cache.get(context.Background(), "a"); err != nil || key != "key a" {
+		t.Fatalf("got %q, %v", key, err)
+	}
+
+	down = true
+	now = now.Add(time.Hour)
+	if key, err := cache.get(context.Background(), "a"); err != nil || key != "key a" {
+		t.Errorf("expired key while down: %q, %v", key, err)
+	}
+	if _, err := cache.get(context.Background(), "b"); err == nil || errors.Is(err, ErrInvalidToken) {
+		t.Errorf("unknown key while down: %v, want a fetch error", err)
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	for header, want := range map[string]time.Duration{
+		"public, max-age=600, must-revalidate": 10 * time.Minute,
+		"no-cache":                             0,
+		"max-age=oops":                         0,
+		"":                                     0,
+	} {
+		if got := maxAge(header); got != want {
+			t.Errorf("maxAge(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
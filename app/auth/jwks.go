@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+)
+
+const (
+	// DefaultKeyTTL is how long fetched keys are used when the provider does
+	// not say how long they may be cached
+	DefaultKeyTTL = time.Hour
+	// minRefetch keeps tokens signed with unknown keys from making the API
+	// fetch keys on every request
+	minRefetch = time.Minute
+)
+
+// keyCache holds a provider's signing keys by key ID. Keys are fetched again
+// when they expire, or when a token is signed with a key the cache does not
+// know, which is how rotated keys are picked up.
+type keyCache[K any] struct {
+	fetch func(ctx context.Context) (map[string]K, time.Duration, error)
+	now   func() time.Time
+
+	mu      sync.Mutex
+	keys    map[string]K
+	fetched time.Time
+	expires time.Time
+}
+
+func newKeyCache[K any](fetch func(ctx context.Context) (map[string]K, time.Duration, error)) *keyCache[K] {
+	return &keyCache[K]{fetch: fetch, now: time.Now}
+}
+
+// get returns the key with ID kid
+func (c *keyCache[K]) get(ctx context.Context, kid string) (K, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var none K
+	now := c.now()
+	key, known := c.keys[kid]
+	if c.keys != nil && now.Before(c.expires) {
+		if known {
+			return key, nil
+		}
+		if now.Sub(c.fetched) < minRefetch {
+			return none, invalidf("unknown signing key %q", kid)
+		}
+	}
+
+	keys, ttl, err := c.fetch(ctx)
+	if err != nil {
+		// Keep signing users in with the keys we have while the provider is down
+		if known {
+			return key, nil
+		}
+		return none, fmt.Errorf("fetching signing keys: %w", err)
+	}
+	if ttl <= 0 {
+		ttl = DefaultKeyTTL
+	}
+	c.keys, c.fetched, c.expires = keys, now, now.Add(ttl)
+
+	key, known = keys[kid]
+	if !known {
+		return none, invalidf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// fetchJWKS gets the signing keys published at url, and how long they may be
+// cached. Keys the API cannot use are left out.
+func fetchJWKS(ctx context.Context, client *http.Client, url string) (map[string]jose.JSONWebKey, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	// Keys are decoded one by one so one of a type go-jose does not know
+	// leaves the others usable
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, 0, fmt.Errorf("decoding %s: %w", url, err)
+	}
+
+	keys := map[string]jose.JSONWebKey{}
+	for _, raw := range set.Keys {
+		var key jose.JSONWebKey
+		if err := key.UnmarshalJSON(raw); err != nil {
+			continue
+		}
+		if key.Use != "" && key.Use != "sig" || !key.IsPublic() || !key.Valid() {
+			continue
+		}
+		keys[key.KeyID] = key
+	}
+	return keys, maxAge(resp.Header.Get("Cache-Control")), nil
+}
+
+// maxAge reads max-age from a Cache-Control header
+func maxAge(cacheControl string) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if strings.EqualFold(name, "max-age") {
+			if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+				return time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return 0
+}
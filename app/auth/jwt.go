@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+)
+
+// token is a JWT, not yet verified. Each authenticator accepts only the
+// algorithms of its keys, so a token cannot pick a weaker one.
+type token struct {
+	jwt    *jwt.JSONWebToken
+	header jose.Header
+}
+
+func parse(raw string) (*token, error) {
+	parsed, err := jwt.ParseSigned(raw)
+	if err != nil {
+		return nil, invalidf("malformed token")
+	}
+	if len(parsed.Headers) != 1 {
+		return nil, invalidf("token has %d signatures", len(parsed.Headers))
+	}
+	return &token{jwt: parsed, header: parsed.Headers[0]}, nil
+}
+
+// algorithm is the algorithm the token says it was signed with
+func (t *token) algorithm() jose.SignatureAlgorithm {
+	return jose.SignatureAlgorithm(t.header.Algorithm)
+}
+
+// verify checks the token was signed with key and returns its claims once
+// they are current, from issuer and, when audience is set, meant for it. Keys
+// are a []byte HMAC secret, an *rsa.PublicKey, an *ecdsa.PublicKey or a
+// jose.JSONWebKey holding one. Other claims are decoded into extra.
+func (t *token) verify(key any, issuer, audience string, extra ...any) (*jwt.Claims, error) {
+	var claims jwt.Claims
+	if err := t.jwt.Claims(key, append([]any{&claims}, extra...)...); err != nil {
+		return nil, invalidf("bad signature")
+	}
+	if claims.Expiry == nil {
+		return nil, invalidf("token does not expire")
+	}
+	expected := jwt.Expected{Issuer: issuer, Time: time.Now()}
+	if audience != "" {
+		expected.Audience = jwt.Audience{audience}
+	}
+	if err := claims.ValidateWithLeeway(expected, Leeway); err != nil {
+		return nil, invalidf("%v", err)
+	}
+	if claims.Subject == "" {
+		return nil, invalidf("token has no subject")
+	}
+	return &claims, nil
+}
+
+// sign encodes claims, and any extra claims, as a JWT signed with key using
+// algorithm, naming keyID in its header when set
+func sign(algorithm jose.SignatureAlgorithm, keyID string, key any, claims jwt.Claims, extra ...any) (string, error) {
+	options := (&jose.SignerOptions{}).WithType("JWT")
+	if keyID != "" {
+		options = options.WithHeader(jose.HeaderKey("kid"), keyID)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: algorithm, Key: key}, options)
+	if err != nil {
+		return "", err
+	}
+	builder := jwt.Signed(signer).Claims(claims)
+	for _, e := range extra {
+		builder = builder.Claims(e)
+	}
+	return builder.CompactSerialize()
+}
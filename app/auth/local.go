@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+)
+
+// DefaultLocalIssuer is the issuer of locally issued tokens when none is set
+const DefaultLocalIssuer = "whatstore-local"
+
+// Local issues and checks its own tokens, signed with a shared HS256 secret
+// or an RS256 key pair. It lets the API run without an identity provider,
+// for development and for services signing their own requests.
+type Local struct {
+	Issuer    string
+	algorithm jose.SignatureAlgorithm
+	verifyKey any // []byte or *rsa.PublicKey
+	signKey   any // []byte or *rsa.PrivateKey, nil when only checking tokens
+}
+
+// NewLocalHS256 creates a local authenticator that signs and checks tokens
+// with secret
+func NewLocalHS256(issuer string, secret []byte) (*Local, error) {
+	if len(secret) < 32 {
+		return nil, errors.New("local auth secret must be at least 32 bytes")
+	}
+	return &Local{Issuer: localIssuer(issuer), algorithm: jose.HS256, verifyKey: secret, signKey: secret}, nil
+}
+
+// NewLocalRS256 creates a local authenticator that checks tokens with public
+// and signs them with private. Either may be nil: without private it only
+// checks tokens, and without public it checks them with private's public key.
+func NewLocalRS256(issuer string, public *rsa.PublicKey, private *rsa.PrivateKey) (*Local, error) {
+	if public == nil {
+		if private == nil {
+			return nil, errors.New("local auth needs an RSA public or private key")
+		}
+		public = &private.PublicKey
+	}
+	l := &Local{Issuer: localIssuer(issuer), algorithm: jose.RS256, verifyKey: public}
+	if private != nil {
+		l.signKey = private
+	}
+	return l, nil
+}
+
+func localIssuer(issuer string) string {
+	if issuer == "" {
+		return DefaultLocalIssuer
+	}
+	return issuer
+}
+
+func (l *Local) Authenticate(_ context.Context, raw string) (*Identity, error) {
+	t, err := parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if t.algorithm() != l.algorithm {
+		return nil, invalidf("unsupported algorithm %q", t.header.Algorithm)
+	}
+	claims, err := t.verify(l.verifyKey, l.Issuer, "")
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{Subject: claims.Subject}, nil
+}
+
+// Issue signs a token for subject that expires after ttl
+func (l *Local) Issue(subject string, ttl time.Duration) (string, error) {
+	if l.signKey == nil {
+		return "", errors.New("local auth has no private key to sign tokens with")
+	}
+	now := time.Now()
+	return sign(l.algorithm, "", l.signKey, jwt.Claims{
+		Subject:  subject,
+		Issuer:   l.Issuer,
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(now.Add(ttl)),
+	})
+}
+
+// ParseRSAPublicKey reads a PEM encoded PKIX or PKCS #1 RSA public key
+func ParseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	public, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%T is not an RSA public key", key)
+	}
+	return public, nil
+}
+
+// ParseRSAPrivateKey reads a PEM encoded PKCS #8 or PKCS #1 RSA private key
+func ParseRSAPrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%T is not an RSA private key", key)
+	}
+	return private, nil
+}
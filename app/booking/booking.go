@@ -0,0 +1,202 @@
+package booking
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidTransition = errors.New("invalid booking status transition")
+	ErrActorNotAllowed   = errors.New("not allowed to perform this status change")
+	ErrNotStarted        = errors.New("booking has not started yet")
+	ErrSlotUnavailable   = errors.New("the service is not available at this time")
+	ErrSlotTaken         = errors.New("this slot has already been booked")
+)
+
+// activeStatuses hold their slot, no other booking may overlap them
+var activeStatuses = []models.BookingStatus{models.BookingStatusRequested, models.BookingStatusConfirmed}
+
+// transitions lists, for every status, the statuses it can move to and
+// which actors are allowed to make that move
+var transitions = map[models.BookingStatus]map[models.BookingStatus][]models.OrderActor{
+	models.BookingStatusRequested: {
+		models.BookingStatusConfirmed: {models.OrderActorVendor, models.OrderActorAdmin},
+		models.BookingStatusCancelled: {models.OrderActorBuyer, models.OrderActorVendor, models.OrderActorAdmin, models.OrderActorSystem},
+	},
+	models.BookingStatusConfirmed: {
+		models.BookingStatusCompleted: {models.OrderActorVendor, models.OrderActorAdmin},
+		models.BookingStatusNoShow:    {models.OrderActorVendor, models.OrderActorAdmin},
+		models.BookingStatusCancelled: {models.OrderActorBuyer, models.OrderActorVendor, models.OrderActorAdmin},
+	},
+}
+
+// IsValidStatus reports whether status is part of the booking lifecycle
+func IsValidStatus(status models.BookingStatus) bool {
+	switch status {
+	case models.BookingStatusRequested, models.BookingStatusConfirmed, models.BookingStatusCompleted,
+		models.BookingStatusCancelled, models.BookingStatusNoShow:
+		return true
+	}
+	return false
+}
+
+// CanTransition checks whether actor may move a booking from one status to another
+func CanTransition(from, to models.BookingStatus, actor models.OrderActor) error {
+	actors, ok := transitions[from][to]
+	if !ok {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, to)
+	}
+	for _, a := range actors {
+		if a == actor {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s cannot move booking from %s to %s", ErrActorNotAllowed, actor, from, to)
+}
+
+// Hook runs inside the transition transaction once the booking has its new
+// status. Returning an error rolls the transition back.
+type Hook func(tx *gorm.DB, booking *models.Booking, from models.BookingStatus) error
+
+var hooks []Hook
+
+// OnTransition registers a hook that runs on every booking status change
+func OnTransition(hook Hook) {
+	hooks = append(hooks, hook)
+}
+
+// Transition moves a booking to a new status with the booking row locked.
+// A booking can only be completed or marked as a no-show once it has started.
+func Transition(db *gorm.DB, booking *models.Booking, to models.BookingStatus, actor models.OrderActor) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var current models.Booking
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, booking.ID).Error; err != nil {
+			return err
+		}
+
+		if err := CanTransition(current.Status, to, actor); err != nil {
+			return err
+		}
+		if (to == models.BookingStatusCompleted || to == models.BookingStatusNoShow) && time.Now().Before(current.StartsAt) {
+			return ErrNotStarted
+		}
+
+		from := current.Status
+		if err := tx.Model(&current).Update("status", to).Error; err != nil {
+			return err
+		}
+		for _, hook := range hooks {
+			if err := hook(tx, &current, from); err != nil {
+				return err
+			}
+		}
+
+		*booking = current
+		return nil
+	})
+}
+
+// Book reserves the slot of service starting at startsAt for a buyer. The
+// service row is locked while the slot is checked so concurrent requests for
+// the same slot are served one at a time, and the database rejects
+// overlapping bookings should anything slip through.
+func Book(db *gorm.DB, service *models.Service, store *models.Store, userID uint, startsAt time.Time, note string) (*models.Booking, error) {
+	var booking *models.Booking
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var locked models.Service
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, service.ID).Error; err != nil {
+			return err
+		}
+
+		loc := Location(store)
+		local := startsAt.In(loc)
+		day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+		slots, err := Slots(tx, &locked, loc, day, day, time.Now())
+		if err != nil {
+			return err
+		}
+
+		var slot *Slot
+		for i := range slots {
+			if slots[i].StartsAt.Equal(startsAt) {
+				slot = &slots[i]
+				break
+			}
+		}
+		if slot == nil {
+			var taken int64
+			if err := overlapping(tx, locked.ID, startsAt, startsAt.Add(slotLength(&locked))).Count(&taken).Error; err != nil {
+				return err
+			}
+			if taken > 0 {
+				return ErrSlotTaken
+			}
+			return ErrSlotUnavailable
+		}
+
+		booking = &models.Booking{
+			ServiceID: locked.ID,
+			StoreID:   locked.StoreID,
+			UserID:    userID,
+			StartsAt:  slot.StartsAt,
+			EndsAt:    slot.EndsAt,
+			Status:    models.BookingStatusRequested,
+			Price:     locked.Rate,
+			Currency:  locked.Currency,
+			Note:      note,
+		}
+		if err := tx.Create(booking).Error; err != nil {
+			if isExclusionViolation(err) {
+				return ErrSlotTaken
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return booking, nil
+}
+
+// overlapping selects the active bookings of a service that overlap start to end
+func overlapping(db *gorm.DB, serviceID uint, start, end time.Time) *gorm.DB {
+	return db.Model(&models.Booking{}).
+		Where("service_id = ? AND status IN ? AND starts_at < ? AND ends_at > ?", serviceID, activeStatuses, end, start)
+}
+
+// isExclusionViolation reports whether err is Postgres rejecting a row that
+// conflicts with an exclusion constraint
+func isExclusionViolation(err error) bool {
+	var pgErr interface{ SQLState() string }
+	return errors.As(err, &pgErr) && pgErr.SQLState() == "23P01"
+}
+
+// ExpireRequests periodically cancels requested bookings that were never
+// confirmed before they were due to start. Cancelling a paid booking refunds
+// it through the transition hooks.
+func ExpireRequests(db *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		var expired []models.Booking
+		if err := db.Where("status = ? AND starts_at < ?", models.BookingStatusRequested, time.Now()).
+			Find(&expired).Error; err != nil {
+			log.Printf("Warning: could not fetch unconfirmed bookings: %v", err)
+			continue
+		}
+
+		for i := range expired {
+			if err := Transition(db, &expired[i], models.BookingStatusCancelled, models.OrderActorSystem); err != nil {
+				log.Printf("Warning: could not expire booking %d: %v", expired[i].ID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package booking
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"gorm.io/gorm"
+)
+
+// DefaultTimezone is used for stores without a valid time zone
+const DefaultTimezone = "Africa/Lagos"
+
+// DefaultSlotMinutes is the booking length of services that have none set
+const DefaultSlotMinutes = 60
+
+const (
+	dateLayout  = "2006-01-02"
+	clockLayout = "15:04"
+)
+
+// Slot is a bookable period of a service
+type Slot struct {
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+// Location returns the time zone of a store's opening hours
+func Location(store *models.Store) *time.Location {
+	if store.Timezone != "" {
+		if loc, err := time.LoadLocation(store.Timezone); err == nil {
+			return loc
+		}
+	}
+	loc, err := time.LoadLocation(DefaultTimezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// ParseDate reads a YYYY-MM-DD date as midnight in loc
+func ParseDate(value string, loc *time.Location) (time.Time, error) {
+	return time.ParseInLocation(dateLayout, value, loc)
+}
+
+// ParseClock checks a wall clock time such as "09:00" and returns the
+// minutes since midnight
+func ParseClock(value string) (int, error) {
+	t, err := time.Parse(clockLayout, value)
+	if err != nil {
+		return 0, errors.New("times must look like 09:00")
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func slotLength(service *models.Service) time.Duration {
+	if service.SlotMinutes <= 0 {
+		return DefaultSlotMinutes * time.Minute
+	}
+	return time.Duration(service.SlotMinutes) * time.Minute
+}
+
+// Slots lists the open slots of a service from the start of day from to the
+// end of day to, both midnight in loc. Slots come from the service's weekly
+// availability on days that are not blacked out, and slots that have started
+// by now or overlap an active booking are left out.
+func Slots(db *gorm.DB, service *models.Service, loc *time.Location, from, to, now time.Time) ([]Slot, error) {
+	var windows []models.ServiceAvailability
+	if err := db.Where("service_id = ?", service.ID).Order("start_time").Find(&windows).Error; err != nil {
+		return nil, err
+	}
+
+	var blackouts []models.ServiceBlackout
+	if err := db.Where("service_id = ? AND date BETWEEN ? AND ?", service.ID, from.Format(dateLayout), to.Format(dateLayout)).
+		Find(&blackouts).Error; err != nil {
+		return nil, err
+	}
+	blackedOut := make(map[string]bool, len(blackouts))
+	for _, blackout := range blackouts {
+		blackedOut[blackout.Date] = true
+	}
+
+	end := to.AddDate(0, 0, 1)
+	var bookings []models.Booking
+	if err := overlapping(db, service.ID, from, end).Order("starts_at").Find(&bookings).Error; err != nil {
+		return nil, err
+	}
+
+	length := slotLength(service)
+	var slots []Slot
+	for day := from; day.Before(end); day = day.AddDate(0, 0, 1) {
+		if blackedOut[day.Format(dateLayout)] {
+			continue
+		}
+
+		for _, window := range windows {
+			if window.Weekday != int(day.Weekday()) {
+				continue
+			}
+			startMinutes, err := ParseClock(window.StartTime)
+			if err != nil {
+				continue
+			}
+			endMinutes, err := ParseClock(window.EndTime)
+			if err != nil {
+				continue
+			}
+
+			// Build wall clock times so slots keep their local time across
+			// daylight saving changes
+			start := time.Date(day.Year(), day.Month(), day.Day(), 0, startMinutes, 0, 0, loc)
+			closes := time.Date(day.Year(), day.Month(), day.Day(), 0, endMinutes, 0, 0, loc)
+			for slotStart := start; !slotStart.Add(length).After(closes); slotStart = slotStart.Add(length) {
+				slot := Slot{StartsAt: slotStart, EndsAt: slotStart.Add(length)}
+				if slot.StartsAt.Before(now) || booked(bookings, slot) {
+					continue
+				}
+				slots = append(slots, slot)
+			}
+		}
+	}
+
+	sort.Slice(slots, func(i, j int) bool { return slots[i].StartsAt.Before(slots[j].StartsAt) })
+	return slots, nil
+}
+
+func booked(bookings []models.Booking, slot Slot) bool {
+	for _, b := range bookings {
+		if b.StartsAt.Before(slot.EndsAt) && b.EndsAt.After(slot.StartsAt) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,401 @@
+package catalog
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/whatsapp"
+	"gorm.io/gorm"
+)
+
+// batchSize is the most changes sent to the catalog API in one batch
+const batchSize = 1000
+
+const deletedHash = "deleted"
+
+// ErrNotConfigured is returned by Sync when no catalog syncer is set up
+var ErrNotConfigured = errors.New("catalog sync is not configured")
+
+var defaultSyncer *Syncer
+
+// Use sets the syncer behind Sync
+func Use(s *Syncer) {
+	defaultSyncer = s
+}
+
+// Enabled reports whether a syncer has been set up
+func Enabled() bool {
+	return defaultSyncer != nil
+}
+
+// Sync syncs a store with the syncer set by Use, retrying products the
+// catalog rejected
+func Sync(ctx context.Context, db *gorm.DB, store *models.Store) error {
+	if defaultSyncer == nil {
+		return ErrNotConfigured
+	}
+
+	err := db.Model(&models.CatalogProductSync{}).
+		Where("store_id = ? AND status = ?", store.ID, models.CatalogSyncFailed).
+		Update("hash", "").Error
+	if err != nil {
+		return err
+	}
+	return defaultSyncer.SyncStore(ctx, db, store)
+}
+
+// Syncer pushes stores' products to their WhatsApp Business catalogs.
+// Product links point at StorefrontBaseURL/<store url>/products/<id>.
+// Images kept on local disk have relative URLs such as /media/..., which are
+// made absolute with PublicBaseURL, the address the API is served from, or
+// left out of the catalog when it is not set.
+type Syncer struct {
+	Client            *whatsapp.Client
+	StorefrontBaseURL string
+	PublicBaseURL     string
+}
+
+// Run periodically syncs every store that has a catalog connected
+func (s *Syncer) Run(db *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		var stores []models.Store
+		if err := db.Where("whatsapp_catalog_id <> ''").Find(&stores).Error; err != nil {
+			log.Printf("Warning: could not fetch stores to sync: %v", err)
+			continue
+		}
+
+		for i := range stores {
+			if err := s.SyncStore(context.Background(), db, &stores[i]); err != nil {
+				log.Printf("Warning: could not sync catalog of store %d: %v", stores[i].ID, err)
+			}
+		}
+	}
+}
+
+// SyncStore settles the store's previously submitted batches, then sends
+// every product that changed since its last sync and deletes products that
+// no longer exist. The outcome is recorded on the store's catalog sync.
+func (s *Syncer) SyncStore(ctx context.Context, db *gorm.DB, store *models.Store) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	err := s.syncStore(ctx, db, store)
+	if recordErr := recordStatus(db, store.ID, err); recordErr != nil {
+		return recordErr
+	}
+	return err
+}
+
+func (s *Syncer) syncStore(ctx context.Context, db *gorm.DB, store *models.Store) error {
+	if store.WhatsappCatalogID == "" {
+		return nil
+	}
+
+	if err := s.settleBatches(ctx, db, store); err != nil {
+		return err
+	}
+
+	var products []models.Product
+	if err := db.Where("store_id = ?", store.ID).Find(&products).Error; err != nil {
+		return err
+	}
+
+	var syncs []models.CatalogProductSync
+	if err := db.Where("store_id = ?", store.ID).Find(&syncs).Error; err != nil {
+		return err
+	}
+	syncsByProduct := make(map[uint]*models.CatalogProductSync, len(syncs))
+	for i := range syncs {
+		syncsByProduct[syncs[i].ProductID] = &syncs[i]
+	}
+
+	var requests []whatsapp.CatalogRequest
+	var changed []*models.CatalogProductSync
+	for _, product := range products {
+		sync, ok := syncsByProduct[product.ID]
+		delete(syncsByProduct, product.ID)
+		if ok && sync.Status == models.CatalogSyncPending {
+			// Wait for the batch already carrying this product
+			continue
+		}
+
+		// Products the catalog rejected are only resent once they change
+		item := s.catalogItem(store, &product)
+		hash := itemHash(item)
+		if ok && sync.Hash == hash {
+			continue
+		}
+
+		if !ok {
+			sync = &models.CatalogProductSync{
+				StoreID:    store.ID,
+				ProductID:  product.ID,
+				RetailerID: item.ID,
+			}
+		}
+		sync.Hash = hash
+		sync.Action = models.CatalogSyncUpsert
+		requests = append(requests, whatsapp.CatalogRequest{Method: whatsapp.CatalogUpdate, Data: item})
+		changed = append(changed, sync)
+	}
+
+	// Whatever is left belongs to deleted products. Deletes already sent, or
+	// rejected, are marked with deletedHash so they are not sent again.
+	for _, sync := range syncsByProduct {
+		if sync.Action == models.CatalogSyncDelete && sync.Hash == deletedHash {
+			continue
+		}
+		sync.Hash = deletedHash
+		sync.Action = models.CatalogSyncDelete
+		requests = append(requests, whatsapp.CatalogRequest{
+			Method: whatsapp.CatalogDelete,
+			Data:   whatsapp.CatalogItem{ID: sync.RetailerID},
+		})
+		changed = append(changed, sync)
+	}
+
+	for start := 0; start < len(requests); start += batchSize {
+		end := start + batchSize
+		if end > len(requests) {
+			end = len(requests)
+		}
+
+		handles, err := s.Client.CatalogBatch(ctx, store.WhatsappCatalogID, requests[start:end])
+		if err != nil {
+			return err
+		}
+		handle := strings.Join(handles, ",")
+
+		for _, sync := range changed[start:end] {
+			sync.Status = models.CatalogSyncPending
+			sync.BatchHandle = handle
+			sync.LastError = ""
+			if err := db.Save(sync).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// settleBatches checks on submitted batches and records each product's outcome
+func (s *Syncer) settleBatches(ctx context.Context, db *gorm.DB, store *models.Store) error {
+	var handles []string
+	err := db.Model(&models.CatalogProductSync{}).
+		Where("store_id = ? AND status = ?", store.ID, models.CatalogSyncPending).
+		Distinct().Pluck("batch_handle", &handles).Error
+	if err != nil {
+		return err
+	}
+
+	for _, handle := range handles {
+		itemErrors := map[string]string{}
+		finished := true
+		for _, h := range strings.Split(handle, ",") {
+			if h == "" {
+				continue
+			}
+			status, err := s.Client.CatalogBatchStatus(ctx, store.WhatsappCatalogID, h)
+			if err != nil {
+				return err
+			}
+			finished = finished && status.Finished
+			for id, message := range status.Errors {
+				itemErrors[id] = message
+			}
+		}
+		if !finished {
+			continue
+		}
+
+		var syncs []models.CatalogProductSync
+		if err := db.Where("store_id = ? AND batch_handle = ? AND status = ?", store.ID, handle, models.CatalogSyncPending).Find(&syncs).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var created []string
+		for i := range syncs {
+			sync := &syncs[i]
+			if message, failed := itemErrors[sync.RetailerID]; failed {
+				sync.Status = models.CatalogSyncFailed
+				sync.LastError = message
+			} else if sync.Action == models.CatalogSyncDelete {
+				if err := db.Delete(sync).Error; err != nil {
+					return err
+				}
+				continue
+			} else {
+				sync.Status = models.CatalogSyncSynced
+				sync.SyncedAt = &now
+				if sync.RemoteID == "" {
+					created = append(created, sync.RetailerID)
+				}
+			}
+			sync.BatchHandle = ""
+			if err := db.Save(sync).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := s.fetchRemoteIDs(ctx, db, store, created); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fetchRemoteIDs stores the catalog's IDs for newly created products
+func (s *Syncer) fetchRemoteIDs(ctx context.Context, db *gorm.DB, store *models.Store, retailerIDs []string) error {
+	for start := 0; start < len(retailerIDs); start += batchSize {
+		end := start + batchSize
+		if end > len(retailerIDs) {
+			end = len(retailerIDs)
+		}
+
+		ids, err := s.Client.CatalogProductIDs(ctx, store.WhatsappCatalogID, retailerIDs[start:end])
+		if err != nil {
+			return err
+		}
+		for retailerID, remoteID := range ids {
+			err := db.Model(&models.CatalogProductSync{}).
+				Where("store_id = ? AND retailer_id = ?", store.ID, retailerID).
+				Update("remote_id", remoteID).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Syncer) catalogItem(store *models.Store, product *models.Product) whatsapp.CatalogItem {
+	item := whatsapp.CatalogItem{
+		ID:           RetailerID(product.ID),
+		Title:        product.Name,
+		Description:  product.Description,
+		Availability: "out of stock",
+		Condition:    "new",
+		Price:        fmt.Sprintf("%.2f %s", product.Price, product.Currency),
+		Link:         strings.TrimRight(s.StorefrontBaseURL, "/") + "/" + store.StoreUrl + "/products/" + strconv.Itoa(int(product.ID)),
+		Brand:        store.Name,
+	}
+	if item.Description == "" {
+		// The catalog rejects products without a description
+		item.Description = product.Name
+	}
+	if product.Stock > 0 {
+		item.Availability = "in stock"
+	}
+	for _, image := range product.Images {
+		link, ok := s.imageLink(image)
+		if !ok {
+			log.Printf("Warning: leaving image %s of product %d out of the catalog, it needs a public base URL", image, product.ID)
+			continue
+		}
+		if item.ImageLink == "" {
+			item.ImageLink = link
+		} else {
+			item.AdditionalImageLink = append(item.AdditionalImageLink, link)
+		}
+	}
+	return item
+}
+
+// imageLink is the absolute URL of a product image, which the catalog needs
+// to fetch it. It reports false for a relative URL with no base to resolve
+// it against.
+func (s *Syncer) imageLink(image string) (string, bool) {
+	if u, err := url.Parse(image); err == nil && u.IsAbs() {
+		return image, true
+	}
+	if s.PublicBaseURL == "" {
+		return "", false
+	}
+	return strings.TrimRight(s.PublicBaseURL, "/") + "/" + strings.TrimLeft(image, "/"), true
+}
+
+// RetailerID is the ID a product is known by in WhatsApp catalogs
+func RetailerID(productID uint) string {
+	return "ws-" + strconv.Itoa(int(productID))
+}
+
+func itemHash(item whatsapp.CatalogItem) string {
+	payload, _ := json.Marshal(item)
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// recordStatus summarises the store's product sync rows on its catalog sync
+func recordStatus(db *gorm.DB, storeID uint, syncErr error) error {
+	var counts []struct {
+		Status models.CatalogSyncStatus
+		Count  int
+	}
+	err := db.Model(&models.CatalogProductSync{}).
+		Select("status, count(*) AS count").
+		Where("store_id = ?", storeID).
+		Group("status").
+		Scan(&counts).Error
+	if err != nil {
+		return err
+	}
+
+	status := models.StoreCatalogSync{StoreID: storeID}
+	if err := db.Where("store_id = ?", storeID).FirstOrInit(&status).Error; err != nil {
+		return err
+	}
+	status.SyncedProducts, status.PendingChanges, status.FailedProducts = 0, 0, 0
+	for _, count := range counts {
+		switch count.Status {
+		case models.CatalogSyncSynced:
+			status.SyncedProducts = count.Count
+		case models.CatalogSyncPending:
+			status.PendingChanges = count.Count
+		case models.CatalogSyncFailed:
+			status.FailedProducts = count.Count
+		}
+	}
+
+	status.LastError = ""
+	switch {
+	case syncErr != nil:
+		status.Status = models.CatalogSyncFailed
+		status.LastError = syncErr.Error()
+	case status.FailedProducts > 0:
+		status.Status = models.CatalogSyncFailed
+		status.LastError = fmt.Sprintf("%d products were rejected by the catalog", status.FailedProducts)
+	case status.PendingChanges > 0:
+		status.Status = models.CatalogSyncPending
+	default:
+		status.Status = models.CatalogSyncSynced
+		now := time.Now()
+		status.LastSyncedAt = &now
+	}
+
+	return db.Save(&status).Error
+}
+
+// Disconnect forgets a store's catalog sync state, for when the store
+// switches or removes its catalog
+func Disconnect(tx *gorm.DB, storeID uint) error {
+	if err := tx.Where("store_id = ?", storeID).Delete(&models.CatalogProductSync{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("store_id = ?", storeID).Delete(&models.StoreCatalogSync{}).Error
+}
@@ -0,0 +1,32 @@
+package catalog
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+)
+
+func TestCatalogItemImages(t *testing.T) {
+	store := &models.Store{Name: "Ada's Shoes", StoreUrl: "adas"}
+	product := &models.Product{ID: 7, Name: "Sneakers", Price: 100, Currency: "NGN",
+		Images: []string{"/media/a.jpg", "https://cdn.example.com/b.jpg"}}
+
+	// Relative images are resolved against the public base URL
+	syncer := &Syncer{StorefrontBaseURL: "https://shop.example.com", PublicBaseURL: "https://api.example.com/"}
+	item := syncer.catalogItem(store, product)
+	if item.ImageLink != "https://api.example.com/media/a.jpg" ||
+		!reflect.DeepEqual(item.AdditionalImageLink, []string{"https://cdn.example.com/b.jpg"}) {
+		t.Errorf("images %q, %q", item.ImageLink, item.AdditionalImageLink)
+	}
+	if item.Link != "https://shop.example.com/adas/products/7" {
+		t.Errorf("link %q", item.Link)
+	}
+
+	// and left out without one
+	syncer.PublicBaseURL = ""
+	item = syncer.catalogItem(store, product)
+	if item.ImageLink != "https://cdn.example.com/b.jpg" || len(item.AdditionalImageLink) != 0 {
+		t.Errorf("images without a base URL %q, %q", item.ImageLink, item.AdditionalImageLink)
+	}
+}
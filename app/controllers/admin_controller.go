@@ -2,10 +2,22 @@ package controllers
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/escrow"
 	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/orderflow"
 	"gorm.io/gorm"
 )
 
+// AdminHandler serves the admin endpoints
+type AdminHandler struct {
+	db *gorm.DB
+}
+
+// NewAdminHandler returns the admin endpoints backed by db
+func NewAdminHandler(db *gorm.DB) *AdminHandler {
+	return &AdminHandler{db: db}
+}
+
 // GetStats godoc
 // @Summary Get admin dashboard stats
 // @Description Get statistics for admin dashboard
@@ -16,8 +28,8 @@ import (
 // @Success 200 {object} models.DashboardStats
 // @Failure 401 {object} models.ErrorResponse
 // @Router /api/v1/admin/stats [get]
-func GetStats(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
+func (h *AdminHandler) GetStats(c *fiber.Ctx) error {
+	db := h.db
 
 	var stats models.DashboardStats
 
@@ -45,8 +57,8 @@ func GetStats(c *fiber.Ctx) error {
 // @Success 200 {object} PaginationResponse{data=[]models.Order}
 // @Failure 401 {object} models.ErrorResponse
 // @Router /api/v1/admin/orders [get]
-func GetAllOrders(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
+func (h *AdminHandler) GetAllOrders(c *fiber.Ctx) error {
+	db := h.db
 	page, perPage := paginate(c)
 
 	var orders []models.Order
@@ -75,9 +87,11 @@ func GetAllOrders(c *fiber.Ctx) error {
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
 // @Router /api/v1/admin/orders/{id}/status [put]
-func UpdateOrderStatusAdmin(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
+func (h *AdminHandler) UpdateOrderStatusAdmin(c *fiber.Ctx) error {
+	db := h.db
+	user := c.Locals("user").(*models.User)
 	orderID := c.Params("id")
 
 	var statusRequest models.UpdateOrderStatusRequest
@@ -87,6 +101,13 @@ func UpdateOrderStatusAdmin(c *fiber.Ctx) error {
 		})
 	}
 
+	status := models.OrderStatus(statusRequest.Status)
+	if !orderflow.IsValidStatus(status) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid order status",
+		})
+	}
+
 	var order models.Order
 	if err := db.First(&order, orderID).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
@@ -94,12 +115,33 @@ func UpdateOrderStatusAdmin(c *fiber.Ctx) error {
 		})
 	}
 
-	order.Status = models.OrderStatus(statusRequest.Status)
-	if err := db.Save(&order).Error; err != nil {
+	if err := orderflow.Transition(db, &order, status, models.OrderActorAdmin, &user.ID, statusRequest.Note); err != nil {
+		return transitionError(c, err)
+	}
+
+	return c.JSON(order)
+}
+
+// ReconcileEscrow godoc
+// @Summary Reconcile the escrow ledger (admin)
+// @Description Check that every escrow transaction balances and that holds match the ledger
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} escrow.Reconciliation
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/admin/escrow/reconcile [get]
+func (h *AdminHandler) ReconcileEscrow(c *fiber.Ctx) error {
+	db := h.db
+
+	result, err := escrow.Reconcile(db)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to update order status",
+			"error": "Failed to reconcile escrow ledger",
 		})
 	}
 
-	return c.JSON(order)
+	return c.JSON(result)
 }
@@ -0,0 +1,577 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/booking"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/rbac"
+	"gorm.io/gorm"
+)
+
+// BookingHandler serves the booking endpoints
+type BookingHandler struct {
+	db *gorm.DB
+}
+
+// NewBookingHandler returns the booking endpoints backed by db
+func NewBookingHandler(db *gorm.DB) *BookingHandler {
+	return &BookingHandler{db: db}
+}
+
+// maxSlotDays is the longest range of days slots can be listed for at once
+const maxSlotDays = 62
+
+// ServiceAvailability is a service's booking schedule
+type ServiceAvailability struct {
+	Timezone    string                       `json:"timezone"`
+	SlotMinutes int                          `json:"slot_minutes"`
+	Windows     []models.ServiceAvailability `json:"windows"`
+	Blackouts   []models.ServiceBlackout     `json:"blackouts"`
+}
+
+// ServiceSlots are the open slots of a service between two dates
+type ServiceSlots struct {
+	Timezone string         `json:"timezone"`
+	From     string         `json:"from"`
+	To       string         `json:"to"`
+	Slots    []booking.Slot `json:"slots"`
+}
+
+// serviceWithStore loads a service and the store offering it
+func serviceWithStore(db *gorm.DB, serviceID interface{}) (*models.Service, *models.Store, error) {
+	var service models.Service
+	if err := db.First(&service, serviceID).Error; err != nil {
+		return nil, nil, err
+	}
+	var store models.Store
+	if err := db.First(&store, service.StoreID).Error; err != nil {
+		return nil, nil, err
+	}
+	return &service, &store, nil
+}
+
+// vendorService loads the service in the path, checking it belongs to the
+// store in the path and the user may edit the store's catalog
+func vendorService(c *fiber.Ctx, db *gorm.DB) (*models.Service, *models.Store, error) {
+	user := c.Locals("user").(*models.User)
+	storeID, err := c.ParamsInt("storeId")
+	if err != nil {
+		return nil, nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid store ID"})
+	}
+
+	if err := authorizeStore(c.UserContext(), db, user, uint(storeID), rbac.StorePermissionCatalog); err != nil {
+		return nil, nil, c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	service, store, err := serviceWithStore(db, c.Params("id"))
+	if err != nil || service.StoreID != uint(storeID) {
+		return nil, nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Service not found"})
+	}
+	return service, store, nil
+}
+
+// GetServiceAvailability godoc
+// @Summary Get a service's availability
+// @Description Get the weekly booking windows, slot length and upcoming blackout dates of a service. Times are in the store's time zone.
+// @Tags services
+// @Produce json
+// @Param id path string true "Service ID"
+// @Success 200 {object} ServiceAvailability
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/services/{id}/availability [get]
+func (h *BookingHandler) GetServiceAvailability(c *fiber.Ctx) error {
+	db := h.db
+
+	service, store, err := serviceWithStore(db, c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Service not found"})
+	}
+
+	return serviceAvailability(c, db, service, store)
+}
+
+func serviceAvailability(c *fiber.Ctx, db *gorm.DB, service *models.Service, store *models.Store) error {
+	loc := booking.Location(store)
+	availability := ServiceAvailability{
+		Timezone:    loc.String(),
+		SlotMinutes: service.SlotMinutes,
+		Windows:     []models.ServiceAvailability{},
+		Blackouts:   []models.ServiceBlackout{},
+	}
+
+	if err := db.Where("service_id = ?", service.ID).Order("weekday, start_time").Find(&availability.Windows).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch availability"})
+	}
+	today := time.Now().In(loc).Format("2006-01-02")
+	if err := db.Where("service_id = ? AND date >= ?", service.ID, today).Order("date").Find(&availability.Blackouts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch availability"})
+	}
+
+	return c.JSON(availability)
+}
+
+// GetServiceSlots godoc
+// @Summary List open booking slots
+// @Description List the slots of a service that can still be booked between two dates, inclusive. Dates are in the store's time zone and default to the coming week.
+// @Tags services
+// @Produce json
+// @Param id path string true "Service ID"
+// @Param from query string false "First date, YYYY-MM-DD (default today)"
+// @Param to query string false "Last date, YYYY-MM-DD (default six days after from)"
+// @Success 200 {object} ServiceSlots
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/services/{id}/slots [get]
+func (h *BookingHandler) GetServiceSlots(c *fiber.Ctx) error {
+	db := h.db
+
+	service, store, err := serviceWithStore(db, c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Service not found"})
+	}
+
+	loc := booking.Location(store)
+	now := time.Now().In(loc)
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if raw := c.Query("from"); raw != "" {
+		if from, err = booking.ParseDate(raw, loc); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "from must be a date like 2024-01-31"})
+		}
+	}
+	to := from.AddDate(0, 0, 6)
+	if raw := c.Query("to"); raw != "" {
+		if to, err = booking.ParseDate(raw, loc); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "to must be a date like 2024-01-31"})
+		}
+	}
+	if to.Before(from) || to.After(from.AddDate(0, 0, maxSlotDays-1)) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "to must be on or after from and at most 62 days later"})
+	}
+
+	slots, err := booking.Slots(db, service, loc, from, to, time.Now())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch slots"})
+	}
+	if slots == nil {
+		slots = []booking.Slot{}
+	}
+
+	return c.JSON(ServiceSlots{
+		Timezone: loc.String(),
+		From:     from.Format("2006-01-02"),
+		To:       to.Format("2006-01-02"),
+		Slots:    slots,
+	})
+}
+
+// SetServiceAvailability godoc
+// @Summary Set a service's availability
+// @Description Replace the weekly booking windows and slot length of a service. Windows are wall clock times in the store's time zone and may not overlap on the same weekday.
+// @Tags store-services
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param storeId path string true "Store ID"
+// @Param id path string true "Service ID"
+// @Param availability body models.ServiceAvailabilityRequest true "Slot length and weekly windows"
+// @Success 200 {object} ServiceAvailability
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/stores/{storeId}/services/{id}/availability [put]
+func (h *BookingHandler) SetServiceAvailability(c *fiber.Ctx) error {
+	db := h.db
+	service, store, err := vendorService(c, db)
+	if service == nil {
+		return err
+	}
+
+	var input models.ServiceAvailabilityRequest
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if input.SlotMinutes < 5 || input.SlotMinutes > 24*60 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "slot_minutes must be between 5 and 1440"})
+	}
+
+	type span struct{ start, end int }
+	spans := map[int][]span{}
+	windows := make([]models.ServiceAvailability, 0, len(input.Windows))
+	for _, window := range input.Windows {
+		if window.Weekday < 0 || window.Weekday > 6 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "weekday must be between 0 (Sunday) and 6 (Saturday)"})
+		}
+		start, err := booking.ParseClock(window.StartTime)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		end, err := booking.ParseClock(window.EndTime)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if end-start < input.SlotMinutes {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Every window must fit at least one slot"})
+		}
+
+		spans[window.Weekday] = append(spans[window.Weekday], span{start, end})
+		windows = append(windows, models.ServiceAvailability{
+			ServiceID: service.ID,
+			Weekday:   window.Weekday,
+			StartTime: window.StartTime,
+			EndTime:   window.EndTime,
+		})
+	}
+	for _, day := range spans {
+		sort.Slice(day, func(i, j int) bool { return day[i].start < day[j].start })
+		for i := 1; i < len(day); i++ {
+			if day[i].start < day[i-1].end {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Windows on the same weekday may not overlap"})
+			}
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(service).Update("slot_minutes", input.SlotMinutes).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("service_id = ?", service.ID).Delete(&models.ServiceAvailability{}).Error; err != nil {
+			return err
+		}
+		if len(windows) == 0 {
+			return nil
+		}
+		return tx.Create(&windows).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save availability"})
+	}
+
+	return serviceAvailability(c, db, service, store)
+}
+
+// AddServiceBlackout godoc
+// @Summary Block out a date
+// @Description Stop a service from being booked on a date in the store's time zone. Existing bookings on that date are kept.
+// @Tags store-services
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param storeId path string true "Store ID"
+// @Param id path string true "Service ID"
+// @Param blackout body models.ServiceBlackoutRequest true "Date and reason"
+// @Success 201 {object} models.ServiceBlackout
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/stores/{storeId}/services/{id}/blackouts [post]
+func (h *BookingHandler) AddServiceBlackout(c *fiber.Ctx) error {
+	db := h.db
+	service, store, err := vendorService(c, db)
+	if service == nil {
+		return err
+	}
+
+	var input models.ServiceBlackoutRequest
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if _, err := booking.ParseDate(input.Date, booking.Location(store)); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "date must look like 2024-01-31"})
+	}
+
+	var existing int64
+	db.Model(&models.ServiceBlackout{}).Where("service_id = ? AND date = ?", service.ID, input.Date).Count(&existing)
+	if existing > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "This date is already blocked out"})
+	}
+
+	blackout := models.ServiceBlackout{
+		ServiceID: service.ID,
+		Date:      input.Date,
+		Reason:    input.Reason,
+	}
+	if err := db.Create(&blackout).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save blackout date"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(blackout)
+}
+
+// DeleteServiceBlackout godoc
+// @Summary Remove a blackout date
+// @Description Make a blocked out date bookable again
+// @Tags store-services
+// @Produce json
+// @Security BearerAuth
+// @Param storeId path string true "Store ID"
+// @Param id path string true "Service ID"
+// @Param blackoutId path string true "Blackout ID"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/stores/{storeId}/services/{id}/blackouts/{blackoutId} [delete]
+func (h *BookingHandler) DeleteServiceBlackout(c *fiber.Ctx) error {
+	db := h.db
+	service, _, err := vendorService(c, db)
+	if service == nil {
+		return err
+	}
+
+	result := db.Where("id = ? AND service_id = ?", c.Params("blackoutId"), service.ID).Delete(&models.ServiceBlackout{})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete blackout date"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Blackout date not found"})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// CreateBooking godoc
+// @Summary Book a service
+// @Description Request one of the open slots of a service. The booking starts out requested until the vendor confirms it.
+// @Tags bookings
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Service ID"
+// @Param booking body models.CreateBookingRequest true "Slot start time (RFC 3339) and a note for the vendor"
+// @Success 201 {object} models.Booking
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/services/{id}/bookings [post]
+func (h *BookingHandler) CreateBooking(c *fiber.Ctx) error {
+	db := h.db
+	user := c.Locals("user").(*models.User)
+
+	service, store, err := serviceWithStore(db, c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Service not found"})
+	}
+
+	var input models.CreateBookingRequest
+	if err := c.BodyParser(&input); err != nil || input.StartsAt.IsZero() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "starts_at must be an RFC 3339 time"})
+	}
+
+	created, err := booking.Book(db, service, store, user.ID, input.StartsAt, input.Note)
+	switch {
+	case errors.Is(err, booking.ErrSlotTaken), errors.Is(err, booking.ErrSlotUnavailable):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create booking"})
+	}
+
+	created.Service = service
+	return c.Status(fiber.StatusCreated).JSON(created)
+}
+
+// GetUserBookings godoc
+// @Summary Get user bookings
+// @Description Get the authenticated user's service bookings, soonest first
+// @Tags bookings
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Only bookings with this status"
+// @Param page query int false "Page number"
+// @Param per_page query int false "Items per page"
+// @Success 200 {object} PaginationResponse
+// @Router /api/v1/bookings [get]
+func (h *BookingHandler) GetUserBookings(c *fiber.Ctx) error {
+	db := h.db
+	user := c.Locals("user").(*models.User)
+
+	return listBookings(c, db.Where("user_id = ?", user.ID))
+}
+
+// GetStoreBookings godoc
+// @Summary Get store bookings
+// @Description Get the bookings of a store's services, soonest first
+// @Tags stores
+// @Produce json
+// @Security BearerAuth
+// @Param storeId path string true "Store ID"
+// @Param status query string false "Only bookings with this status"
+// @Param page query int false "Page number"
+// @Param per_page query int false "Items per page"
+// @Success 200 {object} PaginationResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/v1/stores/{storeId}/bookings [get]
+func (h *BookingHandler) GetStoreBookings(c *fiber.Ctx) error {
+	db := h.db
+	user := c.Locals("user").(*models.User)
+	storeID, err := c.ParamsInt("storeId")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid store ID"})
+	}
+
+	if err := authorizeStore(c.UserContext(), db, user, uint(storeID), rbac.StorePermissionOrders); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return listBookings(c, db.Where("store_id = ?", storeID))
+}
+
+func listBookings(c *fiber.Ctx, query *gorm.DB) error {
+	page, perPage := paginate(c)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Session(&gorm.Session{}).Model(&models.Booking{}).Count(&total)
+
+	var bookings []models.Booking
+	err := query.Preload("Service").Order("starts_at").
+		Offset((page - 1) * perPage).
+		Limit(perPage).
+		Find(&bookings).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch bookings"})
+	}
+
+	return c.JSON(NewPaginationResponse(bookings, total, page, perPage))
+}
+
+// GetBooking godoc
+// @Summary Get booking by ID
+// @Description Get a booking made by the authenticated user or for one of their stores
+// @Tags bookings
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Booking ID"
+// @Success 200 {object} models.Booking
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/bookings/{id} [get]
+func (h *BookingHandler) GetBooking(c *fiber.Ctx) error {
+	db := h.db
+	user := c.Locals("user").(*models.User)
+
+	var found models.Booking
+	if err := db.Preload("Service").First(&found, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Booking not found"})
+	}
+	if len(bookingActors(c.UserContext(), db, user, &found)) == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Booking not found"})
+	}
+
+	return c.JSON(found)
+}
+
+// UpdateBookingStatus godoc
+// @Summary Update booking status
+// @Description Move a booking to a new status. Vendors confirm requests and mark bookings completed or no-show once they have started; buyers and vendors can cancel.
+// @Tags bookings
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Booking ID"
+// @Param status body models.UpdateBookingStatusRequest true "New booking status"
+// @Success 200 {object} models.Booking
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/bookings/{id}/status [put]
+func (h *BookingHandler) UpdateBookingStatus(c *fiber.Ctx) error {
+	db := h.db
+	user := c.Locals("user").(*models.User)
+
+	var input models.UpdateBookingStatusRequest
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	status := models.BookingStatus(input.Status)
+	if !booking.IsValidStatus(status) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid booking status"})
+	}
+
+	var found models.Booking
+	if err := db.First(&found, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Booking not found"})
+	}
+
+	actors := bookingActors(c.UserContext(), db, user, &found)
+	if len(actors) == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Booking not found"})
+	}
+
+	// A vendor booking their own service acts as both buyer and vendor
+	actor := actors[0]
+	for _, a := range actors {
+		if booking.CanTransition(found.Status, status, a) == nil {
+			actor = a
+			break
+		}
+	}
+
+	err := booking.Transition(db, &found, status, actor)
+	switch {
+	case errors.Is(err, booking.ErrInvalidTransition), errors.Is(err, booking.ErrNotStarted):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, booking.ErrActorNotAllowed):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update booking status"})
+	}
+
+	return c.JSON(found)
+}
+
+// PayBooking godoc
+// @Summary Pay for a booking
+// @Description Start a payment for a requested or confirmed booking with the chosen provider and get the URL the buyer should be redirected to
+// @Tags bookings
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Booking ID"
+// @Param payment body models.InitializePaymentRequest true "Payment provider and callback URL"
+// @Success 200 {object} models.Payment "The pending payment already started"
+// @Success 201 {object} models.Payment
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 502 {object} models.ErrorResponse
+// @Router /api/v1/bookings/{id}/pay [post]
+func (h *BookingHandler) PayBooking(c *fiber.Ctx) error {
+	db := h.db
+	user := c.Locals("user").(*models.User)
+
+	var found models.Booking
+	if err := db.Where("id = ? AND user_id = ?", c.Params("id"), user.ID).First(&found).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Booking not found"})
+	}
+
+	if found.PaymentID != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Booking has already been paid"})
+	}
+	if found.Status != models.BookingStatusRequested && found.Status != models.BookingStatusConfirmed {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Booking is not awaiting payment"})
+	}
+
+	return startPayment(c, db, user, models.Payment{
+		BookingID: &found.ID,
+		Amount:    found.Price,
+		Currency:  found.Currency,
+	}, map[string]interface{}{"booking_id": found.ID})
+}
+
+// bookingActors returns the roles the user plays for a booking
+func bookingActors(ctx context.Context, db *gorm.DB, user *models.User, b *models.Booking) []models.OrderActor {
+	var actors []models.OrderActor
+
+	if err := authorizeStore(ctx, db, user, b.StoreID, rbac.StorePermissionOrders); err == nil {
+		actors = append(actors, models.OrderActorVendor)
+	}
+	if b.UserID == user.ID {
+		actors = append(actors, models.OrderActorBuyer)
+	}
+
+	return actors
+}
@@ -0,0 +1,532 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"gorm.io/gorm"
+)
+
+// CartHandler serves the cart endpoints
+type CartHandler struct {
+	db *gorm.DB
+}
+
+// NewCartHandler returns the cart endpoints backed by db
+func NewCartHandler(db *gorm.DB) *CartHandler {
+	return &CartHandler{db: db}
+}
+
+const (
+	cartCookieName = "cart_token"
+	cartCookieTTL  = 30 * 24 * time.Hour
+)
+
+// CartResponse is a cart with its prices re-validated against the catalog
+type CartResponse struct {
+	*models.Cart
+	Subtotal     float64                  `json:"subtotal"`
+	PriceChanges []models.CartPriceChange `json:"price_changes"`
+}
+
+func newCartToken() string {
+	b := make([]byte, 24)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func setCartCookie(c *fiber.Ctx, token string, expires time.Time) {
+	c.Cookie(&fiber.Cookie{
+		Name:     cartCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+// currentCart finds the cart for the request. Signed-in buyers get their own
+// cart, with any guest cart from the cookie merged into it; guests get the
+// cart their cookie points at. When create is set a missing cart is created.
+func currentCart(c *fiber.Ctx, db *gorm.DB, create bool) (*models.Cart, error) {
+	user, _ := c.Locals("user").(*models.User)
+	token := c.Cookies(cartCookieName)
+
+	var guestCart *models.Cart
+	if token != "" {
+		var cart models.Cart
+		err := db.Where("token = ? AND user_id IS NULL", token).First(&cart).Error
+		if err == nil {
+			guestCart = &cart
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	if user == nil {
+		if guestCart != nil || !create {
+			return guestCart, nil
+		}
+
+		token = newCartToken()
+		cart := models.Cart{Token: &token}
+		if err := db.Create(&cart).Error; err != nil {
+			return nil, err
+		}
+		setCartCookie(c, token, time.Now().Add(cartCookieTTL))
+		return &cart, nil
+	}
+
+	var cart models.Cart
+	err := db.Where("user_id = ?", user.ID).First(&cart).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound) && guestCart != nil:
+		// Adopt the guest cart as the buyer's cart
+		cart = *guestCart
+		if err := db.Model(&cart).Updates(map[string]interface{}{"user_id": user.ID, "token": nil}).Error; err != nil {
+			return nil, err
+		}
+		setCartCookie(c, "", time.Now().Add(-time.Hour))
+		return &cart, nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if !create {
+			return nil, nil
+		}
+		cart = models.Cart{UserID: &user.ID}
+		if err := db.Create(&cart).Error; err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	}
+
+	if guestCart != nil {
+		if err := mergeCarts(db, &cart, guestCart); err != nil {
+			return nil, err
+		}
+		setCartCookie(c, "", time.Now().Add(-time.Hour))
+	}
+
+	return &cart, nil
+}
+
+// cartItemQuery selects the cart line of a product variant, or of a product
+// without variants when variantID is nil
+func cartItemQuery(db *gorm.DB, cartID, productID uint, variantID *uint) *gorm.DB {
+	query := db.Where("cart_id = ? AND product_id = ?", cartID, productID)
+	if variantID == nil {
+		return query.Where("variant_id IS NULL")
+	}
+	return query.Where("variant_id = ?", *variantID)
+}
+
+// mergeCarts moves the items of a guest cart into a buyer's cart, adding up
+// quantities of products that are in both, and deletes the guest cart
+func mergeCarts(db *gorm.DB, cart *models.Cart, guest *models.Cart) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var guestItems []models.CartItem
+		if err := tx.Where("cart_id = ?", guest.ID).Find(&guestItems).Error; err != nil {
+			return err
+		}
+
+		for _, item := range guestItems {
+			var existing models.CartItem
+			err := cartItemQuery(tx, cart.ID, item.ProductID, item.VariantID).First(&existing).Error
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				if err := tx.Model(&item).Update("cart_id", cart.ID).Error; err != nil {
+					return err
+				}
+				continue
+			case err != nil:
+				return err
+			}
+
+			if err := tx.Model(&existing).Update("quantity", existing.Quantity+item.Quantity).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("cart_id = ?", guest.ID).Delete(&models.CartItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(guest).Error
+	})
+}
+
+// refreshCart loads the cart's items and re-validates them against the
+// catalog: items whose product is gone are dropped, unit prices are brought
+// up to date and every change is reported back
+func refreshCart(db *gorm.DB, cart *models.Cart) (*CartResponse, error) {
+	response := &CartResponse{Cart: cart, PriceChanges: []models.CartPriceChange{}}
+
+	if cart.ID == 0 {
+		cart.Items = []models.CartItem{}
+		return response, nil
+	}
+
+	var items []models.CartItem
+	if err := db.Preload("Product").Preload("Variant").Where("cart_id = ?", cart.ID).Order("id").Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	cart.Items = []models.CartItem{}
+	for _, item := range items {
+		if item.Product.ID == 0 || (item.VariantID != nil && item.Variant == nil) {
+			if err := db.Delete(&item).Error; err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		price := item.Product.PriceFor(item.Variant)
+		if item.UnitPrice != price {
+			response.PriceChanges = append(response.PriceChanges, models.CartPriceChange{
+				ProductID: item.ProductID,
+				VariantID: item.VariantID,
+				Name:      item.Product.Name,
+				OldPrice:  item.UnitPrice,
+				NewPrice:  price,
+			})
+			item.UnitPrice = price
+			if err := db.Model(&item).Update("unit_price", item.UnitPrice).Error; err != nil {
+				return nil, err
+			}
+		}
+
+		item.InStock = item.Product.Stock >= item.Quantity
+		if item.Variant != nil {
+			item.InStock = item.Variant.Stock >= item.Quantity
+		}
+		response.Subtotal += item.UnitPrice * float64(item.Quantity)
+		cart.Items = append(cart.Items, item)
+	}
+
+	return response, nil
+}
+
+func cartResponse(c *fiber.Ctx, db *gorm.DB, cart *models.Cart) error {
+	response, err := refreshCart(db, cart)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load cart",
+		})
+	}
+	return c.JSON(response)
+}
+
+// GetCart godoc
+// @Summary Get cart
+// @Description Get the current cart with prices re-validated against the catalog. Works for guests through the cart cookie.
+// @Tags cart
+// @Produce json
+// @Success 200 {object} CartResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/cart [get]
+func (h *CartHandler) GetCart(c *fiber.Ctx) error {
+	db := h.db
+
+	cart, err := currentCart(c, db, false)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load cart",
+		})
+	}
+	if cart == nil {
+		cart = &models.Cart{}
+	}
+
+	return cartResponse(c, db, cart)
+}
+
+// AddCartItem godoc
+// @Summary Add item to cart
+// @Description Add a product to the cart, or increase its quantity if it is already there
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Param item body models.CartItemRequest true "Product and quantity to add"
+// @Success 200 {object} CartResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/cart [post]
+func (h *CartHandler) AddCartItem(c *fiber.Ctx) error {
+	db := h.db
+
+	var itemRequest models.CartItemRequest
+	if err := c.BodyParser(&itemRequest); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if itemRequest.Quantity <= 0 {
+		itemRequest.Quantity = 1
+	}
+
+	var product models.Product
+	if err := db.First(&product, itemRequest.ProductID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Product not found",
+		})
+	}
+
+	variant, err := orderVariant(c.UserContext(), db, &product, itemRequest.VariantID)
+	if err != nil {
+		return orderErrorResponse(c, err)
+	}
+
+	cart, err := currentCart(c, db, true)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load cart",
+		})
+	}
+
+	var item models.CartItem
+	err = cartItemQuery(db, cart.ID, product.ID, itemRequest.VariantID).First(&item).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		item = models.CartItem{
+			CartID:    cart.ID,
+			ProductID: product.ID,
+			VariantID: itemRequest.VariantID,
+			Quantity:  itemRequest.Quantity,
+			UnitPrice: product.PriceFor(variant),
+		}
+		err = db.Create(&item).Error
+	case err == nil:
+		err = db.Model(&item).Update("quantity", item.Quantity+itemRequest.Quantity).Error
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to add item to cart",
+		})
+	}
+
+	return cartResponse(c, db, cart)
+}
+
+// UpdateCartItem godoc
+// @Summary Update cart item
+// @Description Set the quantity of a product in the cart. A quantity of zero removes it.
+// @Tags cart
+// @Accept json
+// @Produce json
+// @Param item body models.CartItemRequest true "Product and new quantity"
+// @Success 200 {object} CartResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/cart [put]
+func (h *CartHandler) UpdateCartItem(c *fiber.Ctx) error {
+	db := h.db
+
+	var itemRequest models.CartItemRequest
+	if err := c.BodyParser(&itemRequest); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if itemRequest.Quantity < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Quantity cannot be negative",
+		})
+	}
+
+	cart, err := currentCart(c, db, false)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load cart",
+		})
+	}
+	if cart == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Item not in cart",
+		})
+	}
+
+	var item models.CartItem
+	if err := cartItemQuery(db, cart.ID, itemRequest.ProductID, itemRequest.VariantID).First(&item).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Item not in cart",
+		})
+	}
+
+	if itemRequest.Quantity == 0 {
+		err = db.Delete(&item).Error
+	} else {
+		err = db.Model(&item).Update("quantity", itemRequest.Quantity).Error
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update cart",
+		})
+	}
+
+	return cartResponse(c, db, cart)
+}
+
+// RemoveCartItem godoc
+// @Summary Remove item from cart
+// @Description Remove a product from the cart
+// @Tags cart
+// @Produce json
+// @Param productId path string true "Product ID"
+// @Param variant_id query int false "Variant ID, for products with variants"
+// @Success 200 {object} CartResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/cart/items/{productId} [delete]
+func (h *CartHandler) RemoveCartItem(c *fiber.Ctx) error {
+	db := h.db
+	productID, err := c.ParamsInt("productId")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
+		})
+	}
+	var variantID *uint
+	if id := c.QueryInt("variant_id"); id > 0 {
+		v := uint(id)
+		variantID = &v
+	}
+
+	cart, err := currentCart(c, db, false)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load cart",
+		})
+	}
+	if cart == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Item not in cart",
+		})
+	}
+
+	result := cartItemQuery(db, cart.ID, uint(productID), variantID).Delete(&models.CartItem{})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update cart",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Item not in cart",
+		})
+	}
+
+	return cartResponse(c, db, cart)
+}
+
+// ClearCart godoc
+// @Summary Clear cart
+// @Description Remove every item from the cart
+// @Tags cart
+// @Produce json
+// @Success 204 "No Content"
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/cart [delete]
+func (h *CartHandler) ClearCart(c *fiber.Ctx) error {
+	db := h.db
+
+	cart, err := currentCart(c, db, false)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load cart",
+		})
+	}
+
+	if cart != nil {
+		if err := db.Where("cart_id = ?", cart.ID).Delete(&models.CartItem{}).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to clear cart",
+			})
+		}
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// CheckoutCart godoc
+// @Summary Check out cart
+// @Description Turn the cart into a checkout with one order per store. If any price changed since the buyer last saw the cart, nothing is ordered and the changes are returned.
+// @Tags cart
+// @Produce json
+// @Security BearerAuth
+// @Success 201 {object} models.CheckoutGroup
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/cart/checkout [post]
+func (h *CartHandler) CheckoutCart(c *fiber.Ctx) error {
+	db := h.db
+	user, ok := c.Locals("user").(*models.User)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Sign in to check out",
+		})
+	}
+
+	cart, err := currentCart(c, db, false)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load cart",
+		})
+	}
+	if cart == nil {
+		cart = &models.Cart{}
+	}
+
+	response, err := refreshCart(db, cart)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load cart",
+		})
+	}
+	if len(cart.Items) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cart is empty",
+		})
+	}
+	if len(response.PriceChanges) > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":         "Cart prices have changed",
+			"price_changes": response.PriceChanges,
+		})
+	}
+
+	items := make([]models.OrderItem, 0, len(cart.Items))
+	for _, item := range cart.Items {
+		items = append(items, models.OrderItem{
+			ProductID: item.ProductID,
+			VariantID: item.VariantID,
+			Quantity:  item.Quantity,
+		})
+	}
+
+	// One order is created per store, grouped under a single checkout
+	var group *models.CheckoutGroup
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		group, err = placeCheckoutGroup(c.UserContext(), tx, user.ID, items)
+		if err != nil {
+			return err
+		}
+		return tx.Where("cart_id = ?", cart.ID).Delete(&models.CartItem{}).Error
+	})
+	if err != nil {
+		return orderErrorResponse(c, err)
+	}
+
+	group, err = loadCheckoutGroup(db, user.ID, group.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch created checkout",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(group)
+}
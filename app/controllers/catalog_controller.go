@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/catalog"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/rbac"
+	"gorm.io/gorm"
+)
+
+// CatalogHandler serves the WhatsApp catalog endpoints
+type CatalogHandler struct {
+	db *gorm.DB
+}
+
+// NewCatalogHandler returns the WhatsApp catalog endpoints backed by db
+func NewCatalogHandler(db *gorm.DB) *CatalogHandler {
+	return &CatalogHandler{db: db}
+}
+
+// StoreCatalog is a store's WhatsApp catalog connection and sync state
+type StoreCatalog struct {
+	CatalogID string                      `json:"catalog_id"`
+	Sync      *models.StoreCatalogSync    `json:"sync"`
+	Failed    []models.CatalogProductSync `json:"failed"`
+}
+
+// GetStoreCatalog godoc
+// @Summary Get store catalog sync status
+// @Description Get the store's WhatsApp Business catalog and how its last product sync went, with the products the catalog rejected
+// @Tags stores
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Store ID"
+// @Success 200 {object} StoreCatalog
+// @Failure 403 {object} models.ErrorResponse
+// @Router /stores/{id}/catalog [get]
+func (h *CatalogHandler) GetStoreCatalog(c *fiber.Ctx) error {
+	db := h.db
+	store, err := memberStore(c, db, rbac.StorePermissionSettings)
+	if store == nil {
+		return err
+	}
+
+	response := StoreCatalog{CatalogID: store.WhatsappCatalogID, Failed: []models.CatalogProductSync{}}
+
+	var sync models.StoreCatalogSync
+	if err := db.Where("store_id = ?", store.ID).First(&sync).Error; err == nil {
+		response.Sync = &sync
+	}
+
+	if err := db.Where("store_id = ? AND status = ?", store.ID, models.CatalogSyncFailed).Order("product_id").Find(&response.Failed).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch catalog sync status"})
+	}
+
+	return c.JSON(response)
+}
+
+// ConnectStoreCatalog godoc
+// @Summary Connect a WhatsApp catalog
+// @Description Set the WhatsApp Business catalog the store's products are synced to. Switching catalogs starts the sync over, an empty catalog ID disconnects it.
+// @Tags stores
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Store ID"
+// @Param input body models.ConnectCatalogRequest true "Catalog ID"
+// @Success 200 {object} models.Store
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /stores/{id}/catalog [put]
+func (h *CatalogHandler) ConnectStoreCatalog(c *fiber.Ctx) error {
+	db := h.db
+	store, err := memberStore(c, db, rbac.StorePermissionSettings)
+	if store == nil {
+		return err
+	}
+
+	var input models.ConnectCatalogRequest
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if input.CatalogID != store.WhatsappCatalogID {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := catalog.Disconnect(tx, store.ID); err != nil {
+				return err
+			}
+			store.WhatsappCatalogID = input.CatalogID
+			return tx.Model(store).Update("whatsapp_catalog_id", store.WhatsappCatalogID).Error
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update store"})
+		}
+	}
+
+	return c.JSON(store)
+}
+
+// SyncStoreCatalog godoc
+// @Summary Sync store catalog now
+// @Description Start syncing the store's products to its WhatsApp catalog without waiting for the next scheduled sync. Products the catalog rejected are retried.
+// @Tags stores
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Store ID"
+// @Success 202 {object} object{message=string}
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /stores/{id}/catalog/sync [post]
+func (h *CatalogHandler) SyncStoreCatalog(c *fiber.Ctx) error {
+	db := h.db
+	store, err := memberStore(c, db, rbac.StorePermissionSettings)
+	if store == nil {
+		return err
+	}
+
+	if !catalog.Enabled() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Catalog sync is not available"})
+	}
+	if store.WhatsappCatalogID == "" {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Store has no WhatsApp catalog connected"})
+	}
+
+	go func() {
+		if err := catalog.Sync(context.Background(), db, store); err != nil {
+			log.Printf("Catalog sync of store %d failed: %v", store.ID, err)
+		}
+	}()
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"message": "Catalog sync started"})
+}
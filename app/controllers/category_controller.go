@@ -0,0 +1,343 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/slug"
+	"gorm.io/gorm"
+)
+
+// CategoryHandler serves the category endpoints
+type CategoryHandler struct {
+	db *gorm.DB
+}
+
+// NewCategoryHandler returns the category endpoints backed by db
+func NewCategoryHandler(db *gorm.DB) *CategoryHandler {
+	return &CategoryHandler{db: db}
+}
+
+var errCategoryNotFound = errors.New("category not found")
+
+// taxonomy is the whole category tree with product counts that include
+// descendant categories
+type taxonomy struct {
+	roots    []*models.Category
+	byID     map[uint]*models.Category
+	children map[uint][]*models.Category
+}
+
+func loadTaxonomy(db *gorm.DB) (*taxonomy, error) {
+	var categories []models.Category
+	if err := db.Order("name").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		CategoryID uint
+		Count      int64
+	}
+	err := db.Model(&models.Product{}).
+		Select("category_id, count(*) AS count").
+		Where("category_id IS NOT NULL").
+		Group("category_id").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	t := &taxonomy{
+		byID:     make(map[uint]*models.Category, len(categories)),
+		children: map[uint][]*models.Category{},
+	}
+	for i := range categories {
+		category := &categories[i]
+		t.byID[category.ID] = category
+		if category.ParentID == nil {
+			t.roots = append(t.roots, category)
+		} else {
+			t.children[*category.ParentID] = append(t.children[*category.ParentID], category)
+		}
+	}
+
+	for _, count := range counts {
+		// Add each category's own products to it and all of its ancestors
+		for category := t.byID[count.CategoryID]; category != nil; {
+			category.ProductCount += count.Count
+			if category.ParentID == nil {
+				break
+			}
+			category = t.byID[*category.ParentID]
+		}
+	}
+
+	return t, nil
+}
+
+// subtree copies a category with its children filled in depth levels deep,
+// or all the way down when depth is negative
+func (t *taxonomy) subtree(category *models.Category, depth int) models.Category {
+	node := *category
+	node.Children = nil
+	if depth == 0 {
+		return node
+	}
+	for _, child := range t.children[category.ID] {
+		node.Children = append(node.Children, t.subtree(child, depth-1))
+	}
+	return node
+}
+
+// findCategory looks a category up by ID or slug
+func findCategory(db *gorm.DB, idOrSlug string) (*models.Category, error) {
+	var category models.Category
+	query := db.Where("slug = ?", idOrSlug)
+	if id, err := strconv.ParseUint(idOrSlug, 10, 64); err == nil {
+		query = db.Where("id = ?", id)
+	}
+	if err := query.First(&category).Error; err != nil {
+		return nil, errCategoryNotFound
+	}
+	return &category, nil
+}
+
+// categoryWithDescendants returns the IDs of a category and everything below it
+func categoryWithDescendants(db *gorm.DB, categoryID uint) ([]uint, error) {
+	var ids []uint
+	err := db.Raw(`WITH RECURSIVE tree AS (
+			SELECT id FROM categories WHERE id = ?
+			UNION ALL
+			SELECT categories.id FROM categories JOIN tree ON categories.parent_id = tree.id
+		)
+		SELECT id FROM tree`, categoryID).Scan(&ids).Error
+	return ids, err
+}
+
+// validateCategory checks that a product's category exists
+func validateCategory(db *gorm.DB, categoryID *uint) error {
+	if categoryID == nil {
+		return nil
+	}
+	var count int64
+	if err := db.Model(&models.Category{}).Where("id = ?", *categoryID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errCategoryNotFound
+	}
+	return nil
+}
+
+// GetCategories godoc
+// @Summary List categories
+// @Description Get the product category tree. Product counts include products in subcategories.
+// @Tags categories
+// @Produce json
+// @Success 200 {array} models.Category
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/categories [get]
+func (h *CategoryHandler) GetCategories(c *fiber.Ctx) error {
+	db := h.db
+
+	t, err := loadTaxonomy(db)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch categories",
+		})
+	}
+
+	tree := make([]models.Category, 0, len(t.roots))
+	for _, root := range t.roots {
+		tree = append(tree, t.subtree(root, -1))
+	}
+
+	return c.JSON(tree)
+}
+
+// GetCategory godoc
+// @Summary Get a category
+// @Description Get a category by ID or slug with its parent and subcategories
+// @Tags categories
+// @Produce json
+// @Param id path string true "Category ID or slug"
+// @Success 200 {object} models.Category
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/categories/{id} [get]
+func (h *CategoryHandler) GetCategory(c *fiber.Ctx) error {
+	db := h.db
+
+	found, err := findCategory(db, c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Category not found"})
+	}
+
+	t, err := loadTaxonomy(db)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch category",
+		})
+	}
+
+	category := t.subtree(t.byID[found.ID], 1)
+	if category.ParentID != nil {
+		if parent, ok := t.byID[*category.ParentID]; ok {
+			p := *parent
+			category.Parent = &p
+		}
+	}
+
+	return c.JSON(category)
+}
+
+// CreateCategory godoc
+// @Summary Create a category
+// @Description Add a category to the product taxonomy (admin only)
+// @Tags admin-categories
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param category body models.CategoryRequest true "Category"
+// @Success 201 {object} models.Category
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/admin/categories [post]
+func (h *CategoryHandler) CreateCategory(c *fiber.Ctx) error {
+	db := h.db
+
+	var input models.CategoryRequest
+	if err := c.BodyParser(&input); err != nil || input.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	category := models.Category{Name: input.Name, Icon: input.Icon}
+	if status, err := applyCategoryInput(db, &category, input); err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := db.Create(&category).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not create category"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(category)
+}
+
+// UpdateCategory godoc
+// @Summary Update a category
+// @Description Rename, re-slug or move a category (admin only)
+// @Tags admin-categories
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Category ID"
+// @Param category body models.CategoryRequest true "Category"
+// @Success 200 {object} models.Category
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/admin/categories/{id} [put]
+func (h *CategoryHandler) UpdateCategory(c *fiber.Ctx) error {
+	db := h.db
+
+	var category models.Category
+	if err := db.First(&category, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Category not found"})
+	}
+
+	var input models.CategoryRequest
+	if err := c.BodyParser(&input); err != nil || input.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	category.Name = input.Name
+	category.Icon = input.Icon
+	if status, err := applyCategoryInput(db, &category, input); err != nil {
+		return c.Status(status).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := db.Select("name", "slug", "icon", "parent_id").Save(&category).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update category"})
+	}
+
+	return c.JSON(category)
+}
+
+// applyCategoryInput sets the slug and parent of a category, checking the
+// slug is free and the parent exists and is not the category or below it
+func applyCategoryInput(db *gorm.DB, category *models.Category, input models.CategoryRequest) (int, error) {
+	category.Slug = slug.Make(input.Slug)
+	if category.Slug == "" {
+		category.Slug = slug.Make(input.Name)
+	}
+	if category.Slug == "" {
+		return fiber.StatusBadRequest, errors.New("category needs a name or slug with letters or digits")
+	}
+
+	var taken int64
+	db.Model(&models.Category{}).Where("slug = ? AND id <> ?", category.Slug, category.ID).Count(&taken)
+	if taken > 0 {
+		return fiber.StatusConflict, errors.New("a category with this slug already exists")
+	}
+
+	category.ParentID = input.ParentID
+	if category.ParentID == nil {
+		return 0, nil
+	}
+	if err := validateCategory(db, category.ParentID); err != nil {
+		return fiber.StatusBadRequest, errors.New("parent category not found")
+	}
+	if category.ID != 0 {
+		descendants, err := categoryWithDescendants(db, category.ID)
+		if err != nil {
+			return fiber.StatusInternalServerError, errors.New("could not check category parent")
+		}
+		for _, id := range descendants {
+			if id == *category.ParentID {
+				return fiber.StatusBadRequest, errors.New("a category cannot be moved under itself")
+			}
+		}
+	}
+	return 0, nil
+}
+
+// DeleteCategory godoc
+// @Summary Delete a category
+// @Description Remove a category without subcategories (admin only). Its products move to the parent category.
+// @Tags admin-categories
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Category ID"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/admin/categories/{id} [delete]
+func (h *CategoryHandler) DeleteCategory(c *fiber.Ctx) error {
+	db := h.db
+
+	var category models.Category
+	if err := db.First(&category, c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Category not found"})
+	}
+
+	var children int64
+	db.Model(&models.Category{}).Where("parent_id = ?", category.ID).Count(&children)
+	if children > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Category has subcategories, move or delete them first",
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Product{}).Where("category_id = ?", category.ID).Update("category_id", category.ParentID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&category).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete category"})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,277 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/inventory"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"gorm.io/gorm"
+)
+
+// CheckoutHandler serves the checkout endpoints
+type CheckoutHandler struct {
+	db *gorm.DB
+}
+
+// NewCheckoutHandler returns the checkout endpoints backed by db
+func NewCheckoutHandler(db *gorm.DB) *CheckoutHandler {
+	return &CheckoutHandler{db: db}
+}
+
+// placeCheckoutGroup splits the items across their stores and creates one
+// order per store under a single checkout group inside tx
+func placeCheckoutGroup(ctx context.Context, tx *gorm.DB, userID uint, items []models.OrderItem) (*models.CheckoutGroup, error) {
+	if len(items) == 0 {
+		return nil, &orderError{status: fiber.StatusBadRequest, message: "Order has no items"}
+	}
+
+	productIDs := make([]uint, 0, len(items))
+	for _, item := range items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+
+	var products []models.Product
+	if err := tx.Select("id", "store_id", "currency").Where("id IN ?", productIDs).Find(&products).Error; err != nil {
+		return nil, err
+	}
+	productsByID := map[uint]models.Product{}
+	for _, product := range products {
+		productsByID[product.ID] = product
+	}
+
+	group := models.CheckoutGroup{UserID: userID}
+	itemsByStore := map[uint][]models.OrderItem{}
+	for _, item := range items {
+		product, ok := productsByID[item.ProductID]
+		if !ok {
+			return nil, &orderError{status: fiber.StatusBadRequest, message: "Product not found: " + strconv.Itoa(int(item.ProductID))}
+		}
+
+		// One payment covers the whole checkout, so it can only be in one currency
+		if group.Currency == "" {
+			group.Currency = product.Currency
+		} else if group.Currency != product.Currency {
+			return nil, &orderError{status: fiber.StatusBadRequest, message: "All products must be priced in the same currency"}
+		}
+
+		itemsByStore[product.StoreID] = append(itemsByStore[product.StoreID], item)
+	}
+
+	if err := tx.Create(&group).Error; err != nil {
+		return nil, err
+	}
+
+	storeIDs := make([]uint, 0, len(itemsByStore))
+	for storeID := range itemsByStore {
+		storeIDs = append(storeIDs, storeID)
+	}
+	sort.Slice(storeIDs, func(i, j int) bool { return storeIDs[i] < storeIDs[j] })
+
+	// Report stock problems for every store at once rather than one store at a time
+	var stockErrors []*inventory.InsufficientStockError
+	for _, storeID := range storeIDs {
+		order, err := placeOrder(ctx, tx, userID, itemsByStore[storeID])
+		if err != nil {
+			var orderErr *orderError
+			if errors.As(err, &orderErr) && len(orderErr.items) > 0 {
+				stockErrors = append(stockErrors, orderErr.items...)
+				continue
+			}
+			return nil, err
+		}
+
+		if err := tx.Model(order).Update("checkout_group_id", group.ID).Error; err != nil {
+			return nil, err
+		}
+		group.TotalAmount += order.TotalAmount
+	}
+
+	if len(stockErrors) > 0 {
+		return nil, &orderError{status: fiber.StatusConflict, message: "Insufficient stock", items: stockErrors}
+	}
+
+	if err := tx.Model(&group).Update("total_amount", group.TotalAmount).Error; err != nil {
+		return nil, err
+	}
+
+	return &group, nil
+}
+
+// loadCheckoutGroup fetches a buyer's checkout group with its orders and
+// works out the group status from the orders' statuses
+func loadCheckoutGroup(db *gorm.DB, userID uint, groupID interface{}) (*models.CheckoutGroup, error) {
+	var group models.CheckoutGroup
+	err := db.Preload("Orders", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Orders.Items.Product").
+		Preload("Orders.Items.Variant").
+		Where("id = ? AND user_id = ?", groupID, userID).
+		First(&group).Error
+	if err != nil {
+		return nil, err
+	}
+
+	setCheckoutStatus(&group)
+	return &group, nil
+}
+
+func setCheckoutStatus(group *models.CheckoutGroup) {
+	group.Status = ""
+	for _, order := range group.Orders {
+		if group.Status == "" {
+			group.Status = string(order.Status)
+		} else if group.Status != string(order.Status) {
+			group.Status = models.CheckoutStatusMixed
+			return
+		}
+	}
+}
+
+// CreateCheckout godoc
+// @Summary Create a checkout
+// @Description Order products from one or more stores at once. One order is created per store and they are paid for together.
+// @Tags checkouts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param checkout body models.CreateCheckoutRequest true "Items to order"
+// @Success 201 {object} models.CheckoutGroup
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/checkouts [post]
+func (h *CheckoutHandler) CreateCheckout(c *fiber.Ctx) error {
+	db := h.db
+	user := c.Locals("user").(*models.User)
+
+	var checkoutRequest models.CreateCheckoutRequest
+	if err := c.BodyParser(&checkoutRequest); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	var group *models.CheckoutGroup
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		group, err = placeCheckoutGroup(c.UserContext(), tx, user.ID, checkoutRequest.Items)
+		return err
+	})
+	if err != nil {
+		return orderErrorResponse(c, err)
+	}
+
+	group, err = loadCheckoutGroup(db, user.ID, group.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch created checkout",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(group)
+}
+
+// GetUserCheckouts godoc
+// @Summary Get user checkouts
+// @Description Get the authenticated user's checkouts with the status of each store's order
+// @Tags checkouts
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number"
+// @Param per_page query int false "Items per page"
+// @Success 200 {object} PaginationResponse{data=[]models.CheckoutGroup}
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/v1/checkouts [get]
+func (h *CheckoutHandler) GetUserCheckouts(c *fiber.Ctx) error {
+	db := h.db
+	user := c.Locals("user").(*models.User)
+	page, perPage := paginate(c)
+
+	var groups []models.CheckoutGroup
+	var total int64
+
+	db.Model(&models.CheckoutGroup{}).Where("user_id = ?", user.ID).Count(&total)
+	err := db.Preload("Orders", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Where("user_id = ?", user.ID).
+		Order("created_at DESC").
+		Offset((page - 1) * perPage).Limit(perPage).
+		Find(&groups).Error
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch checkouts",
+		})
+	}
+
+	for i := range groups {
+		setCheckoutStatus(&groups[i])
+	}
+
+	return c.JSON(NewPaginationResponse(groups, total, page, perPage))
+}
+
+// GetCheckout godoc
+// @Summary Get checkout details
+// @Description Get a checkout with every store's order and its status
+// @Tags checkouts
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Checkout ID"
+// @Success 200 {object} models.CheckoutGroup
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/checkouts/{id} [get]
+func (h *CheckoutHandler) GetCheckout(c *fiber.Ctx) error {
+	db := h.db
+	user := c.Locals("user").(*models.User)
+
+	group, err := loadCheckoutGroup(db, user.ID, c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Checkout not found",
+		})
+	}
+
+	return c.JSON(group)
+}
+
+// PayCheckout godoc
+// @Summary Pay for a checkout
+// @Description Start one payment covering every order of a checkout
+// @Tags checkouts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Checkout ID"
+// @Param payment body models.InitializePaymentRequest true "Payment provider and callback URL"
+// @Success 200 {object} models.Payment "The pending payment already started"
+// @Success 201 {object} models.Payment
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 502 {object} models.ErrorResponse
+// @Router /api/v1/checkouts/{id}/pay [post]
+func (h *CheckoutHandler) PayCheckout(c *fiber.Ctx) error {
+	db := h.db
+	user := c.Locals("user").(*models.User)
+
+	group, err := loadCheckoutGroup(db, user.ID, c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Checkout not found"})
+	}
+
+	if group.PaymentID != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Checkout has already been paid"})
+	}
+	if group.Status != string(models.OrderStatusPending) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Checkout is not awaiting payment"})
+	}
+
+	return startPayment(c, db, user, models.Payment{
+		CheckoutGroupID: &group.ID,
+		Amount:          group.TotalAmount,
+		Currency:        group.Currency,
+	}, map[string]interface{}{"checkout_group_id": group.ID})
+}
@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/domains"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/rbac"
+	"gorm.io/gorm"
+)
+
+// DomainHandler serves the custom domain endpoints
+type DomainHandler struct {
+	db *gorm.DB
+}
+
+// NewDomainHandler returns the custom domain endpoints backed by db
+func NewDomainHandler(db *gorm.DB) *DomainHandler {
+	return &DomainHandler{db: db}
+}
+
+// StoreDomainSetup is a store's custom domain with the DNS TXT record the
+// vendor publishes to verify it
+type StoreDomainSetup struct {
+	models.StoreDomain
+	RecordType  string `json:"record_type"`
+	RecordName  string `json:"record_name"`
+	RecordValue string `json:"record_value"`
+}
+
+func domainSetup(domain models.StoreDomain) StoreDomainSetup {
+	return StoreDomainSetup{
+		StoreDomain: domain,
+		RecordType:  "TXT",
+		RecordName:  domains.RecordName(domain.Domain),
+		RecordValue: domains.RecordValue(domain.Token),
+	}
+}
+
+// ownedStoreDomain loads a domain of the vendor's store, writing the error
+// response when there is none
+func ownedStoreDomain(c *fiber.Ctx, db *gorm.DB) (*models.StoreDomain, error) {
+	store, err := memberStore(c, db, rbac.StorePermissionSettings)
+	if store == nil {
+		return nil, err
+	}
+
+	var domain models.StoreDomain
+	if err := db.Where("id = ? AND store_id = ?", c.Params("domainId"), store.ID).First(&domain).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Domain not found"})
+	}
+	return &domain, nil
+}
+
+// GetStoreDomains godoc
+// @Summary List store custom domains
+// @Description List the store's custom domains with their verification status and the DNS record each needs
+// @Tags stores
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Store ID"
+// @Success 200 {array} StoreDomainSetup
+// @Failure 403 {object} models.ErrorResponse
+// @Router /stores/{id}/domains [get]
+func (h *DomainHandler) GetStoreDomains(c *fiber.Ctx) error {
+	db := h.db
+	store, err := memberStore(c, db, rbac.StorePermissionSettings)
+	if store == nil {
+		return err
+	}
+
+	var storeDomains []models.StoreDomain
+	if err := db.Where("store_id = ?", store.ID).Order("id").Find(&storeDomains).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch domains"})
+	}
+
+	setups := make([]StoreDomainSetup, len(storeDomains))
+	for i, domain := range storeDomains {
+		setups[i] = domainSetup(domain)
+	}
+	return c.JSON(setups)
+}
+
+// AddStoreDomain godoc
+// @Summary Add a custom domain
+// @Description Add a domain to serve the store's storefront on. The domain stays pending until the returned TXT record is found in its DNS, which is checked periodically or on request. Several stores may claim a domain, the first one verified gets it.
+// @Tags stores
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Store ID"
+// @Param input body models.AddStoreDomainRequest true "Domain"
+// @Success 201 {object} StoreDomainSetup
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /stores/{id}/domains [post]
+func (h *DomainHandler) AddStoreDomain(c *fiber.Ctx) error {
+	db := h.db
+	store, err := memberStore(c, db, rbac.StorePermissionSettings)
+	if store == nil {
+		return err
+	}
+
+	var input models.AddStoreDomainRequest
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	name, err := domains.Normalize(input.Domain)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// Other stores may claim a domain until one of them verifies it
+	var existing []models.StoreDomain
+	if err := db.Where("domain = ? AND (store_id = ? OR status = ?)", name, store.ID, models.DomainStatusVerified).
+		Find(&existing).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to add domain"})
+	}
+	for _, other := range existing {
+		if other.StoreID == store.ID {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Domain has already been added to this store"})
+		}
+	}
+	if len(existing) > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Domain is already in use by a store"})
+	}
+
+	token, err := domains.NewToken()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to add domain"})
+	}
+	now := time.Now()
+	domain := models.StoreDomain{
+		StoreID:     store.ID,
+		Domain:      name,
+		Token:       token,
+		Status:      models.DomainStatusPending,
+		NextCheckAt: &now,
+	}
+	if err := db.Create(&domain).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to add domain"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(domainSetup(domain))
+}
+
+// VerifyStoreDomain godoc
+// @Summary Verify a custom domain now
+// @Description Look up the domain's TXT record without waiting for the next scheduled check. A domain that failed verification starts over.
+// @Tags stores
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Store ID"
+// @Param domainId path string true "Domain ID"
+// @Success 200 {object} StoreDomainSetup
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /stores/{id}/domains/{domainId}/verify [post]
+func (h *DomainHandler) VerifyStoreDomain(c *fiber.Ctx) error {
+	db := h.db
+	domain, err := ownedStoreDomain(c, db)
+	if domain == nil {
+		return err
+	}
+
+	if err := domains.Verify(context.Background(), db, domain); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to verify domain"})
+	}
+	return c.JSON(domainSetup(*domain))
+}
+
+// DeleteStoreDomain godoc
+// @Summary Remove a custom domain
+// @Description Stop serving the store's storefront on a domain
+// @Tags stores
+// @Security BearerAuth
+// @Param id path string true "Store ID"
+// @Param domainId path string true "Domain ID"
+// @Success 204 "No Content"
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /stores/{id}/domains/{domainId} [delete]
+func (h *DomainHandler) DeleteStoreDomain(c *fiber.Ctx) error {
+	db := h.db
+	domain, err := ownedStoreDomain(c, db)
+	if domain == nil {
+		return err
+	}
+
+	if err := db.Delete(domain).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to remove domain"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/repository"
+	"github.com/theHoracle/whatstore-api/app/services"
+)
+
+var serviceErrorStatus = map[services.Kind]int{
+	services.KindInvalid:   fiber.StatusBadRequest,
+	services.KindForbidden: fiber.StatusForbidden,
+	services.KindNotFound:  fiber.StatusNotFound,
+	services.KindConflict:  fiber.StatusConflict,
+}
+
+// serviceError writes the response for an error from the service layer.
+// Broken business rules are reported to the caller, anything else is a 500
+// with the fallback message.
+func serviceError(c *fiber.Ctx, err error, fallback string) error {
+	var ruleErr *services.Error
+	if errors.As(err, &ruleErr) {
+		return c.Status(serviceErrorStatus[ruleErr.Kind]).JSON(fiber.Map{"error": ruleErr.Message})
+	}
+
+	var stockErr *repository.StockError
+	if errors.As(err, &stockErr) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Insufficient stock",
+			"items": stockErr.Items,
+		})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fallback})
+}
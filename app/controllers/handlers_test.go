@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/repository/memory"
+	"github.com/theHoracle/whatstore-api/app/services"
+)
+
+// testAPI serves the handlers built on in-memory repositories, on the same
+// paths as the real routes. Requests are made as the user whose ID is in
+// the X-User header.
+type testAPI struct {
+	t   *testing.T
+	app *fiber.App
+	db  *memory.DB
+
+	owner    *models.User // Owns store
+	buyer    *models.User
+	stranger *models.User
+	vendor   *models.Vendor
+	store    *models.Store
+	category *models.Category
+	product  *models.Product // 10 in stock at 100
+	shirt    *models.Product // Sold by variant
+	small    *models.ProductVariant
+	service  *models.Service
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	db := memory.New()
+	api := &testAPI{t: t, db: db}
+	api.seed()
+
+	users, stores, products, offered, orders := db.Users(), db.Stores(), db.Products(), db.Services(), db.Orders()
+	viewers := services.NewViewers(stores, orders, offered)
+	storeHandler := NewStoreHandler(services.NewStores(stores, users))
+	productHandler := NewProductHandler(services.NewProducts(products, stores), viewers, nil)
+	serviceHandler := NewServiceHandler(services.NewServices(offered, stores), viewers, nil)
+	orderHandler := NewOrderHandler(services.NewOrders(orders, products, stores))
+	userHandler := NewUserHandler(services.NewUsers(users))
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		if header := c.Get("X-User"); header != "" {
+			id, _ := strconv.Atoi(header)
+			user, err := users.Get(c.UserContext(), uint(id))
+			if err != nil {
+				return c.SendStatus(fiber.StatusUnauthorized)
+			}
+			c.Locals("user", user)
+		}
+		return c.Next()
+	})
+
+	v1 := app.Group("/api/v1")
+	v1.Get("/products/:id", productHandler.GetProduct)
+	v1.Get("/services/:id", serviceHandler.GetService)
+	v1.Get("/stores/:storeId/services", serviceHandler.GetStoreServices)
+
+	v1.Get("/stores/check-url", storeHandler.CheckStoreUrlAvailability)
+	v1.Post("/stores", storeHandler.CreateStore)
+	v1.Put("/stores/:id", storeHandler.UpdateStore)
+	v1.Delete("/stores/:id", storeHandler.DeleteStore)
+	v1.Get("/stores/:id", storeHandler.GetStore)
+	v1.Get("/stores/vendor/:vendorId", storeHandler.GetAllStores)
+	v1.Get("/stores/:storeId/orders", orderHandler.GetStoreOrders)
+
+	v1.Post("/stores/:storeId/products", productHandler.CreateProduct)
+	v1.Put("/stores/:storeId/products/:id", productHandler.UpdateProduct)
+	v1.Delete("/stores/:storeId/products/:id", productHandler.DeleteProduct)
+
+	v1.Post("/stores/:storeId/services", serviceHandler.CreateService)
+	v1.Put("/stores/:storeId/services/:id", serviceHandler.UpdateService)
+	v1.Delete("/stores/:storeId/services/:id", serviceHandler.DeleteService)
+
+	v1.Post("/orders", orderHandler.CreateOrder)
+	v1.Get("/orders", orderHandler.GetUserOrders)
+	v1.Get("/orders/:id", orderHandler.GetOrder)
+	v1.Get("/orders/:id/history", orderHandler.GetOrderHistory)
+	v1.Put("/orders/:id/status", orderHandler.UpdateOrderStatus)
+
+	v1.Get("/users/me", userHandler.GetUserProfile)
+	v1.Put("/users/me", userHandler.UpdateUserProfile)
+
+	api.app = app
+	return api
+}
+
+func (api *testAPI) seed() {
+	db := api.db
+	api.owner = &models.User{Name: "Ada", Email: "ada@example.com", Role: models.RoleVendor}
+	api.buyer = &models.User{Name: "Bola", Email: "bola@example.com", Role: models.RoleBuyer}
+	api.stranger = &models.User{Name: "Chidi", Email: "chidi@example.com", Role: models.RoleVendor}
+	db.AddUser(api.owner)
+	db.AddUser(api.buyer)
+	db.AddUser(api.stranger)
+
+	api.vendor = &models.Vendor{UserID: api.owner.ID, IsActive: true}
+	db.AddVendor(api.vendor)
+	db.AddVendor(&models.Vendor{UserID: api.stranger.ID, IsActive: true})
+
+	api.store = &models.Store{VendorID: api.vendor.ID, Name: "Ada's", StoreUrl: "adas", Timezone: "Africa/Lagos"}
+	db.AddStore(api.store, api.owner)
+
+	api.category = &models.Category{Name: "Shoes", Slug: "shoes"}
+	db.AddCategory(api.category)
+
+	api.product = &models.Product{StoreID: api.store.ID, Name: "Sneakers", Price: 100, Stock: 10}
+	db.AddProduct(api.product)
+
+	price := 60.0
+	api.shirt = &models.Product{StoreID: api.store.ID, Name: "Shirt", Price: 50, Stock: 3,
+		Variants: []models.ProductVariant{{Title: "S", Stock: 2, Price: &price}, {Title: "M", Stock: 1}}}
+	db.AddProduct(api.shirt)
+	api.small = &api.shirt.Variants[0]
+
+	api.service = &models.Service{StoreID: api.store.ID, Name: "Fitting", Rate: 20, Currency: "NGN"}
+	db.AddService(api.service)
+}
+
+// addMember gives user an active role in the test store
+func (api *testAPI) addMember(user *models.User, role models.StoreRole) {
+	api.db.AddMember(&models.StoreMember{
+		StoreID: api.store.ID,
+		Email:   user.Email,
+		UserID:  &user.ID,
+		Role:    role,
+		Status:  models.MemberStatusActive,
+	})
+}
+
+// do makes a request as user, nil for none, decoding the response into out
+// when set, and returns the status code
+func (api *testAPI) do(method, path string, user *models.User, body any, out any) int {
+	api.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			api.t.Fatal(err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if user != nil {
+		req.Header.Set("X-User", strconv.Itoa(int(user.ID)))
+	}
+
+	resp, err := api.app.Test(req, -1)
+	if err != nil {
+		api.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		raw, _ := io.ReadAll(resp.Body)
+		if err := json.Unmarshal(raw, out); err != nil {
+			api.t.Fatalf("%s %s: decoding %q: %v", method, path, raw, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// expect checks a request answers with status, returning the error message
+// of failed requests
+func (api *testAPI) expect(status int, method, path string, user *models.User, body any, out any) string {
+	api.t.Helper()
+
+	var failure struct {
+		Error string `json:"error"`
+	}
+	if out == nil && status >= 400 {
+		out = &failure
+	}
+	if got := api.do(method, path, user, body, out); got != status {
+		api.t.Fatalf("%s %s: status %d, want %d", method, path, got, status)
+	}
+	return failure.Error
+}
+
+func id(n uint) string {
+	return strconv.Itoa(int(n))
+}
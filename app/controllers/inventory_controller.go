@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/rbac"
+	"gorm.io/gorm"
+)
+
+// InventoryHandler serves the inventory endpoints
+type InventoryHandler struct {
+	db *gorm.DB
+}
+
+// NewInventoryHandler returns the inventory endpoints backed by db
+func NewInventoryHandler(db *gorm.DB) *InventoryHandler {
+	return &InventoryHandler{db: db}
+}
+
+// GetStoreInventoryLedger godoc
+// @Summary Get store inventory ledger
+// @Description Get every stock change for a store's products, newest first, with the reason it happened
+// @Tags store-products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param storeId path string true "Store ID"
+// @Param product_id query int false "Only show changes for this product"
+// @Param page query int false "Page number"
+// @Param per_page query int false "Items per page"
+// @Success 200 {object} PaginationResponse{data=[]models.InventoryLedgerEntry}
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/v1/stores/{storeId}/inventory [get]
+func (h *InventoryHandler) GetStoreInventoryLedger(c *fiber.Ctx) error {
+	db := h.db
+	user := c.Locals("user").(*models.User)
+	storeID, err := c.ParamsInt("storeId")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid store ID",
+		})
+	}
+
+	if err := authorizeStore(c.UserContext(), db, user, uint(storeID), rbac.StorePermissionOrders); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	page, perPage := paginate(c)
+
+	query := db.Model(&models.InventoryLedgerEntry{}).Where("store_id = ?", storeID)
+	if productID := c.QueryInt("product_id"); productID > 0 {
+		query = query.Where("product_id = ?", productID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var entries []models.InventoryLedgerEntry
+	if err := query.Order("created_at DESC, id DESC").Offset((page - 1) * perPage).Limit(perPage).Find(&entries).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch inventory ledger",
+		})
+	}
+
+	return c.JSON(NewPaginationResponse(entries, total, page, perPage))
+}
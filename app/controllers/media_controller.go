@@ -0,0 +1,235 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"mime/multipart"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/media"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/rbac"
+	"gorm.io/gorm"
+)
+
+// MediaHandler serves the media endpoints
+type MediaHandler struct {
+	db *gorm.DB
+}
+
+// NewMediaHandler returns the media endpoints backed by db
+func NewMediaHandler(db *gorm.DB) *MediaHandler {
+	return &MediaHandler{db: db}
+}
+
+// readUpload reads an uploaded file, refusing files over the upload limit
+// without reading them whole
+func readUpload(file *multipart.FileHeader) ([]byte, error) {
+	if file.Size > media.MaxUploadSize {
+		return nil, media.ErrTooLarge
+	}
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, media.MaxUploadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > media.MaxUploadSize {
+		return nil, media.ErrTooLarge
+	}
+	return data, nil
+}
+
+// uploadErrorResponse maps an upload error to its response
+func uploadErrorResponse(c *fiber.Ctx, filename string, err error) error {
+	switch {
+	case errors.Is(err, media.ErrTooLarge):
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": filename + ": " + err.Error()})
+	case errors.Is(err, media.ErrUnsupportedType), errors.Is(err, media.ErrTooManyPixels):
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{"error": filename + ": " + err.Error()})
+	case errors.Is(err, media.ErrNotConfigured):
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Uploads are not available"})
+	default:
+		log.Printf("Upload of %s failed: %v", filename, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to upload " + filename})
+	}
+}
+
+// UploadStoreMedia godoc
+// @Summary Upload store images
+// @Description Upload up to 5 JPEG, PNG or WebP images of at most 8 MB for the store's products and services. Each is turned upright, stripped of EXIF data and scaled to fit 2048 pixels, with medium and thumbnail variants in its own format, and in WebP when that is smaller. Use the returned URLs in products, variants and services; uploads nothing uses are deleted after a day.
+// @Tags media
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Store ID"
+// @Param files formData file true "Images"
+// @Success 201 {array} models.Media
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 415 {object} models.ErrorResponse
+// @Router /stores/{id}/media [post]
+func (h *MediaHandler) UploadStoreMedia(c *fiber.Ctx) error {
+	db := h.db
+	store, err := memberStore(c, db, rbac.StorePermissionCatalog)
+	if store == nil {
+		return err
+	}
+	if !media.Enabled() {
+		return uploadErrorResponse(c, "", media.ErrNotConfigured)
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Expected a multipart form with images in files"})
+	}
+	files := form.File["files"]
+	if len(files) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "No images uploaded"})
+	}
+	if len(files) > media.MaxFilesPerUpload {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Too many images in one upload"})
+	}
+
+	uploaded := make([]models.Media, 0, len(files))
+	for _, file := range files {
+		upload, err := uploadFile(c, db, store.ID, file)
+		if err != nil {
+			// Keep the upload all or nothing
+			for i := range uploaded {
+				if err := media.Delete(context.Background(), db, &uploaded[i]); err != nil {
+					log.Printf("Warning: could not delete upload %d: %v", uploaded[i].ID, err)
+				}
+			}
+			return uploadErrorResponse(c, file.Filename, err)
+		}
+		uploaded = append(uploaded, *upload)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(uploaded)
+}
+
+func uploadFile(c *fiber.Ctx, db *gorm.DB, storeID uint, file *multipart.FileHeader) (*models.Media, error) {
+	data, err := readUpload(file)
+	if err != nil {
+		return nil, err
+	}
+	return media.Upload(c.Context(), db, storeID, file.Filename, data)
+}
+
+// UploadStoreLogo godoc
+// @Summary Upload a store logo
+// @Description Upload a JPEG, PNG or WebP image of at most 8 MB as the store's logo. The previous logo's upload is deleted once nothing uses it.
+// @Tags media
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Store ID"
+// @Param file formData file true "Logo"
+// @Success 200 {object} models.Store
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 415 {object} models.ErrorResponse
+// @Router /stores/{id}/logo [put]
+func (h *MediaHandler) UploadStoreLogo(c *fiber.Ctx) error {
+	db := h.db
+	store, err := memberStore(c, db, rbac.StorePermissionSettings)
+	if store == nil {
+		return err
+	}
+	if !media.Enabled() {
+		return uploadErrorResponse(c, "", media.ErrNotConfigured)
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Expected a multipart form with the logo in file"})
+	}
+	upload, err := uploadFile(c, db, store.ID, file)
+	if err != nil {
+		return uploadErrorResponse(c, file.Filename, err)
+	}
+
+	if err := db.Model(store).Update("store_logo", upload.URL).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update store"})
+	}
+	return c.JSON(store)
+}
+
+// GetStoreMedia godoc
+// @Summary List store images
+// @Description List the images uploaded for the store, newest first
+// @Tags media
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Store ID"
+// @Param page query int false "Page number"
+// @Param per_page query int false "Items per page"
+// @Success 200 {object} PaginationResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /stores/{id}/media [get]
+func (h *MediaHandler) GetStoreMedia(c *fiber.Ctx) error {
+	db := h.db
+	store, err := memberStore(c, db, rbac.StorePermissionCatalog)
+	if store == nil {
+		return err
+	}
+
+	page, perPage := paginate(c)
+	var total int64
+	var uploads []models.Media
+	db.Model(&models.Media{}).Where("store_id = ?", store.ID).Count(&total)
+	if err := db.Preload("Variants").Where("store_id = ?", store.ID).Order("id DESC").
+		Offset((page - 1) * perPage).Limit(perPage).
+		Find(&uploads).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch images"})
+	}
+
+	return c.JSON(NewPaginationResponse(uploads, total, page, perPage))
+}
+
+// DeleteStoreMedia godoc
+// @Summary Delete a store image
+// @Description Delete an uploaded image and its variants. Images a product, variant, service or the store logo still uses cannot be deleted.
+// @Tags media
+// @Security BearerAuth
+// @Param id path string true "Store ID"
+// @Param mediaId path string true "Media ID"
+// @Success 204 "No Content"
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /stores/{id}/media/{mediaId} [delete]
+func (h *MediaHandler) DeleteStoreMedia(c *fiber.Ctx) error {
+	db := h.db
+	store, err := memberStore(c, db, rbac.StorePermissionCatalog)
+	if store == nil {
+		return err
+	}
+
+	var upload models.Media
+	if err := db.Preload("Variants").Where("id = ? AND store_id = ?", c.Params("mediaId"), store.ID).First(&upload).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Image not found"})
+	}
+
+	inUse, err := media.InUse(db, &upload)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete image"})
+	}
+	if inUse {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Image is still in use"})
+	}
+
+	if err := media.Delete(c.Context(), db, &upload); err != nil {
+		return uploadErrorResponse(c, upload.Filename, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,349 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/rbac"
+	"github.com/theHoracle/whatstore-api/app/repository"
+	"github.com/theHoracle/whatstore-api/app/services"
+	"gorm.io/gorm"
+)
+
+// MemberHandler serves the store member endpoints
+type MemberHandler struct {
+	db *gorm.DB
+}
+
+// NewMemberHandler returns the store member endpoints backed by db
+func NewMemberHandler(db *gorm.DB) *MemberHandler {
+	return &MemberHandler{db: db}
+}
+
+var (
+	errInvalidRole     = errors.New("role must be manager, order_handler or catalog_editor")
+	errOwnerMembership = errors.New("the store owner's membership cannot be changed")
+)
+
+// authorizeStore checks the user is an active member of the store whose
+// role allows permission
+func authorizeStore(ctx context.Context, db *gorm.DB, user *models.User, storeID uint, permission rbac.StorePermission) error {
+	return services.AuthorizeStore(ctx, repository.NewStores(db), user, storeID, permission)
+}
+
+// memberStore loads the store in the path, writing the error response when
+// the user's membership does not allow permission
+func memberStore(c *fiber.Ctx, db *gorm.DB, permission rbac.StorePermission) (*models.Store, error) {
+	user := c.Locals("user").(*models.User)
+	storeID, err := c.ParamsInt("id")
+	if err != nil {
+		return nil, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid store ID"})
+	}
+
+	if err := authorizeStore(c.UserContext(), db, user, uint(storeID), permission); err != nil {
+		return nil, c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var store models.Store
+	if err := db.First(&store, storeID).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Store not found"})
+	}
+	return &store, nil
+}
+
+// storeMember loads a member of the store in the path for its owner
+func storeMember(c *fiber.Ctx, db *gorm.DB) (*models.StoreMember, error) {
+	store, err := memberStore(c, db, rbac.StorePermissionMembers)
+	if store == nil {
+		return nil, err
+	}
+
+	var member models.StoreMember
+	if err := db.Where("id = ? AND store_id = ?", c.Params("memberId"), store.ID).First(&member).Error; err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Member not found"})
+	}
+	if member.Role == models.StoreRoleOwner {
+		return nil, c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": errOwnerMembership.Error()})
+	}
+	return &member, nil
+}
+
+// staffRole checks a role can be given to an invited member
+func staffRole(role string) (models.StoreRole, error) {
+	storeRole := models.StoreRole(role)
+	if storeRole == models.StoreRoleOwner || !rbac.IsValidStoreRole(storeRole) {
+		return "", errInvalidRole
+	}
+	return storeRole, nil
+}
+
+// leaveStore removes a membership and makes staff who are left without a
+// store buyers again
+func leaveStore(db *gorm.DB, member *models.StoreMember) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(member).Error; err != nil {
+			return err
+		}
+		if member.UserID == nil {
+			return nil
+		}
+		return tx.Model(&models.User{}).
+			Where("id = ? AND role = ?", *member.UserID, models.RoleVendorStaff).
+			Where("NOT EXISTS (SELECT 1 FROM store_members WHERE user_id = users.id AND status = ?)", models.MemberStatusActive).
+			Update("role", models.RoleBuyer).Error
+	})
+}
+
+// GetStoreMembers godoc
+// @Summary List store members
+// @Description List the store's owner, members and pending invites
+// @Tags store-members
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Store ID"
+// @Success 200 {array} models.StoreMember
+// @Failure 403 {object} models.ErrorResponse
+// @Router /stores/{id}/members [get]
+func (h *MemberHandler) GetStoreMembers(c *fiber.Ctx) error {
+	db := h.db
+	store, err := memberStore(c, db, rbac.StorePermissionMembers)
+	if store == nil {
+		return err
+	}
+
+	var members []models.StoreMember
+	if err := db.Where("store_id = ?", store.ID).Order("id").Find(&members).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch members"})
+	}
+	return c.JSON(members)
+}
+
+// InviteStoreMember godoc
+// @Summary Invite a store member
+// @Description Invite someone by email to help run the store as a manager, order handler or catalog editor. They join once they sign in with that email and accept.
+// @Tags store-members
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Store ID"
+// @Param input body models.InviteStoreMemberRequest true "Invite"
+// @Success 201 {object} models.StoreMember
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /stores/{id}/members [post]
+func (h *MemberHandler) InviteStoreMember(c *fiber.Ctx) error {
+	db := h.db
+	user := c.Locals("user").(*models.User)
+	store, err := memberStore(c, db, rbac.StorePermissionMembers)
+	if store == nil {
+		return err
+	}
+
+	var input models.InviteStoreMemberRequest
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	address, err := mail.ParseAddress(strings.TrimSpace(input.Email))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid email address"})
+	}
+	role, err := staffRole(input.Role)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	email := strings.ToLower(address.Address)
+	var existing int64
+	db.Model(&models.StoreMember{}).Where("store_id = ? AND email = ?", store.ID, email).Count(&existing)
+	if existing > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "This email is already a member or invited"})
+	}
+
+	member := models.StoreMember{
+		StoreID:     store.ID,
+		Email:       email,
+		Role:        role,
+		Status:      models.MemberStatusInvited,
+		InvitedByID: &user.ID,
+	}
+	if err := db.Create(&member).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to invite member"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(member)
+}
+
+// UpdateStoreMember godoc
+// @Summary Change a store member's role
+// @Description Change the role of a member or pending invite. The owner's role cannot be changed.
+// @Tags store-members
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Store ID"
+// @Param memberId path string true "Member ID"
+// @Param input body models.UpdateStoreMemberRequest true "Role"
+// @Success 200 {object} models.StoreMember
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /stores/{id}/members/{memberId} [put]
+func (h *MemberHandler) UpdateStoreMember(c *fiber.Ctx) error {
+	db := h.db
+	member, err := storeMember(c, db)
+	if member == nil {
+		return err
+	}
+
+	var input models.UpdateStoreMemberRequest
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	role, err := staffRole(input.Role)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := db.Model(member).Update("role", role).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update member"})
+	}
+	return c.JSON(member)
+}
+
+// RemoveStoreMember godoc
+// @Summary Revoke a store member
+// @Description Remove a member's access to the store or withdraw a pending invite. The owner cannot be removed.
+// @Tags store-members
+// @Security BearerAuth
+// @Param id path string true "Store ID"
+// @Param memberId path string true "Member ID"
+// @Success 204 "No Content"
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /stores/{id}/members/{memberId} [delete]
+func (h *MemberHandler) RemoveStoreMember(c *fiber.Ctx) error {
+	db := h.db
+	member, err := storeMember(c, db)
+	if member == nil {
+		return err
+	}
+
+	if err := leaveStore(db, member); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to remove member"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// userMembership loads a membership of the signed-in user, matching pending
+// invites by email
+func userMembership(c *fiber.Ctx, db *gorm.DB, user *models.User) (*models.StoreMember, error) {
+	var member models.StoreMember
+	err := db.Where("id = ?", c.Params("id")).
+		Where("user_id = ? OR (status = ? AND email = ?)", user.ID, models.MemberStatusInvited, strings.ToLower(user.Email)).
+		First(&member).Error
+	if err != nil {
+		return nil, c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Membership not found"})
+	}
+	return &member, nil
+}
+
+// GetUserMemberships godoc
+// @Summary List my store memberships
+// @Description List the stores the user is a member of and the invites waiting for them
+// @Tags store-members
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "invited or active"
+// @Success 200 {array} models.StoreMember
+// @Router /users/me/memberships [get]
+func (h *MemberHandler) GetUserMemberships(c *fiber.Ctx) error {
+	db := h.db
+	user := c.Locals("user").(*models.User)
+
+	query := db.Preload("Store").
+		Where("user_id = ? OR (status = ? AND email = ?)", user.ID, models.MemberStatusInvited, strings.ToLower(user.Email))
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var memberships []models.StoreMember
+	if err := query.Order("id").Find(&memberships).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch memberships"})
+	}
+	return c.JSON(memberships)
+}
+
+// AcceptStoreInvite godoc
+// @Summary Accept a store invite
+// @Description Join a store the user was invited to by email
+// @Tags store-members
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Membership ID"
+// @Success 200 {object} models.StoreMember
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /users/me/memberships/{id}/accept [post]
+func (h *MemberHandler) AcceptStoreInvite(c *fiber.Ctx) error {
+	db := h.db
+	user := c.Locals("user").(*models.User)
+	member, err := userMembership(c, db, user)
+	if member == nil {
+		return err
+	}
+	if member.Status != models.MemberStatusInvited {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Invite was already accepted"})
+	}
+
+	now := time.Now()
+	member.UserID = &user.ID
+	member.Status = models.MemberStatusActive
+	member.AcceptedAt = &now
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(member).Select("user_id", "status", "accepted_at").Updates(member).Error; err != nil {
+			return err
+		}
+		// Buyers become staff, vendors and platform roles are kept
+		return tx.Model(&models.User{}).
+			Where("id = ? AND role = ?", user.ID, models.RoleBuyer).
+			Update("role", models.RoleVendorStaff).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to accept invite"})
+	}
+	return c.JSON(member)
+}
+
+// LeaveStoreMembership godoc
+// @Summary Decline an invite or leave a store
+// @Description Decline a store invite or give up membership of a store. Owners cannot leave their own store.
+// @Tags store-members
+// @Security BearerAuth
+// @Param id path string true "Membership ID"
+// @Success 204 "No Content"
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /users/me/memberships/{id} [delete]
+func (h *MemberHandler) LeaveStoreMembership(c *fiber.Ctx) error {
+	db := h.db
+	user := c.Locals("user").(*models.User)
+	member, err := userMembership(c, db, user)
+	if member == nil {
+		return err
+	}
+	if member.Role == models.StoreRoleOwner {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Owners cannot leave their own store"})
+	}
+
+	if err := leaveStore(db, member); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to leave store"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
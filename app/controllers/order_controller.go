@@ -1,13 +1,28 @@
 package controllers
 
 import (
-	"strconv"
+	"context"
+	"errors"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/inventory"
 	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/orderflow"
+	"github.com/theHoracle/whatstore-api/app/repository"
+	"github.com/theHoracle/whatstore-api/app/services"
 	"gorm.io/gorm"
 )
 
+// OrderHandler serves the order endpoints
+type OrderHandler struct {
+	orders *services.Orders
+}
+
+// NewOrderHandler returns the order endpoints backed by orders
+func NewOrderHandler(orders *services.Orders) *OrderHandler {
+	return &OrderHandler{orders: orders}
+}
+
 // GetUserOrders godoc
 // @Summary Get user orders
 // @Description Get all orders for the authenticated user
@@ -20,17 +35,12 @@ import (
 // @Success 200 {object} PaginationResponse{data=[]models.Order}
 // @Failure 401 {object} models.ErrorResponse
 // @Router /api/v1/orders [get]
-func GetUserOrders(c *fiber.Ctx) error {
+func (h *OrderHandler) GetUserOrders(c *fiber.Ctx) error {
 	user := c.Locals("user").(*models.User)
-	db := c.Locals("db").(*gorm.DB)
 	page, perPage := paginate(c)
 
-	var orders []models.Order
-	var total int64
-
-	db.Model(&models.Order{}).Where("user_id = ?", user.ID).Count(&total)
-	query := db.Where("user_id = ?", user.ID).Offset((page - 1) * perPage).Limit(perPage)
-	if err := query.Find(&orders).Error; err != nil {
+	orders, total, err := h.orders.ListByUser(c.UserContext(), user.ID, page, perPage)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch orders",
 		})
@@ -41,7 +51,7 @@ func GetUserOrders(c *fiber.Ctx) error {
 
 // CreateOrder godoc
 // @Summary Create new order
-// @Description Create a new order for the authenticated user
+// @Description Create a new order for the authenticated user and reserve stock for every item
 // @Tags orders
 // @Accept json
 // @Produce json
@@ -50,11 +60,11 @@ func GetUserOrders(c *fiber.Ctx) error {
 // @Success 201 {object} models.Order
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /api/v1/orders [post]
-func CreateOrder(c *fiber.Ctx) error {
+func (h *OrderHandler) CreateOrder(c *fiber.Ctx) error {
 	user := c.Locals("user").(*models.User)
-	db := c.Locals("db").(*gorm.DB)
 
 	var orderRequest models.CreateOrderRequest
 	if err := c.BodyParser(&orderRequest); err != nil {
@@ -63,88 +73,78 @@ func CreateOrder(c *fiber.Ctx) error {
 		})
 	}
 
-	// Start a transaction
-	tx := db.Begin()
-
-	order := models.Order{
-		UserID: user.ID,
-		Status: models.OrderStatusPending,
+	order, err := h.orders.Create(c.UserContext(), user.ID, orderRequest.Items)
+	if err != nil {
+		return serviceError(c, err, "Failed to create order")
 	}
 
-	// First create the order
-	if err := tx.Create(&order).Error; err != nil {
-		tx.Rollback()
+	return c.Status(fiber.StatusCreated).JSON(order)
+}
+
+// orderError is a problem with the requested items that is reported back to the buyer
+type orderError struct {
+	status  int
+	message string
+	items   []*inventory.InsufficientStockError
+}
+
+func (e *orderError) Error() string {
+	return e.message
+}
+
+func orderErrorResponse(c *fiber.Ctx, err error) error {
+	var orderErr *orderError
+	if !errors.As(err, &orderErr) {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create order",
 		})
 	}
 
-	var totalAmount float64
-	var orderItems []models.OrderItem
-
-	// Get all products and create order items
-	for _, item := range orderRequest.Items {
-		var product models.Product
-		if err := tx.First(&product, item.ProductID).Error; err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Product not found: " + strconv.Itoa(int(item.ProductID)),
-			})
-		}
-
-		// Set store ID from first product if not set
-		if order.StoreID == 0 {
-			order.StoreID = product.StoreID
-		} else if order.StoreID != product.StoreID {
-			tx.Rollback()
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "All products must be from the same store",
-			})
-		}
-
-		orderItem := models.OrderItem{
-			OrderID:   order.ID,
-			ProductID: product.ID,
-			Quantity:  item.Quantity,
-			Price:     product.Price, // Store current price
-		}
-
-		orderItems = append(orderItems, orderItem)
-		totalAmount += product.Price * float64(item.Quantity)
-	}
-
-	// Update order with total and store ID
-	order.TotalAmount = totalAmount
-	if err := tx.Save(&order).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to update order",
-		})
+	body := fiber.Map{"error": orderErr.message}
+	if len(orderErr.items) > 0 {
+		body["items"] = orderErr.items
 	}
+	return c.Status(orderErr.status).JSON(body)
+}
 
-	// Create all order items
-	if err := tx.Create(&orderItems).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to create order items",
-		})
+// asOrderError turns the order service's rule and stock errors into an
+// orderError, for the handlers that place orders inside their own
+// transaction
+func asOrderError(err error) error {
+	var ruleErr *services.Error
+	if errors.As(err, &ruleErr) {
+		return &orderError{status: serviceErrorStatus[ruleErr.Kind], message: ruleErr.Message}
 	}
-
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to commit transaction",
-		})
+	var stockErr *repository.StockError
+	if errors.As(err, &stockErr) {
+		return &orderError{status: fiber.StatusConflict, message: "Insufficient stock", items: stockErr.Items}
 	}
+	return err
+}
 
-	// Fetch complete order with items
-	if err := db.Preload("Items.Product").First(&order, order.ID).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch created order",
-		})
+// orderService returns the order service working inside tx
+func orderService(tx *gorm.DB) *services.Orders {
+	return services.NewOrders(repository.NewOrders(tx), repository.NewProducts(tx), repository.NewStores(tx))
+}
+
+// placeOrder creates a pending order for products of a single store inside
+// tx, reserving stock for every item at the current product price
+func placeOrder(ctx context.Context, tx *gorm.DB, userID uint, requested []models.OrderItem) (*models.Order, error) {
+	order, err := orderService(tx).Place(ctx, userID, requested)
+	if err != nil {
+		return nil, asOrderError(err)
 	}
+	return order, nil
+}
 
-	return c.Status(fiber.StatusCreated).JSON(order)
+// orderVariant loads the variant an order item asks for. Products with
+// variants must be ordered by variant, products without cannot be.
+func orderVariant(ctx context.Context, tx *gorm.DB, product *models.Product, variantID *uint) (*models.ProductVariant, error) {
+	variant, err := orderService(tx).ItemVariant(ctx, product, variantID)
+	if err != nil {
+		return nil, asOrderError(err)
+	}
+	return variant, nil
 }
 
 // GetOrder godoc
@@ -159,74 +159,113 @@ func CreateOrder(c *fiber.Ctx) error {
 // @Failure 401 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
 // @Router /api/v1/orders/{id} [get]
-func GetOrder(c *fiber.Ctx) error {
+func (h *OrderHandler) GetOrder(c *fiber.Ctx) error {
 	user := c.Locals("user").(*models.User)
-	db := c.Locals("db").(*gorm.DB)
-	orderID := c.Params("id")
+	orderID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid order ID"})
+	}
 
-	var order models.Order
-	if err := db.Where("id = ? AND user_id = ?", orderID, user.ID).First(&order).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Order not found",
-		})
+	order, err := h.orders.Get(c.UserContext(), user, uint(orderID))
+	if err != nil {
+		return serviceError(c, err, "Failed to fetch order")
 	}
 
 	return c.JSON(order)
 }
 
-// TODO: Use to validate order products
-// func validateOrderProducts(order *models.Order) error {
-// 	if len(order.Items) == 0 {
-// 		return errors.New("order has no products")
-// 	}
+func (h *OrderHandler) GetStoreOrders(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	storeID, err := c.ParamsInt("storeId")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid store ID"})
+	}
 
-// 	storeID := order.Items[0].StoreID
-// 	for _, product := range order.Items {
-// 		if product.StoreID != storeID {
-// 			return errors.New("all products must belong to the same store")
-// 		}
-// 	}
+	orders, err := h.orders.ListByStore(c.UserContext(), user, uint(storeID))
+	if err != nil {
+		return serviceError(c, err, "Failed to fetch orders")
+	}
 
-// 	return nil
-// }
+	return c.JSON(orders)
+}
 
-func GetStoreOrders(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
+// UpdateOrderStatus godoc
+// @Summary Update order status
+// @Description Move an order to a new status. Buyers and the store's vendor can only make the transitions allowed for their role.
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param status body models.UpdateOrderStatusRequest true "New order status"
+// @Success 200 {object} models.Order
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/orders/{id}/status [put]
+func (h *OrderHandler) UpdateOrderStatus(c *fiber.Ctx) error {
 	user := c.Locals("user").(*models.User)
-	storeID := c.Params("storeId")
+	orderID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid order ID"})
+	}
 
-	// Verify user owns the store
-	var store models.Store
-	if err := db.Where("id = ? AND vendor_id = ?", storeID, user.Vendor.ID).First(&store).Error; err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Not authorized"})
+	var statusRequest models.UpdateOrderStatusRequest
+	if err := c.BodyParser(&statusRequest); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	var orders []models.Order
-	if err := db.Where("store_id = ?", storeID).Find(&orders).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch orders"})
+	order, err := h.orders.UpdateStatus(c.UserContext(), user, uint(orderID), models.OrderStatus(statusRequest.Status), statusRequest.Note)
+	if err != nil {
+		return serviceError(c, err, "Failed to update order status")
 	}
 
-	return c.JSON(orders)
+	return c.JSON(order)
 }
 
-func UpdateOrderStatus(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
+// GetOrderHistory godoc
+// @Summary Get order status history
+// @Description Get every status change of an order, oldest first
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Success 200 {array} models.OrderStatusHistory
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/orders/{id}/history [get]
+func (h *OrderHandler) GetOrderHistory(c *fiber.Ctx) error {
 	user := c.Locals("user").(*models.User)
-	orderID := c.Params("id")
-
-	var order models.Order
-	if err := db.Where("id = ? AND user_id = ?", orderID, user.ID).First(&order).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Order not found"})
+	orderID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid order ID"})
 	}
 
-	status := c.Query("status")
-	if status != "" {
-		order.Status = models.OrderStatus(status)
-		// Here we can add payment/escrow release logic when implementing payments
-		if err := db.Save(&order).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update order"})
-		}
+	history, err := h.orders.History(c.UserContext(), user, uint(orderID))
+	if err != nil {
+		return serviceError(c, err, "Failed to fetch order history")
 	}
 
-	return c.JSON(order)
+	return c.JSON(history)
+}
+
+// orderActors returns the roles the user holds on an order: the buyer who
+// placed it and/or the vendor, a member of the store it was placed with who
+// handles its orders
+func orderActors(ctx context.Context, db *gorm.DB, user *models.User, order *models.Order) []models.OrderActor {
+	return services.OrderActors(ctx, repository.NewStores(db), user, order)
+}
+
+// transitionError maps order lifecycle errors to HTTP responses
+func transitionError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, orderflow.ErrInvalidTransition):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, orderflow.ErrActorNotAllowed):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update order status"})
+	}
 }
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/models"
+)
+
+func TestCreateOrder(t *testing.T) {
+	api := newTestAPI(t)
+	items := []models.OrderItem{
+		{ProductID: api.shirt.ID, VariantID: &api.small.ID, Quantity: 2},
+		{ProductID: api.product.ID, Quantity: 3},
+	}
+
+	var order models.Order
+	api.expect(fiber.StatusCreated, "POST", "/api/v1/orders", api.buyer, models.CreateOrderRequest{Items: items}, &order)
+	if order.StoreID != api.store.ID || order.Status != models.OrderStatusPending || len(order.Items) != 2 {
+		t.Fatalf("order %+v", order)
+	}
+	// The variant's own price overrides the product's
+	if order.TotalAmount != 2*60+3*100 {
+		t.Errorf("total %v", order.TotalAmount)
+	}
+
+	// Stock was reserved, so the same order no longer fits
+	var short struct {
+		Error string `json:"error"`
+		Items []struct {
+			ProductID uint `json:"product_id"`
+			Available int  `json:"available"`
+		} `json:"items"`
+	}
+	api.expect(fiber.StatusConflict, "POST", "/api/v1/orders", api.buyer, models.CreateOrderRequest{Items: items}, &short)
+	if short.Error != "Insufficient stock" || len(short.Items) != 1 || short.Items[0].ProductID != api.shirt.ID {
+		t.Errorf("short %+v", short)
+	}
+}
+
+func TestCreateOrderRejectsItems(t *testing.T) {
+	api := newTestAPI(t)
+	other := &models.Store{VendorID: api.vendor.ID, StoreUrl: "other"}
+	api.db.AddStore(other, api.owner)
+	elsewhere := &models.Product{StoreID: other.ID, Name: "Hat", Price: 5, Stock: 5}
+	api.db.AddProduct(elsewhere)
+	wrongVariant := api.small.ID + 100
+
+	cases := []struct {
+		items []models.OrderItem
+		msg   string
+	}{
+		{nil, "Order has no items"},
+		{[]models.OrderItem{{ProductID: api.product.ID}}, "Invalid quantity for product: " + id(api.product.ID)},
+		{[]models.OrderItem{{ProductID: 999, Quantity: 1}}, "Product not found: 999"},
+		{[]models.OrderItem{{ProductID: api.shirt.ID, Quantity: 1}}, "Choose a variant for product: " + id(api.shirt.ID)},
+		{[]models.OrderItem{{ProductID: api.shirt.ID, VariantID: &wrongVariant, Quantity: 1}}, "Variant not found for product: " + id(api.shirt.ID)},
+		{[]models.OrderItem{{ProductID: api.product.ID, Quantity: 1}, {ProductID: elsewhere.ID, Quantity: 1}},
+			"All products must be from the same store, use a checkout to order from several stores"},
+	}
+	for _, tc := range cases {
+		msg := api.expect(fiber.StatusBadRequest, "POST", "/api/v1/orders", api.buyer, models.CreateOrderRequest{Items: tc.items}, nil)
+		if msg != tc.msg {
+			t.Errorf("got %q, want %q", msg, tc.msg)
+		}
+	}
+}
+
+func TestOrderAccess(t *testing.T) {
+	api := newTestAPI(t)
+	var order models.Order
+	api.expect(fiber.StatusCreated, "POST", "/api/v1/orders", api.buyer,
+		models.CreateOrderRequest{Items: []models.OrderItem{{ProductID: api.product.ID, Quantity: 1}}}, &order)
+	path := "/api/v1/orders/" + id(order.ID)
+
+	api.expect(fiber.StatusOK, "GET", path, api.buyer, nil, nil)
+	api.expect(fiber.StatusNotFound, "GET", path, api.stranger, nil, nil)
+	api.expect(fiber.StatusNotFound, "GET", "/api/v1/orders/999", api.buyer, nil, nil)
+
+	var page struct {
+		Data  []models.Order `json:"data"`
+		Total int64          `json:"total"`
+	}
+	api.expect(fiber.StatusOK, "GET", "/api/v1/orders", api.buyer, nil, &page)
+	if page.Total != 1 || len(page.Data) != 1 {
+		t.Errorf("buyer orders %+v", page)
+	}
+
+	storeOrders := "/api/v1/stores/" + id(api.store.ID) + "/orders"
+	var list []models.Order
+	api.expect(fiber.StatusOK, "GET", storeOrders, api.owner, nil, &list)
+	if len(list) != 1 {
+		t.Errorf("store orders %+v", list)
+	}
+	api.expect(fiber.StatusForbidden, "GET", storeOrders, api.buyer, nil, nil)
+	api.addMember(api.stranger, models.StoreRoleCatalogEditor)
+	api.expect(fiber.StatusForbidden, "GET", storeOrders, api.stranger, nil, nil)
+}
+
+func TestUpdateOrderStatus(t *testing.T) {
+	api := newTestAPI(t)
+	var order models.Order
+	api.expect(fiber.StatusCreated, "POST", "/api/v1/orders", api.buyer,
+		models.CreateOrderRequest{Items: []models.OrderItem{{ProductID: api.product.ID, Quantity: 1}}}, &order)
+	path := "/api/v1/orders/" + id(order.ID)
+
+	api.expect(fiber.StatusBadRequest, "PUT", path+"/status", api.owner, models.UpdateOrderStatusRequest{Status: "teleported"}, nil)
+	api.expect(fiber.StatusNotFound, "PUT", path+"/status", api.stranger, models.UpdateOrderStatusRequest{Status: "confirmed"}, nil)
+	api.expect(fiber.StatusForbidden, "PUT", path+"/status", api.buyer, models.UpdateOrderStatusRequest{Status: "confirmed"}, nil)
+	api.expect(fiber.StatusConflict, "PUT", path+"/status", api.owner, models.UpdateOrderStatusRequest{Status: "delivered"}, nil)
+
+	// Order handlers act for the vendor
+	api.addMember(api.stranger, models.StoreRoleOrderHandler)
+	var updated models.Order
+	api.expect(fiber.StatusOK, "PUT", path+"/status", api.stranger, models.UpdateOrderStatusRequest{Status: "confirmed", Note: "On it"}, &updated)
+	if updated.Status != models.OrderStatusConfirmed {
+		t.Errorf("status %s", updated.Status)
+	}
+
+	var history []models.OrderStatusHistory
+	api.expect(fiber.StatusOK, "GET", path+"/history", api.buyer, nil, &history)
+	if len(history) != 2 || history[1].ToStatus != models.OrderStatusConfirmed || history[1].ActorRole != models.OrderActorVendor || history[1].Note != "On it" {
+		t.Errorf("history %+v", history)
+	}
+
+	outsider := &models.User{Email: "dayo@example.com"}
+	api.db.AddUser(outsider)
+	api.expect(fiber.StatusNotFound, "GET", path+"/history", outsider, nil, nil)
+}
@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/payments"
+	"gorm.io/gorm"
+)
+
+// PaymentHandler serves the payment endpoints
+type PaymentHandler struct {
+	db *gorm.DB
+}
+
+// NewPaymentHandler returns the payment endpoints backed by db
+func NewPaymentHandler(db *gorm.DB) *PaymentHandler {
+	return &PaymentHandler{db: db}
+}
+
+// PayOrder godoc
+// @Summary Pay for an order
+// @Description Start a payment for a pending order with the chosen provider and get the URL the buyer should be redirected to
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param payment body models.InitializePaymentRequest true "Payment provider and callback URL"
+// @Success 200 {object} models.Payment "The pending payment already started"
+// @Success 201 {object} models.Payment
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 502 {object} models.ErrorResponse
+// @Router /api/v1/orders/{id}/pay [post]
+func (h *PaymentHandler) PayOrder(c *fiber.Ctx) error {
+	db := h.db
+	user := c.Locals("user").(*models.User)
+	orderID := c.Params("id")
+
+	var order models.Order
+	if err := db.Preload("Items.Product").Where("id = ? AND user_id = ?", orderID, user.ID).First(&order).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Order not found"})
+	}
+
+	if order.CheckoutGroupID != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Order is part of a checkout, pay for the checkout instead"})
+	}
+	if order.PaymentID != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Order has already been paid"})
+	}
+	if order.Status != models.OrderStatusPending {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Order is not awaiting payment"})
+	}
+
+	currency := "NGN"
+	if len(order.Items) > 0 && order.Items[0].Product.Currency != "" {
+		currency = order.Items[0].Product.Currency
+	}
+
+	return startPayment(c, db, user, models.Payment{
+		OrderID:  &order.ID,
+		Amount:   order.TotalAmount,
+		Currency: currency,
+	}, map[string]interface{}{"order_id": order.ID})
+}
+
+// startPayment records a pending payment and initializes it with the
+// provider chosen in the request body. A pending payment already started for
+// the same amount with the same provider is returned instead, and any other
+// is cancelled, so the buyer is only ever asked to pay once.
+func startPayment(c *fiber.Ctx, db *gorm.DB, user *models.User, payment models.Payment, metadata map[string]interface{}) error {
+	var paymentRequest models.InitializePaymentRequest
+	if err := c.BodyParser(&paymentRequest); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	provider, err := payments.Get(paymentRequest.Provider)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":     "Unsupported payment provider",
+			"providers": payments.Names(),
+		})
+	}
+
+	var pending []models.Payment
+	if err := pendingPayments(db, payment).Find(&pending).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create payment"})
+	}
+	var reused *models.Payment
+	var cancelled []uint
+	for i, previous := range pending {
+		if reused == nil && previous.UserID == user.ID && previous.Provider == provider.Name() &&
+			previous.Amount == payment.Amount && previous.Currency == payment.Currency && previous.AuthorizationURL != "" {
+			reused = &pending[i]
+			continue
+		}
+		cancelled = append(cancelled, previous.ID)
+	}
+	if len(cancelled) > 0 {
+		if err := db.Model(&models.Payment{}).
+			Where("id IN ? AND status = ?", cancelled, models.PaymentStatusPending).
+			Update("status", models.PaymentStatusCancelled).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create payment"})
+		}
+	}
+	if reused != nil {
+		return c.JSON(reused)
+	}
+
+	scope := ""
+	if payment.BookingID != nil {
+		scope = "B" + strconv.Itoa(int(*payment.BookingID))
+	} else if payment.CheckoutGroupID != nil {
+		scope = "C" + strconv.Itoa(int(*payment.CheckoutGroupID))
+	} else if payment.OrderID != nil {
+		scope = strconv.Itoa(int(*payment.OrderID))
+	}
+	payment.UserID = user.ID
+	payment.Provider = provider.Name()
+	payment.Reference = payments.NewReference(scope)
+	payment.Status = models.PaymentStatusPending
+	if err := db.Create(&payment).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create payment"})
+	}
+
+	transaction, err := provider.InitializeTransaction(c.UserContext(), payments.InitializeRequest{
+		Reference:   payment.Reference,
+		Amount:      payment.Amount,
+		Currency:    payment.Currency,
+		Email:       user.Email,
+		Name:        user.Name,
+		CallbackURL: paymentRequest.CallbackURL,
+		Metadata:    metadata,
+	})
+	if err != nil {
+		db.Model(&payment).Update("status", models.PaymentStatusFailed)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "Failed to initialize payment"})
+	}
+
+	payment.AuthorizationURL = transaction.AuthorizationURL
+	if err := db.Model(&payment).Update("authorization_url", payment.AuthorizationURL).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save payment"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(payment)
+}
+
+// pendingPayments selects the pending payments for what payment pays for
+func pendingPayments(db *gorm.DB, payment models.Payment) *gorm.DB {
+	query := db.Where("status = ?", models.PaymentStatusPending).Order("id DESC")
+	switch {
+	case payment.BookingID != nil:
+		return query.Where("booking_id = ?", *payment.BookingID)
+	case payment.CheckoutGroupID != nil:
+		return query.Where("checkout_group_id = ?", *payment.CheckoutGroupID)
+	default:
+		return query.Where("order_id = ?", payment.OrderID)
+	}
+}
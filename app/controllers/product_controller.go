@@ -1,44 +1,81 @@
 package controllers
 
 import (
-	"errors"
-
 	"github.com/gofiber/fiber/v2"
 	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/services"
 	"gorm.io/gorm"
 )
 
-// Helper function to validate store ownership
-func validateStoreOwnership(db *gorm.DB, storeID uint, vendorID uint) error {
-	var store models.Store
-	if err := db.Where("id = ? AND vendor_id = ?", storeID, vendorID).First(&store).Error; err != nil {
-		return errors.New("store not found or not authorized")
-	}
-	return nil
+// ProductHandler serves the product endpoints
+type ProductHandler struct {
+	products *services.Products
+	viewers  *services.Viewers
+	db       *gorm.DB
+}
+
+// NewProductHandler returns the product endpoints backed by products, with
+// reads personalized by viewers. Listings and searches query db.
+func NewProductHandler(products *services.Products, viewers *services.Viewers, db *gorm.DB) *ProductHandler {
+	return &ProductHandler{products: products, viewers: viewers, db: db}
+}
+
+// ProductResponse is a product and, when the request is signed in, how it
+// relates to the user
+type ProductResponse struct {
+	models.Product
+	Viewer *models.ProductViewer `json:"viewer,omitempty"`
 }
 
 // GetProduct godoc
 // @Summary Get a single product
-// @Description Get product details by ID
+// @Description Get product details by ID. Signed-in requests also get how the product relates to the user.
 // @Tags products
 // @Accept json
 // @Produce json
 // @Param id path string true "Product ID"
-// @Success 200 {object} models.Product
+// @Success 200 {object} ProductResponse
 // @Failure 404 {object} models.ErrorResponse
 // @Router /api/v1/products/{id} [get]
-func GetProduct(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
-	id := c.Params("id")
-
-	var product models.Product
-	if err := db.First(&product, id).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Product not found",
+func (h *ProductHandler) GetProduct(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
 		})
 	}
 
-	return c.JSON(product)
+	product, err := h.products.Get(c.UserContext(), uint(id))
+	if err != nil {
+		return serviceError(c, err, "Failed to fetch product")
+	}
+
+	response := ProductResponse{Product: *product}
+	if user, ok := c.Locals("user").(*models.User); ok {
+		if response.Viewer, err = h.viewers.Product(c.UserContext(), user, product); err != nil {
+			return serviceError(c, err, "Failed to fetch product")
+		}
+	}
+	return c.JSON(response)
+}
+
+// responses pairs each of products with how it relates to the user the
+// request is signed in as, if any
+func (h *ProductHandler) responses(c *fiber.Ctx, products []models.Product) ([]ProductResponse, error) {
+	responses := make([]ProductResponse, len(products))
+	for i, product := range products {
+		responses[i].Product = product
+	}
+	if user, ok := c.Locals("user").(*models.User); ok {
+		viewers, err := h.viewers.Products(c.UserContext(), user, products)
+		if err != nil {
+			return nil, err
+		}
+		for i := range responses {
+			responses[i].Viewer = viewers[i]
+		}
+	}
+	return responses, nil
 }
 
 // CreateProduct godoc
@@ -54,8 +91,7 @@ func GetProduct(c *fiber.Ctx) error {
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 403 {object} models.ErrorResponse
 // @Router /api/v1/stores/{storeId}/products [post]
-func CreateProduct(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
+func (h *ProductHandler) CreateProduct(c *fiber.Ctx) error {
 	user := c.Locals("user").(*models.User)
 	storeID, err := c.ParamsInt("storeId")
 	if err != nil {
@@ -64,13 +100,6 @@ func CreateProduct(c *fiber.Ctx) error {
 		})
 	}
 
-	// Validate store ownership
-	if err := validateStoreOwnership(db, uint(storeID), user.Vendor.ID); err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": err.Error(),
-		})
-	}
-
 	var productRequest models.CreateProductRequest
 	if err := c.BodyParser(&productRequest); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -78,20 +107,9 @@ func CreateProduct(c *fiber.Ctx) error {
 		})
 	}
 
-	product := models.Product{
-		Name:        productRequest.Name,
-		Description: productRequest.Description,
-		Price:       productRequest.Price,
-		StoreID:     uint(storeID),
-		Images:      productRequest.Images,
-		Stock:       productRequest.Stock,
-		Category:    productRequest.Category,
-	}
-
-	if err := db.Create(&product).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to create product",
-		})
+	product, err := h.products.Create(c.UserContext(), user, uint(storeID), productRequest)
+	if err != nil {
+		return serviceError(c, err, "Failed to create product")
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(product)
@@ -111,22 +129,12 @@ func CreateProduct(c *fiber.Ctx) error {
 // @Failure 401 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
 // @Router /api/v1/admin/products/{id} [put]
-func UpdateProduct(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
+func (h *ProductHandler) UpdateProduct(c *fiber.Ctx) error {
 	user := c.Locals("user").(*models.User)
-	productID := c.Params("id")
-
-	var product models.Product
-	if err := db.Where("id = ?", productID).First(&product).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Product not found",
-		})
-	}
-
-	// Validate store ownership
-	if err := validateStoreOwnership(db, product.StoreID, user.Vendor.ID); err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": err.Error(),
+	productID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
 		})
 	}
 
@@ -137,10 +145,9 @@ func UpdateProduct(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := db.Model(&product).Updates(updateData).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to update product",
-		})
+	product, err := h.products.Update(c.UserContext(), user, uint(productID), updateData)
+	if err != nil {
+		return serviceError(c, err, "Failed to update product")
 	}
 
 	return c.JSON(product)
@@ -159,29 +166,17 @@ func UpdateProduct(c *fiber.Ctx) error {
 // @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /api/v1/admin/products/{id} [delete]
-func DeleteProduct(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
+func (h *ProductHandler) DeleteProduct(c *fiber.Ctx) error {
 	user := c.Locals("user").(*models.User)
-	productID := c.Params("id")
-
-	var product models.Product
-	if err := db.Where("id = ?", productID).First(&product).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Product not found",
-		})
-	}
-
-	// Validate store ownership
-	if err := validateStoreOwnership(db, product.StoreID, user.Vendor.ID); err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": err.Error(),
+	productID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product ID",
 		})
 	}
 
-	if err := db.Delete(&product).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete product",
-		})
+	if err := h.products.Delete(c.UserContext(), user, uint(productID)); err != nil {
+		return serviceError(c, err, "Failed to delete product")
 	}
 
 	return c.JSON(fiber.Map{
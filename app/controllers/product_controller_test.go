@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/models"
+)
+
+func TestGetProduct(t *testing.T) {
+	api := newTestAPI(t)
+
+	var product models.Product
+	api.expect(fiber.StatusOK, "GET", "/api/v1/products/"+id(api.shirt.ID), nil, nil, &product)
+	if product.Name != "Shirt" || len(product.Variants) != 2 {
+		t.Errorf("product %+v", product)
+	}
+
+	api.expect(fiber.StatusNotFound, "GET", "/api/v1/products/999", nil, nil, nil)
+	api.expect(fiber.StatusBadRequest, "GET", "/api/v1/products/shirt", nil, nil, nil)
+}
+
+func TestGetProductViewer(t *testing.T) {
+	api := newTestAPI(t)
+	path := "/api/v1/products/" + id(api.product.ID)
+
+	var anonymous ProductResponse
+	api.expect(fiber.StatusOK, "GET", path, nil, nil, &anonymous)
+	if anonymous.Viewer != nil || anonymous.Name != "Sneakers" {
+		t.Errorf("anonymous %+v", anonymous)
+	}
+
+	var owner ProductResponse
+	api.expect(fiber.StatusOK, "GET", path, api.owner, nil, &owner)
+	if owner.Viewer == nil || owner.Viewer.StoreRole != models.StoreRoleOwner || !owner.Viewer.CanEdit || owner.Viewer.Purchased {
+		t.Errorf("owner viewer %+v", owner.Viewer)
+	}
+
+	api.addMember(api.stranger, models.StoreRoleOrderHandler)
+	var handler ProductResponse
+	api.expect(fiber.StatusOK, "GET", path, api.stranger, nil, &handler)
+	if handler.Viewer == nil || handler.Viewer.StoreRole != models.StoreRoleOrderHandler || handler.Viewer.CanEdit {
+		t.Errorf("order handler viewer %+v", handler.Viewer)
+	}
+
+	// A pending order is not a purchase until the vendor accepts it
+	var order models.Order
+	api.expect(fiber.StatusCreated, "POST", "/api/v1/orders", api.buyer,
+		models.CreateOrderRequest{Items: []models.OrderItem{{ProductID: api.product.ID, Quantity: 1}}}, &order)
+	var buyer ProductResponse
+	api.expect(fiber.StatusOK, "GET", path, api.buyer, nil, &buyer)
+	if buyer.Viewer == nil || buyer.Viewer.Purchased || buyer.Viewer.StoreRole != "" {
+		t.Errorf("buyer viewer before confirmation %+v", buyer.Viewer)
+	}
+
+	api.expect(fiber.StatusOK, "PUT", "/api/v1/orders/"+id(order.ID)+"/status", api.owner,
+		models.UpdateOrderStatusRequest{Status: "confirmed"}, nil)
+	api.expect(fiber.StatusOK, "GET", path, api.buyer, nil, &buyer)
+	if !buyer.Viewer.Purchased {
+		t.Errorf("buyer viewer after confirmation %+v", buyer.Viewer)
+	}
+}
+
+func TestCreateProduct(t *testing.T) {
+	api := newTestAPI(t)
+	path := "/api/v1/stores/" + id(api.store.ID) + "/products"
+	input := models.CreateProductRequest{Name: "Boots", Price: 250, Stock: 4, CategoryID: &api.category.ID}
+
+	var product models.Product
+	api.expect(fiber.StatusCreated, "POST", path, api.owner, input, &product)
+	if product.ID == 0 || product.StoreID != api.store.ID || product.Stock != 4 {
+		t.Fatalf("created %+v", product)
+	}
+
+	api.addMember(api.buyer, models.StoreRoleCatalogEditor)
+	api.expect(fiber.StatusCreated, "POST", path, api.buyer, input, nil)
+
+	api.addMember(api.stranger, models.StoreRoleOrderHandler)
+	api.expect(fiber.StatusForbidden, "POST", path, api.stranger, input, nil)
+
+	missing := uint(999)
+	input.CategoryID = &missing
+	if msg := api.expect(fiber.StatusBadRequest, "POST", path, api.owner, input, nil); msg != "Category not found" {
+		t.Errorf("missing category: %q", msg)
+	}
+}
+
+func TestUpdateProduct(t *testing.T) {
+	api := newTestAPI(t)
+	path := "/api/v1/stores/" + id(api.store.ID) + "/products/"
+
+	var product models.Product
+	stock := 7
+	api.expect(fiber.StatusOK, "PUT", path+id(api.product.ID), api.owner,
+		models.UpdateProductRequest{Price: 120, Stock: &stock}, &product)
+	if product.Price != 120 || product.Stock != 7 || product.Name != "Sneakers" {
+		t.Errorf("updated %+v", product)
+	}
+
+	// Selling out sets the stock to zero
+	stock = 0
+	api.expect(fiber.StatusOK, "PUT", path+id(api.product.ID), api.owner, models.UpdateProductRequest{Stock: &stock}, &product)
+	if product.Stock != 0 || product.Price != 120 {
+		t.Errorf("sold out %+v", product)
+	}
+
+	stock = 9
+	msg := api.expect(fiber.StatusConflict, "PUT", path+id(api.shirt.ID), api.owner, models.UpdateProductRequest{Stock: &stock}, nil)
+	if msg != "Product stock is the sum of its variants, update the variants instead" {
+		t.Errorf("variant stock: %q", msg)
+	}
+
+	api.expect(fiber.StatusForbidden, "PUT", path+id(api.product.ID), api.stranger, models.UpdateProductRequest{Price: 1}, nil)
+	api.expect(fiber.StatusNotFound, "PUT", path+"999", api.owner, models.UpdateProductRequest{Price: 1}, nil)
+}
+
+func TestDeleteProduct(t *testing.T) {
+	api := newTestAPI(t)
+	path := "/api/v1/stores/" + id(api.store.ID) + "/products/" + id(api.product.ID)
+
+	api.expect(fiber.StatusForbidden, "DELETE", path, api.buyer, nil, nil)
+	api.expect(fiber.StatusOK, "DELETE", path, api.owner, nil, nil)
+	api.expect(fiber.StatusNotFound, "GET", "/api/v1/products/"+id(api.product.ID), nil, nil, nil)
+	api.expect(fiber.StatusNotFound, "DELETE", path, api.owner, nil, nil)
+}
@@ -1,35 +1,55 @@
 package controllers
 
 import (
-	"fmt"
 	"math"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/repository"
+	"github.com/theHoracle/whatstore-api/app/search"
 	"gorm.io/gorm"
 )
 
 // GetAllProducts godoc
 // @Summary Get all products with pagination
-// @Description Get a list of all available products with pagination support
+// @Description Get a list of all available products with pagination support. Signed-in requests also get how each product relates to the user.
 // @Tags products
 // @Produce json
 // @Param page query int false "Page number"
 // @Param per_page query int false "Items per page"
+// @Param category query string false "Category ID or slug, includes its subcategories"
 // @Success 200 {object} PaginationResponse
+// @Failure 404 {object} models.ErrorResponse
 // @Router /products [get]
-func GetAllProducts(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
+func (h *ProductHandler) GetAllProducts(c *fiber.Ctx) error {
+	db := h.db
 	page, perPage := paginate(c)
 
 	var products []models.Product
 	var total int64
 
+	query := db.Model(&models.Product{})
+	if categoryParam := c.Query("category"); categoryParam != "" {
+		category, err := findCategory(db, categoryParam)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Category not found",
+			})
+		}
+		categoryIDs, err := categoryWithDescendants(db, category.ID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Could not fetch products",
+			})
+		}
+		query = query.Where("category_id IN ?", categoryIDs)
+	}
+
 	// Get total count
-	db.Model(&models.Product{}).Count(&total)
+	query.Session(&gorm.Session{}).Count(&total)
 
 	// Get paginated products
-	err := db.Offset((page - 1) * perPage).
+	err := repository.WithVariants(query.Preload("Category")).Offset((page - 1) * perPage).
 		Limit(perPage).
 		Find(&products).Error
 
@@ -39,62 +59,17 @@ func GetAllProducts(c *fiber.Ctx) error {
 		})
 	}
 
-	totalPages := int(math.Ceil(float64(total) / float64(perPage)))
-
-	return c.JSON(PaginationResponse{
-		Data:        products,
-		Total:       total,
-		Page:        page,
-		PerPage:     perPage,
-		TotalPages:  totalPages,
-		HasNext:     page < totalPages,
-		HasPrevious: page > 1,
-	})
-}
-
-// SearchProducts godoc
-// @Summary Search products
-// @Description Search products using full-text search
-// @Tags products
-// @Produce json
-// @Param q query string true "Search query"
-// @Param page query int false "Page number"
-// @Param per_page query int false "Items per page"
-// @Success 200 {object} PaginationResponse
-// @Router /products/search [get]
-func SearchProducts(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
-	query := c.Query("q")
-	page, perPage := paginate(c)
-
-	var products []models.Product
-	var total int64
-
-	// Using the search_vector column for better performance
-	searchQuery := db.Where(
-		"search_vector @@ plainto_tsquery('english', ?)",
-		query,
-	).Order(fmt.Sprintf("ts_rank(search_vector, plainto_tsquery('english', '%s')) DESC", query))
-
-	// Get total count for search results
-	searchQuery.Model(&models.Product{}).Count(&total)
-
-	// Get paginated search results
-	err := searchQuery.
-		Offset((page - 1) * perPage).
-		Limit(perPage).
-		Find(&products).Error
-
+	responses, err := h.responses(c, products)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Could not perform search",
+			"error": "Could not fetch products",
 		})
 	}
 
 	totalPages := int(math.Ceil(float64(total) / float64(perPage)))
 
 	return c.JSON(PaginationResponse{
-		Data:        products,
+		Data:        responses,
 		Total:       total,
 		Page:        page,
 		PerPage:     perPage,
@@ -106,95 +81,47 @@ func SearchProducts(c *fiber.Ctx) error {
 
 // GetAllServices godoc
 // @Summary Get all services with pagination
-// @Description Get a list of all available services with pagination support
+// @Description Get a list of all available services with pagination support, optionally filtered by store, rate and currency. Signed-in requests also get how each service relates to the user.
 // @Tags services
 // @Produce json
+// @Param store query int false "Store ID"
+// @Param min_price query number false "Minimum rate"
+// @Param max_price query number false "Maximum rate"
+// @Param currency query string false "Currency code"
+// @Param sort query string false "price_asc, price_desc or newest (default)"
 // @Param page query int false "Page number"
 // @Param per_page query int false "Items per page"
 // @Success 200 {object} PaginationResponse
+// @Failure 400 {object} models.ErrorResponse
 // @Router /services [get]
-func GetAllServices(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
-	page, perPage := paginate(c)
-
-	var services []models.Service
-	var total int64
-
-	// Get total count
-	db.Model(&models.Service{}).Count(&total)
+func (h *ServiceHandler) GetAllServices(c *fiber.Ctx) error {
+	db := h.db
 
-	// Get paginated services
-	err := db.Offset((page - 1) * perPage).
-		Limit(perPage).
-		Find(&services).Error
+	q, err := parseSearchQuery(c, db)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	q.Text = ""
 
+	result, err := search.Services.List(db, q)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Could not fetch services",
 		})
 	}
 
-	totalPages := int(math.Ceil(float64(total) / float64(perPage)))
-
-	return c.JSON(PaginationResponse{
-		Data:        services,
-		Total:       total,
-		Page:        page,
-		PerPage:     perPage,
-		TotalPages:  totalPages,
-		HasNext:     page < totalPages,
-		HasPrevious: page > 1,
-	})
-}
-
-// SearchServices godoc
-// @Summary Search services
-// @Description Search services using full-text search
-// @Tags services
-// @Produce json
-// @Param q query string true "Search query"
-// @Param page query int false "Page number"
-// @Param per_page query int false "Items per page"
-// @Success 200 {object} PaginationResponse
-// @Router /services/search [get]
-func SearchServices(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
-	query := c.Query("q")
-	page, perPage := paginate(c)
-
-	var services []models.Service
-	var total int64
-
-	// Using the search_vector column for better performance
-	searchQuery := db.Where(
-		"search_vector @@ plainto_tsquery('english', ?)",
-		query,
-	).Order(fmt.Sprintf("ts_rank(search_vector, plainto_tsquery('english', '%s')) DESC", query))
-
-	// Get total count for search results
-	searchQuery.Model(&models.Service{}).Count(&total)
-
-	// Get paginated search results
-	err := searchQuery.
-		Offset((page - 1) * perPage).
-		Limit(perPage).
-		Find(&services).Error
-
+	services, err := servicesForHits(db, result.Hits)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Could not perform search",
+			"error": "Could not fetch services",
+		})
+	}
+	responses, err := h.responses(c, services)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch services",
 		})
 	}
 
-	totalPages := int(math.Ceil(float64(total) / float64(perPage)))
-
-	return c.JSON(PaginationResponse{
-		Data:        services,
-		Total:       total,
-		Page:        page,
-		PerPage:     perPage,
-		TotalPages:  totalPages,
-		HasNext:     page < totalPages,
-		HasPrevious: page > 1,
-	})
+	return c.JSON(NewPaginationResponse(responses, result.Total, q.Page, q.PerPage))
 }
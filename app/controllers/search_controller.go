@@ -0,0 +1,291 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/repository"
+	"github.com/theHoracle/whatstore-api/app/search"
+	"gorm.io/gorm"
+)
+
+// SearchHandler serves the search suggestion endpoints
+type SearchHandler struct {
+	db *gorm.DB
+}
+
+// NewSearchHandler returns the search suggestion endpoints backed by db
+func NewSearchHandler(db *gorm.DB) *SearchHandler {
+	return &SearchHandler{db: db}
+}
+
+// SearchResponse is a page of search results with facet counts for the
+// whole result set. Fuzzy is set when few results matched exactly and names
+// similar to the query were included; DidYouMean then lists the closest names.
+type SearchResponse struct {
+	PaginationResponse
+	Facets     search.Facets `json:"facets"`
+	Fuzzy      bool          `json:"fuzzy"`
+	DidYouMean []string      `json:"did_you_mean,omitempty"`
+}
+
+// ProductHit is a product matching a search
+type ProductHit struct {
+	ProductResponse
+	Rank      float64 `json:"rank"`
+	Highlight string  `json:"highlight"`
+}
+
+// ServiceHit is a service matching a search
+type ServiceHit struct {
+	ServiceResponse
+	Rank      float64 `json:"rank"`
+	Highlight string  `json:"highlight"`
+}
+
+// parseSearchQuery reads the search text, filters, sort order and page from
+// the query string
+func parseSearchQuery(c *fiber.Ctx, db *gorm.DB) (search.Query, error) {
+	q := search.Query{
+		Text:     c.Query("q"),
+		Currency: c.Query("currency"),
+		InStock:  c.QueryBool("in_stock"),
+		Sort:     c.Query("sort", search.SortRelevance),
+	}
+	q.Page, q.PerPage = paginate(c)
+
+	switch q.Sort {
+	case search.SortRelevance, search.SortPriceAsc, search.SortPriceDesc, search.SortNewest:
+	default:
+		return q, errors.New("sort must be one of relevance, price_asc, price_desc or newest")
+	}
+
+	for _, bound := range []struct {
+		key   string
+		value **float64
+	}{{"min_price", &q.MinPrice}, {"max_price", &q.MaxPrice}} {
+		if raw := c.Query(bound.key); raw != "" {
+			price, err := strconv.ParseFloat(raw, 64)
+			if err != nil || price < 0 {
+				return q, errors.New("invalid " + bound.key)
+			}
+			*bound.value = &price
+		}
+	}
+
+	if raw := c.Query("store"); raw != "" {
+		storeID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return q, errors.New("invalid store")
+		}
+		id := uint(storeID)
+		q.StoreID = &id
+	}
+
+	if raw := c.Query("category"); raw != "" {
+		category, err := findCategory(db, raw)
+		if err != nil {
+			return q, err
+		}
+		if q.CategoryIDs, err = categoryWithDescendants(db, category.ID); err != nil {
+			return q, err
+		}
+	}
+
+	return q, nil
+}
+
+// hitIDs returns the IDs of hits in result order
+func hitIDs(hits []search.Hit) []uint {
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	return ids
+}
+
+// servicesForHits loads the services behind hits in hit order
+func servicesForHits(db *gorm.DB, hits []search.Hit) ([]models.Service, error) {
+	var services []models.Service
+	if err := db.Where("id IN ?", hitIDs(hits)).Find(&services).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.Service, len(services))
+	for _, service := range services {
+		byID[service.ID] = service
+	}
+
+	ordered := make([]models.Service, 0, len(hits))
+	for _, hit := range hits {
+		if service, ok := byID[hit.ID]; ok {
+			ordered = append(ordered, service)
+		}
+	}
+	return ordered, nil
+}
+
+// SearchProducts godoc
+// @Summary Search products
+// @Description Full-text search over products with filters, typo tolerance, sorting, highlighted snippets and facet counts by category, price and store. Without q every product matching the filters is returned. Signed-in requests also get how each product relates to the user.
+// @Tags products
+// @Produce json
+// @Param q query string false "Search query"
+// @Param category query string false "Category ID or slug, includes its subcategories"
+// @Param store query int false "Store ID"
+// @Param min_price query number false "Minimum price"
+// @Param max_price query number false "Maximum price"
+// @Param currency query string false "Currency code"
+// @Param in_stock query bool false "Only products in stock"
+// @Param sort query string false "relevance (default), price_asc, price_desc or newest"
+// @Param page query int false "Page number"
+// @Param per_page query int false "Items per page"
+// @Success 200 {object} SearchResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /products/search [get]
+func (h *ProductHandler) SearchProducts(c *fiber.Ctx) error {
+	db := h.db
+
+	q, err := parseSearchQuery(c, db)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	result, err := search.Products.Search(db, q)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not perform search",
+		})
+	}
+
+	var products []models.Product
+	if err := repository.WithVariants(db.Preload("Category")).Where("id IN ?", hitIDs(result.Hits)).Find(&products).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not perform search",
+		})
+	}
+	responses, err := h.responses(c, products)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not perform search",
+		})
+	}
+	byID := make(map[uint]ProductResponse, len(responses))
+	for _, response := range responses {
+		byID[response.ID] = response
+	}
+
+	hits := make([]ProductHit, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		if response, ok := byID[hit.ID]; ok {
+			hits = append(hits, ProductHit{ProductResponse: response, Rank: hit.Rank, Highlight: hit.Highlight})
+		}
+	}
+
+	return c.JSON(SearchResponse{
+		PaginationResponse: NewPaginationResponse(hits, result.Total, q.Page, q.PerPage),
+		Facets:             result.Facets,
+		Fuzzy:              result.Fuzzy,
+		DidYouMean:         result.Suggestions,
+	})
+}
+
+// SearchServices godoc
+// @Summary Search services
+// @Description Full-text search over services with filters, typo tolerance, sorting, highlighted snippets and facet counts by price and store. Without q every service matching the filters is returned. Signed-in requests also get how each service relates to the user.
+// @Tags services
+// @Produce json
+// @Param q query string false "Search query"
+// @Param store query int false "Store ID"
+// @Param min_price query number false "Minimum rate"
+// @Param max_price query number false "Maximum rate"
+// @Param currency query string false "Currency code"
+// @Param sort query string false "relevance (default), price_asc, price_desc or newest"
+// @Param page query int false "Page number"
+// @Param per_page query int false "Items per page"
+// @Success 200 {object} SearchResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Router /services/search [get]
+func (h *ServiceHandler) SearchServices(c *fiber.Ctx) error {
+	db := h.db
+
+	q, err := parseSearchQuery(c, db)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	result, err := search.Services.Search(db, q)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not perform search",
+		})
+	}
+
+	services, err := servicesForHits(db, result.Hits)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not perform search",
+		})
+	}
+
+	responses, err := h.responses(c, services)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not perform search",
+		})
+	}
+
+	hitsByID := make(map[uint]search.Hit, len(result.Hits))
+	for _, hit := range result.Hits {
+		hitsByID[hit.ID] = hit
+	}
+	hits := make([]ServiceHit, 0, len(responses))
+	for _, response := range responses {
+		hit := hitsByID[response.ID]
+		hits = append(hits, ServiceHit{ServiceResponse: response, Rank: hit.Rank, Highlight: hit.Highlight})
+	}
+
+	return c.JSON(SearchResponse{
+		PaginationResponse: NewPaginationResponse(hits, result.Total, q.Page, q.PerPage),
+		Facets:             result.Facets,
+		Fuzzy:              result.Fuzzy,
+		DidYouMean:         result.Suggestions,
+	})
+}
+
+// SearchSuggest godoc
+// @Summary Autocomplete search
+// @Description Suggest product and service names for partly typed text. Names starting with the text come first, followed by names containing or resembling it.
+// @Tags search
+// @Produce json
+// @Param q query string true "Text typed so far"
+// @Param limit query int false "Number of suggestions, at most 20 (default 8)"
+// @Success 200 {array} search.Suggestion
+// @Router /search/suggest [get]
+func (h *SearchHandler) SearchSuggest(c *fiber.Ctx) error {
+	db := h.db
+	text := strings.TrimSpace(c.Query("q"))
+
+	limit := c.QueryInt("limit", 8)
+	if limit < 1 || limit > 20 {
+		limit = 8
+	}
+
+	if len([]rune(text)) < 2 {
+		return c.JSON([]search.Suggestion{})
+	}
+
+	suggestions, err := search.Suggest(db, text, limit, search.Products, search.Services)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Could not fetch suggestions",
+		})
+	}
+	if suggestions == nil {
+		suggestions = []search.Suggestion{}
+	}
+
+	return c.JSON(suggestions)
+}
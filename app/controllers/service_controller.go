@@ -3,9 +3,80 @@ package controllers
 import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/services"
 	"gorm.io/gorm"
 )
 
+// ServiceHandler serves the endpoints of the services stores offer
+type ServiceHandler struct {
+	services *services.Services
+	viewers  *services.Viewers
+	db       *gorm.DB
+}
+
+// NewServiceHandler returns the service endpoints backed by svc, with reads
+// personalized by viewers. Listings and searches query db.
+func NewServiceHandler(svc *services.Services, viewers *services.Viewers, db *gorm.DB) *ServiceHandler {
+	return &ServiceHandler{services: svc, viewers: viewers, db: db}
+}
+
+// ServiceResponse is a service and, when the request is signed in, how it
+// relates to the user
+type ServiceResponse struct {
+	models.Service
+	Viewer *models.ServiceViewer `json:"viewer,omitempty"`
+}
+
+// GetService godoc
+// @Summary Get a service by ID
+// @Description Get detailed information about a specific service. Signed-in requests also get how the service relates to the user.
+// @Tags services
+// @Produce json
+// @Param id path string true "Service ID"
+// @Success 200 {object} ServiceResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /services/{id} [get]
+func (h *ServiceHandler) GetService(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid service ID",
+		})
+	}
+
+	service, err := h.services.Get(c.UserContext(), uint(id))
+	if err != nil {
+		return serviceError(c, err, "Failed to fetch service")
+	}
+
+	response := ServiceResponse{Service: *service}
+	if user, ok := c.Locals("user").(*models.User); ok {
+		if response.Viewer, err = h.viewers.Service(c.UserContext(), user, service); err != nil {
+			return serviceError(c, err, "Failed to fetch service")
+		}
+	}
+	return c.JSON(response)
+}
+
+// responses pairs each of services with how it relates to the user the
+// request is signed in as, if any
+func (h *ServiceHandler) responses(c *fiber.Ctx, services []models.Service) ([]ServiceResponse, error) {
+	responses := make([]ServiceResponse, len(services))
+	for i, service := range services {
+		responses[i].Service = service
+	}
+	if user, ok := c.Locals("user").(*models.User); ok {
+		viewers, err := h.viewers.Services(c.UserContext(), user, services)
+		if err != nil {
+			return nil, err
+		}
+		for i := range responses {
+			responses[i].Viewer = viewers[i]
+		}
+	}
+	return responses, nil
+}
+
 // CreateService godoc
 // @Summary Create a new service
 // @Description Create a new service for a specific store
@@ -19,8 +90,7 @@ import (
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 403 {object} models.ErrorResponse
 // @Router /api/v1/stores/{storeId}/services [post]
-func CreateService(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
+func (h *ServiceHandler) CreateService(c *fiber.Ctx) error {
 	user := c.Locals("user").(*models.User)
 	storeID, err := c.ParamsInt("storeId")
 	if err != nil {
@@ -29,13 +99,6 @@ func CreateService(c *fiber.Ctx) error {
 		})
 	}
 
-	// Validate store ownership
-	if err := validateStoreOwnership(db, uint(storeID), user.Vendor.ID); err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": err.Error(),
-		})
-	}
-
 	var serviceRequest models.CreateServiceRequest
 	if err := c.BodyParser(&serviceRequest); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -43,19 +106,9 @@ func CreateService(c *fiber.Ctx) error {
 		})
 	}
 
-	service := models.Service{
-		Name:        serviceRequest.Name,
-		Description: serviceRequest.Description,
-		Rate:        serviceRequest.Rate,
-		Currency:    serviceRequest.Currency,
-		StoreID:     uint(storeID),
-		ImageURL:    serviceRequest.ImageURL,
-	}
-
-	if err := db.Create(&service).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to create service",
-		})
+	service, err := h.services.Create(c.UserContext(), user, uint(storeID), serviceRequest)
+	if err != nil {
+		return serviceError(c, err, "Failed to create service")
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(service)
@@ -76,22 +129,12 @@ func CreateService(c *fiber.Ctx) error {
 // @Failure 403 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
 // @Router /api/v1/stores/{storeId}/services/{id} [put]
-func UpdateService(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
+func (h *ServiceHandler) UpdateService(c *fiber.Ctx) error {
 	user := c.Locals("user").(*models.User)
-	serviceID := c.Params("id")
-
-	var service models.Service
-	if err := db.Where("id = ?", serviceID).First(&service).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Service not found",
-		})
-	}
-
-	// Validate store ownership
-	if err := validateStoreOwnership(db, service.StoreID, user.Vendor.ID); err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": err.Error(),
+	serviceID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid service ID",
 		})
 	}
 
@@ -102,10 +145,9 @@ func UpdateService(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := db.Model(&service).Updates(updateData).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to update service",
-		})
+	service, err := h.services.Update(c.UserContext(), user, uint(serviceID), updateData)
+	if err != nil {
+		return serviceError(c, err, "Failed to update service")
 	}
 
 	return c.JSON(service)
@@ -124,29 +166,17 @@ func UpdateService(c *fiber.Ctx) error {
 // @Failure 403 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
 // @Router /api/v1/stores/{storeId}/services/{id} [delete]
-func DeleteService(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
+func (h *ServiceHandler) DeleteService(c *fiber.Ctx) error {
 	user := c.Locals("user").(*models.User)
-	serviceID := c.Params("id")
-
-	var service models.Service
-	if err := db.Where("id = ?", serviceID).First(&service).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Service not found",
-		})
-	}
-
-	// Validate store ownership
-	if err := validateStoreOwnership(db, service.StoreID, user.Vendor.ID); err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": err.Error(),
+	serviceID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid service ID",
 		})
 	}
 
-	if err := db.Delete(&service).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete service",
-		})
+	if err := h.services.Delete(c.UserContext(), user, uint(serviceID)); err != nil {
+		return serviceError(c, err, "Failed to delete service")
 	}
 
 	return c.JSON(fiber.Map{
@@ -156,18 +186,15 @@ func DeleteService(c *fiber.Ctx) error {
 
 // GetStoreServices godoc
 // @Summary Get all services for a store
-// @Description Get all services for a specific store
-// @Tags store-services
+// @Description Get all services offered by a specific store. Signed-in requests also get how each service relates to the user.
+// @Tags services
 // @Accept json
 // @Produce json
-// @Security BearerAuth
 // @Param storeId path string true "Store ID"
-// @Success 200 {array} models.Service
-// @Failure 403 {object} models.ErrorResponse
+// @Success 200 {array} ServiceResponse
+// @Failure 404 {object} models.ErrorResponse
 // @Router /api/v1/stores/{storeId}/services [get]
-func GetStoreServices(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
-	user := c.Locals("user").(*models.User)
+func (h *ServiceHandler) GetStoreServices(c *fiber.Ctx) error {
 	storeID, err := c.ParamsInt("storeId")
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -175,19 +202,14 @@ func GetStoreServices(c *fiber.Ctx) error {
 		})
 	}
 
-	// Validate store ownership
-	if err := validateStoreOwnership(db, uint(storeID), user.Vendor.ID); err != nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+	services, err := h.services.ListByStore(c.UserContext(), uint(storeID))
+	if err != nil {
+		return serviceError(c, err, "Failed to fetch services")
 	}
-
-	var services []models.Service
-	if err := db.Where("store_id = ?", storeID).Find(&services).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch services",
-		})
+	responses, err := h.responses(c, services)
+	if err != nil {
+		return serviceError(c, err, "Failed to fetch services")
 	}
 
-	return c.JSON(services)
+	return c.JSON(responses)
 }
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/models"
+)
+
+func TestGetServices(t *testing.T) {
+	api := newTestAPI(t)
+
+	var service models.Service
+	api.expect(fiber.StatusOK, "GET", "/api/v1/services/"+id(api.service.ID), nil, nil, &service)
+	if service.Name != "Fitting" {
+		t.Errorf("service %+v", service)
+	}
+	api.expect(fiber.StatusNotFound, "GET", "/api/v1/services/999", nil, nil, nil)
+
+	api.db.AddService(&models.Service{StoreID: api.store.ID, Name: "Alterations"})
+	var list []models.Service
+	api.expect(fiber.StatusOK, "GET", "/api/v1/stores/"+id(api.store.ID)+"/services", nil, nil, &list)
+	if len(list) != 2 || list[0].Name != "Alterations" {
+		t.Errorf("store services %+v", list)
+	}
+	if msg := api.expect(fiber.StatusNotFound, "GET", "/api/v1/stores/999/services", nil, nil, nil); msg != "Store not found" {
+		t.Errorf("missing store: %q", msg)
+	}
+}
+
+func TestGetServiceViewer(t *testing.T) {
+	api := newTestAPI(t)
+	path := "/api/v1/services/" + id(api.service.ID)
+
+	var anonymous ServiceResponse
+	api.expect(fiber.StatusOK, "GET", path, nil, nil, &anonymous)
+	if anonymous.Viewer != nil {
+		t.Errorf("anonymous viewer %+v", anonymous.Viewer)
+	}
+
+	var owner ServiceResponse
+	api.expect(fiber.StatusOK, "GET", path, api.owner, nil, &owner)
+	if owner.Viewer == nil || !owner.Viewer.CanEdit || owner.Viewer.Booked {
+		t.Errorf("owner viewer %+v", owner.Viewer)
+	}
+
+	booking := &models.Booking{ServiceID: api.service.ID, StoreID: api.store.ID, UserID: api.buyer.ID, Status: models.BookingStatusRequested}
+	api.db.AddBooking(booking)
+	var buyer ServiceResponse
+	api.expect(fiber.StatusOK, "GET", path, api.buyer, nil, &buyer)
+	if buyer.Viewer == nil || buyer.Viewer.Booked || buyer.Viewer.CanEdit {
+		t.Errorf("buyer viewer with a requested booking %+v", buyer.Viewer)
+	}
+
+	booking.Status = models.BookingStatusCompleted
+	api.db.AddBooking(booking)
+	api.expect(fiber.StatusOK, "GET", path, api.buyer, nil, &buyer)
+	if !buyer.Viewer.Booked {
+		t.Errorf("buyer viewer with a completed booking %+v", buyer.Viewer)
+	}
+
+	// Listings relate each service to the user
+	api.db.AddService(&models.Service{StoreID: api.store.ID, Name: "Alterations"})
+	var list []ServiceResponse
+	api.expect(fiber.StatusOK, "GET", "/api/v1/stores/"+id(api.store.ID)+"/services", api.buyer, nil, &list)
+	if len(list) != 2 {
+		t.Fatalf("store services %+v", list)
+	}
+	for _, listed := range list {
+		if listed.Viewer == nil || listed.Viewer.Booked != (listed.ID == api.service.ID) || listed.Viewer.CanEdit {
+			t.Errorf("buyer viewer of %s %+v", listed.Name, listed.Viewer)
+		}
+	}
+	var anonymousList []ServiceResponse
+	api.expect(fiber.StatusOK, "GET", "/api/v1/stores/"+id(api.store.ID)+"/services", nil, nil, &anonymousList)
+	if len(anonymousList) != 2 || anonymousList[0].Viewer != nil {
+		t.Errorf("anonymous store services %+v", anonymousList)
+	}
+}
+
+func TestManageServices(t *testing.T) {
+	api := newTestAPI(t)
+	path := "/api/v1/stores/" + id(api.store.ID) + "/services"
+
+	var created models.Service
+	api.expect(fiber.StatusCreated, "POST", path, api.owner,
+		models.CreateServiceRequest{Name: "Repairs", Rate: 15, Currency: "NGN"}, &created)
+	if created.ID == 0 || created.StoreID != api.store.ID {
+		t.Fatalf("created %+v", created)
+	}
+	api.expect(fiber.StatusForbidden, "POST", path, api.stranger, models.CreateServiceRequest{Name: "Repairs"}, nil)
+
+	var updated models.Service
+	api.expect(fiber.StatusOK, "PUT", path+"/"+id(created.ID), api.owner, models.UpdateServiceRequest{Rate: 30}, &updated)
+	if updated.Rate != 30 || updated.Name != "Repairs" {
+		t.Errorf("updated %+v", updated)
+	}
+	api.expect(fiber.StatusForbidden, "PUT", path+"/"+id(created.ID), api.buyer, models.UpdateServiceRequest{Rate: 1}, nil)
+
+	api.expect(fiber.StatusForbidden, "DELETE", path+"/"+id(created.ID), api.buyer, nil, nil)
+	api.expect(fiber.StatusOK, "DELETE", path+"/"+id(created.ID), api.owner, nil, nil)
+	api.expect(fiber.StatusNotFound, "GET", "/api/v1/services/"+id(created.ID), nil, nil, nil)
+}
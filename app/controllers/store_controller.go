@@ -1,21 +1,98 @@
 package controllers
 
 import (
-	"time"
+	"errors"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/theHoracle/whatstore-api/app/models"
-	"gorm.io/gorm"
+	"github.com/theHoracle/whatstore-api/app/services"
 )
 
+// StoreHandler serves the store endpoints
+type StoreHandler struct {
+	stores *services.Stores
+}
+
+// NewStoreHandler returns the store endpoints backed by stores
+func NewStoreHandler(stores *services.Stores) *StoreHandler {
+	return &StoreHandler{stores: stores}
+}
+
+// CreateStore godoc
+// @Summary Create a new store
+// @Description Create a new store for the authenticated vendor
+// @Tags stores
+// @Accept json
+// @Produce json
+// @Param input body models.CreateStoreRequest true "Store details"
+// @Success 201 {object} models.Store
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /store/create [post]
+func (h *StoreHandler) CreateStore(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+
+	var input models.CreateStoreRequest
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	store, err := h.stores.Create(c.UserContext(), user, input)
+	if err != nil {
+		return serviceError(c, err, "Could not create store")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(store)
+}
+
+// CheckStoreUrlAvailability godoc
+// @Summary Check if a store URL is available
+// @Description Check if a store URL is valid and not already taken, now or as a previous URL of another store. Returns the URL as it would be saved and, when unavailable, why.
+// @Tags stores
+// @Accept json
+// @Produce json
+// @Param url query string true "Store URL to check"
+// @Success 200 {object} object{available=boolean,store_url=string,reason=string}
+// @Failure 400 {object} models.ErrorResponse
+// @Router /stores/check-url [get]
+func (h *StoreHandler) CheckStoreUrlAvailability(c *fiber.Ctx) error {
+	url := c.Query("url")
+
+	if url == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "URL parameter is required",
+		})
+	}
+
+	storeURL, err := h.stores.CheckURL(c.UserContext(), url)
+	var ruleErr *services.Error
+	if errors.As(err, &ruleErr) {
+		return c.JSON(fiber.Map{
+			"available": false,
+			"store_url": storeURL,
+			"reason":    ruleErr.Message,
+		})
+	}
+	if err != nil {
+		return serviceError(c, err, "Could not check store URL")
+	}
+
+	return c.JSON(fiber.Map{
+		"available": true,
+		"store_url": storeURL,
+	})
+}
+
 // UpdateStore godoc
 // @Summary Update store details
-// @Description Update an existing store's information
+// @Description Update an existing store's information. Open to the store's owner and managers. When the store URL changes the old URL keeps redirecting to the store.
 // @Tags stores
 // @Accept json
 // @Produce json
 // @Param id path string true "Store ID"
-// @Param input body object true "Store update information" SchemaExample({"name":"Store Name","description":"Store Description","store_logo":"http://example.com/image.jpg"})
+// @Param input body models.UpdateStoreRequest true "Store update information"
 // @Success 200 {object} models.Store
 // @Failure 400 {object} object{error=string}
 // @Failure 403 {object} object{error=string}
@@ -23,50 +100,21 @@ import (
 // @Failure 500 {object} object{error=string}
 // @Security BearerAuth
 // @Router /stores/{id} [put]
-func UpdateStore(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
-	storeID := c.Params("id")
-	vendor := c.Locals("user").(*models.User)
-
-	var store models.Store
-	if err := db.First(&store, storeID).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Store not found"})
-	}
-
-	// Verify store belongs to vendor
-	var vendorStore models.Vendor
-	if err := db.First(&vendorStore, store.VendorID).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Vendor not found"})
-	}
-
-	if vendorStore.UserID != vendor.ID {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Not authorized to modify this store"})
-	}
-
-	var input struct {
-		Name                 string `json:"name"`
-		Description          string `json:"description"`
-		StoreLogo            string `json:"store_logo"`
-		StoreUrl             string `json:"store_url"`
-		StoreAddress         string `json:"store_address"`
-		StoreWhatsappContact string `json:"store_whatsapp_contact"`
+func (h *StoreHandler) UpdateStore(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	storeID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid store ID"})
 	}
 
+	var input models.UpdateStoreRequest
 	if err := c.BodyParser(&input); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
 	}
 
-	// Update all fields
-	store.Name = input.Name
-	store.Description = input.Description
-	store.StoreLogo = input.StoreLogo
-	store.StoreUrl = input.StoreUrl
-	store.StoreAddress = input.StoreAddress
-	store.StoreWhatsappContact = input.StoreWhatsappContact
-	store.UpdatedAt = time.Now()
-
-	if err := db.Save(&store).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update store"})
+	store, err := h.stores.Update(c.UserContext(), user, uint(storeID), input)
+	if err != nil {
+		return serviceError(c, err, "Could not update store")
 	}
 
 	return c.JSON(store)
@@ -74,7 +122,7 @@ func UpdateStore(c *fiber.Ctx) error {
 
 // DeleteStore godoc
 // @Summary Delete a store
-// @Description Delete a store and all associated data
+// @Description Delete a store and all associated data. Only the store's owner can delete it.
 // @Tags stores
 // @Produce json
 // @Param id path string true "Store ID"
@@ -84,59 +132,15 @@ func UpdateStore(c *fiber.Ctx) error {
 // @Failure 500 {object} object{error=string}
 // @Security BearerAuth
 // @Router /stores/{id} [delete]
-func DeleteStore(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
-	storeID := c.Params("id")
-	vendor := c.Locals("user").(*models.User)
-
-	var store models.Store
-	if err := db.First(&store, storeID).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Store not found"})
+func (h *StoreHandler) DeleteStore(c *fiber.Ctx) error {
+	user := c.Locals("user").(*models.User)
+	storeID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid store ID"})
 	}
 
-	// Verify ownership
-	var vendorStore models.Vendor
-	if err := db.First(&vendorStore, store.VendorID).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Vendor not found"})
-	}
-
-	if vendorStore.UserID != vendor.ID {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Not authorized to delete this store"})
-	}
-
-	// Start transaction
-	tx := db.Begin()
-
-	// Delete all associated products and services
-	if err := tx.Where("store_id = ?", store.ID).Delete(&models.Product{}).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete products"})
-	}
-
-	if err := tx.Delete(&store).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not delete store"})
-	}
-
-	// if vendor has no store set vendor.isActive to false
-	var vendorStores []models.Store
-	if err := tx.Where("vendor_id = ?", vendorStore.ID).Find(&vendorStores).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch vendor stores"})
-	}
-
-	if len(vendorStores) == 0 {
-		vendorStore.IsActive = false
-		if err := tx.Save(&vendorStore).Error; err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update vendor"})
-		}
-	}
-
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not commit transaction"})
+	if err := h.stores.Delete(c.UserContext(), user, uint(storeID)); err != nil {
+		return serviceError(c, err, "Could not delete store")
 	}
 
 	return c.SendStatus(fiber.StatusNoContent)
@@ -151,13 +155,15 @@ func DeleteStore(c *fiber.Ctx) error {
 // @Success 200 {object} models.Store
 // @Failure 404 {object} object{error=string}
 // @Router /stores/{id} [get]
-func GetStore(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
-	storeID := c.Params("id")
+func (h *StoreHandler) GetStore(c *fiber.Ctx) error {
+	storeID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid store ID"})
+	}
 
-	var store models.Store
-	if err := db.Preload("Products").Preload("Services").First(&store, storeID).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Store not found"})
+	store, err := h.stores.Get(c.UserContext(), uint(storeID))
+	if err != nil {
+		return serviceError(c, err, "Could not fetch store")
 	}
 
 	return c.JSON(store)
@@ -172,12 +178,14 @@ func GetStore(c *fiber.Ctx) error {
 // @Success 200 {array} models.Store
 // @Failure 500 {object} object{error=string}
 // @Router /vendors/{vendorId}/stores [get]
-func GetAllStores(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
-	vendorID := c.Params("vendorId")
+func (h *StoreHandler) GetAllStores(c *fiber.Ctx) error {
+	vendorID, err := c.ParamsInt("vendorId")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid vendor ID"})
+	}
 
-	var stores []models.Store
-	if err := db.Where("vendor_id = ?", vendorID).Find(&stores).Error; err != nil {
+	stores, err := h.stores.ListByVendor(c.UserContext(), uint(vendorID))
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch stores"})
 	}
 
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/models"
+)
+
+func TestCreateStore(t *testing.T) {
+	api := newTestAPI(t)
+	input := models.CreateStoreRequest{
+		StoreName:            "Second",
+		StoreUrl:             " Second-Store ",
+		StoreAddress:         "Lagos",
+		StoreWhatsappContact: "+2348012345678",
+	}
+
+	var store models.Store
+	api.expect(fiber.StatusCreated, "POST", "/api/v1/stores", api.owner, input, &store)
+	if store.StoreUrl != "second-store" || store.VendorID != api.vendor.ID || store.Timezone != "Africa/Lagos" {
+		t.Fatalf("created %+v", store)
+	}
+
+	// The creator owns the new store
+	api.expect(fiber.StatusOK, "PUT", "/api/v1/stores/"+id(store.ID), api.owner,
+		models.UpdateStoreRequest{Name: "Renamed", StoreUrl: "second-store"}, nil)
+
+	if msg := api.expect(fiber.StatusBadRequest, "POST", "/api/v1/stores", api.owner, input, nil); msg != "Store URL already taken" {
+		t.Errorf("duplicate URL: %q", msg)
+	}
+
+	input.StoreUrl, input.StoreWhatsappContact = "third", "08012345678"
+	if msg := api.expect(fiber.StatusBadRequest, "POST", "/api/v1/stores", api.owner, input, nil); msg != "Invalid phone number format" {
+		t.Errorf("bad phone: %q", msg)
+	}
+
+	input.StoreWhatsappContact, input.Timezone = "+2348012345678", "Lagos"
+	api.expect(fiber.StatusBadRequest, "POST", "/api/v1/stores", api.owner, input, nil)
+
+	input.Timezone = ""
+	if msg := api.expect(fiber.StatusNotFound, "POST", "/api/v1/stores", api.buyer, input, nil); msg != "Vendor account not found" {
+		t.Errorf("buyer: %q", msg)
+	}
+}
+
+func TestCheckStoreUrlAvailability(t *testing.T) {
+	api := newTestAPI(t)
+
+	cases := []struct {
+		url       string
+		available bool
+		storeURL  string
+	}{
+		{"New-Store", true, "new-store"},
+		{"ADAS", false, "adas"},
+		{"ab", false, "ab"},
+	}
+	for _, tc := range cases {
+		var got struct {
+			Available bool   `json:"available"`
+			StoreURL  string `json:"store_url"`
+			Reason    string `json:"reason"`
+		}
+		api.expect(fiber.StatusOK, "GET", "/api/v1/stores/check-url?url="+tc.url, nil, nil, &got)
+		if got.Available != tc.available || got.StoreURL != tc.storeURL || (!tc.available && got.Reason == "") {
+			t.Errorf("%s: got %+v", tc.url, got)
+		}
+	}
+
+	api.expect(fiber.StatusBadRequest, "GET", "/api/v1/stores/check-url", nil, nil, nil)
+}
+
+func TestUpdateStore(t *testing.T) {
+	api := newTestAPI(t)
+	path := "/api/v1/stores/" + id(api.store.ID)
+	input := models.UpdateStoreRequest{Name: "Ada's Shoes", StoreUrl: "ada-shoes", Timezone: "Europe/London"}
+
+	var store models.Store
+	api.expect(fiber.StatusOK, "PUT", path, api.owner, input, &store)
+	if store.Name != "Ada's Shoes" || store.StoreUrl != "ada-shoes" || store.Timezone != "Europe/London" {
+		t.Fatalf("updated %+v", store)
+	}
+
+	// The previous URL still redirects to the store, so no other store can take it
+	var check struct {
+		Available bool `json:"available"`
+	}
+	api.expect(fiber.StatusOK, "GET", "/api/v1/stores/check-url?url=adas", nil, nil, &check)
+	if check.Available {
+		t.Error("previous URL is available")
+	}
+
+	// Changing back is allowed
+	input.StoreUrl = "adas"
+	api.expect(fiber.StatusOK, "PUT", path, api.owner, input, nil)
+
+	api.expect(fiber.StatusForbidden, "PUT", path, api.stranger, input, nil)
+
+	// Catalog editors cannot change store settings, managers can
+	api.addMember(api.buyer, models.StoreRoleCatalogEditor)
+	api.expect(fiber.StatusForbidden, "PUT", path, api.buyer, input, nil)
+	api.addMember(api.stranger, models.StoreRoleManager)
+	api.expect(fiber.StatusOK, "PUT", path, api.stranger, input, nil)
+
+	input.Timezone = "Mars/Olympus"
+	api.expect(fiber.StatusBadRequest, "PUT", path, api.owner, input, nil)
+	api.expect(fiber.StatusBadRequest, "PUT", "/api/v1/stores/abc", api.owner, input, nil)
+}
+
+func TestDeleteStore(t *testing.T) {
+	api := newTestAPI(t)
+	path := "/api/v1/stores/" + id(api.store.ID)
+
+	api.addMember(api.stranger, models.StoreRoleManager)
+	if msg := api.expect(fiber.StatusForbidden, "DELETE", path, api.stranger, nil, nil); msg != "your role in this store does not allow this" {
+		t.Errorf("manager: %q", msg)
+	}
+	if msg := api.expect(fiber.StatusForbidden, "DELETE", path, api.buyer, nil, nil); msg != "store not found or not authorized" {
+		t.Errorf("buyer: %q", msg)
+	}
+
+	api.expect(fiber.StatusNoContent, "DELETE", path, api.owner, nil, nil)
+	api.expect(fiber.StatusNotFound, "GET", path, nil, nil, nil)
+	api.expect(fiber.StatusNotFound, "GET", "/api/v1/products/"+id(api.product.ID), nil, nil, nil)
+
+	// The vendor's last store is gone, so the vendor is no longer active
+	vendor, err := api.db.Users().VendorByUser(t.Context(), api.owner.ID)
+	if err != nil || vendor.IsActive {
+		t.Errorf("vendor %+v, %v", vendor, err)
+	}
+}
+
+func TestGetStores(t *testing.T) {
+	api := newTestAPI(t)
+
+	var store models.Store
+	api.expect(fiber.StatusOK, "GET", "/api/v1/stores/"+id(api.store.ID), nil, nil, &store)
+	if store.Name != "Ada's" || len(store.Products) != 2 || len(store.Services) != 1 {
+		t.Errorf("store %+v", store)
+	}
+	api.expect(fiber.StatusNotFound, "GET", "/api/v1/stores/999", nil, nil, nil)
+
+	var stores []models.Store
+	api.expect(fiber.StatusOK, "GET", "/api/v1/stores/vendor/"+id(api.vendor.ID), nil, nil, &stores)
+	if len(stores) != 1 || stores[0].ID != api.store.ID {
+		t.Errorf("vendor stores %+v", stores)
+	}
+}
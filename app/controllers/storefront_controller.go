@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/repository"
+	"github.com/theHoracle/whatstore-api/app/search"
+	"github.com/theHoracle/whatstore-api/app/services"
+	"gorm.io/gorm"
+)
+
+// StorefrontHandler serves the storefront endpoints
+type StorefrontHandler struct {
+	db *gorm.DB
+}
+
+// NewStorefrontHandler returns the storefront endpoints backed by db
+func NewStorefrontHandler(db *gorm.DB) *StorefrontHandler {
+	return &StorefrontHandler{db: db}
+}
+
+// Storefront is a store's public page: its profile, a page of its products
+// and services, and how many of its products are in each category
+type Storefront struct {
+	Store      models.Store        `json:"store"`
+	Products   PaginationResponse  `json:"products"`
+	Services   PaginationResponse  `json:"services"`
+	Categories []search.FacetCount `json:"categories"`
+	Viewer     *models.StoreViewer `json:"viewer,omitempty"` // How the store relates to the signed-in user
+}
+
+// GetStorefront godoc
+// @Summary Get a storefront
+// @Description Get a store's public profile by its URL with a page of its products and services and its product count per category. A store's previous URL answers with a 301 pointing at the current one. Signed-in requests also get how the store relates to the user.
+// @Tags storefronts
+// @Produce json
+// @Param slug path string true "Store URL"
+// @Param category query string false "Only products in this category ID or slug, including subcategories"
+// @Param page query int false "Page number of products and services"
+// @Param per_page query int false "Products and services per page"
+// @Success 200 {object} Storefront
+// @Success 301 {object} object{store_url=string}
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/storefronts/{slug} [get]
+func (h *StorefrontHandler) GetStorefront(c *fiber.Ctx) error {
+	db := h.db
+	url := strings.ToLower(strings.TrimSpace(c.Params("slug")))
+
+	var store models.Store
+	if err := db.Where("LOWER(store_url) = ?", url).First(&store).Error; err != nil {
+		var redirect models.StoreURLRedirect
+		if err := db.Preload("Store").Where("LOWER(store_url) = ?", url).First(&redirect).Error; err != nil || redirect.Store == nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Store not found"})
+		}
+
+		location := "/api/v1/storefronts/" + redirect.Store.StoreUrl
+		if query := string(c.Request().URI().QueryString()); query != "" {
+			location += "?" + query
+		}
+		c.Location(location)
+		return c.Status(fiber.StatusMovedPermanently).JSON(fiber.Map{"store_url": redirect.Store.StoreUrl})
+	}
+
+	return storefrontResponse(c, db, &store)
+}
+
+// GetDomainStorefront godoc
+// @Summary Get the storefront of the request's domain
+// @Description Get the storefront of the store whose verified custom domain the request was made to, with a page of its products and services and its product count per category. Signed-in requests also get how the store relates to the user.
+// @Tags storefronts
+// @Produce json
+// @Param category query string false "Only products in this category ID or slug, including subcategories"
+// @Param page query int false "Page number of products and services"
+// @Param per_page query int false "Products and services per page"
+// @Success 200 {object} Storefront
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/storefront [get]
+func (h *StorefrontHandler) GetDomainStorefront(c *fiber.Ctx) error {
+	db := h.db
+	store, ok := c.Locals("domainStore").(*models.Store)
+	if !ok || store == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Store not found"})
+	}
+	return storefrontResponse(c, db, store)
+}
+
+// storefrontResponse writes the storefront of store for the request's page
+// and category filter
+func storefrontResponse(c *fiber.Ctx, db *gorm.DB, store *models.Store) error {
+	page, perPage := paginate(c)
+
+	products := db.Model(&models.Product{}).Where("store_id = ?", store.ID)
+	if categoryParam := c.Query("category"); categoryParam != "" {
+		category, err := findCategory(db, categoryParam)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Category not found"})
+		}
+		categoryIDs, err := categoryWithDescendants(db, category.ID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch storefront"})
+		}
+		products = products.Where("category_id IN ?", categoryIDs)
+	}
+
+	var productTotal int64
+	var productPage []models.Product
+	products.Session(&gorm.Session{}).Count(&productTotal)
+	if err := repository.WithVariants(products.Preload("Category")).Order("id DESC").
+		Offset((page - 1) * perPage).Limit(perPage).
+		Find(&productPage).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch storefront"})
+	}
+
+	var serviceTotal int64
+	var servicePage []models.Service
+	db.Model(&models.Service{}).Where("store_id = ?", store.ID).Count(&serviceTotal)
+	if err := db.Where("store_id = ?", store.ID).Order("id DESC").
+		Offset((page - 1) * perPage).Limit(perPage).
+		Find(&servicePage).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch storefront"})
+	}
+
+	categories := []search.FacetCount{}
+	if err := db.Model(&models.Product{}).
+		Select("categories.id, categories.name, categories.slug, count(*) AS count").
+		Joins("JOIN categories ON categories.id = products.category_id").
+		Where("products.store_id = ?", store.ID).
+		Group("categories.id, categories.name, categories.slug").
+		Order("count DESC, categories.name").
+		Scan(&categories).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch storefront"})
+	}
+
+	storefront := Storefront{
+		Store:      *store,
+		Products:   NewPaginationResponse(productPage, productTotal, page, perPage),
+		Services:   NewPaginationResponse(servicePage, serviceTotal, page, perPage),
+		Categories: categories,
+	}
+	if user, ok := c.Locals("user").(*models.User); ok {
+		viewers := services.NewViewers(repository.NewStores(db), repository.NewOrders(db), repository.NewServices(db))
+		viewer, err := viewers.Store(c.UserContext(), user, store.ID, productPage)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not fetch storefront"})
+		}
+		storefront.Viewer = viewer
+	}
+	return c.JSON(storefront)
+}
@@ -3,9 +3,19 @@ package controllers
 import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/theHoracle/whatstore-api/app/models"
-	"gorm.io/gorm"
+	"github.com/theHoracle/whatstore-api/app/services"
 )
 
+// UserHandler serves the endpoints of the authenticated user's profile
+type UserHandler struct {
+	users *services.Users
+}
+
+// NewUserHandler returns the profile endpoints backed by users
+func NewUserHandler(users *services.Users) *UserHandler {
+	return &UserHandler{users: users}
+}
+
 // GetUserProfile godoc
 // @Summary Get user profile
 // @Description Get the profile of the authenticated user
@@ -16,14 +26,14 @@ import (
 // @Success 200 {object} models.User
 // @Failure 401 {object} models.ErrorResponse
 // @Router /api/v1/users/me [get]
-func GetUserProfile(c *fiber.Ctx) error {
+func (h *UserHandler) GetUserProfile(c *fiber.Ctx) error {
 	user := c.Locals("user").(*models.User)
 	return c.JSON(user)
 }
 
 // UpdateUserProfile godoc
 // @Summary Update user profile
-// @Description Update the profile of the authenticated user
+// @Description Update the name, phone number and shipping address of the authenticated user. Fields left empty are kept.
 // @Tags users
 // @Accept json
 // @Produce json
@@ -33,9 +43,8 @@ func GetUserProfile(c *fiber.Ctx) error {
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
 // @Router /api/v1/users/me [put]
-func UpdateUserProfile(c *fiber.Ctx) error {
+func (h *UserHandler) UpdateUserProfile(c *fiber.Ctx) error {
 	user := c.Locals("user").(*models.User)
-	db := c.Locals("db").(*gorm.DB)
 
 	var updateData models.UpdateUserRequest
 	if err := c.BodyParser(&updateData); err != nil {
@@ -44,18 +53,10 @@ func UpdateUserProfile(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := db.Model(user).Updates(updateData).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to update profile",
-		})
+	updated, err := h.users.UpdateProfile(c.UserContext(), user, updateData)
+	if err != nil {
+		return serviceError(c, err, "Failed to update profile")
 	}
 
-	// Fetch updated user data
-	if err := db.First(user, user.ID).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch updated profile",
-		})
-	}
-
-	return c.JSON(user)
+	return c.JSON(updated)
 }
@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/models"
+)
+
+func TestUserProfile(t *testing.T) {
+	api := newTestAPI(t)
+
+	var user models.User
+	api.expect(fiber.StatusOK, "GET", "/api/v1/users/me", api.buyer, nil, &user)
+	if user.Email != "bola@example.com" {
+		t.Errorf("profile %+v", user)
+	}
+
+	api.expect(fiber.StatusOK, "PUT", "/api/v1/users/me", api.buyer,
+		models.UpdateUserRequest{Phone: "+2348098765432", Address: "12 Allen Avenue"}, &user)
+	if user.Name != "Bola" || user.PhoneNumber != "+2348098765432" {
+		t.Errorf("updated %+v", user)
+	}
+	if got := api.db.ShippingAddress(api.buyer.ID); got != "12 Allen Avenue" {
+		t.Errorf("shipping address %q", got)
+	}
+
+	api.expect(fiber.StatusBadRequest, "PUT", "/api/v1/users/me", api.buyer, models.UpdateUserRequest{Phone: "0809"}, nil)
+}
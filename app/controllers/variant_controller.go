@@ -0,0 +1,396 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/inventory"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/rbac"
+	"gorm.io/gorm"
+)
+
+// VariantHandler serves the product variant endpoints
+type VariantHandler struct {
+	db *gorm.DB
+}
+
+// NewVariantHandler returns the product variant endpoints backed by db
+func NewVariantHandler(db *gorm.DB) *VariantHandler {
+	return &VariantHandler{db: db}
+}
+
+// variantError is a problem with a variant request that is reported back to the vendor
+type variantError struct {
+	status  int
+	message string
+}
+
+func (e *variantError) Error() string {
+	return e.message
+}
+
+func variantErrorResponse(c *fiber.Ctx, err error, fallback string) error {
+	var variantErr *variantError
+	if errors.As(err, &variantErr) {
+		return c.Status(variantErr.status).JSON(fiber.Map{"error": variantErr.message})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": fallback})
+}
+
+// vendorProduct loads the product in the path, checking it belongs to the
+// store in the path and the user may edit the store's catalog
+func vendorProduct(c *fiber.Ctx, db *gorm.DB) (*models.Product, error) {
+	user := c.Locals("user").(*models.User)
+	storeID, err := c.ParamsInt("storeId")
+	if err != nil {
+		return nil, &variantError{fiber.StatusBadRequest, "Invalid store ID"}
+	}
+
+	if err := authorizeStore(c.UserContext(), db, user, uint(storeID), rbac.StorePermissionCatalog); err != nil {
+		return nil, &variantError{fiber.StatusForbidden, err.Error()}
+	}
+
+	var product models.Product
+	err = db.Preload("Options", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Where("id = ? AND store_id = ?", c.Params("id"), storeID).
+		First(&product).Error
+	if err != nil {
+		return nil, &variantError{fiber.StatusNotFound, "Product not found"}
+	}
+	return &product, nil
+}
+
+// variantTitle checks that values picks one allowed value for every option
+// of the product and nothing else, and names the combination
+func variantTitle(options []models.ProductOption, values map[string]string) (string, error) {
+	if len(options) == 0 {
+		return "", &variantError{fiber.StatusBadRequest, "Set the product's options before adding variants"}
+	}
+	if len(values) != len(options) {
+		return "", &variantError{fiber.StatusBadRequest, "Variants need exactly one value for every product option"}
+	}
+
+	parts := make([]string, 0, len(options))
+	for _, option := range options {
+		value, ok := values[option.Name]
+		if !ok || !containsString(option.Values, value) {
+			return "", &variantError{fiber.StatusBadRequest, "Invalid value for option " + option.Name}
+		}
+		parts = append(parts, value)
+	}
+	return strings.Join(parts, " / "), nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// checkVariant validates a variant request against the product and its
+// other variants and copies it onto variant
+func checkVariant(db *gorm.DB, product *models.Product, variant *models.ProductVariant, input models.ProductVariantRequest) error {
+	if input.Stock < 0 {
+		return &variantError{fiber.StatusBadRequest, "Stock cannot be negative"}
+	}
+	if input.Price != nil && *input.Price <= 0 {
+		return &variantError{fiber.StatusBadRequest, "Price must be greater than zero"}
+	}
+
+	title, err := variantTitle(product.Options, input.OptionValues)
+	if err != nil {
+		return err
+	}
+
+	var siblings []models.ProductVariant
+	if err := db.Where("product_id = ? AND id <> ?", product.ID, variant.ID).Find(&siblings).Error; err != nil {
+		return err
+	}
+	for _, sibling := range siblings {
+		if sibling.Title == title {
+			return &variantError{fiber.StatusConflict, "A variant with these options already exists"}
+		}
+	}
+
+	if input.SKU != "" {
+		var taken int64
+		db.Model(&models.ProductVariant{}).
+			Joins("JOIN products ON products.id = product_variants.product_id").
+			Where("products.store_id = ? AND product_variants.sku = ? AND product_variants.id <> ?", product.StoreID, input.SKU, variant.ID).
+			Count(&taken)
+		if taken > 0 {
+			return &variantError{fiber.StatusConflict, "SKU is already used in this store"}
+		}
+	}
+
+	variant.ProductID = product.ID
+	variant.SKU = input.SKU
+	variant.Title = title
+	variant.OptionValues = input.OptionValues
+	variant.Price = input.Price
+	variant.Images = input.Images
+	return nil
+}
+
+// SetProductOptions godoc
+// @Summary Set product options
+// @Description Replace the options (e.g. Size, Color) a product's variants are made of. Values still used by variants cannot be removed.
+// @Tags store-products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param storeId path string true "Store ID"
+// @Param id path string true "Product ID"
+// @Param options body models.SetProductOptionsRequest true "Options in display order"
+// @Success 200 {array} models.ProductOption
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/stores/{storeId}/products/{id}/options [put]
+func (h *VariantHandler) SetProductOptions(c *fiber.Ctx) error {
+	db := h.db
+	product, err := vendorProduct(c, db)
+	if err != nil {
+		return variantErrorResponse(c, err, "Failed to load product")
+	}
+
+	var input models.SetProductOptionsRequest
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	options := make([]models.ProductOption, 0, len(input.Options))
+	seen := map[string]bool{}
+	for i, option := range input.Options {
+		name := strings.TrimSpace(option.Name)
+		if name == "" || seen[name] || len(option.Values) == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Options need a unique name and at least one value",
+			})
+		}
+		seen[name] = true
+		options = append(options, models.ProductOption{
+			ProductID: product.ID,
+			Name:      name,
+			Position:  i,
+			Values:    option.Values,
+		})
+	}
+
+	var variants []models.ProductVariant
+	if err := db.Where("product_id = ?", product.ID).Find(&variants).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load variants"})
+	}
+	for _, variant := range variants {
+		if _, err := variantTitle(options, variant.OptionValues); err != nil {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Variant " + variant.Title + " uses options or values that would be removed",
+			})
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_id = ?", product.ID).Delete(&models.ProductOption{}).Error; err != nil {
+			return err
+		}
+		if len(options) == 0 {
+			return nil
+		}
+		return tx.Create(&options).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save options"})
+	}
+
+	return c.JSON(options)
+}
+
+// CreateProductVariant godoc
+// @Summary Add a product variant
+// @Description Add a purchasable combination of option values with its own stock and, optionally, its own price and images. The product's stock becomes the sum of its variants' stock.
+// @Tags store-products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param storeId path string true "Store ID"
+// @Param id path string true "Product ID"
+// @Param variant body models.ProductVariantRequest true "Variant"
+// @Success 201 {object} models.ProductVariant
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/stores/{storeId}/products/{id}/variants [post]
+func (h *VariantHandler) CreateProductVariant(c *fiber.Ctx) error {
+	db := h.db
+	user := c.Locals("user").(*models.User)
+	product, err := vendorProduct(c, db)
+	if err != nil {
+		return variantErrorResponse(c, err, "Failed to load product")
+	}
+
+	var input models.ProductVariantRequest
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	var variant models.ProductVariant
+	if err := checkVariant(db, product, &variant, input); err != nil {
+		return variantErrorResponse(c, err, "Failed to create variant")
+	}
+	variant.Stock = input.Stock
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.ProductVariant{}).Where("product_id = ?", product.ID).Count(&count).Error; err != nil {
+			return err
+		}
+
+		// The first variant takes over stock keeping from the product itself
+		if count == 0 && product.Stock != 0 {
+			change := -product.Stock
+			product.Stock = 0
+			if err := tx.Model(product).UpdateColumn("stock", 0).Error; err != nil {
+				return err
+			}
+			if err := inventory.RecordAdjustment(tx, product, change, models.InventoryReasonAdjustment, &user.ID, "Stock moved to variants"); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(&variant).Error; err != nil {
+			return err
+		}
+		if variant.Stock == 0 {
+			return nil
+		}
+		return inventory.AdjustVariant(tx, product, &variant, variant.Stock, models.InventoryReasonInitialStock, &user.ID, "")
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create variant"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(variant)
+}
+
+// UpdateProductVariant godoc
+// @Summary Update a product variant
+// @Description Change a variant's options, SKU, price, images or stock
+// @Tags store-products
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param storeId path string true "Store ID"
+// @Param id path string true "Product ID"
+// @Param variantId path string true "Variant ID"
+// @Param variant body models.ProductVariantRequest true "Variant"
+// @Success 200 {object} models.ProductVariant
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/stores/{storeId}/products/{id}/variants/{variantId} [put]
+func (h *VariantHandler) UpdateProductVariant(c *fiber.Ctx) error {
+	db := h.db
+	user := c.Locals("user").(*models.User)
+	product, err := vendorProduct(c, db)
+	if err != nil {
+		return variantErrorResponse(c, err, "Failed to load product")
+	}
+
+	var variant models.ProductVariant
+	if err := db.Where("id = ? AND product_id = ?", c.Params("variantId"), product.ID).First(&variant).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Variant not found"})
+	}
+
+	var input models.ProductVariantRequest
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	if err := checkVariant(db, product, &variant, input); err != nil {
+		return variantErrorResponse(c, err, "Failed to update variant")
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("sku", "title", "option_values", "price", "images").Save(&variant).Error; err != nil {
+			return err
+		}
+
+		change := input.Stock - variant.Stock
+		if change == 0 {
+			return nil
+		}
+		// Apply the change relative to the current stock so concurrent
+		// reservations are not overwritten
+		if err := tx.Model(&variant).UpdateColumn("stock", gorm.Expr("stock + ?", change)).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ProductVariant{}).Select("stock").Where("id = ?", variant.ID).Scan(&variant.Stock).Error; err != nil {
+			return err
+		}
+		return inventory.AdjustVariant(tx, product, &variant, change, models.InventoryReasonAdjustment, &user.ID, "Stock updated by vendor")
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update variant"})
+	}
+
+	return c.JSON(variant)
+}
+
+// DeleteProductVariant godoc
+// @Summary Delete a product variant
+// @Description Remove a variant that has never been ordered. Its stock is taken off the product.
+// @Tags store-products
+// @Produce json
+// @Security BearerAuth
+// @Param storeId path string true "Store ID"
+// @Param id path string true "Product ID"
+// @Param variantId path string true "Variant ID"
+// @Success 204
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/v1/stores/{storeId}/products/{id}/variants/{variantId} [delete]
+func (h *VariantHandler) DeleteProductVariant(c *fiber.Ctx) error {
+	db := h.db
+	user := c.Locals("user").(*models.User)
+	product, err := vendorProduct(c, db)
+	if err != nil {
+		return variantErrorResponse(c, err, "Failed to load product")
+	}
+
+	var variant models.ProductVariant
+	if err := db.Where("id = ? AND product_id = ?", c.Params("variantId"), product.ID).First(&variant).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Variant not found"})
+	}
+
+	var ordered int64
+	db.Model(&models.OrderItem{}).Where("variant_id = ?", variant.ID).Count(&ordered)
+	if ordered > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Variant has been ordered, set its stock to 0 instead",
+		})
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if variant.Stock != 0 {
+			change := -variant.Stock
+			variant.Stock = 0
+			if err := inventory.AdjustVariant(tx, product, &variant, change, models.InventoryReasonAdjustment, &user.ID, "Variant deleted"); err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("variant_id = ?", variant.ID).Delete(&models.CartItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&variant).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete variant"})
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
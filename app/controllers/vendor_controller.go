@@ -1,15 +1,24 @@
 package controllers
 
 import (
-	"fmt"
-	"regexp"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/escrow"
 	"github.com/theHoracle/whatstore-api/app/models"
 	"gorm.io/gorm"
 )
 
+// VendorHandler serves the vendor endpoints
+type VendorHandler struct {
+	db *gorm.DB
+}
+
+// NewVendorHandler returns the vendor endpoints backed by db
+func NewVendorHandler(db *gorm.DB) *VendorHandler {
+	return &VendorHandler{db: db}
+}
+
 // CreateVendor godoc
 // @Summary Create a new vendor account
 // @Description Create a new vendor account with an initial store
@@ -22,8 +31,8 @@ import (
 // @Failure 500 {object} models.ErrorResponse
 // @Security BearerAuth
 // @Router /vendors [post]
-func CreateVendor(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
+func (h *VendorHandler) CreateVendor(c *fiber.Ctx) error {
+	db := h.db
 	// var input models.CreateVendorRequest
 	// if err := c.BodyParser(&input); err != nil {
 	// 	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
@@ -45,7 +54,16 @@ func CreateVendor(c *fiber.Ctx) error {
 		UpdatedAt: time.Now(),
 	}
 
-	if err := db.Create(&vendor).Error; err != nil {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&vendor).Error; err != nil {
+			return err
+		}
+		// Buyers become vendors, platform roles are kept
+		return tx.Model(&models.User{}).
+			Where("id = ? AND role = ?", userID, models.RoleBuyer).
+			Update("role", models.RoleVendor).Error
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create vendor"})
 	}
 
@@ -59,28 +77,39 @@ func CreateVendor(c *fiber.Ctx) error {
 // @Accept json
 // @Produce json
 // @Param id path string true "Vendor ID"
+// @Param vendor body models.UpdateVendorRequest true "Vendor details"
 // @Success 200 {object} models.Vendor
 // @Failure 400 {object} object{error=string}
+// @Failure 403 {object} object{error=string}
 // @Failure 404 {object} object{error=string}
 // @Failure 500 {object} object{error=string}
 // @Security BearerAuth
 // @Router /vendors/{id} [put]
-func UpdateVendor(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
+func (h *VendorHandler) UpdateVendor(c *fiber.Ctx) error {
+	db := h.db
 	id := c.Params("id")
-	// currentUserID := c.Locals("user").(*models.User).ID
+	currentUserID := c.Locals("user").(*models.User).ID
 
 	vendor := new(models.Vendor)
 
 	if err := db.First(&vendor, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Vendor not found"})
 	}
+	if vendor.UserID != currentUserID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Not authorized to modify this vendor"})
+	}
 
-	if err := c.BodyParser(vendor); err != nil {
+	// Only the editable fields are taken from the body, so it cannot change
+	// which vendor is saved or who owns it
+	input := new(models.UpdateVendorRequest)
+	if err := c.BodyParser(input); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
 	}
+	if input.IsActive != nil {
+		vendor.IsActive = *input.IsActive
+	}
 
-	if err := db.Save(&vendor).Error; err != nil {
+	if err := db.Save(vendor).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Cannot update vendor"})
 	}
 
@@ -94,20 +123,32 @@ func UpdateVendor(c *fiber.Ctx) error {
 // @Produce json
 // @Param id path string true "Vendor ID"
 // @Success 204 "No Content"
+// @Failure 403 {object} object{error=string}
 // @Failure 404 {object} object{error=string}
 // @Failure 500 {object} object{error=string}
 // @Security BearerAuth
 // @Router /vendors/{id} [delete]
-func DeleteVendor(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
+func (h *VendorHandler) DeleteVendor(c *fiber.Ctx) error {
+	db := h.db
 	id := c.Params("id")
 	vendor := new(models.Vendor)
 
 	if err := db.First(&vendor, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Vendor not found"})
 	}
+	if vendor.UserID != c.Locals("user").(*models.User).ID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Not authorized to delete this vendor"})
+	}
 
-	if err := db.Delete(&vendor).Error; err != nil {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&vendor).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).
+			Where("id = ? AND role = ?", vendor.UserID, models.RoleVendor).
+			Update("role", models.RoleBuyer).Error
+	})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Cannot delete vendor"})
 	}
 
@@ -123,8 +164,8 @@ func DeleteVendor(c *fiber.Ctx) error {
 // @Success 200 {object} models.Vendor
 // @Failure 404 {object} object{error=string}
 // @Router /vendors/{id} [get]
-func GetVendor(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
+func (h *VendorHandler) GetVendor(c *fiber.Ctx) error {
+	db := h.db
 	id := c.Params("id")
 	vendor := new(models.Vendor)
 
@@ -143,8 +184,8 @@ func GetVendor(c *fiber.Ctx) error {
 // @Success 200 {array} models.Vendor
 // @Failure 500 {object} object{error=string}
 // @Router /vendors [get]
-func GetAllVendors(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
+func (h *VendorHandler) GetAllVendors(c *fiber.Ctx) error {
+	db := h.db
 	var vendors []models.Vendor
 
 	if err := db.Find(&vendors).Error; err != nil {
@@ -154,97 +195,42 @@ func GetAllVendors(c *fiber.Ctx) error {
 	return c.JSON(vendors)
 }
 
-// CreateStore godoc
-// @Summary Create a new store
-// @Description Create a new store for the authenticated vendor
-// @Tags stores
-// @Accept json
+// GetVendorBalance godoc
+// @Summary Get vendor escrow balance
+// @Description Get the held and available balances of every store owned by the vendor
+// @Tags vendors
 // @Produce json
-// @Param input body models.CreateStoreRequest true "Store details"
-// @Success 201 {object} models.Store
-// @Failure 400 {object} models.ErrorResponse
-// @Failure 404 {object} models.ErrorResponse
+// @Param id path string true "Vendor ID"
+// @Success 200 {object} object{vendor_id=int,totals=[]escrow.CurrencyBalance,stores=[]escrow.StoreBalance}
+// @Failure 403 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Security BearerAuth
-// @Router /store/create [post]
-func CreateStore(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
+// @Router /vendors/{id}/balance [get]
+func (h *VendorHandler) GetVendorBalance(c *fiber.Ctx) error {
+	db := h.db
 	user := c.Locals("user").(*models.User)
-
-	var input models.CreateStoreRequest
-	if err := c.BodyParser(&input); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
-	}
-
-	// Get vendor information for the authenticated user
-	var vendor models.Vendor
-	if err := db.Where("user_id = ?", user.ID).First(&vendor).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Vendor account not found"})
+	vendorID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid vendor ID"})
 	}
 
-	// Validate the phone number format
-	if err := VaidatePhoneNumber(input.StoreWhatsappContact); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid phone number format"})
-	}
-	// Check if the store URL is already taken
-	var existingStore models.Store
-	if err := db.Where("store_url = ?", input.StoreUrl).First(&existingStore).Error; err == nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Store URL already taken"})
+	if user.Vendor == nil || user.Vendor.ID != uint(vendorID) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Not authorized to view this balance"})
 	}
 
-	store := models.Store{
-		VendorID:             vendor.ID,
-		Name:                 input.StoreName,
-		Description:          input.StoreDescription,
-		StoreLogo:            input.StoreLogo,
-		StoreUrl:             input.StoreUrl,
-		StoreAddress:         input.StoreAddress,
-		StoreWhatsappContact: input.StoreWhatsappContact,
-		CreatedAt:            time.Now(),
-		UpdatedAt:            time.Now(),
+	var storeIDs []uint
+	if err := db.Model(&models.Store{}).Where("vendor_id = ?", vendorID).Pluck("id", &storeIDs).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Cannot fetch vendor stores"})
 	}
 
-	if err := db.Create(&store).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	balances, err := escrow.StoreBalances(db, storeIDs)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Cannot fetch balance"})
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(store)
-}
-
-// CheckStoreUrlAvailability godoc
-// @Summary Check if a store URL is available
-// @Description Check if a store URL is already taken
-// @Tags stores
-// @Accept json
-// @Produce json
-// @Param url query string true "Store URL to check"
-// @Success 200 {object} object{available=boolean}
-// @Failure 400 {object} models.ErrorResponse
-// @Router /stores/check-url [get]
-func CheckStoreUrlAvailability(c *fiber.Ctx) error {
-	db := c.Locals("db").(*gorm.DB)
-	url := c.Query("url")
-
-	if url == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "URL parameter is required",
-		})
-	}
-
-	var count int64
-	db.Model(&models.Store{}).Where("store_url = ?", url).Count(&count)
-
 	return c.JSON(fiber.Map{
-		"available": count == 0,
+		"vendor_id": vendorID,
+		"totals":    escrow.Totals(balances),
+		"stores":    balances,
 	})
 }
-
-var e164Regex = regexp.MustCompile(`^\+[1-9]\d{1,14}$`)
-
-func VaidatePhoneNumber(phone string) error {
-	// Check if the phone number matches the E.164 format
-	if !e164Regex.MatchString(phone) {
-		return fmt.Errorf("invalid phone number format")
-	}
-	return nil
-}
@@ -0,0 +1,336 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/rbac"
+	"github.com/theHoracle/whatstore-api/app/whatsapp"
+	"gorm.io/gorm"
+)
+
+// WhatsappHandler serves the WhatsApp handoff endpoints
+type WhatsappHandler struct {
+	db *gorm.DB
+}
+
+// NewWhatsappHandler returns the WhatsApp handoff endpoints backed by db
+func NewWhatsappHandler(db *gorm.DB) *WhatsappHandler {
+	return &WhatsappHandler{db: db}
+}
+
+// WhatsappLink is a click-to-chat link that hands an order over to a store on WhatsApp
+type WhatsappLink struct {
+	StoreID uint   `json:"store_id"`
+	Phone   string `json:"phone"`
+	Message string `json:"message"`
+	URL     string `json:"url"`
+}
+
+func newWhatsappLink(store *models.Store, msg whatsapp.OrderMessage) WhatsappLink {
+	msg.StoreName = store.Name
+	message := whatsapp.RenderMessage(store.WhatsappTemplate, msg)
+
+	return WhatsappLink{
+		StoreID: store.ID,
+		Phone:   store.StoreWhatsappContact,
+		Message: message,
+		URL:     whatsapp.ClickToChatURL(store.StoreWhatsappContact, message),
+	}
+}
+
+// sendQRCode responds with a PNG QR code of link, sized by the size query parameter
+func sendQRCode(c *fiber.Ctx, link string) error {
+	size := c.QueryInt("size", 256)
+	if size < 128 {
+		size = 128
+	} else if size > 1024 {
+		size = 1024
+	}
+
+	png, err := whatsapp.QRCode(link, size)
+	if errors.Is(err, whatsapp.ErrLinkTooLong) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "The message is too long for a QR code, use the WhatsApp link instead",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate QR code",
+		})
+	}
+
+	c.Type("png")
+	return c.Send(png)
+}
+
+// orderWhatsappLink builds the WhatsApp handoff link for an order the user bought or sells
+func orderWhatsappLink(ctx context.Context, db *gorm.DB, user *models.User, orderID string) (*WhatsappLink, error) {
+	var order models.Order
+	if err := db.Preload("Items.Product").Preload("Items.Variant").Preload("Store").Preload("User.UserDetails").First(&order, orderID).Error; err != nil {
+		return nil, err
+	}
+
+	if len(orderActors(ctx, db, user, &order)) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	msg := whatsapp.OrderMessage{
+		OrderNumber: "#" + strconv.Itoa(int(order.ID)),
+		BuyerName:   order.User.Name,
+		Currency:    "NGN",
+	}
+	if order.User.UserDetails != nil {
+		msg.DeliveryAddress = order.User.UserDetails.ShippingAddress
+	}
+	for _, item := range order.Items {
+		if item.Product.Currency != "" {
+			msg.Currency = item.Product.Currency
+		}
+		msg.Items = append(msg.Items, whatsapp.LineItem{
+			Name:      whatsapp.ItemName(&item.Product, item.Variant),
+			Quantity:  item.Quantity,
+			UnitPrice: item.Price,
+		})
+	}
+
+	link := newWhatsappLink(&order.Store, msg)
+	return &link, nil
+}
+
+// GetOrderWhatsappLink godoc
+// @Summary Get WhatsApp link for an order
+// @Description Get a wa.me link that opens a chat with the store with the order details pre-filled
+// @Tags orders
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Success 200 {object} WhatsappLink
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/orders/{id}/whatsapp [get]
+func (h *WhatsappHandler) GetOrderWhatsappLink(c *fiber.Ctx) error {
+	db := h.db
+	user := c.Locals("user").(*models.User)
+
+	link, err := orderWhatsappLink(c.UserContext(), db, user, c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Order not found"})
+	}
+
+	return c.JSON(link)
+}
+
+// GetOrderWhatsappQR godoc
+// @Summary Get WhatsApp QR code for an order
+// @Description Get a PNG QR code of the order's WhatsApp link
+// @Tags orders
+// @Produce png
+// @Security BearerAuth
+// @Param id path string true "Order ID"
+// @Param size query int false "Image size in pixels (128-1024)"
+// @Success 200 {file} binary
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/orders/{id}/whatsapp/qr [get]
+func (h *WhatsappHandler) GetOrderWhatsappQR(c *fiber.Ctx) error {
+	db := h.db
+	user := c.Locals("user").(*models.User)
+
+	link, err := orderWhatsappLink(c.UserContext(), db, user, c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Order not found"})
+	}
+
+	return sendQRCode(c, link.URL)
+}
+
+// cartWhatsappLinks builds one WhatsApp handoff link per store in the cart.
+// Guests can pass their name and address as query parameters.
+func cartWhatsappLinks(c *fiber.Ctx, db *gorm.DB) ([]WhatsappLink, error) {
+	cart, err := currentCart(c, db, false)
+	if err != nil {
+		return nil, err
+	}
+	if cart == nil {
+		return []WhatsappLink{}, nil
+	}
+
+	if _, err := refreshCart(db, cart); err != nil {
+		return nil, err
+	}
+
+	buyerName := c.Query("name")
+	deliveryAddress := c.Query("address")
+	if user, ok := c.Locals("user").(*models.User); ok {
+		if buyerName == "" {
+			buyerName = user.Name
+		}
+		if deliveryAddress == "" {
+			var details models.UserDetails
+			if err := db.Where("user_id = ?", user.ID).First(&details).Error; err == nil {
+				deliveryAddress = details.ShippingAddress
+			}
+		}
+	}
+
+	var storeIDs []uint
+	messages := map[uint]*whatsapp.OrderMessage{}
+	for _, item := range cart.Items {
+		msg, ok := messages[item.Product.StoreID]
+		if !ok {
+			msg = &whatsapp.OrderMessage{
+				OrderNumber:     "New order",
+				BuyerName:       buyerName,
+				DeliveryAddress: deliveryAddress,
+				Currency:        item.Product.Currency,
+			}
+			messages[item.Product.StoreID] = msg
+			storeIDs = append(storeIDs, item.Product.StoreID)
+		}
+		msg.Items = append(msg.Items, whatsapp.LineItem{
+			Name:      whatsapp.ItemName(&item.Product, item.Variant),
+			Quantity:  item.Quantity,
+			UnitPrice: item.UnitPrice,
+		})
+	}
+
+	var stores []models.Store
+	if len(storeIDs) > 0 {
+		if err := db.Where("id IN ?", storeIDs).Order("id").Find(&stores).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	links := make([]WhatsappLink, 0, len(stores))
+	for i := range stores {
+		links = append(links, newWhatsappLink(&stores[i], *messages[stores[i].ID]))
+	}
+	return links, nil
+}
+
+// GetCartWhatsappLinks godoc
+// @Summary Get WhatsApp links for the cart
+// @Description Get one wa.me link per store in the cart with the cart's items for that store pre-filled
+// @Tags cart
+// @Produce json
+// @Param name query string false "Buyer name, for guests"
+// @Param address query string false "Delivery address, for guests"
+// @Success 200 {array} WhatsappLink
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/v1/cart/whatsapp [get]
+func (h *WhatsappHandler) GetCartWhatsappLinks(c *fiber.Ctx) error {
+	db := h.db
+
+	links, err := cartWhatsappLinks(c, db)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to build WhatsApp links",
+		})
+	}
+
+	return c.JSON(links)
+}
+
+// GetCartWhatsappQR godoc
+// @Summary Get WhatsApp QR code for the cart
+// @Description Get a PNG QR code of the cart's WhatsApp link for one store
+// @Tags cart
+// @Produce png
+// @Param store_id query int true "Store ID"
+// @Param size query int false "Image size in pixels (128-1024)"
+// @Param name query string false "Buyer name, for guests"
+// @Param address query string false "Delivery address, for guests"
+// @Success 200 {file} binary
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/v1/cart/whatsapp/qr [get]
+func (h *WhatsappHandler) GetCartWhatsappQR(c *fiber.Ctx) error {
+	db := h.db
+	storeID := uint(c.QueryInt("store_id"))
+
+	links, err := cartWhatsappLinks(c, db)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to build WhatsApp links",
+		})
+	}
+
+	for _, link := range links {
+		if link.StoreID == storeID {
+			return sendQRCode(c, link.URL)
+		}
+	}
+
+	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		"error": "No items from this store in the cart",
+	})
+}
+
+// UpdateStoreWhatsappTemplate godoc
+// @Summary Update store WhatsApp message template
+// @Description Set the message pre-filled in WhatsApp order links. Placeholders: {{order_number}}, {{store_name}}, {{buyer_name}}, {{delivery_address}}, {{items}}, {{total}}, {{currency}}. An empty template restores the default.
+// @Tags stores
+// @Accept json
+// @Produce json
+// @Param id path string true "Store ID"
+// @Param input body models.UpdateWhatsappTemplateRequest true "Message template"
+// @Success 200 {object} object{template=string,preview=string}
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /stores/{id}/whatsapp-template [put]
+func (h *WhatsappHandler) UpdateStoreWhatsappTemplate(c *fiber.Ctx) error {
+	db := h.db
+	user := c.Locals("user").(*models.User)
+	storeID, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid store ID"})
+	}
+
+	if err := authorizeStore(c.UserContext(), db, user, uint(storeID), rbac.StorePermissionSettings); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var input models.UpdateWhatsappTemplateRequest
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid input"})
+	}
+
+	if err := whatsapp.ValidateTemplate(input.Template); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":        err.Error(),
+			"placeholders": whatsapp.Placeholders,
+		})
+	}
+
+	var store models.Store
+	if err := db.First(&store, storeID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Store not found"})
+	}
+
+	store.WhatsappTemplate = input.Template
+	if err := db.Model(&store).Update("whatsapp_template", store.WhatsappTemplate).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Could not update store"})
+	}
+
+	preview := whatsapp.RenderMessage(store.WhatsappTemplate, whatsapp.OrderMessage{
+		OrderNumber:     "#1024",
+		StoreName:       store.Name,
+		BuyerName:       "Ada Obi",
+		DeliveryAddress: "12 Allen Avenue, Ikeja, Lagos",
+		Currency:        "NGN",
+		Items: []whatsapp.LineItem{
+			{Name: "Sample product", Quantity: 2, UnitPrice: 2500},
+		},
+	})
+
+	return c.JSON(fiber.Map{
+		"template": store.WhatsappTemplate,
+		"preview":  preview,
+	})
+}
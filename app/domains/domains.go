@@ -0,0 +1,301 @@
+package domains
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"gorm.io/gorm"
+)
+
+// RecordPrefix is the label the verification TXT record is published under,
+// e.g. _whatstore-verify.shop.example.com
+const RecordPrefix = "_whatstore-verify"
+
+const valuePrefix = "whatstore-verify="
+
+const (
+	// RecheckInterval is how often the record of a verified domain is checked again
+	RecheckInterval = 24 * time.Hour
+	// MaxPendingFailures is how many failed checks a new domain gets before
+	// verification gives up, about four days with the retry backoff
+	MaxPendingFailures = 20
+	// MaxRecheckFailures is how many failed checks in a row a verified domain
+	// survives, so a DNS hiccup does not take a storefront offline
+	MaxRecheckFailures = 3
+
+	firstRetry    = 5 * time.Minute
+	maxRetry      = 6 * time.Hour
+	lookupTimeout = 10 * time.Second
+)
+
+var (
+	ErrInvalidDomain  = errors.New("domain must be a host name such as shop.example.com")
+	ErrPlatformDomain = errors.New("this domain belongs to the platform")
+)
+
+// Resolver looks up DNS TXT records. *net.Resolver satisfies it.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+var defaultResolver Resolver = net.DefaultResolver
+
+// Use sets the resolver verification records are looked up with
+func Use(r Resolver) {
+	defaultResolver = r
+}
+
+// platformHosts are the hosts the API and the main site are served on
+var platformHosts []string
+
+// UsePlatformHosts sets the hosts the platform itself is served on. They and
+// their subdomains cannot be added as custom domains and are never looked up
+// as one.
+func UsePlatformHosts(hosts []string) {
+	platformHosts = platformHosts[:0]
+	for _, host := range hosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			platformHosts = append(platformHosts, host)
+		}
+	}
+}
+
+// IsPlatformHost reports whether host is, or is under, a platform host
+func IsPlatformHost(host string) bool {
+	for _, platform := range platformHosts {
+		if host == platform || strings.HasSuffix(host, "."+platform) {
+			return true
+		}
+	}
+	return false
+}
+
+var labelPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Normalize lowercases a domain and drops a trailing dot, then checks it is a
+// host name of at least two labels with a top level domain that is not a number
+func Normalize(raw string) (string, error) {
+	domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(raw)), ".")
+	if len(domain) == 0 || len(domain) > 253 {
+		return domain, ErrInvalidDomain
+	}
+
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return domain, ErrInvalidDomain
+	}
+	for _, label := range labels {
+		if !labelPattern.MatchString(label) {
+			return domain, ErrInvalidDomain
+		}
+	}
+	if strings.Trim(labels[len(labels)-1], "0123456789") == "" {
+		return domain, ErrInvalidDomain
+	}
+
+	if IsPlatformHost(domain) {
+		return domain, ErrPlatformDomain
+	}
+	return domain, nil
+}
+
+// NewToken generates a verification token
+func NewToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// RecordName is the name the verification TXT record of domain is published under
+func RecordName(domain string) string {
+	return RecordPrefix + "." + domain
+}
+
+// RecordValue is the content of the verification TXT record for token
+func RecordValue(token string) string {
+	return valuePrefix + token
+}
+
+// retryAfter backs off exponentially from five minutes to six hours
+func retryAfter(failures int) time.Duration {
+	wait := firstRetry
+	for i := 1; i < failures && wait < maxRetry; i++ {
+		wait *= 2
+	}
+	if wait > maxRetry {
+		return maxRetry
+	}
+	return wait
+}
+
+// Check looks up the verification record of a domain and records the outcome.
+// A domain with the record is verified, unless another store verified it
+// first, and checked again after RecheckInterval. Without it the check is retried with backoff until the
+// domain runs out of attempts and fails; failed domains are not checked again
+// until the vendor asks for it with Verify.
+func Check(ctx context.Context, db *gorm.DB, domain *models.StoreDomain) error {
+	ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+
+	found := false
+	records, lookupErr := defaultResolver.LookupTXT(ctx, RecordName(domain.Domain))
+	for _, record := range records {
+		if strings.TrimSpace(record) == RecordValue(domain.Token) {
+			found = true
+			break
+		}
+	}
+
+	now := time.Now()
+	claiming := found && domain.Status != models.DomainStatusVerified
+	domain.LastCheckedAt = &now
+	if found {
+		if domain.Status != models.DomainStatusVerified {
+			domain.VerifiedAt = &now
+		}
+		domain.Status = models.DomainStatusVerified
+		domain.Failures = 0
+		domain.LastError = ""
+		next := now.Add(RecheckInterval)
+		domain.NextCheckAt = &next
+	} else {
+		domain.Failures++
+		if lookupErr != nil {
+			domain.LastError = fmt.Sprintf("could not look up %s: %v", RecordName(domain.Domain), lookupErr)
+		} else {
+			domain.LastError = fmt.Sprintf("no TXT record %q found at %s", RecordValue(domain.Token), RecordName(domain.Domain))
+		}
+
+		limit := MaxPendingFailures
+		if domain.Status == models.DomainStatusVerified {
+			limit = MaxRecheckFailures
+		}
+		if domain.Failures >= limit {
+			domain.Status = models.DomainStatusFailed
+			domain.NextCheckAt = nil
+		} else {
+			next := now.Add(retryAfter(domain.Failures))
+			domain.NextCheckAt = &next
+		}
+	}
+
+	if claiming {
+		return claim(db, domain)
+	}
+	return save(db, domain)
+}
+
+// claimedError is the last error of a domain another store verified first
+const claimedError = "domain is verified by another store"
+
+// claim verifies a domain for its store unless another store verified it
+// first. Several stores may publish a record for the same domain; the first
+// one found wins, and the other stores' claims fail.
+func claim(db *gorm.DB, domain *models.StoreDomain) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var taken int64
+		if err := tx.Model(&models.StoreDomain{}).
+			Where("domain = ? AND status = ? AND id <> ?", domain.Domain, models.DomainStatusVerified, domain.ID).
+			Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			lose(domain)
+			return save(tx, domain)
+		}
+
+		if err := save(tx, domain); err != nil {
+			return err
+		}
+		return tx.Model(&models.StoreDomain{}).
+			Where("domain = ? AND status = ? AND id <> ?", domain.Domain, models.DomainStatusPending, domain.ID).
+			Updates(map[string]interface{}{"status": models.DomainStatusFailed, "next_check_at": nil, "last_error": claimedError}).Error
+	})
+	// Only one store's domain can be verified, the database rejects a
+	// claim that raced another
+	if isUniqueViolation(err) {
+		lose(domain)
+		return save(db, domain)
+	}
+	return err
+}
+
+// lose fails a domain another store has verified
+func lose(domain *models.StoreDomain) {
+	domain.Status = models.DomainStatusFailed
+	domain.VerifiedAt = nil
+	domain.NextCheckAt = nil
+	domain.LastError = claimedError
+}
+
+func save(db *gorm.DB, domain *models.StoreDomain) error {
+	return db.Model(domain).
+		Select("status", "failures", "last_error", "last_checked_at", "verified_at", "next_check_at").
+		Updates(domain).Error
+}
+
+// isUniqueViolation reports whether err is Postgres rejecting a duplicate
+// value of a unique index
+func isUniqueViolation(err error) bool {
+	var pgErr interface{ SQLState() string }
+	return errors.As(err, &pgErr) && pgErr.SQLState() == "23505"
+}
+
+// Verify checks a domain now. A failed domain starts verification over.
+func Verify(ctx context.Context, db *gorm.DB, domain *models.StoreDomain) error {
+	if domain.Status == models.DomainStatusFailed {
+		domain.Status = models.DomainStatusPending
+		domain.Failures = 0
+		domain.VerifiedAt = nil
+	}
+	return Check(ctx, db, domain)
+}
+
+// Store finds the store a verified domain belongs to
+func Store(db *gorm.DB, host string) (*models.Store, error) {
+	var domain models.StoreDomain
+	if err := db.Preload("Store").
+		Where("domain = ? AND status = ?", host, models.DomainStatusVerified).
+		First(&domain).Error; err != nil {
+		return nil, err
+	}
+	if domain.Store == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return domain.Store, nil
+}
+
+// Run periodically checks the domains that are due for verification or
+// re-verification
+func Run(db *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		var due []models.StoreDomain
+		if err := db.Where("status IN ? AND next_check_at <= ?",
+			[]models.DomainStatus{models.DomainStatusPending, models.DomainStatusVerified}, time.Now()).
+			Order("next_check_at").Limit(100).
+			Find(&due).Error; err != nil {
+			log.Printf("Warning: could not fetch domains to verify: %v", err)
+			continue
+		}
+
+		for i := range due {
+			if err := Check(context.Background(), db, &due[i]); err != nil {
+				log.Printf("Warning: could not verify domain %s: %v", due[i].Domain, err)
+			}
+		}
+	}
+}
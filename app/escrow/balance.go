@@ -0,0 +1,126 @@
+package escrow
+
+import (
+	"github.com/theHoracle/whatstore-api/app/models"
+	"gorm.io/gorm"
+)
+
+// StoreBalance is a store's escrow position in one currency
+type StoreBalance struct {
+	StoreID   uint    `json:"store_id"`
+	Currency  string  `json:"currency"`
+	Held      float64 `json:"held"`
+	Available float64 `json:"available"`
+}
+
+// CurrencyBalance totals balances across stores for one currency
+type CurrencyBalance struct {
+	Currency  string  `json:"currency"`
+	Held      float64 `json:"held"`
+	Available float64 `json:"available"`
+}
+
+// Reconciliation summarises the health of the escrow ledger
+type Reconciliation struct {
+	Balanced             bool     `json:"balanced"`
+	UnbalancedCurrencies []string `json:"unbalanced_currencies"`
+	UnbalancedTxns       []string `json:"unbalanced_transactions"`
+	HoldsOutOfStep       []uint   `json:"holds_out_of_step"`
+}
+
+// StoreBalances returns the held and available balances of the given stores
+func StoreBalances(db *gorm.DB, storeIDs []uint) ([]StoreBalance, error) {
+	var rows []struct {
+		StoreID  uint
+		Currency string
+		Account  models.EscrowAccount
+		Total    int64
+	}
+	if len(storeIDs) > 0 {
+		err := db.Model(&models.EscrowLedgerEntry{}).
+			Select("store_id, currency, account, SUM(amount) AS total").
+			Where("store_id IN ? AND account IN ?", storeIDs, []models.EscrowAccount{models.EscrowAccountStoreHeld, models.EscrowAccountStoreAvailable}).
+			Group("store_id, currency, account").
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	type key struct {
+		storeID  uint
+		currency string
+	}
+	index := map[key]int{}
+	balances := []StoreBalance{}
+	for _, row := range rows {
+		k := key{row.StoreID, row.Currency}
+		i, ok := index[k]
+		if !ok {
+			i = len(balances)
+			index[k] = i
+			balances = append(balances, StoreBalance{StoreID: row.StoreID, Currency: row.Currency})
+		}
+		switch row.Account {
+		case models.EscrowAccountStoreHeld:
+			balances[i].Held = fromMinorUnits(row.Total)
+		case models.EscrowAccountStoreAvailable:
+			balances[i].Available = fromMinorUnits(row.Total)
+		}
+	}
+
+	return balances, nil
+}
+
+// Totals adds store balances up per currency
+func Totals(balances []StoreBalance) []CurrencyBalance {
+	index := map[string]int{}
+	totals := []CurrencyBalance{}
+	for _, b := range balances {
+		i, ok := index[b.Currency]
+		if !ok {
+			i = len(totals)
+			index[b.Currency] = i
+			totals = append(totals, CurrencyBalance{Currency: b.Currency})
+		}
+		totals[i].Held += b.Held
+		totals[i].Available += b.Available
+	}
+	return totals
+}
+
+// Reconcile checks that every transaction and every currency in the ledger
+// sums to zero and that each hold's status matches where its money sits
+func Reconcile(db *gorm.DB) (*Reconciliation, error) {
+	result := &Reconciliation{
+		UnbalancedCurrencies: []string{},
+		UnbalancedTxns:       []string{},
+		HoldsOutOfStep:       []uint{},
+	}
+
+	if err := db.Model(&models.EscrowLedgerEntry{}).
+		Select("currency").Group("currency").Having("SUM(amount) <> 0").
+		Scan(&result.UnbalancedCurrencies).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Model(&models.EscrowLedgerEntry{}).
+		Select("transaction_id").Group("transaction_id").Having("SUM(amount) <> 0").
+		Scan(&result.UnbalancedTxns).Error; err != nil {
+		return nil, err
+	}
+
+	// A held hold must still have its full amount in store_held
+	if err := db.Table("escrow_holds").
+		Select("escrow_holds.id").
+		Joins("LEFT JOIN escrow_ledger_entries e ON e.hold_id = escrow_holds.id AND e.account = ?", models.EscrowAccountStoreHeld).
+		Where("escrow_holds.status = ?", models.EscrowStatusHeld).
+		Group("escrow_holds.id, escrow_holds.amount").
+		Having("COALESCE(SUM(e.amount), 0) <> ROUND(escrow_holds.amount * 100)").
+		Scan(&result.HoldsOutOfStep).Error; err != nil {
+		return nil, err
+	}
+
+	result.Balanced = len(result.UnbalancedCurrencies) == 0 && len(result.UnbalancedTxns) == 0 && len(result.HoldsOutOfStep) == 0
+	return result, nil
+}
@@ -0,0 +1,282 @@
+package escrow
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/orderflow"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrUnbalanced = errors.New("escrow transaction does not balance")
+
+// leg is one side of a ledger transaction
+type leg struct {
+	account models.EscrowAccount
+	storeID *uint
+	userID  *uint
+	amount  int64
+}
+
+func toMinorUnits(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func fromMinorUnits(amount int64) float64 {
+	return float64(amount) / 100
+}
+
+func newTransactionID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// post writes a balanced set of ledger entries for a hold
+func post(tx *gorm.DB, hold *models.EscrowHold, description string, legs ...leg) error {
+	var sum int64
+	for _, l := range legs {
+		sum += l.amount
+	}
+	if sum != 0 {
+		return fmt.Errorf("%w: %s is off by %d", ErrUnbalanced, description, sum)
+	}
+
+	transactionID := newTransactionID()
+	entries := make([]models.EscrowLedgerEntry, 0, len(legs))
+	for _, l := range legs {
+		entries = append(entries, models.EscrowLedgerEntry{
+			TransactionID: transactionID,
+			HoldID:        hold.ID,
+			OrderID:       hold.OrderID,
+			BookingID:     hold.BookingID,
+			Account:       l.account,
+			StoreID:       l.storeID,
+			UserID:        l.userID,
+			Amount:        l.amount,
+			Currency:      hold.Currency,
+			Description:   description,
+		})
+	}
+	return tx.Create(&entries).Error
+}
+
+// HoldPayment is a payment hook that moves a confirmed payment into the held
+// balance of the store of each paid order, or of the paid booking. If an
+// order or booking was closed while the buyer was still paying, its share
+// goes straight back to the buyer.
+func HoldPayment(tx *gorm.DB, payment *models.Payment) error {
+	if payment.BookingID != nil {
+		var booking models.Booking
+		if err := tx.First(&booking, *payment.BookingID).Error; err != nil {
+			return err
+		}
+		return holdBooking(tx, payment, &booking)
+	}
+
+	var orders []models.Order
+	query := tx.Order("id")
+	if payment.CheckoutGroupID != nil {
+		query = query.Where("checkout_group_id = ?", *payment.CheckoutGroupID)
+	} else {
+		query = query.Where("id = ?", payment.OrderID)
+	}
+	if err := query.Find(&orders).Error; err != nil {
+		return err
+	}
+
+	for i := range orders {
+		if err := holdOrder(tx, payment, &orders[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func holdOrder(tx *gorm.DB, payment *models.Payment, order *models.Order) error {
+	var existing int64
+	if err := tx.Model(&models.EscrowHold{}).Where("order_id = ?", order.ID).Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return nil
+	}
+
+	hold := models.EscrowHold{
+		OrderID:   &order.ID,
+		PaymentID: payment.ID,
+		StoreID:   order.StoreID,
+		UserID:    order.UserID,
+		Amount:    order.TotalAmount,
+		Currency:  payment.Currency,
+		Status:    models.EscrowStatusHeld,
+	}
+	if err := tx.Create(&hold).Error; err != nil {
+		return err
+	}
+
+	amount := toMinorUnits(hold.Amount)
+	if err := post(tx, &hold, "Payment received",
+		leg{account: models.EscrowAccountClearing, amount: -amount},
+		leg{account: models.EscrowAccountStoreHeld, storeID: &hold.StoreID, amount: amount},
+	); err != nil {
+		return err
+	}
+
+	switch order.Status {
+	case models.OrderStatusCancelled, models.OrderStatusRejected, models.OrderStatusRefunded:
+		return refund(tx, &hold, models.EscrowAccountStoreHeld, "Order closed before payment completed")
+	}
+	return nil
+}
+
+func holdBooking(tx *gorm.DB, payment *models.Payment, booking *models.Booking) error {
+	var existing int64
+	if err := tx.Model(&models.EscrowHold{}).Where("booking_id = ?", booking.ID).Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return nil
+	}
+
+	hold := models.EscrowHold{
+		BookingID: &booking.ID,
+		PaymentID: payment.ID,
+		StoreID:   booking.StoreID,
+		UserID:    booking.UserID,
+		Amount:    payment.Amount,
+		Currency:  payment.Currency,
+		Status:    models.EscrowStatusHeld,
+	}
+	if err := tx.Create(&hold).Error; err != nil {
+		return err
+	}
+
+	amount := toMinorUnits(hold.Amount)
+	if err := post(tx, &hold, "Payment received",
+		leg{account: models.EscrowAccountClearing, amount: -amount},
+		leg{account: models.EscrowAccountStoreHeld, storeID: &hold.StoreID, amount: amount},
+	); err != nil {
+		return err
+	}
+
+	switch booking.Status {
+	case models.BookingStatusCancelled, models.BookingStatusNoShow:
+		return refund(tx, &hold, models.EscrowAccountStoreHeld, "Booking closed before payment completed")
+	case models.BookingStatusCompleted:
+		return release(tx, &hold)
+	}
+	return nil
+}
+
+// release moves a hold from the store's held balance to its available balance
+func release(tx *gorm.DB, hold *models.EscrowHold) error {
+	if hold.Status != models.EscrowStatusHeld {
+		return nil
+	}
+
+	amount := toMinorUnits(hold.Amount)
+	if err := post(tx, hold, "Released to store",
+		leg{account: models.EscrowAccountStoreHeld, storeID: &hold.StoreID, amount: -amount},
+		leg{account: models.EscrowAccountStoreAvailable, storeID: &hold.StoreID, amount: amount},
+	); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	hold.Status = models.EscrowStatusReleased
+	hold.ReleasedAt = &now
+	return tx.Save(hold).Error
+}
+
+// refund moves a hold from one of the store's balances into the buyer's
+// refund balance, where it waits for the provider refund to go through
+func refund(tx *gorm.DB, hold *models.EscrowHold, from models.EscrowAccount, description string) error {
+	amount := toMinorUnits(hold.Amount)
+	if err := post(tx, hold, description,
+		leg{account: from, storeID: &hold.StoreID, amount: -amount},
+		leg{account: models.EscrowAccountBuyerRefund, userID: &hold.UserID, amount: amount},
+	); err != nil {
+		return err
+	}
+
+	hold.Status = models.EscrowStatusRefundPending
+	hold.ReleaseAfter = nil
+	return tx.Save(hold).Error
+}
+
+// BookingHook is a booking lifecycle hook that keeps escrow in step with the
+// booking. The store is paid once the service is completed and the buyer is
+// refunded when the booking is cancelled or they did not show up.
+func BookingHook(tx *gorm.DB, booking *models.Booking, from models.BookingStatus) error {
+	var hold models.EscrowHold
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("booking_id = ?", booking.ID).First(&hold).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Unpaid bookings have nothing in escrow
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch booking.Status {
+	case models.BookingStatusCompleted:
+		return release(tx, &hold)
+	case models.BookingStatusCancelled, models.BookingStatusNoShow:
+		if hold.Status != models.EscrowStatusHeld {
+			return nil
+		}
+		return refund(tx, &hold, models.EscrowAccountStoreHeld, fmt.Sprintf("Booking %s", booking.Status))
+	}
+	return nil
+}
+
+// OrderHook returns an order lifecycle hook that keeps escrow in step with
+// the order. Shipped orders are released automatically after releaseAfter
+// unless the buyer confirms delivery or opens a dispute first.
+func OrderHook(releaseAfter time.Duration) orderflow.Hook {
+	return func(tx *gorm.DB, order *models.Order, change *models.OrderStatusHistory) error {
+		var hold models.EscrowHold
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("order_id = ?", order.ID).First(&hold).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Unpaid orders have nothing in escrow
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch change.ToStatus {
+		case models.OrderStatusShipped:
+			if hold.Status != models.EscrowStatusHeld {
+				return nil
+			}
+			due := time.Now().Add(releaseAfter)
+			hold.ReleaseAfter = &due
+			return tx.Save(&hold).Error
+
+		case models.OrderStatusDisputed:
+			hold.ReleaseAfter = nil
+			return tx.Save(&hold).Error
+
+		case models.OrderStatusDelivered:
+			return release(tx, &hold)
+
+		case models.OrderStatusCancelled, models.OrderStatusRejected, models.OrderStatusRefunded:
+			switch hold.Status {
+			case models.EscrowStatusHeld:
+				return refund(tx, &hold, models.EscrowAccountStoreHeld, fmt.Sprintf("Order %s", change.ToStatus))
+			case models.EscrowStatusReleased:
+				return refund(tx, &hold, models.EscrowAccountStoreAvailable, fmt.Sprintf("Order %s after release", change.ToStatus))
+			}
+		}
+
+		return nil
+	}
+}
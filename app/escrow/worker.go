@@ -0,0 +1,219 @@
+package escrow
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/orderflow"
+	"github.com/theHoracle/whatstore-api/app/payments"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Run periodically auto-confirms delivery of shipped orders whose release
+// date has passed and pushes pending refunds through the payment providers
+func Run(db *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		releaseDue(db)
+		ProcessRefunds(db)
+	}
+}
+
+func releaseDue(db *gorm.DB) {
+	var orders []models.Order
+	err := db.Joins("JOIN escrow_holds ON escrow_holds.order_id = orders.id").
+		Where("escrow_holds.status = ? AND escrow_holds.release_after < ? AND orders.status = ?",
+			models.EscrowStatusHeld, time.Now(), models.OrderStatusShipped).
+		Find(&orders).Error
+	if err != nil {
+		log.Printf("Warning: could not fetch escrow holds due for release: %v", err)
+		return
+	}
+
+	for i := range orders {
+		// Delivering the order releases the hold through the order hook
+		if err := orderflow.Transition(db, &orders[i], models.OrderStatusDelivered, models.OrderActorSystem, nil, "Delivery confirmed automatically, no dispute was opened"); err != nil {
+			log.Printf("Warning: could not auto-release order %d: %v", orders[i].ID, err)
+		}
+	}
+}
+
+// refundStuckAfter is how long a refund may sit claimed without the
+// provider's reference before it is reported for someone to check by hand
+const refundStuckAfter = time.Hour
+
+// ProcessRefunds pushes each pending refund through its payment provider and
+// reports refunds that were sent but never recorded
+func ProcessRefunds(db *gorm.DB) {
+	// Claimed refunds the provider already accepted only need their
+	// bookkeeping finished
+	var holds []models.EscrowHold
+	err := db.Where("status = ? OR (status = ? AND refund_reference <> '')",
+		models.EscrowStatusRefundPending, models.EscrowStatusRefundProcessing).
+		Find(&holds).Error
+	if err != nil {
+		log.Printf("Warning: could not fetch pending refunds: %v", err)
+		return
+	}
+
+	for i := range holds {
+		if err := settleRefund(db, &holds[i]); err != nil {
+			log.Printf("Warning: could not refund %s: %v", subject(&holds[i]), err)
+		}
+	}
+
+	var stuck []models.EscrowHold
+	err = db.Where("status = ? AND COALESCE(refund_reference, '') = '' AND updated_at < ?",
+		models.EscrowStatusRefundProcessing, time.Now().Add(-refundStuckAfter)).
+		Find(&stuck).Error
+	if err != nil {
+		log.Printf("Warning: could not fetch stuck refunds: %v", err)
+		return
+	}
+	for i := range stuck {
+		log.Printf("Warning: refund of %s was sent to the provider but never recorded, check it with the provider", subject(&stuck[i]))
+	}
+}
+
+// settleRefund returns a hold's money to the buyer through the payment
+// provider. The hold is claimed before the provider is called and the
+// provider's refund reference is saved as soon as it is known, so two
+// workers, or a retry after a failure, never refund the same hold twice.
+func settleRefund(db *gorm.DB, hold *models.EscrowHold) error {
+	claimed, err := claimRefund(db, hold)
+	if err != nil || !claimed {
+		return err
+	}
+
+	var payment models.Payment
+	if err := db.First(&payment, hold.PaymentID).Error; err != nil {
+		return err
+	}
+
+	if hold.RefundReference == "" {
+		reference, err := requestRefund(db, hold, &payment)
+		if err != nil {
+			return err
+		}
+		hold.RefundReference = reference
+		if err := db.Model(hold).Update("refund_reference", reference).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(hold, hold.ID).Error; err != nil {
+			return err
+		}
+		if hold.Status != models.EscrowStatusRefundProcessing {
+			return nil
+		}
+
+		amount := toMinorUnits(hold.Amount)
+		if err := post(tx, hold, "Refunded through "+payment.Provider+" ("+hold.RefundReference+")",
+			leg{account: models.EscrowAccountBuyerRefund, userID: &hold.UserID, amount: -amount},
+			leg{account: models.EscrowAccountClearing, amount: amount},
+		); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		hold.Status = models.EscrowStatusRefunded
+		hold.RefundedAt = &now
+		if err := tx.Save(hold).Error; err != nil {
+			return err
+		}
+
+		// A payment for a checkout group holds money for each of its orders,
+		// and is only refunded in full once every one of them is
+		var unrefunded int64
+		if err := tx.Model(&models.EscrowHold{}).Where("payment_id = ? AND status <> ?", payment.ID, models.EscrowStatusRefunded).
+			Count(&unrefunded).Error; err != nil {
+			return err
+		}
+		status := models.PaymentStatusRefunded
+		if unrefunded > 0 {
+			status = models.PaymentStatusPartiallyRefunded
+		}
+		return tx.Model(&payment).Update("status", status).Error
+	}); err != nil {
+		return err
+	}
+
+	if hold.OrderID == nil {
+		return nil
+	}
+	var order models.Order
+	if err := db.First(&order, *hold.OrderID).Error; err != nil {
+		return err
+	}
+	switch order.Status {
+	case models.OrderStatusCancelled, models.OrderStatusRejected:
+		return orderflow.Transition(db, &order, models.OrderStatusRefunded, models.OrderActorSystem, nil, "Payment refunded to buyer")
+	}
+	return nil
+}
+
+// subject names what a hold was paid for, for logs
+func subject(hold *models.EscrowHold) string {
+	if hold.BookingID != nil {
+		return fmt.Sprintf("booking %d", *hold.BookingID)
+	}
+	if hold.OrderID != nil {
+		return fmt.Sprintf("order %d", *hold.OrderID)
+	}
+	return fmt.Sprintf("hold %d", hold.ID)
+}
+
+// claimRefund moves a pending refund to processing, committed before the
+// provider is called. It reports false when another worker got there first.
+// A refund already processing is only claimed when the provider accepted it.
+func claimRefund(db *gorm.DB, hold *models.EscrowHold) (bool, error) {
+	claimed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(hold, hold.ID).Error; err != nil {
+			return err
+		}
+		switch {
+		case hold.Status == models.EscrowStatusRefundPending:
+			hold.Status = models.EscrowStatusRefundProcessing
+			claimed = true
+			return tx.Model(hold).Update("status", hold.Status).Error
+		case hold.Status == models.EscrowStatusRefundProcessing && hold.RefundReference != "":
+			claimed = true
+		}
+		return nil
+	})
+	return claimed, err
+}
+
+// requestRefund asks the provider for the refund of a claimed hold and
+// returns the provider's reference for it. When the provider refuses, the
+// hold goes back to pending to be tried again.
+func requestRefund(db *gorm.DB, hold *models.EscrowHold, payment *models.Payment) (string, error) {
+	provider, err := payments.Get(payment.Provider)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		var refund *payments.Refund
+		if refund, err = provider.Refund(ctx, payment.Reference, hold.Amount); err == nil {
+			if refund.ProviderID != "" {
+				return refund.ProviderID, nil
+			}
+			return payment.Reference, nil
+		}
+	}
+
+	if unclaim := db.Model(&models.EscrowHold{}).
+		Where("id = ? AND status = ?", hold.ID, models.EscrowStatusRefundProcessing).
+		Update("status", models.EscrowStatusRefundPending).Error; unclaim != nil {
+		log.Printf("Warning: could not return refund of %s to pending: %v", subject(hold), unclaim)
+	}
+	return "", err
+}
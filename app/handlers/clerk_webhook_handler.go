@@ -7,6 +7,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	svix "github.com/svix/svix-webhooks/go"
 	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/rbac"
 	"gorm.io/gorm"
 )
 
@@ -75,6 +76,12 @@ func ClerkWebhookHandler(db *gorm.DB, signingSecret string) fiber.Handler {
 				EmailAddresses []struct {
 					EmailAddress string `json:"email_address"`
 				} `json:"email_addresses"`
+				PhoneNumbers []struct {
+					PhoneNumber string `json:"phone_number"`
+				} `json:"phone_numbers"`
+				PublicMetadata struct {
+					Role string `json:"role"`
+				} `json:"public_metadata"`
 			}
 
 			// Debug print the raw data
@@ -126,6 +133,10 @@ func ClerkWebhookHandler(db *gorm.DB, signingSecret string) fiber.Handler {
 				Username:  clerkUser.Username,
 				AvatarURL: clerkUser.ImageURL,
 			}
+			if len(clerkUser.PhoneNumbers) > 0 {
+				newUser.PhoneNumber = clerkUser.PhoneNumbers[0].PhoneNumber
+			}
+			newUser.Role = rbac.ClerkRole(&newUser, clerkUser.PublicMetadata.Role)
 
 			// Debug print the user we're about to create
 			log.Printf("Creating user with data: %+v", newUser)
@@ -148,6 +159,12 @@ func ClerkWebhookHandler(db *gorm.DB, signingSecret string) fiber.Handler {
 				EmailAddresses []struct {
 					EmailAddress string `json:"email_address"`
 				} `json:"email_addresses"`
+				PhoneNumbers []struct {
+					PhoneNumber string `json:"phone_number"`
+				} `json:"phone_numbers"`
+				PublicMetadata struct {
+					Role string `json:"role"`
+				} `json:"public_metadata"`
 			}
 
 			if err := json.Unmarshal(event.Data, &clerkUser); err != nil {
@@ -157,14 +174,21 @@ func ClerkWebhookHandler(db *gorm.DB, signingSecret string) fiber.Handler {
 			}
 			// Update the user in the database
 			var user models.User
-			if err := db.Where("clerk_id = ?", clerkUser.ID).First(&user).Error; err != nil {
+			if err := db.Preload("Vendor").Where("clerk_id = ?", clerkUser.ID).First(&user).Error; err != nil {
 				log.Printf("User not found: %v", err)
 				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 					"error": "User not found",
 				})
 			}
 			user.Email = clerkUser.EmailAddresses[0].EmailAddress
-			if err := db.Save(&user).Error; err != nil {
+			if len(clerkUser.PhoneNumbers) > 0 {
+				user.PhoneNumber = clerkUser.PhoneNumbers[0].PhoneNumber
+			}
+			if role := rbac.ClerkRole(&user, clerkUser.PublicMetadata.Role); role != user.Role {
+				log.Printf("Role of user %d changed from %s to %s", user.ID, user.Role, role)
+				user.Role = role
+			}
+			if err := db.Omit("Vendor").Save(&user).Error; err != nil {
 				log.Printf("Failed to update user: %v", err)
 				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 					"error": "Failed to update user",
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/payments"
+	"gorm.io/gorm"
+)
+
+// PaymentWebhookHandler handles signed webhooks from payment providers
+func PaymentWebhookHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		provider, err := payments.Get(c.Params("provider"))
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Unknown payment provider",
+			})
+		}
+
+		// Verify the signature and parse the event
+		event, err := provider.ParseWebhook(http.Header(c.GetReqHeaders()), c.Body())
+		if err != nil {
+			if errors.Is(err, payments.ErrInvalidSignature) {
+				log.Printf("%s webhook verification failed", provider.Name())
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error": "Invalid webhook signature",
+				})
+			}
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid webhook payload",
+			})
+		}
+
+		if event.Reference == "" {
+			log.Printf("Unhandled %s event type: %s", provider.Name(), event.Type)
+			return c.SendStatus(fiber.StatusOK)
+		}
+
+		switch event.Status {
+		case models.PaymentStatusSuccess:
+			// Never trust the webhook body alone, confirm with the provider
+			if _, err := payments.Confirm(c.UserContext(), db, provider, event.Reference); err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					log.Printf("Payment %s not found", event.Reference)
+					return c.SendStatus(fiber.StatusOK)
+				}
+				log.Printf("Failed to confirm payment %s: %v", event.Reference, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to confirm payment",
+				})
+			}
+		case models.PaymentStatusFailed:
+			if err := payments.MarkFailed(db, provider, event.Reference); err != nil {
+				log.Printf("Failed to mark payment %s as failed: %v", event.Reference, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to update payment",
+				})
+			}
+		default:
+			log.Printf("Unhandled %s event type: %s", provider.Name(), event.Type)
+		}
+
+		return c.SendStatus(fiber.StatusOK)
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/orderflow"
+	"github.com/theHoracle/whatstore-api/app/whatsapp"
+	"gorm.io/gorm"
+)
+
+// WhatsappVerifyHandler answers the Cloud API's subscription challenge
+func WhatsappVerifyHandler(verifyToken string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Query("hub.mode") != "subscribe" || verifyToken == "" || c.Query("hub.verify_token") != verifyToken {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "Invalid verify token",
+			})
+		}
+		return c.SendString(c.Query("hub.challenge"))
+	}
+}
+
+// WhatsappWebhookHandler handles signed Cloud API webhooks. Text messages
+// such as "CONFIRM 123" move orders along, once however often Meta delivers
+// them, and delivery updates are recorded against the sent messages.
+func WhatsappWebhookHandler(db *gorm.DB, client *whatsapp.Client) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		body := c.Body()
+		if err := client.VerifySignature(c.Get("X-Hub-Signature-256"), body); err != nil {
+			log.Printf("WhatsApp webhook verification failed")
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid webhook signature",
+			})
+		}
+
+		event, err := whatsapp.ParseWebhook(body)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid webhook payload",
+			})
+		}
+
+		// Statuses go first: failing them asks Meta to deliver the webhook
+		// again, which must not happen once commands were applied and
+		// replies queued
+		if err := whatsapp.UpdateStatuses(db, event.Statuses); err != nil {
+			log.Printf("Failed to record WhatsApp message statuses: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to update messages",
+			})
+		}
+
+		for _, msg := range event.Messages {
+			cmd, ok := whatsapp.ParseCommand(msg.Text)
+			if !ok {
+				continue
+			}
+
+			// Meta redelivers webhooks it thinks failed, each command is
+			// applied once
+			fresh, err := whatsapp.Receive(db, msg)
+			if err != nil {
+				log.Printf("Failed to record WhatsApp message %s: %v", msg.ID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to record messages",
+				})
+			}
+			if !fresh {
+				continue
+			}
+
+			reply := commandReply(db, msg.From, cmd)
+			if err := whatsapp.Reply(db, &cmd.OrderID, msg.From, reply); err != nil {
+				log.Printf("Failed to queue WhatsApp reply to %s: %v", msg.From, err)
+			}
+		}
+
+		return c.SendStatus(fiber.StatusOK)
+	}
+}
+
+// commandReply applies an order command and describes the outcome for the sender
+func commandReply(db *gorm.DB, from string, cmd *whatsapp.Command) string {
+	order, err := whatsapp.ApplyCommand(db, from, cmd)
+	switch {
+	case err == nil:
+		return fmt.Sprintf("Order #%d is now %s.", order.ID, order.Status)
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, whatsapp.ErrUnknownSender):
+		return fmt.Sprintf("Order #%d was not found.", cmd.OrderID)
+	case errors.Is(err, orderflow.ErrInvalidTransition), errors.Is(err, orderflow.ErrActorNotAllowed):
+		return fmt.Sprintf("Order #%d can't be moved to %s right now.", cmd.OrderID, cmd.Status)
+	default:
+		log.Printf("Failed to apply WhatsApp command for order %d: %v", cmd.OrderID, err)
+		return fmt.Sprintf("Something went wrong updating order #%d, please try again.", cmd.OrderID)
+	}
+}
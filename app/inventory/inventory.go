@@ -0,0 +1,246 @@
+package inventory
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/orderflow"
+	"gorm.io/gorm"
+)
+
+// InsufficientStockError is returned when a product, or one of its variants,
+// cannot cover a requested quantity
+type InsufficientStockError struct {
+	ProductID uint   `json:"product_id"`
+	VariantID *uint  `json:"variant_id,omitempty"`
+	Name      string `json:"name"`
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+}
+
+func (e *InsufficientStockError) Error() string {
+	if e.VariantID != nil {
+		return fmt.Sprintf("insufficient stock for product %d variant %d: requested %d, available %d", e.ProductID, *e.VariantID, e.Requested, e.Available)
+	}
+	return fmt.Sprintf("insufficient stock for product %d: requested %d, available %d", e.ProductID, e.Requested, e.Available)
+}
+
+// Reserve takes quantity units of a product, or of one of its variants, out
+// of stock for an order. The decrement is conditional on enough stock being
+// left, so two concurrent orders can never both take the last unit. A
+// variant's product stock goes down with it.
+func Reserve(tx *gorm.DB, product *models.Product, variant *models.ProductVariant, quantity int, orderID uint, actorID *uint) error {
+	if variant == nil {
+		return reserveProduct(tx, product, quantity, orderID, actorID)
+	}
+
+	result := tx.Model(&models.ProductVariant{}).
+		Where("id = ? AND stock >= ?", variant.ID, quantity).
+		UpdateColumn("stock", gorm.Expr("stock - ?", quantity))
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if err := tx.Model(&models.ProductVariant{}).Select("stock").Where("id = ?", variant.ID).Scan(&variant.Stock).Error; err != nil {
+		return err
+	}
+
+	if result.RowsAffected == 0 {
+		return &InsufficientStockError{
+			ProductID: product.ID,
+			VariantID: &variant.ID,
+			Name:      product.Name + " (" + variant.Title + ")",
+			Requested: quantity,
+			Available: variant.Stock,
+		}
+	}
+
+	if err := addProductStock(tx, product, -quantity); err != nil {
+		return err
+	}
+
+	return tx.Create(&models.InventoryLedgerEntry{
+		ProductID:  product.ID,
+		VariantID:  &variant.ID,
+		StoreID:    product.StoreID,
+		OrderID:    &orderID,
+		Change:     -quantity,
+		StockAfter: variant.Stock,
+		Reason:     models.InventoryReasonOrderReserved,
+		ActorID:    actorID,
+	}).Error
+}
+
+func reserveProduct(tx *gorm.DB, product *models.Product, quantity int, orderID uint, actorID *uint) error {
+	result := tx.Model(&models.Product{}).
+		Where("id = ? AND stock >= ?", product.ID, quantity).
+		UpdateColumn("stock", gorm.Expr("stock - ?", quantity))
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if err := tx.Model(&models.Product{}).Select("stock").Where("id = ?", product.ID).Scan(&product.Stock).Error; err != nil {
+		return err
+	}
+
+	if result.RowsAffected == 0 {
+		return &InsufficientStockError{
+			ProductID: product.ID,
+			Name:      product.Name,
+			Requested: quantity,
+			Available: product.Stock,
+		}
+	}
+
+	return tx.Create(&models.InventoryLedgerEntry{
+		ProductID:  product.ID,
+		StoreID:    product.StoreID,
+		OrderID:    &orderID,
+		Change:     -quantity,
+		StockAfter: product.Stock,
+		Reason:     models.InventoryReasonOrderReserved,
+		ActorID:    actorID,
+	}).Error
+}
+
+// addProductStock changes a product's stock by change and reloads it
+func addProductStock(tx *gorm.DB, product *models.Product, change int) error {
+	if err := tx.Model(&models.Product{}).
+		Where("id = ?", product.ID).
+		UpdateColumn("stock", gorm.Expr("stock + ?", change)).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Product{}).Select("stock").Where("id = ?", product.ID).Scan(&product.Stock).Error
+}
+
+// Release puts the stock reserved by an order back on the shelf
+func Release(tx *gorm.DB, order *models.Order, note string) error {
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ?", order.ID).Order("product_id").Order("variant_id").Find(&items).Error; err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		var product models.Product
+		if err := tx.Select("id", "store_id", "stock").First(&product, item.ProductID).Error; err != nil {
+			return err
+		}
+		if err := addProductStock(tx, &product, item.Quantity); err != nil {
+			return err
+		}
+
+		stockAfter := product.Stock
+		if item.VariantID != nil {
+			if err := tx.Model(&models.ProductVariant{}).
+				Where("id = ?", *item.VariantID).
+				UpdateColumn("stock", gorm.Expr("stock + ?", item.Quantity)).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.ProductVariant{}).Select("stock").Where("id = ?", *item.VariantID).Scan(&stockAfter).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(&models.InventoryLedgerEntry{
+			ProductID:  product.ID,
+			VariantID:  item.VariantID,
+			StoreID:    product.StoreID,
+			OrderID:    &order.ID,
+			Change:     item.Quantity,
+			StockAfter: stockAfter,
+			Reason:     models.InventoryReasonOrderReleased,
+			Note:       note,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RecordAdjustment logs a stock change made directly on a product, such as
+// the initial stock or a vendor restocking
+func RecordAdjustment(tx *gorm.DB, product *models.Product, change int, reason models.InventoryReason, actorID *uint, note string) error {
+	return tx.Create(&models.InventoryLedgerEntry{
+		ProductID:  product.ID,
+		StoreID:    product.StoreID,
+		Change:     change,
+		StockAfter: product.Stock,
+		Reason:     reason,
+		ActorID:    actorID,
+		Note:       note,
+	}).Error
+}
+
+// AdjustVariant records a change made directly to a variant's stock, keeping
+// its product's stock in step. The variant's stock must already hold the new
+// value.
+func AdjustVariant(tx *gorm.DB, product *models.Product, variant *models.ProductVariant, change int, reason models.InventoryReason, actorID *uint, note string) error {
+	if err := addProductStock(tx, product, change); err != nil {
+		return err
+	}
+
+	return tx.Create(&models.InventoryLedgerEntry{
+		ProductID:  product.ID,
+		VariantID:  &variant.ID,
+		StoreID:    product.StoreID,
+		Change:     change,
+		StockAfter: variant.Stock,
+		Reason:     reason,
+		ActorID:    actorID,
+		Note:       note,
+	}).Error
+}
+
+// ReleaseOnCancel is an order lifecycle hook that returns reserved stock when
+// an order is cancelled or rejected
+func ReleaseOnCancel(tx *gorm.DB, order *models.Order, change *models.OrderStatusHistory) error {
+	switch order.Status {
+	case models.OrderStatusCancelled, models.OrderStatusRejected:
+		return Release(tx, order, fmt.Sprintf("Order %s", order.Status))
+	}
+	return nil
+}
+
+// ExpireUnpaidOrders periodically cancels pending orders that have not been
+// paid within timeout, which releases their reserved stock
+func ExpireUnpaidOrders(db *gorm.DB, timeout time.Duration) {
+	interval := timeout / 4
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := ExpireUnpaid(db, timeout); err != nil {
+			log.Printf("Warning: could not fetch unpaid orders: %v", err)
+		}
+	}
+}
+
+// ExpireUnpaid cancels the pending orders placed more than timeout ago. Orders
+// with a payment started within timeout are left for it to complete, so the
+// buyer is not charged for an order that was just cancelled.
+func ExpireUnpaid(db *gorm.DB, timeout time.Duration) error {
+	cutoff := time.Now().Add(-timeout)
+	var expired []models.Order
+	err := db.Where("status = ? AND payment_id IS NULL AND created_at < ?", models.OrderStatusPending, cutoff).
+		Where(`NOT EXISTS (SELECT 1 FROM payments WHERE payments.status = ? AND payments.created_at >= ?
+			AND (payments.order_id = orders.id OR payments.checkout_group_id = orders.checkout_group_id))`,
+			models.PaymentStatusPending, cutoff).
+		Find(&expired).Error
+	if err != nil {
+		return err
+	}
+
+	for i := range expired {
+		if err := orderflow.Transition(db, &expired[i], models.OrderStatusCancelled, models.OrderActorSystem, nil, "Reservation expired before payment"); err != nil {
+			log.Printf("Warning: could not expire order %d: %v", expired[i].ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// WebPEncoder encodes an image as WebP
+type WebPEncoder func(img image.Image) ([]byte, error)
+
+var webpEncoder WebPEncoder
+
+// UseWebP sets the encoder WebP variants are made with. Without one uploads
+// only get JPEG or PNG variants.
+func UseWebP(encoder WebPEncoder) {
+	webpEncoder = encoder
+}
+
+const cwebpTimeout = 30 * time.Second
+
+// Cwebp encodes lossy WebP with libwebp's cwebp command at quality, 0 to 100
+func Cwebp(path string, quality int) WebPEncoder {
+	return func(img image.Image) ([]byte, error) {
+		dir, err := os.MkdirTemp("", "cwebp")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+
+		var source bytes.Buffer
+		if err := png.Encode(&source, img); err != nil {
+			return nil, err
+		}
+		in, out := filepath.Join(dir, "in.png"), filepath.Join(dir, "out.webp")
+		if err := os.WriteFile(in, source.Bytes(), 0o600); err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), cwebpTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, path, "-quiet", "-q", strconv.Itoa(quality), "-metadata", "none", in, "-o", out)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("cwebp: %w: %s", err, bytes.TrimSpace(output))
+		}
+		return os.ReadFile(out)
+	}
+}
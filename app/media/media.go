@@ -0,0 +1,368 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/storage"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // WebP uploads
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxUploadSize is the largest image file accepted
+	MaxUploadSize = 8 << 20
+	// MaxFilesPerUpload is how many images one upload request may carry
+	MaxFilesPerUpload = 5
+	// MaxRequestSize is the largest upload request body
+	MaxRequestSize = MaxFilesPerUpload*MaxUploadSize + 1<<20
+
+	maxPixels   = 40_000_000 // Guards against images that decompress to gigabytes
+	maxSide     = 2048       // Originals are scaled down to fit
+	jpegQuality = 85
+)
+
+var (
+	ErrNotConfigured   = errors.New("media storage is not configured")
+	ErrTooLarge        = fmt.Errorf("images can be at most %d MB", MaxUploadSize>>20)
+	ErrUnsupportedType = errors.New("only JPEG, PNG and WebP images can be uploaded")
+	ErrTooManyPixels   = errors.New("image dimensions are too large")
+)
+
+// sizes are the resized variants made of every upload, each as JPEG or PNG
+// and, with a WebP encoder set up, as WebP
+var sizes = []struct {
+	name string
+	side int
+}{
+	{"medium", 800},
+	{"thumb", 200},
+}
+
+var allowedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+var backend storage.Storage
+
+// Use sets the storage uploads are kept in
+func Use(s storage.Storage) {
+	backend = s
+}
+
+// Enabled reports whether a storage has been set up
+func Enabled() bool {
+	return backend != nil
+}
+
+// rendition is one encoded file of an upload
+type rendition struct {
+	name        string
+	ext         string
+	contentType string
+	width       int
+	height      int
+	data        []byte
+}
+
+// process checks an uploaded file is an image we accept and renders it: the
+// original scaled down to fit 2048 pixels and re-encoded, which turns it
+// upright and drops its EXIF and other metadata, and the resized variants.
+// Images with transparency are kept as PNG, everything else becomes JPEG.
+func process(data []byte) (*rendition, []rendition, error) {
+	if len(data) > MaxUploadSize {
+		return nil, nil, ErrTooLarge
+	}
+	if !allowedTypes[http.DetectContentType(data)] {
+		return nil, nil, ErrUnsupportedType
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, ErrUnsupportedType
+	}
+	if config.Width < 1 || config.Height < 1 || config.Width*config.Height > maxPixels {
+		return nil, nil, ErrTooManyPixels
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read image: %w", err)
+	}
+
+	// Scale before turning upright, fitting a square works either way round
+	img = fit(img, maxSide)
+	if format == "jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+
+	opaque := isOpaque(img)
+	original, err := encode("original", img, opaque)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var variants []rendition
+	for _, size := range sizes {
+		resized := fit(img, size.side)
+		variant, err := encode(size.name, resized, opaque)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		variants = append(variants, *variant)
+
+		if webpEncoder == nil {
+			continue
+		}
+		webp, err := webpEncoder(resized)
+		if err != nil {
+			return nil, nil, err
+		}
+		// A WebP variant is only worth serving when it is the smaller file
+		if len(webp) < len(variant.data) {
+			variants = append(variants, rendition{
+				name:        size.name,
+				ext:         "webp",
+				contentType: "image/webp",
+				width:       variant.width,
+				height:      variant.height,
+				data:        webp,
+			})
+		}
+	}
+
+	return original, variants, nil
+}
+
+// fit scales img down so neither side is longer than side
+func fit(img image.Image, side int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= side && h <= side {
+		return img
+	}
+
+	if w >= h {
+		h = max(1, h*side/w)
+		w = side
+	} else {
+		w = max(1, w*side/h)
+		h = side
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+func encode(name string, img image.Image, opaque bool) (*rendition, error) {
+	bounds := img.Bounds()
+	r := &rendition{name: name, width: bounds.Dx(), height: bounds.Dy()}
+
+	var buf bytes.Buffer
+	if opaque {
+		r.ext, r.contentType = "jpg", "image/jpeg"
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+	} else {
+		r.ext, r.contentType = "png", "image/png"
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+	}
+	r.data = buf.Bytes()
+	return r, nil
+}
+
+func newPrefix(storeID uint) (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("stores/%d/%s", storeID, hex.EncodeToString(b)), nil
+}
+
+// Upload processes an image uploaded for a store, puts the original and its
+// variants in storage and records them. Nothing is kept when any step fails.
+func Upload(ctx context.Context, db *gorm.DB, storeID uint, filename string, data []byte) (*models.Media, error) {
+	if backend == nil {
+		return nil, ErrNotConfigured
+	}
+
+	original, variants, err := process(data)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, err := newPrefix(storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored []string
+	put := func(r *rendition) (string, error) {
+		key := prefix + "/" + r.name + "." + r.ext
+		if err := backend.Put(ctx, key, r.data, r.contentType); err != nil {
+			return "", err
+		}
+		stored = append(stored, key)
+		return key, nil
+	}
+	cleanup := func() {
+		for _, key := range stored {
+			if err := backend.Delete(context.Background(), key); err != nil {
+				log.Printf("Warning: could not delete %s after a failed upload: %v", key, err)
+			}
+		}
+	}
+
+	key, err := put(original)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	media := &models.Media{
+		StoreID:     storeID,
+		Filename:    filename,
+		Key:         key,
+		URL:         backend.URL(key),
+		ContentType: original.contentType,
+		Width:       original.width,
+		Height:      original.height,
+		Size:        int64(len(original.data)),
+	}
+	for i := range variants {
+		key, err := put(&variants[i])
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		media.Variants = append(media.Variants, models.MediaVariant{
+			Name:        variants[i].name,
+			Key:         key,
+			URL:         backend.URL(key),
+			ContentType: variants[i].contentType,
+			Width:       variants[i].width,
+			Height:      variants[i].height,
+			Size:        int64(len(variants[i].data)),
+		})
+	}
+
+	if err := db.Create(media).Error; err != nil {
+		cleanup()
+		return nil, err
+	}
+	return media, nil
+}
+
+// Delete removes an upload's files from storage and then its record
+func Delete(ctx context.Context, db *gorm.DB, media *models.Media) error {
+	if backend == nil {
+		return ErrNotConfigured
+	}
+
+	keys := []string{media.Key}
+	for _, variant := range media.Variants {
+		keys = append(keys, variant.Key)
+	}
+	for _, key := range keys {
+		if err := backend.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return db.Select("Variants").Delete(media).Error
+}
+
+// urls lists the URLs of an upload and its variants
+func urls(media *models.Media) []string {
+	list := []string{media.URL}
+	for _, variant := range media.Variants {
+		list = append(list, variant.URL)
+	}
+	return list
+}
+
+// InUse reports whether a product, variant, service or store logo points at
+// the upload or one of its variants
+func InUse(db *gorm.DB, media *models.Media) (bool, error) {
+	list := urls(media)
+	checks := []*gorm.DB{
+		db.Model(&models.Product{}).Where("images && ARRAY[?]::text[]", list),
+		db.Model(&models.ProductVariant{}).Where("images && ARRAY[?]::text[]", list),
+		db.Model(&models.Service{}).Where("image_url IN ?", list),
+		db.Model(&models.Store{}).Where("store_logo IN ?", list),
+	}
+	for _, check := range checks {
+		var count int64
+		if err := check.Limit(1).Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CollectOrphans periodically deletes uploads older than grace that nothing
+// points at, giving vendors time to save the product or store they uploaded
+// them for
+func CollectOrphans(db *gorm.DB, interval, grace time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cutoff := time.Now().Add(-grace)
+		var lastID uint
+		for {
+			var candidates []models.Media
+			if err := db.Preload("Variants").
+				Where("created_at < ? AND id > ?", cutoff, lastID).
+				Order("id").Limit(500).
+				Find(&candidates).Error; err != nil {
+				log.Printf("Warning: could not fetch uploads to collect: %v", err)
+				break
+			}
+			if len(candidates) == 0 {
+				break
+			}
+			lastID = candidates[len(candidates)-1].ID
+
+			for i := range candidates {
+				inUse, err := InUse(db, &candidates[i])
+				if err != nil {
+					log.Printf("Warning: could not check whether upload %d is in use: %v", candidates[i].ID, err)
+					continue
+				}
+				if inUse {
+					continue
+				}
+				if err := Delete(context.Background(), db, &candidates[i]); err != nil {
+					log.Printf("Warning: could not delete orphaned upload %d: %v", candidates[i].ID, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/theHoracle/whatstore-api/app/storage"
+)
+
+// gradient draws an image whose pixels all differ, with some transparency
+// when alpha is set
+func gradient(w, h int, alpha bool) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			a := uint8(255)
+			if alpha {
+				a = uint8((x * y) % 256)
+			}
+			img.Set(x, y, color.NRGBA{R: uint8(x * 7), G: uint8(y * 13), B: uint8((x + y) * 3), A: a})
+		}
+	}
+	return img
+}
+
+// withOrientation inserts an EXIF segment with the given orientation after
+// the start of a JPEG
+func withOrientation(jpegData []byte, orientation uint16) []byte {
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	binary.Write(&tiff, binary.BigEndian, uint16(42))
+	binary.Write(&tiff, binary.BigEndian, uint32(8))
+	binary.Write(&tiff, binary.BigEndian, uint16(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{exifOrientationTag, 3})
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{orientation, 0})
+	binary.Write(&tiff, binary.BigEndian, uint32(0))
+
+	segment := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	out := []byte{0xff, 0xd8, 0xff, 0xe1}
+	out = binary.BigEndian.AppendUint16(out, uint16(len(segment)+2))
+	out = append(out, segment...)
+	return append(out, jpegData[2:]...)
+}
+
+func TestProcessTurnsPhotosUprightAndDropsExif(t *testing.T) {
+	var photo bytes.Buffer
+	if err := jpeg.Encode(&photo, gradient(300, 100, false), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := withOrientation(photo.Bytes(), 6)
+	if got := jpegOrientation(data); got != 6 {
+		t.Fatalf("jpegOrientation = %d, want 6", got)
+	}
+
+	original, variants, err := process(data)
+	if err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if original.contentType != "image/jpeg" || original.width != 100 || original.height != 300 {
+		t.Errorf("original = %s %dx%d, want image/jpeg 100x300", original.contentType, original.width, original.height)
+	}
+	if bytes.Contains(original.data, []byte("Exif")) {
+		t.Error("original still carries EXIF data")
+	}
+
+	names := map[string]bool{}
+	for _, v := range variants {
+		names[v.name+"."+v.ext] = true
+		if v.width > 800 || v.height > 800 {
+			t.Errorf("%s.%s is %dx%d", v.name, v.ext, v.width, v.height)
+		}
+	}
+	for _, want := range []string{"medium.jpg", "thumb.jpg"} {
+		if !names[want] {
+			t.Errorf("missing variant %s", want)
+		}
+	}
+	if len(variants) != 2 {
+		t.Errorf("%d variants without a WebP encoder, want 2", len(variants))
+	}
+}
+
+func TestProcessWebPVariants(t *testing.T) {
+	defer UseWebP(nil)
+	var photo bytes.Buffer
+	if err := jpeg.Encode(&photo, gradient(1000, 500, false), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only WebP files smaller than the JPEG they replace are kept
+	var encoded []image.Rectangle
+	UseWebP(func(img image.Image) ([]byte, error) {
+		encoded = append(encoded, img.Bounds())
+		if img.Bounds().Dx() > 200 {
+			return make([]byte, 10), nil
+		}
+		return make([]byte, 1<<20), nil
+	})
+	_, variants, err := process(photo.Bytes())
+	if err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	names := []string{}
+	for _, v := range variants {
+		names = append(names, v.name+"."+v.ext)
+	}
+	if fmt.Sprint(names) != "[medium.jpg medium.webp thumb.jpg]" {
+		t.Errorf("variants %v", names)
+	}
+	if len(encoded) != 2 || encoded[0].Dx() != 800 || encoded[1].Dx() != 200 {
+		t.Errorf("encoded %v", encoded)
+	}
+
+	UseWebP(func(img image.Image) ([]byte, error) { return nil, errors.New("encoder broke") })
+	if _, _, err := process(photo.Bytes()); err == nil {
+		t.Error("process succeeded with a failing WebP encoder")
+	}
+}
+
+func TestProcessKeepsTransparencyAsPNG(t *testing.T) {
+	var logo bytes.Buffer
+	if err := png.Encode(&logo, gradient(50, 50, true)); err != nil {
+		t.Fatal(err)
+	}
+	original, _, err := process(logo.Bytes())
+	if err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if original.contentType != "image/png" {
+		t.Errorf("content type = %s, want image/png", original.contentType)
+	}
+}
+
+func TestProcessRejectsOtherFiles(t *testing.T) {
+	if _, _, err := process([]byte("%PDF-1.4 not an image")); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("process(pdf) = %v, want ErrUnsupportedType", err)
+	}
+	if _, _, err := process(make([]byte, MaxUploadSize+1)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("process(too large) = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestUploadWithoutStorage(t *testing.T) {
+	Use(nil)
+	if _, err := Upload(context.Background(), nil, 1, "a.png", nil); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("Upload = %v, want ErrNotConfigured", err)
+	}
+	Use(storage.NewLocal(t.TempDir(), "/media"))
+	if !Enabled() {
+		t.Error("Enabled = false after Use")
+	}
+	Use(nil)
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// LimitBody answers 413 to requests whose body is larger than limit bytes.
+// Bodies over the app's own BodyLimit only get this far when the app
+// streams them (fiber.Config.StreamRequestBody); they are read here, up to
+// limit, so handlers see them like any other body.
+func LimitBody(limit int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		req := c.Request()
+		if req.Header.ContentLength() > limit {
+			return tooLarge(c)
+		}
+		if !req.IsBodyStream() {
+			if len(req.Body()) > limit {
+				return tooLarge(c)
+			}
+			return c.Next()
+		}
+
+		body, err := io.ReadAll(io.LimitReader(req.BodyStream(), int64(limit)+1))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Could not read request body"})
+		}
+		if len(body) > limit {
+			return tooLarge(c)
+		}
+		req.SetBody(body)
+		return c.Next()
+	}
+}
+
+// tooLarge refuses a request body. The connection is closed since the rest
+// of a streamed body is left unread on it.
+func tooLarge(c *fiber.Ctx) error {
+	c.Context().SetConnectionClose()
+	return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": "Request body is too large"})
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestLimitBody(t *testing.T) {
+	app := fiber.New(fiber.Config{BodyLimit: 1 << 10, StreamRequestBody: true, DisableStartupMessage: true})
+	size := func(c *fiber.Ctx) error { return c.JSON(len(c.Body())) }
+	app.Post("/small", LimitBody(1<<10), size)
+	app.Post("/large", LimitBody(1<<16), size)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	go app.Listener(listener)
+	defer app.Shutdown()
+
+	post := func(path string, n int) int {
+		t.Helper()
+		resp, err := http.Post("http://"+listener.Addr().String()+path, "application/octet-stream", bytes.NewReader(make([]byte, n)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	cases := []struct {
+		path string
+		size int
+		want int
+	}{
+		{"/small", 1 << 10, fiber.StatusOK},
+		{"/small", 1<<10 + 1, fiber.StatusRequestEntityTooLarge},
+		{"/large", 1 << 15, fiber.StatusOK},
+		{"/large", 1<<16 + 1, fiber.StatusRequestEntityTooLarge},
+	}
+	for _, tc := range cases {
+		if got := post(tc.path, tc.size); got != tc.want {
+			t.Errorf("POST %s with %d bytes = %d, want %d", tc.path, tc.size, got, tc.want)
+		}
+	}
+}
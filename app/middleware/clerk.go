@@ -3,15 +3,134 @@ package middleware
 import (
 	"context"
 	"errors"
+	"log"
 	"strings"
 
-	"github.com/clerk/clerk-sdk-go/v2/jwt"
 	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/auth"
 	"github.com/theHoracle/whatstore-api/app/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// AuthMiddleware verifies Clerk JWT tokens and attaches the user to the context
+// authError is an authentication failure that should be returned to the client
+type authError struct {
+	status  int
+	message string
+}
+
+func (e *authError) Error() string {
+	return e.message
+}
+
+var authenticator auth.Authenticator
+
+// UseAuthenticator sets how bearer tokens are checked: by Clerk, an OIDC
+// provider or locally issued tokens in the API, or any user at all in tests
+func UseAuthenticator(a auth.Authenticator) {
+	authenticator = a
+}
+
+// authenticate verifies the bearer token in authHeader and loads its user
+func authenticate(ctx context.Context, db *gorm.DB, authHeader string) (*models.User, error) {
+	if authenticator == nil {
+		log.Println("No authenticator set, rejecting signed in request")
+		return nil, &authError{fiber.StatusServiceUnavailable, "Authentication is not configured"}
+	}
+
+	// Ensure it’s in "Bearer <token>" format
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return nil, &authError{fiber.StatusUnauthorized, "Invalid Authorization header. Expected: Bearer <token>"}
+	}
+	token := parts[1]
+
+	// Verify the token and get who it was issued to
+	identity, err := authenticator.Authenticate(ctx, token)
+	if errors.Is(err, auth.ErrInvalidToken) {
+		return nil, &authError{fiber.StatusUnauthorized, "Invalid or expired token"}
+	}
+	if err != nil {
+		log.Printf("Could not verify token: %v", err)
+		return nil, &authError{fiber.StatusServiceUnavailable, "Could not verify token, try again later"}
+	}
+	if identity.Subject == "" {
+		return nil, &authError{fiber.StatusUnauthorized, "Token missing user ID"}
+	}
+
+	// Fetch the user from the database
+	user, err := findUser(db, identity.Subject)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		user, err = createUser(db, identity)
+	}
+	if err != nil {
+		var authErr *authError
+		if errors.As(err, &authErr) {
+			return nil, err
+		}
+		return nil, &authError{fiber.StatusInternalServerError, "Database error"}
+	}
+
+	return user, nil
+}
+
+func findUser(db *gorm.DB, clerkID string) (*models.User, error) {
+	var user models.User
+	if err := db.Preload("Vendor").Preload("Vendor.Stores").Where("clerk_id = ?", clerkID).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// createUser adds a buyer for an identity signing in for the first time.
+// Only tokens carrying an email, such as OIDC ID tokens, have enough to go
+// on; Clerk's users are created by its webhook instead.
+func createUser(db *gorm.DB, identity *auth.Identity) (*models.User, error) {
+	if identity.Email == "" {
+		return nil, &authError{fiber.StatusUnauthorized, "User not found in database"}
+	}
+
+	name := identity.Name
+	if name == "" {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+	user := models.User{
+		ClerkID:  identity.Subject,
+		Name:     name,
+		Email:    identity.Email,
+		Username: strings.ToLower(identity.Subject),
+		Role:     models.RoleBuyer,
+	}
+	// A request signing in at the same time may create the user first
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&user)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 1 {
+		log.Printf("Created buyer %d for %s on first sign in", user.ID, identity.Subject)
+	}
+
+	found, err := findUser(db, identity.Subject)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Another user already has the email or username
+		return nil, &authError{fiber.StatusConflict, "Another account already uses this email or username"}
+	}
+	return found, err
+}
+
+func authErrorResponse(c *fiber.Ctx, err error) error {
+	var authErr *authError
+	if errors.As(err, &authErr) {
+		return c.Status(authErr.status).JSON(fiber.Map{
+			"error": authErr.message,
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "Authentication failed",
+	})
+}
+
+// AuthMiddleware verifies bearer tokens and attaches the user to the context
 func AuthMiddleware(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// Extract the Authorization header
@@ -22,50 +141,35 @@ func AuthMiddleware(db *gorm.DB) fiber.Handler {
 			})
 		}
 
-		// Ensure it’s in "Bearer <token>" format
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid Authorization header. Expected: Bearer <token>",
-			})
-		}
-		token := parts[1]
-
-		// Verify the token using Clerk SDK
-		claims, err := jwt.Verify(context.Background(), &jwt.VerifyParams{
-			Token: token,
-		})
+		user, err := authenticate(c.UserContext(), db, authHeader)
 		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid or expired token",
-			})
+			return authErrorResponse(c, err)
 		}
 
-		// Get the user ID (Clerk’s "sub" claim)
-		userID := claims.Subject
-		if userID == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Token missing user ID",
-			})
-		}
+		// Attach the user to the context
+		c.Locals("user", user)
 
-		// Fetch the user from the database
-		var user models.User
-		if err := db.Preload("Vendor").Preload("Vendor.Stores").Where("clerk_id = ?", userID).First(&user).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-					"error": "User not found in database",
-				})
-			}
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Database error",
-			})
+		// Proceed to the next handler
+		return c.Next()
+	}
+}
+
+// OptionalAuthMiddleware attaches the user to the context when a bearer token
+// is sent and lets anonymous requests through. A token that is sent but
+// invalid is still rejected.
+func OptionalAuthMiddleware(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		authHeader := c.Get("Authorization")
+		if authHeader == "" {
+			return c.Next()
 		}
 
-		// Attach the user to the context
-		c.Locals("user", &user)
+		user, err := authenticate(c.UserContext(), db, authHeader)
+		if err != nil {
+			return authErrorResponse(c, err)
+		}
 
-		// Proceed to the next handler
+		c.Locals("user", user)
 		return c.Next()
 	}
 }
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/domains"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"gorm.io/gorm"
+)
+
+// domainCacheTTL is how long a host's store, or its lack of one, is remembered
+const domainCacheTTL = time.Minute
+
+// domainCacheSize is how many hosts are remembered before the cache starts over
+const domainCacheSize = 10000
+
+type cachedDomain struct {
+	store   *models.Store
+	expires time.Time
+}
+
+type domainCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedDomain
+}
+
+func (dc *domainCache) get(host string, now time.Time) (*models.Store, bool) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	entry, ok := dc.entries[host]
+	if !ok || now.After(entry.expires) {
+		return nil, false
+	}
+	return entry.store, true
+}
+
+func (dc *domainCache) set(host string, store *models.Store, now time.Time) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if len(dc.entries) >= domainCacheSize {
+		dc.entries = make(map[string]cachedDomain)
+	}
+	dc.entries[host] = cachedDomain{store: store, expires: now.Add(domainCacheTTL)}
+}
+
+// requestHost is the request's host name without a port
+func requestHost(c *fiber.Ctx) string {
+	host := c.Hostname()
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// StoreDomainMiddleware attaches the store a request's host belongs to as
+// c.Locals("domainStore") when the host is a verified custom domain. Platform
+// hosts, IP addresses and single label hosts are never looked up.
+func StoreDomainMiddleware(db *gorm.DB) fiber.Handler {
+	cache := &domainCache{entries: make(map[string]cachedDomain)}
+
+	return func(c *fiber.Ctx) error {
+		host := requestHost(c)
+		if !strings.Contains(host, ".") || net.ParseIP(host) != nil || domains.IsPlatformHost(host) {
+			return c.Next()
+		}
+
+		now := time.Now()
+		store, ok := cache.get(host, now)
+		if !ok {
+			found, err := domains.Store(db, host)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Database error"})
+			}
+			store = found
+			cache.set(host, store, now)
+		}
+
+		if store != nil {
+			// Handlers get their own copy of the cached store
+			domainStore := *store
+			c.Locals("domainStore", &domainStore)
+		}
+		return c.Next()
+	}
+}
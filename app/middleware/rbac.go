@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/rbac"
+)
+
+// RequirePermission lets a request through when the signed-in user has
+// permission and logs every denial. It must run after AuthMiddleware.
+func RequirePermission(permission rbac.Permission) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, _ := c.Locals("user").(*models.User)
+		if user == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Authentication required",
+			})
+		}
+
+		if !rbac.Can(user, permission) {
+			log.Printf("Permission denied: user %d (%s, role %s) lacks %s for %s %s (route %s)",
+				user.ID, user.ClerkID, user.Role, permission, c.Method(), c.Path(), c.Route().Path)
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "You do not have permission to do this",
+			})
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+type BookingStatus string
+
+const (
+	BookingStatusRequested BookingStatus = "requested"
+	BookingStatusConfirmed BookingStatus = "confirmed"
+	BookingStatusCompleted BookingStatus = "completed"
+	BookingStatusCancelled BookingStatus = "cancelled"
+	BookingStatusNoShow    BookingStatus = "no_show"
+)
+
+// ServiceAvailability is a weekly window in which a service can be booked.
+// Times are wall clock times ("09:00") in the store's time zone and Weekday
+// counts from Sunday = 0.
+type ServiceAvailability struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	ServiceID uint   `gorm:"index" json:"service_id"`
+	Weekday   int    `json:"weekday"`
+	StartTime string `gorm:"size:5" json:"start_time"`
+	EndTime   string `gorm:"size:5" json:"end_time"`
+}
+
+// ServiceBlackout is a date, in the store's time zone, on which a service
+// cannot be booked
+type ServiceBlackout struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ServiceID uint      `gorm:"uniqueIndex:idx_service_blackouts_service_date" json:"service_id"`
+	Date      string    `gorm:"size:10;uniqueIndex:idx_service_blackouts_service_date" json:"date"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Booking is a buyer's reservation of one slot of a service. Active bookings
+// of a service never overlap, which the database enforces.
+type Booking struct {
+	ID        uint          `gorm:"primaryKey" json:"id"`
+	ServiceID uint          `gorm:"index" json:"service_id"`
+	StoreID   uint          `gorm:"index" json:"store_id"`
+	UserID    uint          `gorm:"index" json:"user_id"`
+	StartsAt  time.Time     `gorm:"index" json:"starts_at"`
+	EndsAt    time.Time     `json:"ends_at"`
+	Status    BookingStatus `gorm:"type:string;default:'requested'" json:"status"`
+	Price     float64       `json:"price"`
+	Currency  string        `json:"currency"`
+	Note      string        `json:"note"`
+	PaymentID *string       `json:"payment_id,omitempty"`
+	Service   *Service      `json:"service,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
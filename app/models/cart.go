@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Cart holds the items a buyer intends to order. Signed-in buyers have one
+// cart each; guests are identified by the token in their cart cookie.
+type Cart struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    *uint      `gorm:"uniqueIndex" json:"user_id,omitempty"`
+	Token     *string    `gorm:"uniqueIndex" json:"-"`
+	Items     []CartItem `gorm:"foreignKey:CartID;constraint:OnDelete:CASCADE" json:"items"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type CartItem struct {
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	CartID    uint            `gorm:"index" json:"cart_id"` // Unique with product and variant, see the migrations
+	ProductID uint            `json:"product_id"`
+	Product   Product         `gorm:"foreignKey:ProductID" json:"product"`
+	VariantID *uint           `json:"variant_id,omitempty"`
+	Variant   *ProductVariant `gorm:"foreignKey:VariantID" json:"variant,omitempty"`
+	Quantity  int             `json:"quantity"`
+	UnitPrice float64         `json:"unit_price"` // Price the buyer last saw
+	InStock   bool            `gorm:"-" json:"in_stock"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// CartPriceChange reports a product whose price moved since it was added to the cart
+type CartPriceChange struct {
+	ProductID uint    `json:"product_id"`
+	VariantID *uint   `json:"variant_id,omitempty"`
+	Name      string  `json:"name"`
+	OldPrice  float64 `json:"old_price"`
+	NewPrice  float64 `json:"new_price"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+type CatalogSyncStatus string
+
+const (
+	CatalogSyncPending CatalogSyncStatus = "pending"
+	CatalogSyncSynced  CatalogSyncStatus = "synced"
+	CatalogSyncFailed  CatalogSyncStatus = "failed"
+)
+
+type CatalogSyncAction string
+
+const (
+	CatalogSyncUpsert CatalogSyncAction = "upsert"
+	CatalogSyncDelete CatalogSyncAction = "delete"
+)
+
+// StoreCatalogSync is the outcome of the latest sync of a store's products
+// to its WhatsApp Business catalog
+type StoreCatalogSync struct {
+	ID             uint              `gorm:"primaryKey" json:"id"`
+	StoreID        uint              `gorm:"uniqueIndex" json:"store_id"`
+	Status         CatalogSyncStatus `json:"status"`
+	SyncedProducts int               `json:"synced_products"`
+	PendingChanges int               `json:"pending_changes"`
+	FailedProducts int               `json:"failed_products"`
+	LastError      string            `json:"last_error,omitempty"`
+	LastSyncedAt   *time.Time        `json:"last_synced_at,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+// CatalogProductSync tracks one product in a store's WhatsApp catalog. The
+// row outlives the product until its removal from the catalog is confirmed.
+type CatalogProductSync struct {
+	ID          uint              `gorm:"primaryKey" json:"id"`
+	StoreID     uint              `gorm:"index" json:"store_id"`
+	ProductID   uint              `gorm:"uniqueIndex" json:"product_id"`
+	RetailerID  string            `json:"retailer_id"`
+	RemoteID    string            `json:"remote_id,omitempty"`
+	Hash        string            `json:"-"` // Hash of the last payload sent, to skip unchanged products
+	Action      CatalogSyncAction `json:"action"`
+	Status      CatalogSyncStatus `json:"status"`
+	BatchHandle string            `json:"-"`
+	LastError   string            `json:"last_error,omitempty"`
+	SyncedAt    *time.Time        `json:"synced_at,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
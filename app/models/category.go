@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Category is a node in the product taxonomy. Products can be filed under
+// any category, and filtering by a category includes its descendants.
+type Category struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	ParentID     *uint      `gorm:"index" json:"parent_id"`
+	Name         string     `json:"name"`
+	Slug         string     `gorm:"uniqueIndex" json:"slug"`
+	Icon         string     `json:"icon"`
+	Parent       *Category  `gorm:"foreignKey:ParentID;constraint:OnDelete:RESTRICT" json:"parent,omitempty"`
+	Children     []Category `gorm:"foreignKey:ParentID" json:"children,omitempty"`
+	ProductCount int64      `gorm:"-" json:"product_count"` // Includes products in descendant categories
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// CheckoutGroup is a single purchase spanning several stores. It owns one
+// order per store and is paid for with one combined payment.
+type CheckoutGroup struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"index" json:"user_id"`
+	TotalAmount float64   `json:"total_amount"`
+	Currency    string    `json:"currency"`
+	PaymentID   *string   `json:"payment_id,omitempty"`
+	Status      string    `gorm:"-" json:"status"` // Shared status of the orders, or "mixed"
+	Orders      []Order   `gorm:"foreignKey:CheckoutGroupID" json:"orders"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CheckoutStatusMixed is reported when the orders of a checkout are in different statuses
+const CheckoutStatusMixed = "mixed"
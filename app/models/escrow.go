@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+type EscrowStatus string
+
+const (
+	EscrowStatusHeld          EscrowStatus = "held"
+	EscrowStatusReleased      EscrowStatus = "released"
+	EscrowStatusRefundPending EscrowStatus = "refund_pending"
+	// EscrowStatusRefundProcessing is a refund claimed by a worker and being
+	// sent to the payment provider
+	EscrowStatusRefundProcessing EscrowStatus = "refund_processing"
+	EscrowStatusRefunded         EscrowStatus = "refunded"
+)
+
+type EscrowAccount string
+
+const (
+	// EscrowAccountClearing is money collected through payment providers
+	EscrowAccountClearing EscrowAccount = "clearing"
+	// EscrowAccountStoreHeld is money paid for a store's orders that has not been released yet
+	EscrowAccountStoreHeld EscrowAccount = "store_held"
+	// EscrowAccountStoreAvailable is money released to a store
+	EscrowAccountStoreAvailable EscrowAccount = "store_available"
+	// EscrowAccountBuyerRefund is money owed back to a buyer until the provider refund goes through
+	EscrowAccountBuyerRefund EscrowAccount = "buyer_refund"
+)
+
+// EscrowHold tracks the money paid for a single order or service booking
+// from payment until it is released to the store or returned to the buyer
+type EscrowHold struct {
+	ID           uint         `gorm:"primaryKey" json:"id"`
+	OrderID      *uint        `gorm:"uniqueIndex" json:"order_id,omitempty"`
+	BookingID    *uint        `gorm:"uniqueIndex" json:"booking_id,omitempty"`
+	PaymentID    uint         `json:"payment_id"`
+	StoreID      uint         `gorm:"index" json:"store_id"`
+	UserID       uint         `gorm:"index" json:"user_id"`
+	Amount       float64      `json:"amount"`
+	Currency     string       `json:"currency"`
+	Status       EscrowStatus `gorm:"type:string;default:'held'" json:"status"`
+	ReleaseAfter *time.Time   `json:"release_after,omitempty"`
+	ReleasedAt   *time.Time   `json:"released_at,omitempty"`
+	RefundedAt   *time.Time   `json:"refunded_at,omitempty"`
+	// RefundReference is the provider's ID for the refund. A hold that has
+	// one is never sent to the provider again.
+	RefundReference string    `json:"refund_reference,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// EscrowLedgerEntry is one leg of a double-entry escrow transaction. Amounts
+// are in minor currency units and the entries of a transaction always sum to zero.
+type EscrowLedgerEntry struct {
+	ID            uint          `gorm:"primaryKey" json:"id"`
+	TransactionID string        `gorm:"index" json:"transaction_id"`
+	HoldID        uint          `gorm:"index" json:"hold_id"`
+	OrderID       *uint         `gorm:"index" json:"order_id,omitempty"`
+	BookingID     *uint         `gorm:"index" json:"booking_id,omitempty"`
+	Account       EscrowAccount `gorm:"type:string" json:"account"`
+	StoreID       *uint         `gorm:"index" json:"store_id,omitempty"`
+	UserID        *uint         `json:"user_id,omitempty"`
+	Amount        int64         `json:"amount"`
+	Currency      string        `json:"currency"`
+	Description   string        `json:"description"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+type InventoryReason string
+
+const (
+	InventoryReasonInitialStock  InventoryReason = "initial_stock"
+	InventoryReasonAdjustment    InventoryReason = "adjustment"
+	InventoryReasonOrderReserved InventoryReason = "order_reserved"
+	InventoryReasonOrderReleased InventoryReason = "order_released"
+)
+
+// InventoryLedgerEntry records a single change to a product's stock and why
+// it happened. For variant changes StockAfter is the variant's stock.
+type InventoryLedgerEntry struct {
+	ID         uint            `gorm:"primaryKey" json:"id"`
+	ProductID  uint            `gorm:"index" json:"product_id"`
+	VariantID  *uint           `gorm:"index" json:"variant_id,omitempty"`
+	StoreID    uint            `gorm:"index" json:"store_id"`
+	OrderID    *uint           `gorm:"index" json:"order_id,omitempty"`
+	Change     int             `json:"change"`
+	StockAfter int             `json:"stock_after"`
+	Reason     InventoryReason `gorm:"type:string" json:"reason"`
+	ActorID    *uint           `json:"actor_id,omitempty"`
+	Note       string          `json:"note"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+func (InventoryLedgerEntry) TableName() string {
+	return "inventory_ledger"
+}
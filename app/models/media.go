@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Media is an image a vendor uploaded for their store. Its URL, or one of its
+// variants' URLs, is what products, services and the store logo point at;
+// media nothing points at is garbage collected.
+type Media struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	StoreID     uint           `gorm:"index" json:"store_id"` // No foreign key, media of deleted stores is collected
+	Filename    string         `json:"filename"`              // Name of the uploaded file
+	Key         string         `json:"-"`                     // Storage key of the original
+	URL         string         `json:"url"`
+	ContentType string         `json:"content_type"`
+	Width       int            `json:"width"`
+	Height      int            `json:"height"`
+	Size        int64          `json:"size"`
+	Variants    []MediaVariant `gorm:"foreignKey:MediaID;constraint:OnDelete:CASCADE" json:"variants"`
+	CreatedAt   time.Time      `gorm:"index" json:"created_at"`
+}
+
+// MediaVariant is a resized or re-encoded copy of an uploaded image
+type MediaVariant struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	MediaID     uint   `gorm:"index" json:"media_id"`
+	Name        string `json:"name"` // medium or thumb
+	Key         string `json:"-"`
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Size        int64  `json:"size"`
+}
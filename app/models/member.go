@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// StoreRole is what a member may do in a store
+type StoreRole string
+
+const (
+	StoreRoleOwner         StoreRole = "owner"
+	StoreRoleManager       StoreRole = "manager"
+	StoreRoleOrderHandler  StoreRole = "order_handler"
+	StoreRoleCatalogEditor StoreRole = "catalog_editor"
+)
+
+// MemberStatus is whether a store member has joined
+type MemberStatus string
+
+const (
+	MemberStatusInvited MemberStatus = "invited"
+	MemberStatusActive  MemberStatus = "active"
+)
+
+// StoreMember gives a user access to a store. Members are invited by email
+// and join when the user signed in with that email accepts. Every store has
+// one owner, the vendor who created it.
+type StoreMember struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	StoreID     uint         `gorm:"uniqueIndex:idx_store_members_email" json:"store_id"`
+	Store       *Store       `gorm:"constraint:OnDelete:CASCADE" json:"store,omitempty"`
+	Email       string       `gorm:"uniqueIndex:idx_store_members_email" json:"email"` // Lowercased
+	UserID      *uint        `gorm:"index" json:"user_id"`                             // Set once the invite is accepted
+	User        *User        `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Role        StoreRole    `gorm:"type:varchar(20)" json:"role"`
+	Status      MemberStatus `gorm:"type:varchar(20);index" json:"status"`
+	InvitedByID *uint        `json:"invited_by_id"`
+	AcceptedAt  *time.Time   `json:"accepted_at"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
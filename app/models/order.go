@@ -5,30 +5,72 @@ import "time"
 type OrderStatus string
 
 const (
-	OrderStatusPending  OrderStatus = "pending"
-	OrderStatusSuccess  OrderStatus = "success"
-	OrderStatusRejected OrderStatus = "rejected"
+	OrderStatusPending    OrderStatus = "pending"
+	OrderStatusConfirmed  OrderStatus = "confirmed"
+	OrderStatusProcessing OrderStatus = "processing"
+	OrderStatusShipped    OrderStatus = "shipped"
+	OrderStatusDelivered  OrderStatus = "delivered"
+	OrderStatusRejected   OrderStatus = "rejected"
+	OrderStatusCancelled  OrderStatus = "cancelled"
+	OrderStatusRefunded   OrderStatus = "refunded"
+	OrderStatusDisputed   OrderStatus = "disputed"
+)
+
+// PurchasedOrderStatuses are the statuses of orders that count as the buyer
+// having bought their items: accepted by the vendor and not called off since
+var PurchasedOrderStatuses = []OrderStatus{
+	OrderStatusConfirmed, OrderStatusProcessing, OrderStatusShipped, OrderStatusDelivered,
+}
+
+// OrderActor identifies who moved an order from one status to another
+type OrderActor string
+
+const (
+	OrderActorBuyer  OrderActor = "buyer"
+	OrderActorVendor OrderActor = "vendor"
+	OrderActorAdmin  OrderActor = "admin"
+	OrderActorSystem OrderActor = "system"
 )
 
 type Order struct {
-	ID          uint        `gorm:"primaryKey" json:"id"`
-	UserID      uint        `json:"user_id"`
-	StoreID     uint        `json:"store_id"`
-	Status      OrderStatus `gorm:"type:string;default:'pending'" json:"status"`
-	TotalAmount float64     `json:"total_amount"`
-	Items       []OrderItem `gorm:"foreignKey:OrderID" json:"items"` // Added proper GORM relationship
-	PaymentID   *string     `json:"payment_id,omitempty"`
-	User        User        `gorm:"foreignKey:UserID" json:"-"`
-	Store       Store       `gorm:"foreignKey:StoreID" json:"-"`
-	CreatedAt   time.Time   `json:"created_at"`
-	UpdatedAt   time.Time   `json:"updated_at"`
+	ID              uint                 `gorm:"primaryKey" json:"id"`
+	UserID          uint                 `json:"user_id"`
+	StoreID         uint                 `json:"store_id"`
+	Status          OrderStatus          `gorm:"type:string;default:'pending'" json:"status"`
+	TotalAmount     float64              `json:"total_amount"`
+	Items           []OrderItem          `gorm:"foreignKey:OrderID" json:"items"` // Added proper GORM relationship
+	PaymentID       *string              `json:"payment_id,omitempty"`
+	CheckoutGroupID *uint                `gorm:"index" json:"checkout_group_id,omitempty"`
+	StatusHistory   []OrderStatusHistory `gorm:"foreignKey:OrderID" json:"status_history,omitempty"`
+	User            User                 `gorm:"foreignKey:UserID" json:"-"`
+	Store           Store                `gorm:"foreignKey:StoreID" json:"-"`
+	CreatedAt       time.Time            `json:"created_at"`
+	UpdatedAt       time.Time            `json:"updated_at"`
 }
 
 type OrderItem struct {
-	ID        uint    `gorm:"primaryKey" json:"id"`
-	OrderID   uint    `json:"order_id"`
-	ProductID uint    `json:"product_id"`
-	Product   Product `gorm:"foreignKey:ProductID" json:"product"`
-	Quantity  int     `json:"quantity"`
-	Price     float64 `json:"price"` // Price at time of order
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	OrderID   uint            `json:"order_id"`
+	ProductID uint            `json:"product_id"`
+	Product   Product         `gorm:"foreignKey:ProductID" json:"product"`
+	VariantID *uint           `json:"variant_id,omitempty"`
+	Variant   *ProductVariant `gorm:"foreignKey:VariantID" json:"variant,omitempty"`
+	Quantity  int             `json:"quantity"`
+	Price     float64         `json:"price"` // Price at time of order
+}
+
+// OrderStatusHistory records a single status transition of an order
+type OrderStatusHistory struct {
+	ID         uint        `gorm:"primaryKey" json:"id"`
+	OrderID    uint        `gorm:"index" json:"order_id"`
+	FromStatus OrderStatus `gorm:"type:string" json:"from_status"`
+	ToStatus   OrderStatus `gorm:"type:string" json:"to_status"`
+	ActorID    *uint       `json:"actor_id,omitempty"`
+	ActorRole  OrderActor  `gorm:"type:string" json:"actor_role"`
+	Note       string      `json:"note"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+func (OrderStatusHistory) TableName() string {
+	return "order_status_history"
 }
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+type PaymentStatus string
+
+const (
+	PaymentStatusPending  PaymentStatus = "pending"
+	PaymentStatusSuccess  PaymentStatus = "success"
+	PaymentStatusFailed   PaymentStatus = "failed"
+	PaymentStatusRefunded PaymentStatus = "refunded"
+	// PaymentStatusPartiallyRefunded is a payment for several orders of which
+	// only some have been refunded
+	PaymentStatusPartiallyRefunded PaymentStatus = "partially_refunded"
+	// PaymentStatusCancelled is a pending payment replaced by a newer attempt
+	PaymentStatusCancelled PaymentStatus = "cancelled"
+	// PaymentStatusDuplicate is a payment that went through after its order
+	// or booking had already been paid by another one, and is owed back to
+	// the buyer
+	PaymentStatusDuplicate PaymentStatus = "duplicate"
+)
+
+// Payment is a single attempt to pay for an order, for every order of a
+// checkout group at once, or for a service booking, through a payment provider
+type Payment struct {
+	ID                    uint          `gorm:"primaryKey" json:"id"`
+	OrderID               *uint         `gorm:"index" json:"order_id,omitempty"`
+	CheckoutGroupID       *uint         `gorm:"index" json:"checkout_group_id,omitempty"`
+	BookingID             *uint         `gorm:"index" json:"booking_id,omitempty"`
+	UserID                uint          `gorm:"index" json:"user_id"`
+	Provider              string        `json:"provider"`
+	Reference             string        `gorm:"uniqueIndex" json:"reference"`
+	ProviderTransactionID string        `json:"provider_transaction_id,omitempty"`
+	Amount                float64       `json:"amount"`
+	Currency              string        `json:"currency"`
+	Status                PaymentStatus `gorm:"type:string;default:'pending'" json:"status"`
+	AuthorizationURL      string        `json:"authorization_url,omitempty"`
+	PaidAt                *time.Time    `json:"paid_at,omitempty"`
+	CreatedAt             time.Time     `json:"created_at"`
+	UpdatedAt             time.Time     `json:"updated_at"`
+}
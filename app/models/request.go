@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type UpdateUserRequest struct {
 	Name    string `json:"name"`
 	Address string `json:"address"`
@@ -10,8 +12,24 @@ type CreateOrderRequest struct {
 	Items []OrderItem `json:"items"`
 }
 
+type CreateCheckoutRequest struct {
+	Items []OrderItem `json:"items"`
+}
+
+type CartItemRequest struct {
+	ProductID uint  `json:"product_id" validate:"required"`
+	VariantID *uint `json:"variant_id"` // Required for products with variants
+	Quantity  int   `json:"quantity" validate:"gte=0"`
+}
+
 type UpdateOrderStatusRequest struct {
-	Status string `json:"status" validate:"required,oneof=pending processing shipped delivered cancelled"`
+	Status string `json:"status" validate:"required,oneof=confirmed processing shipped delivered rejected cancelled refunded disputed"`
+	Note   string `json:"note"`
+}
+
+type InitializePaymentRequest struct {
+	Provider    string `json:"provider" validate:"required,oneof=paystack flutterwave"`
+	CallbackURL string `json:"callback_url"`
 }
 
 type DashboardStats struct {
@@ -25,7 +43,7 @@ type CreateProductRequest struct {
 	Description string   `json:"description"`
 	Price       float64  `json:"price" validate:"required,gt=0"`
 	Stock       int      `json:"stock" validate:"required,gte=0"`
-	Category    string   `json:"category"`
+	CategoryID  *uint    `json:"category_id"`
 	Images      []string `json:"images"`
 }
 
@@ -33,14 +51,18 @@ type UpdateProductRequest struct {
 	Name        string  `json:"name,omitempty"`
 	Description string  `json:"description,omitempty"`
 	Price       float64 `json:"price,omitempty" validate:"omitempty,gt=0"`
-	Stock       int     `json:"stock,omitempty" validate:"omitempty,gte=0"`
-	Category    string  `json:"category,omitempty"`
+	Stock       *int    `json:"stock,omitempty" validate:"omitempty,gte=0"`
+	CategoryID  *uint   `json:"category_id,omitempty"`
 }
 
 type CreateVendorRequest struct {
 	User User `json:"user"`
 }
 
+type UpdateVendorRequest struct {
+	IsActive *bool `json:"is_active,omitempty"`
+}
+
 type CreateStoreRequest struct {
 	StoreName            string `json:"store_name" validate:"required"`
 	StoreDescription     string `json:"store_description" validate:"required"`
@@ -48,6 +70,38 @@ type CreateStoreRequest struct {
 	StoreUrl             string `json:"store_url" validate:"required"`
 	StoreAddress         string `json:"store_address" validate:"required"`
 	StoreWhatsappContact string `json:"store_whatsapp_contact" validate:"required"`
+	Timezone             string `json:"timezone"` // IANA name such as Africa/Lagos, defaults to Africa/Lagos
+}
+
+type UpdateStoreRequest struct {
+	Name                 string `json:"name"`
+	Description          string `json:"description"`
+	StoreLogo            string `json:"store_logo"`
+	StoreUrl             string `json:"store_url"`
+	StoreAddress         string `json:"store_address"`
+	StoreWhatsappContact string `json:"store_whatsapp_contact"`
+	Timezone             string `json:"timezone"` // Unchanged when empty
+}
+
+type UpdateWhatsappTemplateRequest struct {
+	Template string `json:"template"`
+}
+
+type ConnectCatalogRequest struct {
+	CatalogID string `json:"catalog_id"` // Empty disconnects the catalog
+}
+
+type InviteStoreMemberRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"` // manager, order_handler or catalog_editor
+}
+
+type UpdateStoreMemberRequest struct {
+	Role string `json:"role"` // manager, order_handler or catalog_editor
+}
+
+type AddStoreDomainRequest struct {
+	Domain string `json:"domain"` // e.g. shop.example.com
 }
 
 type CreateServiceRequest struct {
@@ -65,3 +119,52 @@ type UpdateServiceRequest struct {
 	Currency    string  `json:"currency"`
 	ImageURL    string  `json:"image_url"`
 }
+
+type AvailabilityWindowRequest struct {
+	Weekday   int    `json:"weekday"`    // 0 = Sunday
+	StartTime string `json:"start_time"` // "09:00" in the store's time zone
+	EndTime   string `json:"end_time"`
+}
+
+type ServiceAvailabilityRequest struct {
+	SlotMinutes int                         `json:"slot_minutes" validate:"required"`
+	Windows     []AvailabilityWindowRequest `json:"windows"`
+}
+
+type ServiceBlackoutRequest struct {
+	Date   string `json:"date" validate:"required"` // YYYY-MM-DD in the store's time zone
+	Reason string `json:"reason"`
+}
+
+type CreateBookingRequest struct {
+	StartsAt time.Time `json:"starts_at" validate:"required"`
+	Note     string    `json:"note"`
+}
+
+type UpdateBookingStatusRequest struct {
+	Status string `json:"status" validate:"required"`
+}
+
+type CategoryRequest struct {
+	Name     string `json:"name" validate:"required"`
+	Slug     string `json:"slug"` // Generated from the name when empty
+	Icon     string `json:"icon"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+type ProductOptionRequest struct {
+	Name   string   `json:"name" validate:"required"`
+	Values []string `json:"values" validate:"required"`
+}
+
+type SetProductOptionsRequest struct {
+	Options []ProductOptionRequest `json:"options"`
+}
+
+type ProductVariantRequest struct {
+	SKU          string            `json:"sku"`
+	OptionValues map[string]string `json:"option_values" validate:"required"` // A value for every product option, keyed by option name
+	Price        *float64          `json:"price"`                             // Empty uses the product price
+	Stock        int               `json:"stock" validate:"gte=0"`
+	Images       []string          `json:"images"`
+}
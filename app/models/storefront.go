@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// StoreURLRedirect keeps a store's previous URL pointing at the store after
+// the vendor changes it
+type StoreURLRedirect struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	StoreURL  string    `gorm:"uniqueIndex" json:"store_url"`
+	StoreID   uint      `gorm:"index" json:"store_id"`
+	Store     *Store    `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DomainStatus is where a custom domain is in its DNS verification
+type DomainStatus string
+
+const (
+	DomainStatusPending  DomainStatus = "pending"
+	DomainStatusVerified DomainStatus = "verified"
+	DomainStatusFailed   DomainStatus = "failed"
+)
+
+// StoreDomain is a domain a vendor serves their storefront on. The vendor
+// proves they own it by publishing Token in a DNS TXT record, and the record
+// is checked again periodically while the domain is in use. Several stores
+// may claim a domain but only one can have it verified.
+type StoreDomain struct {
+	ID            uint         `gorm:"primaryKey" json:"id"`
+	StoreID       uint         `gorm:"index;uniqueIndex:idx_store_domains_store_domain" json:"store_id"`
+	Store         *Store       `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Domain        string       `gorm:"uniqueIndex:idx_store_domains_store_domain;uniqueIndex:idx_store_domains_verified_domain,where:status = 'verified'" json:"domain"`
+	Token         string       `json:"token"`
+	Status        DomainStatus `gorm:"type:varchar(20);default:'pending';index" json:"status"`
+	Failures      int          `json:"failures"` // Failed checks in a row
+	LastError     string       `json:"last_error,omitempty"`
+	LastCheckedAt *time.Time   `json:"last_checked_at"`
+	VerifiedAt    *time.Time   `json:"verified_at"`
+	NextCheckAt   *time.Time   `gorm:"index" json:"next_check_at"` // Nil once the domain has failed
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
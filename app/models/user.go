@@ -2,6 +2,20 @@ package models
 
 import "time"
 
+// Role is what a user does on the platform. Buyer, vendor and vendor staff
+// follow from what the user has set up in the app; support, admin and super
+// admin are given in Clerk.
+type Role string
+
+const (
+	RoleBuyer       Role = "buyer"
+	RoleVendor      Role = "vendor"
+	RoleVendorStaff Role = "vendor_staff"
+	RoleSupport     Role = "support"
+	RoleAdmin       Role = "admin"
+	RoleSuperAdmin  Role = "super_admin"
+)
+
 type User struct {
 	ID          uint         `gorm:"primaryKey" json:"id"`
 	ClerkID     string       `gorm:"uniqueIndex" json:"clerk_id"`
@@ -9,6 +23,8 @@ type User struct {
 	Email       string       `gorm:"uniqueIndex" json:"email"`
 	Username    string       `gorm:"uniqueIndex" json:"username"`
 	AvatarURL   string       `json:"avatar_url"`
+	PhoneNumber string       `json:"phone_number"` // E.164, used for WhatsApp order updates
+	Role        Role         `gorm:"type:varchar(20);default:'buyer';index" json:"role"`
 	CreatedAt   time.Time    `json:"created_at"`
 	UpdatedAt   time.Time    `json:"updated_at"`
 	Vendor      *Vendor      `gorm:"foreignKey:UserID;constraint:OnDelete:SET NULL" json:"vendor,omitempty"`
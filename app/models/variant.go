@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// ProductOption is a dimension a product comes in, such as Size or Color,
+// with the values vendors can pick from for its variants
+type ProductOption struct {
+	ID        uint     `gorm:"primaryKey" json:"id"`
+	ProductID uint     `gorm:"index" json:"product_id"`
+	Name      string   `json:"name"`
+	Position  int      `json:"position"`
+	Values    []string `gorm:"type:text[]" json:"values"`
+}
+
+// ProductVariant is one purchasable combination of a product's option
+// values. Products with variants are priced and stocked per variant, and
+// the product's own stock is the sum of its variants' stock.
+type ProductVariant struct {
+	ID           uint              `gorm:"primaryKey" json:"id"`
+	ProductID    uint              `gorm:"index" json:"product_id"`
+	SKU          string            `gorm:"index" json:"sku"`
+	Title        string            `json:"title"` // Option values in option order, e.g. "M / Red"
+	OptionValues map[string]string `gorm:"serializer:json" json:"option_values"`
+	Price        *float64          `json:"price,omitempty"` // Overrides the product price when set
+	Stock        int               `json:"stock"`
+	Images       []string          `gorm:"type:text[]" json:"images"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+// PriceFor returns what one unit of the product costs, in the given variant if any
+func (p *Product) PriceFor(variant *ProductVariant) float64 {
+	if variant != nil && variant.Price != nil {
+		return *variant.Price
+	}
+	return p.Price
+}
@@ -12,33 +12,40 @@ type Vendor struct {
 }
 
 type Store struct {
-	ID                   uint      `gorm:"primaryKey" json:"id"`
-	VendorID             uint      `json:"vendor_id"`
-	Name                 string    `json:"name"`
-	Description          string    `json:"description"`
-	StoreLogo            string    `json:"store_logo"`
-	StoreUrl             string    `json:"store_url" validate:"required"`
-	StoreAddress         string    `json:"store_address" validate:"required"`
-	StoreWhatsappContact string    `json:"store_whatsapp_contact" validate:"required"`
-	Products             []Product `gorm:"foreignKey:StoreID" json:"products,omitempty"`
-	Services             []Service `gorm:"foreignKey:StoreID" json:"services,omitempty"`
-	CreatedAt            time.Time `json:"created_at"`
-	UpdatedAt            time.Time `json:"updated_at"`
+	ID                   uint              `gorm:"primaryKey" json:"id"`
+	VendorID             uint              `json:"vendor_id"`
+	Name                 string            `json:"name"`
+	Description          string            `json:"description"`
+	StoreLogo            string            `json:"store_logo"`
+	StoreUrl             string            `json:"store_url" validate:"required"`
+	StoreAddress         string            `json:"store_address" validate:"required"`
+	StoreWhatsappContact string            `json:"store_whatsapp_contact" validate:"required"`
+	WhatsappTemplate     string            `json:"whatsapp_template"` // Order message template, empty uses the default
+	WhatsappCatalogID    string            `json:"whatsapp_catalog_id"`
+	Timezone             string            `gorm:"default:'Africa/Lagos'" json:"timezone"` // IANA time zone of the store's opening hours and bookings
+	CatalogSync          *StoreCatalogSync `gorm:"foreignKey:StoreID" json:"catalog_sync,omitempty"`
+	Products             []Product         `gorm:"foreignKey:StoreID" json:"products,omitempty"`
+	Services             []Service         `gorm:"foreignKey:StoreID" json:"services,omitempty"`
+	CreatedAt            time.Time         `json:"created_at"`
+	UpdatedAt            time.Time         `json:"updated_at"`
 }
 
 type Product struct {
-	ID           uint      `gorm:"primaryKey" json:"id"`
-	StoreID      uint      `json:"store_id"`
-	Name         string    `json:"name"`
-	Description  string    `json:"description"`
-	Images       []string  `gorm:"type:text[]" json:"images"`
-	Price        float64   `json:"price"`
-	Currency     string    `json:"currency" gorm:"default:NGN"`
-	Stock        int       `json:"stock"`
-	Category     string    `json:"category"` // We will create availabel categories later
-	SearchVector string    `gorm:"type:tsvector;index:idx_products_search,type:gin" json:"-"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           uint             `gorm:"primaryKey" json:"id"`
+	StoreID      uint             `json:"store_id"`
+	Name         string           `json:"name"`
+	Description  string           `json:"description"`
+	Images       []string         `gorm:"type:text[]" json:"images"`
+	Price        float64          `json:"price"`
+	Currency     string           `json:"currency" gorm:"default:NGN"`
+	Stock        int              `json:"stock"`
+	CategoryID   *uint            `gorm:"index" json:"category_id"`
+	Category     *Category        `gorm:"constraint:OnDelete:SET NULL" json:"category,omitempty"`
+	Options      []ProductOption  `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE" json:"options,omitempty"`
+	Variants     []ProductVariant `gorm:"foreignKey:ProductID;constraint:OnDelete:CASCADE" json:"variants,omitempty"`
+	SearchVector string           `gorm:"type:tsvector;index:idx_products_search,type:gin" json:"-"`
+	CreatedAt    time.Time        `json:"created_at"`
+	UpdatedAt    time.Time        `json:"updated_at"`
 }
 
 type Service struct {
@@ -49,6 +56,7 @@ type Service struct {
 	ImageURL     string    `json:"image_url"`
 	Rate         float64   `json:"rate"`
 	Currency     string    `json:"currency" gorm:"default:NGN"`
+	SlotMinutes  int       `gorm:"default:60" json:"slot_minutes"` // Length of one booking
 	SearchVector string    `gorm:"type:tsvector;index:idx_services_search,type:gin" json:"-"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
@@ -0,0 +1,23 @@
+package models
+
+// ProductViewer is how a product relates to the signed-in user reading it
+type ProductViewer struct {
+	StoreRole StoreRole `json:"store_role,omitempty"` // The user's role in the product's store, empty when they are not a member
+	CanEdit   bool      `json:"can_edit"`             // The user may change the product
+	Purchased bool      `json:"purchased"`            // The user has bought the product before
+}
+
+// ServiceViewer is how a service relates to the signed-in user reading it
+type ServiceViewer struct {
+	StoreRole StoreRole `json:"store_role,omitempty"`
+	CanEdit   bool      `json:"can_edit"`
+	Booked    bool      `json:"booked"` // The user has had a booking of the service confirmed before
+}
+
+// StoreViewer is how a storefront relates to the signed-in user reading it
+type StoreViewer struct {
+	StoreRole           StoreRole `json:"store_role,omitempty"`
+	CanEdit             bool      `json:"can_edit"`              // The user may change the store's products and services
+	Orders              int64     `json:"orders"`                // Orders the user has placed in the store
+	PurchasedProductIDs []uint    `json:"purchased_product_ids"` // Products on the page the user has bought before
+}
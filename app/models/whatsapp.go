@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+type WhatsappMessageStatus string
+
+const (
+	WhatsappMessageQueued    WhatsappMessageStatus = "queued"
+	WhatsappMessageSent      WhatsappMessageStatus = "sent"
+	WhatsappMessageDelivered WhatsappMessageStatus = "delivered"
+	WhatsappMessageRead      WhatsappMessageStatus = "read"
+	WhatsappMessageFailed    WhatsappMessageStatus = "failed"
+)
+
+// WhatsappMessage is an outgoing WhatsApp message. Messages are queued inside
+// the transaction that triggers them and sent by a background worker, so a
+// rolled back order change never notifies anyone.
+type WhatsappMessage struct {
+	ID                uint                  `gorm:"primaryKey" json:"id"`
+	OrderID           *uint                 `gorm:"index" json:"order_id,omitempty"`
+	To                string                `json:"to"`
+	Template          string                `json:"template,omitempty"` // Approved template name, empty sends Body as free text
+	Language          string                `json:"language,omitempty"`
+	Params            []string              `gorm:"type:text[]" json:"params,omitempty"`
+	Body              string                `json:"body"`
+	Status            WhatsappMessageStatus `gorm:"index" json:"status"`
+	Attempts          int                   `json:"attempts"`
+	Error             string                `json:"error,omitempty"`
+	ProviderMessageID string                `gorm:"index" json:"provider_message_id,omitempty"`
+	SentAt            *time.Time            `json:"sent_at,omitempty"`
+	CreatedAt         time.Time             `json:"created_at"`
+	UpdatedAt         time.Time             `json:"updated_at"`
+}
+
+// WhatsappInboundMessage records a message received on the business number.
+// Meta delivers webhooks at least once, so a message is recorded before it is
+// acted on and redeliveries of it are skipped.
+type WhatsappInboundMessage struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	MessageID string    `gorm:"uniqueIndex" json:"message_id"`
+	From      string    `json:"from"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,171 @@
+package orderflow
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidTransition = errors.New("invalid order status transition")
+	ErrActorNotAllowed   = errors.New("not allowed to perform this status change")
+)
+
+// transitions lists, for every status, the statuses it can move to and
+// which actors are allowed to make that move
+var transitions = map[models.OrderStatus]map[models.OrderStatus][]models.OrderActor{
+	models.OrderStatusPending: {
+		models.OrderStatusConfirmed: {models.OrderActorVendor, models.OrderActorAdmin},
+		models.OrderStatusRejected:  {models.OrderActorVendor, models.OrderActorAdmin},
+		models.OrderStatusCancelled: {models.OrderActorBuyer, models.OrderActorAdmin, models.OrderActorSystem},
+	},
+	models.OrderStatusConfirmed: {
+		models.OrderStatusProcessing: {models.OrderActorVendor, models.OrderActorAdmin},
+		models.OrderStatusCancelled:  {models.OrderActorBuyer, models.OrderActorVendor, models.OrderActorAdmin},
+	},
+	models.OrderStatusProcessing: {
+		models.OrderStatusShipped:   {models.OrderActorVendor, models.OrderActorAdmin},
+		models.OrderStatusCancelled: {models.OrderActorVendor, models.OrderActorAdmin},
+	},
+	models.OrderStatusShipped: {
+		models.OrderStatusDelivered: {models.OrderActorBuyer, models.OrderActorAdmin, models.OrderActorSystem},
+		models.OrderStatusDisputed:  {models.OrderActorBuyer, models.OrderActorAdmin},
+	},
+	models.OrderStatusDelivered: {
+		models.OrderStatusDisputed: {models.OrderActorBuyer, models.OrderActorAdmin},
+		models.OrderStatusRefunded: {models.OrderActorAdmin},
+	},
+	models.OrderStatusDisputed: {
+		models.OrderStatusDelivered: {models.OrderActorAdmin},
+		models.OrderStatusRefunded:  {models.OrderActorAdmin},
+	},
+	models.OrderStatusCancelled: {
+		models.OrderStatusRefunded: {models.OrderActorAdmin, models.OrderActorSystem},
+	},
+	models.OrderStatusRejected: {
+		models.OrderStatusRefunded: {models.OrderActorAdmin, models.OrderActorSystem},
+	},
+}
+
+// IsValidStatus reports whether status is part of the order lifecycle
+func IsValidStatus(status models.OrderStatus) bool {
+	if _, ok := transitions[status]; ok {
+		return true
+	}
+	return status == models.OrderStatusRefunded
+}
+
+// CanTransition checks whether actor may move an order from one status to another
+func CanTransition(from, to models.OrderStatus, actor models.OrderActor) error {
+	actors, ok := transitions[from][to]
+	if !ok {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, to)
+	}
+	for _, a := range actors {
+		if a == actor {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s cannot move order from %s to %s", ErrActorNotAllowed, actor, from, to)
+}
+
+// AllowedTransitions returns the statuses actor may move an order to from its current status
+func AllowedTransitions(from models.OrderStatus, actor models.OrderActor) []models.OrderStatus {
+	var allowed []models.OrderStatus
+	for to, actors := range transitions[from] {
+		for _, a := range actors {
+			if a == actor {
+				allowed = append(allowed, to)
+				break
+			}
+		}
+	}
+	return allowed
+}
+
+// Hook runs inside the transition transaction after the new status and its
+// history entry have been saved. Returning an error rolls the whole
+// transition back.
+type Hook func(tx *gorm.DB, order *models.Order, change *models.OrderStatusHistory) error
+
+var (
+	hooks        []Hook
+	createdHooks []Hook
+)
+
+// OnTransition registers a hook that runs on every successful status change
+func OnTransition(hook Hook) {
+	hooks = append(hooks, hook)
+}
+
+// OnCreated registers a hook that runs when an order is placed
+func OnCreated(hook Hook) {
+	createdHooks = append(createdHooks, hook)
+}
+
+// RecordCreated writes the initial history entry for a newly created order
+// and runs the created hooks
+func RecordCreated(tx *gorm.DB, order *models.Order, actorID *uint) error {
+	change := models.OrderStatusHistory{
+		OrderID:   order.ID,
+		ToStatus:  order.Status,
+		ActorID:   actorID,
+		ActorRole: models.OrderActorBuyer,
+		Note:      "Order placed",
+	}
+	if err := tx.Create(&change).Error; err != nil {
+		return err
+	}
+
+	for _, hook := range createdHooks {
+		if err := hook(tx, order, &change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Transition moves an order to a new status. The order row is locked for the
+// duration of the change so concurrent updates cannot skip a step, and the
+// change is recorded in the order status history.
+func Transition(db *gorm.DB, order *models.Order, to models.OrderStatus, actor models.OrderActor, actorID *uint, note string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var current models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, order.ID).Error; err != nil {
+			return err
+		}
+
+		from := current.Status
+		if err := CanTransition(from, to, actor); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&current).Update("status", to).Error; err != nil {
+			return err
+		}
+
+		change := models.OrderStatusHistory{
+			OrderID:    current.ID,
+			FromStatus: from,
+			ToStatus:   to,
+			ActorID:    actorID,
+			ActorRole:  actor,
+			Note:       note,
+		}
+		if err := tx.Create(&change).Error; err != nil {
+			return err
+		}
+
+		for _, hook := range hooks {
+			if err := hook(tx, &current, &change); err != nil {
+				return err
+			}
+		}
+
+		*order = current
+		return nil
+	})
+}
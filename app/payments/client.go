@@ -0,0 +1,72 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"time"
+)
+
+var defaultHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// doJSON sends a JSON request to a provider API and decodes the JSON response into out
+func doJSON(ctx context.Context, client *http.Client, method, url, secretKey string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+secretKey)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(respBody, &apiErr)
+		if apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return fmt.Errorf("%s %s: %d %s", method, url, resp.StatusCode, apiErr.Message)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+// toMinorUnits converts an amount to the smallest currency unit (e.g. kobo)
+func toMinorUnits(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func fromMinorUnits(amount int64) float64 {
+	return float64(amount) / 100
+}
@@ -0,0 +1,162 @@
+package payments
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+)
+
+const flutterwaveBaseURL = "https://api.flutterwave.com/v3"
+
+// Flutterwave takes payments through the Flutterwave v3 API
+type Flutterwave struct {
+	SecretKey  string
+	SecretHash string
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewFlutterwave creates a Flutterwave provider. secretHash is the value
+// configured on the dashboard and sent back in the verif-hash header of
+// webhooks. An empty baseURL uses the live API.
+func NewFlutterwave(secretKey, secretHash, baseURL string) *Flutterwave {
+	if baseURL == "" {
+		baseURL = flutterwaveBaseURL
+	}
+	return &Flutterwave{
+		SecretKey:  secretKey,
+		SecretHash: secretHash,
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: defaultHTTPClient,
+	}
+}
+
+func (f *Flutterwave) Name() string {
+	return "flutterwave"
+}
+
+type flutterwaveTransaction struct {
+	ID       int64   `json:"id"`
+	TxRef    string  `json:"tx_ref"`
+	Status   string  `json:"status"`
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+func (t flutterwaveTransaction) toTransaction() *Transaction {
+	return &Transaction{
+		Reference:  t.TxRef,
+		ProviderID: strconv.FormatInt(t.ID, 10),
+		Status:     flutterwaveStatus(t.Status),
+		Amount:     t.Amount,
+		Currency:   t.Currency,
+	}
+}
+
+func flutterwaveStatus(status string) models.PaymentStatus {
+	switch status {
+	case "successful":
+		return models.PaymentStatusSuccess
+	case "failed", "cancelled":
+		return models.PaymentStatusFailed
+	default:
+		return models.PaymentStatusPending
+	}
+}
+
+func (f *Flutterwave) InitializeTransaction(ctx context.Context, req InitializeRequest) (*Transaction, error) {
+	body := map[string]interface{}{
+		"tx_ref":       req.Reference,
+		"amount":       req.Amount,
+		"currency":     req.Currency,
+		"redirect_url": req.CallbackURL,
+		"customer": map[string]string{
+			"email": req.Email,
+			"name":  req.Name,
+		},
+		"meta": req.Metadata,
+	}
+
+	var resp struct {
+		Data struct {
+			Link string `json:"link"`
+		} `json:"data"`
+	}
+	if err := doJSON(ctx, f.HTTPClient, http.MethodPost, f.BaseURL+"/payments", f.SecretKey, body, &resp); err != nil {
+		return nil, err
+	}
+
+	return &Transaction{
+		Reference:        req.Reference,
+		Status:           models.PaymentStatusPending,
+		Amount:           req.Amount,
+		Currency:         req.Currency,
+		AuthorizationURL: resp.Data.Link,
+	}, nil
+}
+
+func (f *Flutterwave) VerifyTransaction(ctx context.Context, reference string) (*Transaction, error) {
+	var resp struct {
+		Data flutterwaveTransaction `json:"data"`
+	}
+	endpoint := f.BaseURL + "/transactions/verify_by_reference?tx_ref=" + url.QueryEscape(reference)
+	if err := doJSON(ctx, f.HTTPClient, http.MethodGet, endpoint, f.SecretKey, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data.toTransaction(), nil
+}
+
+// Refund refunds a transaction. Flutterwave refunds by transaction ID, so the
+// reference is looked up first.
+func (f *Flutterwave) Refund(ctx context.Context, reference string, amount float64) (*Refund, error) {
+	transaction, err := f.VerifyTransaction(ctx, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Data struct {
+			ID             int64   `json:"id"`
+			AmountRefunded float64 `json:"amount_refunded"`
+			Status         string  `json:"status"`
+		} `json:"data"`
+	}
+	endpoint := f.BaseURL + "/transactions/" + url.PathEscape(transaction.ProviderID) + "/refund"
+	if err := doJSON(ctx, f.HTTPClient, http.MethodPost, endpoint, f.SecretKey, map[string]float64{"amount": amount}, &resp); err != nil {
+		return nil, err
+	}
+
+	return &Refund{
+		Reference:  reference,
+		ProviderID: strconv.FormatInt(resp.Data.ID, 10),
+		Amount:     resp.Data.AmountRefunded,
+		Status:     resp.Data.Status,
+	}, nil
+}
+
+// ParseWebhook checks the verif-hash header against the configured secret hash
+func (f *Flutterwave) ParseWebhook(header http.Header, body []byte) (*WebhookEvent, error) {
+	if f.SecretHash == "" || subtle.ConstantTimeCompare([]byte(header.Get("Verif-Hash")), []byte(f.SecretHash)) != 1 {
+		return nil, ErrInvalidSignature
+	}
+
+	var event struct {
+		Event string                 `json:"event"`
+		Data  flutterwaveTransaction `json:"data"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+
+	return &WebhookEvent{
+		Type:      event.Event,
+		Reference: event.Data.TxRef,
+		Status:    flutterwaveStatus(event.Data.Status),
+	}, nil
+}
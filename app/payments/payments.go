@@ -0,0 +1,185 @@
+package payments
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrAmountMismatch = errors.New("paid amount does not match the payment")
+
+// PaidHook runs inside the confirmation transaction once a payment succeeds.
+// Returning an error rolls the confirmation back.
+type PaidHook func(tx *gorm.DB, payment *models.Payment) error
+
+var paidHooks []PaidHook
+
+// OnPaid registers a hook that runs whenever a payment is confirmed
+func OnPaid(hook PaidHook) {
+	paidHooks = append(paidHooks, hook)
+}
+
+// NewReference generates a unique payment reference. scope identifies what
+// is being paid for, such as an order ID, so references are easy to trace.
+func NewReference(scope string) string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("WS-%s-%s", scope, hex.EncodeToString(b))
+}
+
+// settled reports whether a payment has already been confirmed one way or
+// another and needs nothing more from Confirm
+func settled(payment *models.Payment) bool {
+	switch payment.Status {
+	case models.PaymentStatusSuccess, models.PaymentStatusDuplicate, models.PaymentStatusRefunded, models.PaymentStatusPartiallyRefunded:
+		return true
+	}
+	return false
+}
+
+// Confirm re-verifies a payment with its provider and, once the provider
+// reports it successful, marks the payment and its order or booking as paid. Confirming
+// an already successful payment is a no-op, so repeated webhooks are safe.
+// A payment for an order or booking already paid by another payment is
+// marked duplicate and refunded.
+func Confirm(ctx context.Context, db *gorm.DB, provider Provider, reference string) (*models.Payment, error) {
+	var payment models.Payment
+	if err := db.Where("reference = ? AND provider = ?", reference, provider.Name()).First(&payment).Error; err != nil {
+		return nil, err
+	}
+	if settled(&payment) {
+		return &payment, nil
+	}
+
+	transaction, err := provider.VerifyTransaction(ctx, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	switch transaction.Status {
+	case models.PaymentStatusPending:
+		return &payment, nil
+	case models.PaymentStatusFailed:
+		return &payment, MarkFailed(db, provider, reference)
+	}
+
+	if math.Abs(transaction.Amount-payment.Amount) > 0.005 || !strings.EqualFold(transaction.Currency, payment.Currency) {
+		if err := MarkFailed(db, provider, reference); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: expected %.2f %s, got %.2f %s", ErrAmountMismatch, payment.Amount, payment.Currency, transaction.Amount, transaction.Currency)
+	}
+
+	duplicate := false
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, payment.ID).Error; err != nil {
+			return err
+		}
+		if settled(&payment) {
+			return nil
+		}
+
+		paidWith, err := lockPaidWith(tx, &payment)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		payment.Status = models.PaymentStatusSuccess
+		payment.ProviderTransactionID = transaction.ProviderID
+		payment.PaidAt = &now
+		if paidWith != nil && *paidWith != payment.Reference {
+			duplicate = true
+			payment.Status = models.PaymentStatusDuplicate
+			return tx.Save(&payment).Error
+		}
+		if err := tx.Save(&payment).Error; err != nil {
+			return err
+		}
+
+		// Mark the booking, the order, or every order of the checkout group, as paid
+		if payment.BookingID != nil {
+			if err := tx.Model(&models.Booking{}).
+				Where("id = ? AND payment_id IS NULL", *payment.BookingID).
+				Update("payment_id", payment.Reference).Error; err != nil {
+				return err
+			}
+		} else {
+			orders := tx.Model(&models.Order{}).Where("payment_id IS NULL")
+			if payment.CheckoutGroupID != nil {
+				if err := tx.Model(&models.CheckoutGroup{}).
+					Where("id = ?", *payment.CheckoutGroupID).
+					Update("payment_id", payment.Reference).Error; err != nil {
+					return err
+				}
+				orders = orders.Where("checkout_group_id = ?", *payment.CheckoutGroupID)
+			} else {
+				orders = orders.Where("id = ?", payment.OrderID)
+			}
+			if err := orders.Update("payment_id", payment.Reference).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, hook := range paidHooks {
+			if err := hook(tx, &payment); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if duplicate {
+		refundDuplicate(ctx, db, provider, &payment)
+	}
+	return &payment, nil
+}
+
+// lockPaidWith locks the order, checkout group or booking a payment is for
+// and returns the reference of the payment it was already paid with, if any
+func lockPaidWith(tx *gorm.DB, payment *models.Payment) (*string, error) {
+	var paid struct{ PaymentID *string }
+	locked := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("payment_id")
+	var err error
+	switch {
+	case payment.BookingID != nil:
+		err = locked.Model(&models.Booking{}).Where("id = ?", *payment.BookingID).Scan(&paid).Error
+	case payment.CheckoutGroupID != nil:
+		err = locked.Model(&models.CheckoutGroup{}).Where("id = ?", *payment.CheckoutGroupID).Scan(&paid).Error
+	default:
+		err = locked.Model(&models.Order{}).Where("id = ?", payment.OrderID).Scan(&paid).Error
+	}
+	return paid.PaymentID, err
+}
+
+// refundDuplicate gives a duplicate payment back to the buyer. When the
+// provider refuses, the payment stays marked duplicate to be refunded by hand.
+func refundDuplicate(ctx context.Context, db *gorm.DB, provider Provider, payment *models.Payment) {
+	if _, err := provider.Refund(ctx, payment.Reference, payment.Amount); err != nil {
+		log.Printf("Failed to refund duplicate payment %s: %v", payment.Reference, err)
+		return
+	}
+	if err := db.Model(payment).Update("status", models.PaymentStatusRefunded).Error; err != nil {
+		log.Printf("Refunded duplicate payment %s but failed to record it: %v", payment.Reference, err)
+	}
+}
+
+// MarkFailed records that a payment attempt did not go through
+func MarkFailed(db *gorm.DB, provider Provider, reference string) error {
+	return db.Model(&models.Payment{}).
+		Where("reference = ? AND provider = ? AND status = ?", reference, provider.Name(), models.PaymentStatusPending).
+		Update("status", models.PaymentStatusFailed).Error
+}
@@ -0,0 +1,158 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+)
+
+const paystackBaseURL = "https://api.paystack.co"
+
+// Paystack takes payments through the Paystack API
+type Paystack struct {
+	SecretKey  string
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewPaystack creates a Paystack provider. An empty baseURL uses the live API.
+func NewPaystack(secretKey, baseURL string) *Paystack {
+	if baseURL == "" {
+		baseURL = paystackBaseURL
+	}
+	return &Paystack{
+		SecretKey:  secretKey,
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: defaultHTTPClient,
+	}
+}
+
+func (p *Paystack) Name() string {
+	return "paystack"
+}
+
+type paystackTransaction struct {
+	ID        int64  `json:"id"`
+	Reference string `json:"reference"`
+	Status    string `json:"status"`
+	Amount    int64  `json:"amount"`
+	Currency  string `json:"currency"`
+}
+
+func (t paystackTransaction) toTransaction() *Transaction {
+	return &Transaction{
+		Reference:  t.Reference,
+		ProviderID: strconv.FormatInt(t.ID, 10),
+		Status:     paystackStatus(t.Status),
+		Amount:     fromMinorUnits(t.Amount),
+		Currency:   t.Currency,
+	}
+}
+
+func paystackStatus(status string) models.PaymentStatus {
+	switch status {
+	case "success":
+		return models.PaymentStatusSuccess
+	case "failed", "abandoned", "reversed":
+		return models.PaymentStatusFailed
+	default:
+		return models.PaymentStatusPending
+	}
+}
+
+func (p *Paystack) InitializeTransaction(ctx context.Context, req InitializeRequest) (*Transaction, error) {
+	body := map[string]interface{}{
+		"email":        req.Email,
+		"amount":       toMinorUnits(req.Amount),
+		"currency":     req.Currency,
+		"reference":    req.Reference,
+		"callback_url": req.CallbackURL,
+		"metadata":     req.Metadata,
+	}
+
+	var resp struct {
+		Data struct {
+			AuthorizationURL string `json:"authorization_url"`
+			Reference        string `json:"reference"`
+		} `json:"data"`
+	}
+	if err := doJSON(ctx, p.HTTPClient, http.MethodPost, p.BaseURL+"/transaction/initialize", p.SecretKey, body, &resp); err != nil {
+		return nil, err
+	}
+
+	return &Transaction{
+		Reference:        resp.Data.Reference,
+		Status:           models.PaymentStatusPending,
+		Amount:           req.Amount,
+		Currency:         req.Currency,
+		AuthorizationURL: resp.Data.AuthorizationURL,
+	}, nil
+}
+
+func (p *Paystack) VerifyTransaction(ctx context.Context, reference string) (*Transaction, error) {
+	var resp struct {
+		Data paystackTransaction `json:"data"`
+	}
+	if err := doJSON(ctx, p.HTTPClient, http.MethodGet, p.BaseURL+"/transaction/verify/"+url.PathEscape(reference), p.SecretKey, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data.toTransaction(), nil
+}
+
+func (p *Paystack) Refund(ctx context.Context, reference string, amount float64) (*Refund, error) {
+	body := map[string]interface{}{
+		"transaction": reference,
+		"amount":      toMinorUnits(amount),
+	}
+
+	var resp struct {
+		Data struct {
+			ID     int64  `json:"id"`
+			Amount int64  `json:"amount"`
+			Status string `json:"status"`
+		} `json:"data"`
+	}
+	if err := doJSON(ctx, p.HTTPClient, http.MethodPost, p.BaseURL+"/refund", p.SecretKey, body, &resp); err != nil {
+		return nil, err
+	}
+
+	return &Refund{
+		Reference:  reference,
+		ProviderID: strconv.FormatInt(resp.Data.ID, 10),
+		Amount:     fromMinorUnits(resp.Data.Amount),
+		Status:     resp.Data.Status,
+	}, nil
+}
+
+// ParseWebhook verifies the x-paystack-signature header, an HMAC-SHA512 of
+// the body keyed with the secret key
+func (p *Paystack) ParseWebhook(header http.Header, body []byte) (*WebhookEvent, error) {
+	mac := hmac.New(sha512.New, []byte(p.SecretKey))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(header.Get("X-Paystack-Signature"))) {
+		return nil, ErrInvalidSignature
+	}
+
+	var event struct {
+		Event string              `json:"event"`
+		Data  paystackTransaction `json:"data"`
+	}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+
+	return &WebhookEvent{
+		Type:      event.Event,
+		Reference: event.Data.Reference,
+		Status:    paystackStatus(event.Data.Status),
+	}, nil
+}
@@ -0,0 +1,86 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+)
+
+var (
+	ErrUnknownProvider  = errors.New("unknown payment provider")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// InitializeRequest describes a payment the buyer is about to make
+type InitializeRequest struct {
+	Reference   string
+	Amount      float64
+	Currency    string
+	Email       string
+	Name        string
+	CallbackURL string
+	Metadata    map[string]interface{}
+}
+
+// Transaction is a provider's view of a payment
+type Transaction struct {
+	Reference        string
+	ProviderID       string
+	Status           models.PaymentStatus
+	Amount           float64
+	Currency         string
+	AuthorizationURL string
+}
+
+// Refund is a provider's view of a refund
+type Refund struct {
+	Reference  string
+	ProviderID string
+	Amount     float64
+	Status     string
+}
+
+// WebhookEvent is a verified provider webhook reduced to what we act on
+type WebhookEvent struct {
+	Type      string
+	Reference string
+	Status    models.PaymentStatus
+}
+
+// Provider is implemented by every payment gateway we can take money through
+type Provider interface {
+	Name() string
+	InitializeTransaction(ctx context.Context, req InitializeRequest) (*Transaction, error)
+	VerifyTransaction(ctx context.Context, reference string) (*Transaction, error)
+	Refund(ctx context.Context, reference string, amount float64) (*Refund, error)
+	ParseWebhook(header http.Header, body []byte) (*WebhookEvent, error)
+}
+
+var providers = map[string]Provider{}
+
+// Register makes a provider available to checkout and webhooks
+func Register(provider Provider) {
+	providers[provider.Name()] = provider
+}
+
+// Get returns the provider registered under name
+func Get(name string) (Provider, error) {
+	provider, ok := providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return provider, nil
+}
+
+// Names lists the registered providers
+func Names() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,112 @@
+package rbac
+
+import "github.com/theHoracle/whatstore-api/app/models"
+
+// Permission allows a group of actions. Routes require permissions rather
+// than roles so what a role may do is decided in one place.
+type Permission string
+
+const (
+	PermissionVendorCreate    Permission = "vendor:create"    // Become a vendor
+	PermissionVendorManage    Permission = "vendor:manage"    // Vendor profile, balance, creating and deleting stores
+	PermissionStoreManage     Permission = "store:manage"     // Store settings, products, services, catalog, domains and media
+	PermissionStoreOrders     Permission = "store:orders"     // Store orders, bookings and inventory
+	PermissionAdminStats      Permission = "admin:stats"      // Platform statistics
+	PermissionOrdersReadAll   Permission = "orders:read_all"  // Every store's orders
+	PermissionOrdersManageAll Permission = "orders:write_all" // Changing the status of any order
+	PermissionEscrowReconcile Permission = "escrow:reconcile" // Escrow ledger checks
+	PermissionCategoryManage  Permission = "category:manage"  // Product taxonomy
+)
+
+var buyer = []Permission{PermissionVendorCreate}
+
+var vendor = []Permission{PermissionVendorManage, PermissionStoreManage, PermissionStoreOrders}
+
+var vendorStaff = []Permission{PermissionStoreManage, PermissionStoreOrders}
+
+var support = []Permission{PermissionAdminStats, PermissionOrdersReadAll}
+
+var admin = []Permission{PermissionOrdersManageAll, PermissionEscrowReconcile, PermissionCategoryManage}
+
+// roles lists each role's permissions. Every role can do what a buyer can,
+// and each platform role adds to the one below it.
+var roles = map[models.Role][]Permission{
+	models.RoleBuyer:       buyer,
+	models.RoleVendor:      concat(buyer, vendor),
+	models.RoleVendorStaff: concat(buyer, vendorStaff),
+	models.RoleSupport:     concat(buyer, support),
+	models.RoleAdmin:       concat(buyer, support, admin),
+	models.RoleSuperAdmin:  concat(buyer, support, admin),
+}
+
+func concat(lists ...[]Permission) []Permission {
+	var all []Permission
+	for _, list := range lists {
+		all = append(all, list...)
+	}
+	return all
+}
+
+// IsValidRole reports whether role is one of the platform's roles
+func IsValidRole(role models.Role) bool {
+	_, ok := roles[role]
+	return ok
+}
+
+// IsPlatformRole reports whether role is given in Clerk rather than
+// following from the user's activity in the app
+func IsPlatformRole(role models.Role) bool {
+	return role == models.RoleSupport || role == models.RoleAdmin || role == models.RoleSuperAdmin
+}
+
+// Permissions lists what a user may do: their role's permissions, and a
+// vendor's permissions when they have a vendor profile whatever their role
+func Permissions(user *models.User) []Permission {
+	role := user.Role
+	if !IsValidRole(role) {
+		role = models.RoleBuyer
+	}
+	permissions := roles[role]
+	if user.Vendor != nil && role != models.RoleVendor {
+		permissions = concat(permissions, vendor)
+	}
+	return permissions
+}
+
+// Can reports whether user has permission. Super admins can do everything.
+func Can(user *models.User, permission Permission) bool {
+	if user == nil {
+		return false
+	}
+	if user.Role == models.RoleSuperAdmin {
+		return true
+	}
+	for _, p := range Permissions(user) {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// AppRole is the role a user's activity in the app gives them
+func AppRole(user *models.User) models.Role {
+	if user.Vendor != nil {
+		return models.RoleVendor
+	}
+	return models.RoleBuyer
+}
+
+// ClerkRole is the role a user should have given the role in their Clerk
+// public metadata. Platform roles come from Clerk alone: one set there is
+// taken, and a user whose platform role was removed in Clerk falls back to
+// their app role. Other roles in the metadata are ignored.
+func ClerkRole(user *models.User, metadataRole string) models.Role {
+	if role := models.Role(metadataRole); IsPlatformRole(role) {
+		return role
+	}
+	if IsPlatformRole(user.Role) || !IsValidRole(user.Role) {
+		return AppRole(user)
+	}
+	return user.Role
+}
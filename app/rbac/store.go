@@ -0,0 +1,45 @@
+package rbac
+
+import "github.com/theHoracle/whatstore-api/app/models"
+
+// StorePermission allows a group of actions in one store
+type StorePermission string
+
+const (
+	StorePermissionSettings StorePermission = "settings" // Store profile, WhatsApp setup, catalog sync, domains and logo
+	StorePermissionCatalog  StorePermission = "catalog"  // Products, variants, services, availability and images
+	StorePermissionOrders   StorePermission = "orders"   // Orders, bookings and the inventory ledger
+	StorePermissionMembers  StorePermission = "members"  // Inviting, changing and revoking members
+	StorePermissionClose    StorePermission = "close"    // Deleting the store
+)
+
+var storeRoles = map[models.StoreRole][]StorePermission{
+	models.StoreRoleOwner: {
+		StorePermissionSettings, StorePermissionCatalog, StorePermissionOrders,
+		StorePermissionMembers, StorePermissionClose,
+	},
+	models.StoreRoleManager:       {StorePermissionSettings, StorePermissionCatalog, StorePermissionOrders},
+	models.StoreRoleOrderHandler:  {StorePermissionOrders},
+	models.StoreRoleCatalogEditor: {StorePermissionCatalog},
+}
+
+// IsValidStoreRole reports whether role is one of the store roles
+func IsValidStoreRole(role models.StoreRole) bool {
+	_, ok := storeRoles[role]
+	return ok
+}
+
+// StorePermissions lists what a store role allows
+func StorePermissions(role models.StoreRole) []StorePermission {
+	return storeRoles[role]
+}
+
+// StoreCan reports whether a member with role has permission in the store
+func StoreCan(role models.StoreRole, permission StorePermission) bool {
+	for _, p := range storeRoles[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,201 @@
+// Package memory implements the repositories in memory, for testing
+// controllers and services without Postgres. Records are copied in and out,
+// so callers changing what they get back does not change what is stored.
+package memory
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/repository"
+)
+
+// DB holds every record the in-memory repositories share
+type DB struct {
+	mu         sync.Mutex
+	lastID     uint
+	users      map[uint]models.User
+	addresses  map[uint]string // Shipping address by user
+	vendors    map[uint]models.Vendor
+	stores     map[uint]models.Store
+	redirects  map[string]uint // Store by previous URL
+	members    map[uint]models.StoreMember
+	categories map[uint]models.Category
+	products   map[uint]models.Product
+	variants   map[uint]models.ProductVariant
+	services   map[uint]models.Service
+	bookings   map[uint]models.Booking
+	orders     map[uint]models.Order
+	history    []models.OrderStatusHistory
+}
+
+// New returns an empty database
+func New() *DB {
+	return &DB{
+		users:      map[uint]models.User{},
+		addresses:  map[uint]string{},
+		vendors:    map[uint]models.Vendor{},
+		stores:     map[uint]models.Store{},
+		redirects:  map[string]uint{},
+		members:    map[uint]models.StoreMember{},
+		categories: map[uint]models.Category{},
+		products:   map[uint]models.Product{},
+		variants:   map[uint]models.ProductVariant{},
+		services:   map[uint]models.Service{},
+		bookings:   map[uint]models.Booking{},
+		orders:     map[uint]models.Order{},
+	}
+}
+
+// The repositories over the database
+func (db *DB) Users() repository.UserRepository       { return &users{db} }
+func (db *DB) Stores() repository.StoreRepository     { return &stores{db} }
+func (db *DB) Products() repository.ProductRepository { return &products{db} }
+func (db *DB) Services() repository.ServiceRepository { return &services{db} }
+func (db *DB) Orders() repository.OrderRepository     { return &orders{db} }
+
+// id returns the next ID, shared by every table so IDs never collide.
+// Callers hold the lock.
+func (db *DB) id(current uint) uint {
+	if current != 0 {
+		if current > db.lastID {
+			db.lastID = current
+		}
+		return current
+	}
+	db.lastID++
+	return db.lastID
+}
+
+// AddUser stores a user, giving it an ID when it has none
+func (db *DB) AddUser(user *models.User) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	user.ID = db.id(user.ID)
+	db.users[user.ID] = *user
+}
+
+// AddVendor stores a vendor profile
+func (db *DB) AddVendor(vendor *models.Vendor) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	vendor.ID = db.id(vendor.ID)
+	db.vendors[vendor.ID] = *vendor
+}
+
+// AddStore stores a store and, when owner is set, its owner's active
+// membership
+func (db *DB) AddStore(store *models.Store, owner *models.User) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	store.ID = db.id(store.ID)
+	db.stores[store.ID] = *store
+	if owner != nil {
+		db.addMember(models.StoreMember{
+			StoreID: store.ID,
+			Email:   owner.Email,
+			UserID:  &owner.ID,
+			Role:    models.StoreRoleOwner,
+			Status:  models.MemberStatusActive,
+		})
+	}
+}
+
+// AddMember stores a store membership
+func (db *DB) AddMember(member *models.StoreMember) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	member.ID = db.addMember(*member)
+}
+
+func (db *DB) addMember(member models.StoreMember) uint {
+	member.ID = db.id(member.ID)
+	db.members[member.ID] = member
+	return member.ID
+}
+
+// AddCategory stores a product category
+func (db *DB) AddCategory(category *models.Category) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	category.ID = db.id(category.ID)
+	db.categories[category.ID] = *category
+}
+
+// AddProduct stores a product with its variants
+func (db *DB) AddProduct(product *models.Product) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	product.ID = db.id(product.ID)
+	for i := range product.Variants {
+		product.Variants[i].ID = db.id(product.Variants[i].ID)
+		product.Variants[i].ProductID = product.ID
+		db.variants[product.Variants[i].ID] = product.Variants[i]
+	}
+	stored := *product
+	stored.Variants = nil
+	db.products[product.ID] = stored
+}
+
+// AddService stores a service
+func (db *DB) AddService(service *models.Service) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	service.ID = db.id(service.ID)
+	db.services[service.ID] = *service
+}
+
+// AddBooking stores a booking of a service
+func (db *DB) AddBooking(booking *models.Booking) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	booking.ID = db.id(booking.ID)
+	db.bookings[booking.ID] = *booking
+}
+
+// ShippingAddress returns the shipping address saved for a user
+func (db *DB) ShippingAddress(userID uint) string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.addresses[userID]
+}
+
+// product returns a product with its category and variants. Callers hold
+// the lock.
+func (db *DB) product(id uint) (*models.Product, bool) {
+	product, ok := db.products[id]
+	if !ok {
+		return nil, false
+	}
+	if product.CategoryID != nil {
+		if category, ok := db.categories[*product.CategoryID]; ok {
+			product.Category = &category
+		}
+	}
+	product.Variants = db.productVariants(id)
+	return &product, true
+}
+
+func (db *DB) productVariants(productID uint) []models.ProductVariant {
+	var variants []models.ProductVariant
+	for _, variant := range db.variants {
+		if variant.ProductID == productID {
+			variants = append(variants, variant)
+		}
+	}
+	sort.Slice(variants, func(i, j int) bool { return variants[i].ID < variants[j].ID })
+	return variants
+}
+
+// sorted returns records ordered by ID
+func sorted[T any](records map[uint]T, id func(T) uint, keep func(T) bool) []T {
+	var out []T
+	for _, record := range records {
+		if keep(record) {
+			out = append(out, record)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return id(out[i]) < id(out[j]) })
+	return out
+}
@@ -0,0 +1,240 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/theHoracle/whatstore-api/app/inventory"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/orderflow"
+	"github.com/theHoracle/whatstore-api/app/repository"
+)
+
+// orders keeps order status history but, unlike the GORM repository, runs
+// no orderflow hooks and records no inventory ledger
+type orders struct {
+	db *DB
+}
+
+func (r *orders) Get(ctx context.Context, id uint) (*models.Order, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	order, ok := r.db.orders[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	order.Items = nil
+	return &order, nil
+}
+
+func (r *orders) GetWithItems(ctx context.Context, id uint) (*models.Order, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	order, ok := r.db.orders[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	items := make([]models.OrderItem, len(order.Items))
+	for i, item := range order.Items {
+		if product, ok := r.db.products[item.ProductID]; ok {
+			item.Product = product
+		}
+		if item.VariantID != nil {
+			if variant, ok := r.db.variants[*item.VariantID]; ok {
+				item.Variant = &variant
+			}
+		}
+		items[i] = item
+	}
+	order.Items = items
+	return &order, nil
+}
+
+func (r *orders) ListByUser(ctx context.Context, userID uint, page, perPage int) ([]models.Order, int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	all := r.list(func(o models.Order) bool { return o.UserID == userID })
+	start := (page - 1) * perPage
+	if start > len(all) {
+		start = len(all)
+	}
+	end := start + perPage
+	if end > len(all) {
+		end = len(all)
+	}
+	return all[start:end], int64(len(all)), nil
+}
+
+func (r *orders) ListByStore(ctx context.Context, storeID uint) ([]models.Order, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	return r.list(func(o models.Order) bool { return o.StoreID == storeID }), nil
+}
+
+func (r *orders) list(keep func(models.Order) bool) []models.Order {
+	list := sorted(r.db.orders, func(o models.Order) uint { return o.ID }, keep)
+	for i := range list {
+		list[i].Items = nil
+	}
+	return list
+}
+
+func (r *orders) Create(ctx context.Context, order *models.Order, actorID *uint) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	// Check every item before taking any stock, as the GORM repository
+	// rolls back when any item is short
+	var short []*inventory.InsufficientStockError
+	for _, item := range order.Items {
+		product, ok := r.db.products[item.ProductID]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		if item.VariantID == nil {
+			if product.Stock < item.Quantity {
+				short = append(short, &inventory.InsufficientStockError{
+					ProductID: product.ID,
+					Name:      product.Name,
+					Requested: item.Quantity,
+					Available: product.Stock,
+				})
+			}
+			continue
+		}
+		variant, ok := r.db.variants[*item.VariantID]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		if variant.Stock < item.Quantity {
+			short = append(short, &inventory.InsufficientStockError{
+				ProductID: product.ID,
+				VariantID: &variant.ID,
+				Name:      product.Name + " (" + variant.Title + ")",
+				Requested: item.Quantity,
+				Available: variant.Stock,
+			})
+		}
+	}
+	if len(short) > 0 {
+		return &repository.StockError{Items: short}
+	}
+
+	order.ID = r.db.id(0)
+	order.CreatedAt = time.Now()
+	order.UpdatedAt = order.CreatedAt
+	for i := range order.Items {
+		item := &order.Items[i]
+		item.ID = r.db.id(0)
+		item.OrderID = order.ID
+
+		product := r.db.products[item.ProductID]
+		product.Stock -= item.Quantity
+		r.db.products[product.ID] = product
+		if item.VariantID != nil {
+			variant := r.db.variants[*item.VariantID]
+			variant.Stock -= item.Quantity
+			r.db.variants[variant.ID] = variant
+		}
+	}
+	stored := *order
+	stored.Items = append([]models.OrderItem(nil), order.Items...)
+	r.db.orders[order.ID] = stored
+
+	r.db.history = append(r.db.history, models.OrderStatusHistory{
+		ID:        r.db.id(0),
+		OrderID:   order.ID,
+		ToStatus:  order.Status,
+		ActorID:   actorID,
+		ActorRole: models.OrderActorBuyer,
+		Note:      "Order placed",
+		CreatedAt: order.CreatedAt,
+	})
+	return nil
+}
+
+func (r *orders) Transition(ctx context.Context, order *models.Order, to models.OrderStatus, actor models.OrderActor, actorID *uint, note string) error {
+	if err := orderflow.CanTransition(order.Status, to, actor); err != nil {
+		return err
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.orders[order.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	from := stored.Status
+	stored.Status = to
+	stored.UpdatedAt = time.Now()
+	r.db.orders[order.ID] = stored
+	r.db.history = append(r.db.history, models.OrderStatusHistory{
+		ID:         r.db.id(0),
+		OrderID:    order.ID,
+		FromStatus: from,
+		ToStatus:   to,
+		ActorID:    actorID,
+		ActorRole:  actor,
+		Note:       note,
+		CreatedAt:  stored.UpdatedAt,
+	})
+
+	order.Status = to
+	order.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+func (r *orders) History(ctx context.Context, orderID uint) ([]models.OrderStatusHistory, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var history []models.OrderStatusHistory
+	for _, entry := range r.db.history {
+		if entry.OrderID == orderID {
+			history = append(history, entry)
+		}
+	}
+	return history, nil
+}
+
+func (r *orders) Purchased(ctx context.Context, userID uint, productIDs []uint) ([]uint, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	bought := map[uint]bool{}
+	for _, order := range r.db.orders {
+		if order.UserID != userID || !slices.Contains(models.PurchasedOrderStatuses, order.Status) {
+			continue
+		}
+		for _, item := range order.Items {
+			bought[item.ProductID] = true
+		}
+	}
+	purchased := []uint{}
+	for _, id := range productIDs {
+		if bought[id] && !slices.Contains(purchased, id) {
+			purchased = append(purchased, id)
+		}
+	}
+	slices.Sort(purchased)
+	return purchased, nil
+}
+
+func (r *orders) CountByUserInStore(ctx context.Context, userID, storeID uint) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var count int64
+	for _, order := range r.db.orders {
+		if order.UserID == userID && order.StoreID == storeID {
+			count++
+		}
+	}
+	return count, nil
+}
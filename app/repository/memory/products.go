@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/theHoracle/whatstore-api/app/inventory"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/repository"
+)
+
+type products struct {
+	db *DB
+}
+
+func (r *products) Get(ctx context.Context, id uint) (*models.Product, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	product, ok := r.db.product(id)
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return product, nil
+}
+
+func (r *products) Create(ctx context.Context, product *models.Product, actorID *uint) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	product.ID = r.db.id(0)
+	r.db.products[product.ID] = *product
+	return nil
+}
+
+// Update applies the non-empty changes, as GORM does when updating from a
+// struct, and a new stock as a change from the stock product was loaded with
+func (r *products) Update(ctx context.Context, product *models.Product, changes models.UpdateProductRequest, actorID *uint) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.products[product.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if changes.Name != "" {
+		stored.Name = changes.Name
+	}
+	if changes.Description != "" {
+		stored.Description = changes.Description
+	}
+	if changes.Price != 0 {
+		stored.Price = changes.Price
+	}
+	if changes.Stock != nil && *changes.Stock != product.Stock {
+		change := *changes.Stock - product.Stock
+		if stored.Stock+change < 0 {
+			return &repository.StockError{Items: []*inventory.InsufficientStockError{{
+				ProductID: product.ID,
+				Name:      stored.Name,
+				Requested: -change,
+				Available: stored.Stock,
+			}}}
+		}
+		stored.Stock += change
+	}
+	if changes.CategoryID != nil {
+		stored.CategoryID = changes.CategoryID
+	}
+	r.db.products[product.ID] = stored
+	return nil
+}
+
+func (r *products) Delete(ctx context.Context, product *models.Product) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delete(r.db.products, product.ID)
+	for id, variant := range r.db.variants {
+		if variant.ProductID == product.ID {
+			delete(r.db.variants, id)
+		}
+	}
+	return nil
+}
+
+func (r *products) CountVariants(ctx context.Context, productID uint) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	return int64(len(r.db.productVariants(productID))), nil
+}
+
+func (r *products) Variant(ctx context.Context, productID, variantID uint) (*models.ProductVariant, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	variant, ok := r.db.variants[variantID]
+	if !ok || variant.ProductID != productID {
+		return nil, repository.ErrNotFound
+	}
+	return &variant, nil
+}
+
+func (r *products) CategoryExists(ctx context.Context, id uint) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	_, ok := r.db.categories[id]
+	return ok, nil
+}
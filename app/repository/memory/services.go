@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/repository"
+)
+
+type services struct {
+	db *DB
+}
+
+func (r *services) Get(ctx context.Context, id uint) (*models.Service, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	service, ok := r.db.services[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &service, nil
+}
+
+func (r *services) ListByStore(ctx context.Context, storeID uint) ([]models.Service, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	list := sorted(r.db.services, func(s models.Service) uint { return s.ID },
+		func(s models.Service) bool { return s.StoreID == storeID })
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+func (r *services) Create(ctx context.Context, service *models.Service) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	service.ID = r.db.id(0)
+	r.db.services[service.ID] = *service
+	return nil
+}
+
+// Update applies the non-empty changes, as GORM does when updating from a struct
+func (r *services) Update(ctx context.Context, service *models.Service, changes models.UpdateServiceRequest) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.services[service.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if changes.Name != "" {
+		stored.Name = changes.Name
+	}
+	if changes.Description != "" {
+		stored.Description = changes.Description
+	}
+	if changes.Rate != 0 {
+		stored.Rate = changes.Rate
+	}
+	if changes.Currency != "" {
+		stored.Currency = changes.Currency
+	}
+	if changes.ImageURL != "" {
+		stored.ImageURL = changes.ImageURL
+	}
+	r.db.services[service.ID] = stored
+	return nil
+}
+
+func (r *services) Delete(ctx context.Context, service *models.Service) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delete(r.db.services, service.ID)
+	return nil
+}
+
+func (r *services) Booked(ctx context.Context, userID uint, serviceIDs []uint) ([]uint, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	booked := []uint{}
+	for _, booking := range r.db.bookings {
+		if booking.UserID != userID || !slices.Contains(serviceIDs, booking.ServiceID) || slices.Contains(booked, booking.ServiceID) {
+			continue
+		}
+		if booking.Status == models.BookingStatusConfirmed || booking.Status == models.BookingStatusCompleted {
+			booked = append(booked, booking.ServiceID)
+		}
+	}
+	slices.Sort(booked)
+	return booked, nil
+}
@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/repository"
+)
+
+type stores struct {
+	db *DB
+}
+
+func (r *stores) Get(ctx context.Context, id uint) (*models.Store, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	store, ok := r.db.stores[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	return &store, nil
+}
+
+func (r *stores) GetDetails(ctx context.Context, id uint) (*models.Store, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	store, ok := r.db.stores[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	store.Products = sorted(r.db.products, func(p models.Product) uint { return p.ID },
+		func(p models.Product) bool { return p.StoreID == id })
+	store.Services = sorted(r.db.services, func(s models.Service) uint { return s.ID },
+		func(s models.Service) bool { return s.StoreID == id })
+	return &store, nil
+}
+
+func (r *stores) ListByVendor(ctx context.Context, vendorID uint) ([]models.Store, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	return sorted(r.db.stores, func(s models.Store) uint { return s.ID },
+		func(s models.Store) bool { return s.VendorID == vendorID }), nil
+}
+
+func (r *stores) URLTaken(ctx context.Context, url string, storeID uint) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, store := range r.db.stores {
+		if store.StoreUrl == url && store.ID != storeID {
+			return true, nil
+		}
+	}
+	if redirectsTo, ok := r.db.redirects[url]; ok && redirectsTo != storeID {
+		return true, nil
+	}
+	return false, nil
+}
+
+func (r *stores) Create(ctx context.Context, store *models.Store, owner *models.StoreMember) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	store.ID = r.db.id(0)
+	r.db.stores[store.ID] = *store
+	owner.StoreID = store.ID
+	owner.ID = r.db.addMember(*owner)
+	return nil
+}
+
+func (r *stores) Update(ctx context.Context, store *models.Store, previousURL string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.stores[store.ID]; !ok {
+		return repository.ErrNotFound
+	}
+	r.db.stores[store.ID] = *store
+	if store.StoreUrl != previousURL {
+		delete(r.db.redirects, store.StoreUrl)
+		if previousURL != "" {
+			r.db.redirects[previousURL] = store.ID
+		}
+	}
+	return nil
+}
+
+func (r *stores) Delete(ctx context.Context, store *models.Store) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for id, product := range r.db.products {
+		if product.StoreID == store.ID {
+			delete(r.db.products, id)
+		}
+	}
+	delete(r.db.stores, store.ID)
+
+	for _, other := range r.db.stores {
+		if other.VendorID == store.VendorID {
+			return nil
+		}
+	}
+	if vendor, ok := r.db.vendors[store.VendorID]; ok {
+		vendor.IsActive = false
+		r.db.vendors[vendor.ID] = vendor
+	}
+	return nil
+}
+
+func (r *stores) ActiveMember(ctx context.Context, storeID, userID uint) (*models.StoreMember, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, member := range r.db.members {
+		if member.StoreID == storeID && member.UserID != nil && *member.UserID == userID &&
+			member.Status == models.MemberStatusActive {
+			return &member, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/repository"
+)
+
+type users struct {
+	db *DB
+}
+
+func (r *users) Get(ctx context.Context, id uint) (*models.User, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	user, ok := r.db.users[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	for _, vendor := range r.db.vendors {
+		if vendor.UserID == id {
+			vendor := vendor
+			user.Vendor = &vendor
+		}
+	}
+	return &user, nil
+}
+
+func (r *users) UpdateProfile(ctx context.Context, user *models.User, shippingAddress string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.users[user.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	stored.Name = user.Name
+	stored.PhoneNumber = user.PhoneNumber
+	r.db.users[user.ID] = stored
+	if shippingAddress != "" {
+		r.db.addresses[user.ID] = shippingAddress
+	}
+	return nil
+}
+
+func (r *users) VendorByUser(ctx context.Context, userID uint) (*models.Vendor, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, vendor := range r.db.vendors {
+		if vendor.UserID == userID {
+			return &vendor, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/theHoracle/whatstore-api/app/inventory"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/orderflow"
+	"gorm.io/gorm"
+)
+
+type gormOrders struct {
+	db *gorm.DB
+}
+
+// NewOrders returns an OrderRepository backed by db
+func NewOrders(db *gorm.DB) OrderRepository {
+	return &gormOrders{db: db}
+}
+
+func (r *gormOrders) Get(ctx context.Context, id uint) (*models.Order, error) {
+	var order models.Order
+	if err := r.db.WithContext(ctx).First(&order, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &order, nil
+}
+
+func (r *gormOrders) GetWithItems(ctx context.Context, id uint) (*models.Order, error) {
+	var order models.Order
+	if err := r.db.WithContext(ctx).Preload("Items.Product").Preload("Items.Variant").First(&order, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &order, nil
+}
+
+func (r *gormOrders) ListByUser(ctx context.Context, userID uint, page, perPage int) ([]models.Order, int64, error) {
+	db := r.db.WithContext(ctx)
+
+	var total int64
+	if err := db.Model(&models.Order{}).Where("user_id = ?", userID).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var orders []models.Order
+	if err := db.Where("user_id = ?", userID).Offset((page - 1) * perPage).Limit(perPage).Find(&orders).Error; err != nil {
+		return nil, 0, err
+	}
+	return orders, total, nil
+}
+
+func (r *gormOrders) ListByStore(ctx context.Context, storeID uint) ([]models.Order, error) {
+	var orders []models.Order
+	if err := r.db.WithContext(ctx).Where("store_id = ?", storeID).Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+func (r *gormOrders) Create(ctx context.Context, order *models.Order, actorID *uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		items := order.Items
+		if err := tx.Omit("Items").Create(order).Error; err != nil {
+			return err
+		}
+
+		// Items come sorted by product and variant, so concurrent orders
+		// lock stock rows in the same order and cannot deadlock
+		var short []*inventory.InsufficientStockError
+		for i := range items {
+			var product models.Product
+			if err := tx.First(&product, items[i].ProductID).Error; err != nil {
+				return notFound(err)
+			}
+			var variant *models.ProductVariant
+			if items[i].VariantID != nil {
+				variant = &models.ProductVariant{}
+				if err := tx.First(variant, *items[i].VariantID).Error; err != nil {
+					return notFound(err)
+				}
+			}
+
+			if err := inventory.Reserve(tx, &product, variant, items[i].Quantity, order.ID, actorID); err != nil {
+				var stockErr *inventory.InsufficientStockError
+				if errors.As(err, &stockErr) {
+					short = append(short, stockErr)
+					continue
+				}
+				return err
+			}
+			items[i].OrderID = order.ID
+		}
+		if len(short) > 0 {
+			return &StockError{Items: short}
+		}
+
+		if err := tx.Create(&items).Error; err != nil {
+			return err
+		}
+		order.Items = items
+		return orderflow.RecordCreated(tx, order, actorID)
+	})
+}
+
+func (r *gormOrders) Transition(ctx context.Context, order *models.Order, to models.OrderStatus, actor models.OrderActor, actorID *uint, note string) error {
+	return orderflow.Transition(r.db.WithContext(ctx), order, to, actor, actorID, note)
+}
+
+func (r *gormOrders) History(ctx context.Context, orderID uint) ([]models.OrderStatusHistory, error) {
+	var history []models.OrderStatusHistory
+	if err := r.db.WithContext(ctx).Where("order_id = ?", orderID).Order("created_at ASC, id ASC").Find(&history).Error; err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func (r *gormOrders) Purchased(ctx context.Context, userID uint, productIDs []uint) ([]uint, error) {
+	purchased := []uint{}
+	if len(productIDs) == 0 {
+		return purchased, nil
+	}
+	err := r.db.WithContext(ctx).Model(&models.OrderItem{}).
+		Distinct("order_items.product_id").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("orders.user_id = ? AND orders.status IN ? AND order_items.product_id IN ?", userID, models.PurchasedOrderStatuses, productIDs).
+		Order("order_items.product_id").
+		Pluck("order_items.product_id", &purchased).Error
+	return purchased, err
+}
+
+func (r *gormOrders) CountByUserInStore(ctx context.Context, userID, storeID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Order{}).Where("user_id = ? AND store_id = ?", userID, storeID).Count(&count).Error
+	return count, err
+}
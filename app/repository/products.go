@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/theHoracle/whatstore-api/app/inventory"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WithVariants preloads the options and variants shown with a product
+func WithVariants(db *gorm.DB) *gorm.DB {
+	return db.
+		Preload("Options", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("Variants", func(db *gorm.DB) *gorm.DB { return db.Order("id") })
+}
+
+type gormProducts struct {
+	db *gorm.DB
+}
+
+// NewProducts returns a ProductRepository backed by db
+func NewProducts(db *gorm.DB) ProductRepository {
+	return &gormProducts{db: db}
+}
+
+func (r *gormProducts) Get(ctx context.Context, id uint) (*models.Product, error) {
+	var product models.Product
+	if err := WithVariants(r.db.WithContext(ctx).Preload("Category")).First(&product, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &product, nil
+}
+
+func (r *gormProducts) Create(ctx context.Context, product *models.Product, actorID *uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(product).Error; err != nil {
+			return err
+		}
+		if product.Stock == 0 {
+			return nil
+		}
+		return inventory.RecordAdjustment(tx, product, product.Stock, models.InventoryReasonInitialStock, actorID, "")
+	})
+}
+
+// Update saves changes to a product. A new stock is applied as a change from
+// the stock the product was loaded with, on the locked row, so units reserved
+// by orders in the meantime stay reserved.
+func (r *gormProducts) Update(ctx context.Context, product *models.Product, changes models.UpdateProductRequest, actorID *uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(product).Omit("stock").Updates(changes).Error; err != nil {
+			return err
+		}
+		if changes.Stock == nil || *changes.Stock == product.Stock {
+			return nil
+		}
+
+		change := *changes.Stock - product.Stock
+		var current int
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&models.Product{}).Select("stock").Where("id = ?", product.ID).Scan(&current).Error; err != nil {
+			return err
+		}
+		if current+change < 0 {
+			return &StockError{Items: []*inventory.InsufficientStockError{{
+				ProductID: product.ID,
+				Name:      product.Name,
+				Requested: -change,
+				Available: current,
+			}}}
+		}
+		if err := tx.Model(&models.Product{}).Where("id = ?", product.ID).UpdateColumn("stock", current+change).Error; err != nil {
+			return err
+		}
+		product.Stock = current + change
+		return inventory.RecordAdjustment(tx, product, change, models.InventoryReasonAdjustment, actorID, "Stock updated by vendor")
+	})
+}
+
+func (r *gormProducts) Delete(ctx context.Context, product *models.Product) error {
+	return r.db.WithContext(ctx).Delete(product).Error
+}
+
+func (r *gormProducts) CountVariants(ctx context.Context, productID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.ProductVariant{}).Where("product_id = ?", productID).Count(&count).Error
+	return count, err
+}
+
+func (r *gormProducts) Variant(ctx context.Context, productID, variantID uint) (*models.ProductVariant, error) {
+	var variant models.ProductVariant
+	if err := r.db.WithContext(ctx).Where("id = ? AND product_id = ?", variantID, productID).First(&variant).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &variant, nil
+}
+
+func (r *gormProducts) CategoryExists(ctx context.Context, id uint) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Category{}).Where("id = ?", id).Count(&count).Error
+	return count > 0, err
+}
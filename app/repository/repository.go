@@ -0,0 +1,102 @@
+// Package repository is where controllers and services get and save data.
+// Each repository is an interface with a GORM implementation here and an
+// in-memory one in the memory package for tests.
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/theHoracle/whatstore-api/app/inventory"
+	"github.com/theHoracle/whatstore-api/app/models"
+)
+
+// ErrNotFound is returned when the record asked for does not exist
+var ErrNotFound = errors.New("record not found")
+
+// StockError is returned when an order asks for more than is in stock. It
+// lists every item short of stock rather than only the first.
+type StockError struct {
+	Items []*inventory.InsufficientStockError
+}
+
+func (e *StockError) Error() string {
+	return "insufficient stock"
+}
+
+// UserRepository stores users and their vendor profiles
+type UserRepository interface {
+	Get(ctx context.Context, id uint) (*models.User, error)
+	// UpdateProfile saves the user's name and phone number, and their
+	// shipping address in their details
+	UpdateProfile(ctx context.Context, user *models.User, shippingAddress string) error
+	VendorByUser(ctx context.Context, userID uint) (*models.Vendor, error)
+}
+
+// StoreRepository stores stores and who may manage them
+type StoreRepository interface {
+	Get(ctx context.Context, id uint) (*models.Store, error)
+	// GetDetails loads a store with its products, services and catalog sync
+	GetDetails(ctx context.Context, id uint) (*models.Store, error)
+	ListByVendor(ctx context.Context, vendorID uint) ([]models.Store, error)
+	// URLTaken reports whether a store other than storeID uses url, now or
+	// as a previous URL that still redirects to it
+	URLTaken(ctx context.Context, url string, storeID uint) (bool, error)
+	// Create saves a new store together with its owner's membership
+	Create(ctx context.Context, store *models.Store, owner *models.StoreMember) error
+	// Update saves a store, keeping previousURL redirecting to it when the
+	// store's URL changed
+	Update(ctx context.Context, store *models.Store, previousURL string) error
+	// Delete removes a store and its products, and deactivates the vendor
+	// when it was their last store
+	Delete(ctx context.Context, store *models.Store) error
+	// ActiveMember returns the user's active membership of the store
+	ActiveMember(ctx context.Context, storeID, userID uint) (*models.StoreMember, error)
+}
+
+// ProductRepository stores products and their variants
+type ProductRepository interface {
+	// Get loads a product with its category, options and variants
+	Get(ctx context.Context, id uint) (*models.Product, error)
+	// Create saves a new product and records its initial stock
+	Create(ctx context.Context, product *models.Product, actorID *uint) error
+	// Update applies changes to a product, recording the stock adjustment
+	// when its stock changed
+	Update(ctx context.Context, product *models.Product, changes models.UpdateProductRequest, actorID *uint) error
+	Delete(ctx context.Context, product *models.Product) error
+	CountVariants(ctx context.Context, productID uint) (int64, error)
+	Variant(ctx context.Context, productID, variantID uint) (*models.ProductVariant, error)
+	CategoryExists(ctx context.Context, id uint) (bool, error)
+}
+
+// ServiceRepository stores the services stores offer
+type ServiceRepository interface {
+	Get(ctx context.Context, id uint) (*models.Service, error)
+	ListByStore(ctx context.Context, storeID uint) ([]models.Service, error)
+	Create(ctx context.Context, service *models.Service) error
+	Update(ctx context.Context, service *models.Service, changes models.UpdateServiceRequest) error
+	Delete(ctx context.Context, service *models.Service) error
+	// Booked returns which of serviceIDs the user has had a booking of
+	// confirmed, whether or not it has taken place yet
+	Booked(ctx context.Context, userID uint, serviceIDs []uint) ([]uint, error)
+}
+
+// OrderRepository stores orders and their status history
+type OrderRepository interface {
+	Get(ctx context.Context, id uint) (*models.Order, error)
+	// GetWithItems loads an order with its items' products and variants
+	GetWithItems(ctx context.Context, id uint) (*models.Order, error)
+	ListByUser(ctx context.Context, userID uint, page, perPage int) ([]models.Order, int64, error)
+	ListByStore(ctx context.Context, storeID uint) ([]models.Order, error)
+	// Create saves a new pending order with its items, reserving stock for
+	// every item and starting its status history. It returns a *StockError
+	// when items are short of stock.
+	Create(ctx context.Context, order *models.Order, actorID *uint) error
+	// Transition moves an order to a new status and records the change
+	Transition(ctx context.Context, order *models.Order, to models.OrderStatus, actor models.OrderActor, actorID *uint, note string) error
+	History(ctx context.Context, orderID uint) ([]models.OrderStatusHistory, error)
+	// Purchased returns which of productIDs the user has bought, in an order
+	// with one of models.PurchasedOrderStatuses
+	Purchased(ctx context.Context, userID uint, productIDs []uint) ([]uint, error)
+	CountByUserInStore(ctx context.Context, userID, storeID uint) (int64, error)
+}
@@ -0,0 +1,224 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/theHoracle/whatstore-api/app/apitest"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/repository"
+	"gorm.io/gorm"
+)
+
+type fixture struct {
+	owner, buyer *models.User
+	vendor       *models.Vendor
+	store        *models.Store
+	product      *models.Product
+}
+
+func seed(t *testing.T, db *gorm.DB) *fixture {
+	t.Helper()
+	ctx := context.Background()
+	f := &fixture{
+		owner: &models.User{ClerkID: "user_owner", Email: "owner@example.com", Username: "owner", Role: models.RoleVendor},
+		buyer: &models.User{ClerkID: "user_buyer", Email: "buyer@example.com", Username: "buyer", Role: models.RoleBuyer},
+	}
+	must(t, db.Create(f.owner).Error)
+	must(t, db.Create(f.buyer).Error)
+
+	f.vendor = &models.Vendor{UserID: f.owner.ID, IsActive: true}
+	must(t, db.Create(f.vendor).Error)
+
+	f.store = &models.Store{VendorID: f.vendor.ID, Name: "Owner's", StoreUrl: "owners", Timezone: "Africa/Lagos"}
+	must(t, repository.NewStores(db).Create(ctx, f.store, &models.StoreMember{
+		Email:  f.owner.Email,
+		UserID: &f.owner.ID,
+		Role:   models.StoreRoleOwner,
+		Status: models.MemberStatusActive,
+	}))
+
+	f.product = &models.Product{StoreID: f.store.ID, Name: "Sneakers", Price: 100, Stock: 5}
+	must(t, repository.NewProducts(db).Create(ctx, f.product, &f.owner.ID))
+	return f
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStores(t *testing.T) {
+	db := apitest.Database(t)
+	f := seed(t, db)
+	ctx := context.Background()
+	stores := repository.NewStores(db)
+
+	member, err := stores.ActiveMember(ctx, f.store.ID, f.owner.ID)
+	if err != nil || member.Role != models.StoreRoleOwner {
+		t.Fatalf("owner membership %+v, %v", member, err)
+	}
+	if _, err := stores.ActiveMember(ctx, f.store.ID, f.buyer.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("buyer membership: %v", err)
+	}
+
+	// A changed URL keeps redirecting, so only this store can use it
+	f.store.StoreUrl = "owners-new"
+	must(t, stores.Update(ctx, f.store, "owners"))
+	for _, tc := range []struct {
+		url     string
+		storeID uint
+		taken   bool
+	}{
+		{"owners", 0, true},
+		{"owners", f.store.ID, false},
+		{"owners-new", 0, true},
+		{"free", 0, false},
+	} {
+		taken, err := stores.URLTaken(ctx, tc.url, tc.storeID)
+		if err != nil || taken != tc.taken {
+			t.Errorf("URLTaken(%s, %d) = %v, %v", tc.url, tc.storeID, taken, err)
+		}
+	}
+
+	details, err := stores.GetDetails(ctx, f.store.ID)
+	if err != nil || len(details.Products) != 1 {
+		t.Fatalf("details %+v, %v", details, err)
+	}
+
+	must(t, stores.Delete(ctx, f.store))
+	if _, err := stores.Get(ctx, f.store.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("deleted store: %v", err)
+	}
+	vendor, err := repository.NewUsers(db).VendorByUser(ctx, f.owner.ID)
+	if err != nil || vendor.IsActive {
+		t.Errorf("vendor after last store %+v, %v", vendor, err)
+	}
+}
+
+func TestProducts(t *testing.T) {
+	db := apitest.Database(t)
+	f := seed(t, db)
+	ctx := context.Background()
+	products := repository.NewProducts(db)
+
+	stock := 8
+	must(t, products.Update(ctx, f.product, models.UpdateProductRequest{Name: "Runners", Stock: &stock}, &f.owner.ID))
+	product, err := products.Get(ctx, f.product.ID)
+	if err != nil || product.Name != "Runners" || product.Stock != 8 {
+		t.Fatalf("updated %+v, %v", product, err)
+	}
+
+	// An order reserving 3 after the product was loaded keeps its units when
+	// the vendor restocks from 8 to 10
+	reserve := func(quantity int) {
+		must(t, db.Model(&models.Product{}).Where("id = ?", product.ID).UpdateColumn("stock", gorm.Expr("stock - ?", quantity)).Error)
+	}
+	loaded := *product
+	reserve(3)
+	stock = 10
+	must(t, products.Update(ctx, &loaded, models.UpdateProductRequest{Stock: &stock}, &f.owner.ID))
+	if loaded.Stock != 7 {
+		t.Errorf("stock after restock = %d, want 7", loaded.Stock)
+	}
+
+	// Reserved units cannot be taken off the shelf
+	reserve(2)
+	stock = 0
+	var stockErr *repository.StockError
+	err = products.Update(ctx, &loaded, models.UpdateProductRequest{Stock: &stock}, &f.owner.ID)
+	if !errors.As(err, &stockErr) || stockErr.Items[0].Available != 5 {
+		t.Errorf("clearing reserved stock: %v", err)
+	}
+
+	// Initial stock and the adjustment are both in the ledger
+	var ledger []models.InventoryLedgerEntry
+	must(t, db.Where("product_id = ?", f.product.ID).Order("id").Find(&ledger).Error)
+	if len(ledger) != 3 || ledger[0].Change != 5 || ledger[1].Change != 3 || ledger[2].Change != 2 {
+		t.Errorf("ledger %+v", ledger)
+	}
+
+	exists, err := products.CategoryExists(ctx, 999)
+	if err != nil || exists {
+		t.Errorf("CategoryExists = %v, %v", exists, err)
+	}
+}
+
+func TestOrders(t *testing.T) {
+	db := apitest.Database(t)
+	f := seed(t, db)
+	ctx := context.Background()
+	orders := repository.NewOrders(db)
+
+	order := &models.Order{
+		UserID:      f.buyer.ID,
+		StoreID:     f.store.ID,
+		Status:      models.OrderStatusPending,
+		TotalAmount: 300,
+		Items:       []models.OrderItem{{ProductID: f.product.ID, Quantity: 3, Price: 100}},
+	}
+	must(t, orders.Create(ctx, order, &f.buyer.ID))
+
+	loaded, err := orders.GetWithItems(ctx, order.ID)
+	if err != nil || len(loaded.Items) != 1 || loaded.Items[0].Product.Stock != 2 {
+		t.Fatalf("order %+v, %v", loaded, err)
+	}
+
+	// Nothing is created when an item is short of stock
+	short := &models.Order{
+		UserID:  f.buyer.ID,
+		StoreID: f.store.ID,
+		Status:  models.OrderStatusPending,
+		Items:   []models.OrderItem{{ProductID: f.product.ID, Quantity: 3, Price: 100}},
+	}
+	var stockErr *repository.StockError
+	if err := orders.Create(ctx, short, &f.buyer.ID); !errors.As(err, &stockErr) || stockErr.Items[0].Available != 2 {
+		t.Fatalf("short order: %v", err)
+	}
+	list, total, err := orders.ListByUser(ctx, f.buyer.ID, 1, 10)
+	if err != nil || total != 1 || len(list) != 1 {
+		t.Errorf("buyer orders %d %+v, %v", total, list, err)
+	}
+
+	must(t, orders.Transition(ctx, order, models.OrderStatusConfirmed, models.OrderActorVendor, &f.owner.ID, ""))
+	history, err := orders.History(ctx, order.ID)
+	if err != nil || len(history) != 2 || history[1].ToStatus != models.OrderStatusConfirmed {
+		t.Errorf("history %+v, %v", history, err)
+	}
+
+	purchased, err := orders.Purchased(ctx, f.buyer.ID, []uint{f.product.ID, f.product.ID + 1})
+	if err != nil || len(purchased) != 1 || purchased[0] != f.product.ID {
+		t.Errorf("purchased %v, %v", purchased, err)
+	}
+	if purchased, err := orders.Purchased(ctx, f.owner.ID, []uint{f.product.ID}); err != nil || len(purchased) != 0 {
+		t.Errorf("owner purchased %v, %v", purchased, err)
+	}
+	if count, err := orders.CountByUserInStore(ctx, f.buyer.ID, f.store.ID); err != nil || count != 1 {
+		t.Errorf("buyer orders in store %d, %v", count, err)
+	}
+}
+
+func TestUsers(t *testing.T) {
+	db := apitest.Database(t)
+	f := seed(t, db)
+	ctx := context.Background()
+	users := repository.NewUsers(db)
+
+	f.buyer.Name = "Bola"
+	f.buyer.PhoneNumber = "+2348012345678"
+	must(t, users.UpdateProfile(ctx, f.buyer, "1 Marina"))
+	must(t, users.UpdateProfile(ctx, f.buyer, "2 Marina"))
+
+	user, err := users.Get(ctx, f.buyer.ID)
+	if err != nil || user.Name != "Bola" || user.PhoneNumber != "+2348012345678" {
+		t.Fatalf("user %+v, %v", user, err)
+	}
+	var details models.UserDetails
+	must(t, db.Where("user_id = ?", f.buyer.ID).First(&details).Error)
+	if details.ShippingAddress != "2 Marina" {
+		t.Errorf("shipping address %q", details.ShippingAddress)
+	}
+}
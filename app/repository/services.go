@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"gorm.io/gorm"
+)
+
+type gormServices struct {
+	db *gorm.DB
+}
+
+// NewServices returns a ServiceRepository backed by db
+func NewServices(db *gorm.DB) ServiceRepository {
+	return &gormServices{db: db}
+}
+
+func (r *gormServices) Get(ctx context.Context, id uint) (*models.Service, error) {
+	var service models.Service
+	if err := r.db.WithContext(ctx).First(&service, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &service, nil
+}
+
+func (r *gormServices) ListByStore(ctx context.Context, storeID uint) ([]models.Service, error) {
+	var services []models.Service
+	if err := r.db.WithContext(ctx).Where("store_id = ?", storeID).Order("name").Find(&services).Error; err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+func (r *gormServices) Create(ctx context.Context, service *models.Service) error {
+	return r.db.WithContext(ctx).Create(service).Error
+}
+
+func (r *gormServices) Update(ctx context.Context, service *models.Service, changes models.UpdateServiceRequest) error {
+	return r.db.WithContext(ctx).Model(service).Updates(changes).Error
+}
+
+func (r *gormServices) Delete(ctx context.Context, service *models.Service) error {
+	return r.db.WithContext(ctx).Delete(service).Error
+}
+
+func (r *gormServices) Booked(ctx context.Context, userID uint, serviceIDs []uint) ([]uint, error) {
+	booked := []uint{}
+	if len(serviceIDs) == 0 {
+		return booked, nil
+	}
+	err := r.db.WithContext(ctx).Model(&models.Booking{}).
+		Distinct("service_id").
+		Where("user_id = ? AND service_id IN ? AND status IN ?", userID, serviceIDs,
+			[]models.BookingStatus{models.BookingStatusConfirmed, models.BookingStatusCompleted}).
+		Order("service_id").
+		Pluck("service_id", &booked).Error
+	return booked, err
+}
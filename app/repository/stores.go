@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"gorm.io/gorm"
+)
+
+type gormStores struct {
+	db *gorm.DB
+}
+
+// NewStores returns a StoreRepository backed by db
+func NewStores(db *gorm.DB) StoreRepository {
+	return &gormStores{db: db}
+}
+
+func (r *gormStores) Get(ctx context.Context, id uint) (*models.Store, error) {
+	var store models.Store
+	if err := r.db.WithContext(ctx).First(&store, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &store, nil
+}
+
+func (r *gormStores) GetDetails(ctx context.Context, id uint) (*models.Store, error) {
+	var store models.Store
+	if err := r.db.WithContext(ctx).Preload("Products").Preload("Services").Preload("CatalogSync").
+		First(&store, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &store, nil
+}
+
+func (r *gormStores) ListByVendor(ctx context.Context, vendorID uint) ([]models.Store, error) {
+	var stores []models.Store
+	if err := r.db.WithContext(ctx).Where("vendor_id = ?", vendorID).Find(&stores).Error; err != nil {
+		return nil, err
+	}
+	return stores, nil
+}
+
+func (r *gormStores) URLTaken(ctx context.Context, url string, storeID uint) (bool, error) {
+	var stores, redirects int64
+	db := r.db.WithContext(ctx)
+	if err := db.Model(&models.Store{}).Where("store_url = ? AND id <> ?", url, storeID).Count(&stores).Error; err != nil {
+		return false, err
+	}
+	if err := db.Model(&models.StoreURLRedirect{}).Where("store_url = ? AND store_id <> ?", url, storeID).Count(&redirects).Error; err != nil {
+		return false, err
+	}
+	return stores > 0 || redirects > 0, nil
+}
+
+func (r *gormStores) Create(ctx context.Context, store *models.Store, owner *models.StoreMember) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(store).Error; err != nil {
+			return err
+		}
+		owner.StoreID = store.ID
+		return tx.Create(owner).Error
+	})
+}
+
+func (r *gormStores) Update(ctx context.Context, store *models.Store, previousURL string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(store).Error; err != nil {
+			return err
+		}
+		if store.StoreUrl == previousURL {
+			return nil
+		}
+
+		// A redirect for the store's current URL is dropped, for when a
+		// vendor changes back
+		if err := tx.Where("store_url = ?", store.StoreUrl).Delete(&models.StoreURLRedirect{}).Error; err != nil {
+			return err
+		}
+		if previousURL == "" {
+			return nil
+		}
+		return tx.Create(&models.StoreURLRedirect{StoreURL: previousURL, StoreID: store.ID}).Error
+	})
+}
+
+func (r *gormStores) Delete(ctx context.Context, store *models.Store) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("store_id = ?", store.ID).Delete(&models.Product{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(store).Error; err != nil {
+			return err
+		}
+
+		var remaining int64
+		if err := tx.Model(&models.Store{}).Where("vendor_id = ?", store.VendorID).Count(&remaining).Error; err != nil {
+			return err
+		}
+		if remaining > 0 {
+			return nil
+		}
+		return tx.Model(&models.Vendor{}).Where("id = ?", store.VendorID).Update("is_active", false).Error
+	})
+}
+
+func (r *gormStores) ActiveMember(ctx context.Context, storeID, userID uint) (*models.StoreMember, error) {
+	var member models.StoreMember
+	if err := r.db.WithContext(ctx).
+		Where("store_id = ? AND user_id = ? AND status = ?", storeID, userID, models.MemberStatusActive).
+		First(&member).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &member, nil
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormUsers struct {
+	db *gorm.DB
+}
+
+// NewUsers returns a UserRepository backed by db
+func NewUsers(db *gorm.DB) UserRepository {
+	return &gormUsers{db: db}
+}
+
+func (r *gormUsers) Get(ctx context.Context, id uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Preload("Vendor").Preload("Vendor.Stores").First(&user, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUsers) UpdateProfile(ctx context.Context, user *models.User, shippingAddress string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Select("name", "phone_number").Updates(user).Error; err != nil {
+			return err
+		}
+		if shippingAddress == "" {
+			return nil
+		}
+		details := models.UserDetails{UserID: user.ID, ShippingAddress: shippingAddress}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"shipping_address", "updated_at"}),
+		}).Create(&details).Error
+	})
+}
+
+func (r *gormUsers) VendorByUser(ctx context.Context, userID uint) (*models.Vendor, error) {
+	var vendor models.Vendor
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&vendor).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &vendor, nil
+}
+
+// notFound turns GORM's missing record error into ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
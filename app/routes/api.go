@@ -0,0 +1,34 @@
+package routes
+
+import (
+	"regexp"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/middleware"
+	"gorm.io/gorm"
+)
+
+// uploadPath matches the image upload routes, the only ones that take bodies
+// over fiber's default limit
+var uploadPath = regexp.MustCompile(`^/api/v1/stores/[^/]+/(media|logo)$`)
+
+// API registers the middleware and routes of the API on app, serving
+// requests with h and authenticating them against db
+func API(app *fiber.App, db *gorm.DB, h *Handlers) {
+	// Larger bodies reach the app as a stream when it is configured to
+	// stream them, which only image uploads may send
+	limitBody := middleware.LimitBody(fiber.DefaultBodyLimit)
+	app.Use(func(c *fiber.Ctx) error {
+		if uploadPath.MatchString(c.Path()) {
+			return c.Next()
+		}
+		return limitBody(c)
+	})
+
+	// Resolve the store of requests made to vendors' custom domains
+	app.Use(middleware.StoreDomainMiddleware(db))
+
+	PublicRoutes(app, db, h)
+	CartRoutes(app, db, h)
+	PrivateRoutes(app, db, h)
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+func BookingRoutes(app fiber.Router, h *Handlers) {
+	app.Post("/services/:id/bookings", h.Bookings.CreateBooking)
+
+	bookings := app.Group("/bookings")
+
+	bookings.Get("/", h.Bookings.GetUserBookings)
+	bookings.Get("/:id", h.Bookings.GetBooking)
+	bookings.Put("/:id/status", h.Bookings.UpdateBookingStatus)
+	bookings.Post("/:id/pay", h.Bookings.PayBooking)
+}
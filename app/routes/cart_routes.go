@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/theHoracle/whatstore-api/app/middleware"
+	"gorm.io/gorm"
+)
+
+// CartRoutes registers the cart endpoints, which work for guests as well as
+// signed-in buyers. They must be registered before PrivateRoutes.
+func CartRoutes(app *fiber.App, db *gorm.DB, h *Handlers) {
+	cart := app.Group("/api/v1/cart", middleware.OptionalAuthMiddleware(db))
+
+	cart.Get("/", h.Cart.GetCart)
+	cart.Post("/", h.Cart.AddCartItem)
+	cart.Put("/", h.Cart.UpdateCartItem)
+	cart.Delete("/", h.Cart.ClearCart)
+	cart.Delete("/items/:productId", h.Cart.RemoveCartItem)
+	cart.Post("/checkout", h.Cart.CheckoutCart)
+	cart.Get("/whatsapp", h.Whatsapp.GetCartWhatsappLinks)
+	cart.Get("/whatsapp/qr", h.Whatsapp.GetCartWhatsappQR)
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+func CheckoutRoutes(app fiber.Router, h *Handlers) {
+	checkouts := app.Group("/checkouts")
+
+	checkouts.Post("/", h.Checkouts.CreateCheckout)
+	checkouts.Get("/", h.Checkouts.GetUserCheckouts)
+	checkouts.Get("/:id", h.Checkouts.GetCheckout)
+	checkouts.Post("/:id/pay", h.Checkouts.PayCheckout)
+}
@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"github.com/theHoracle/whatstore-api/app/controllers"
+	"github.com/theHoracle/whatstore-api/app/repository"
+	"github.com/theHoracle/whatstore-api/app/services"
+	"gorm.io/gorm"
+)
+
+// Handlers are the API's endpoints, constructed with their dependencies
+type Handlers struct {
+	Stores   *controllers.StoreHandler
+	Products *controllers.ProductHandler
+	Services *controllers.ServiceHandler
+	Orders   *controllers.OrderHandler
+	Users    *controllers.UserHandler
+
+	// Endpoints that still query the database directly
+	Admin       *controllers.AdminHandler
+	Bookings    *controllers.BookingHandler
+	Cart        *controllers.CartHandler
+	Catalogs    *controllers.CatalogHandler
+	Categories  *controllers.CategoryHandler
+	Checkouts   *controllers.CheckoutHandler
+	Domains     *controllers.DomainHandler
+	Inventory   *controllers.InventoryHandler
+	Media       *controllers.MediaHandler
+	Members     *controllers.MemberHandler
+	Payments    *controllers.PaymentHandler
+	Search      *controllers.SearchHandler
+	Storefronts *controllers.StorefrontHandler
+	Variants    *controllers.VariantHandler
+	Vendors     *controllers.VendorHandler
+	Whatsapp    *controllers.WhatsappHandler
+}
+
+// NewHandlers builds the handlers with repositories over db
+func NewHandlers(db *gorm.DB) *Handlers {
+	users := repository.NewUsers(db)
+	stores := repository.NewStores(db)
+	products := repository.NewProducts(db)
+	offered := repository.NewServices(db)
+	orders := repository.NewOrders(db)
+	viewers := services.NewViewers(stores, orders, offered)
+
+	return &Handlers{
+		Stores:   controllers.NewStoreHandler(services.NewStores(stores, users)),
+		Products: controllers.NewProductHandler(services.NewProducts(products, stores), viewers, db),
+		Services: controllers.NewServiceHandler(services.NewServices(offered, stores), viewers, db),
+		Orders:   controllers.NewOrderHandler(services.NewOrders(orders, products, stores)),
+		Users:    controllers.NewUserHandler(services.NewUsers(users)),
+
+		Admin:       controllers.NewAdminHandler(db),
+		Bookings:    controllers.NewBookingHandler(db),
+		Cart:        controllers.NewCartHandler(db),
+		Catalogs:    controllers.NewCatalogHandler(db),
+		Categories:  controllers.NewCategoryHandler(db),
+		Checkouts:   controllers.NewCheckoutHandler(db),
+		Domains:     controllers.NewDomainHandler(db),
+		Inventory:   controllers.NewInventoryHandler(db),
+		Media:       controllers.NewMediaHandler(db),
+		Members:     controllers.NewMemberHandler(db),
+		Payments:    controllers.NewPaymentHandler(db),
+		Search:      controllers.NewSearchHandler(db),
+		Storefronts: controllers.NewStorefrontHandler(db),
+		Variants:    controllers.NewVariantHandler(db),
+		Vendors:     controllers.NewVendorHandler(db),
+		Whatsapp:    controllers.NewWhatsappHandler(db),
+	}
+}
@@ -2,14 +2,20 @@ package routes
 
 import (
 	"github.com/gofiber/fiber/v2"
-	"github.com/theHoracle/whatstore-api/app/controllers"
+	"github.com/theHoracle/whatstore-api/app/middleware"
+	"github.com/theHoracle/whatstore-api/app/rbac"
 )
 
-func OrderRoutes(app fiber.Router) {
+func OrderRoutes(app fiber.Router, h *Handlers) {
 	orders := app.Group("/orders")
 
-	orders.Post("/", controllers.CreateOrder)
-	orders.Get("/", controllers.GetUserOrders)
-	orders.Get("/store/:storeId", controllers.GetStoreOrders)
-	orders.Put("/:id/status", controllers.UpdateOrderStatus)
+	orders.Post("/", h.Orders.CreateOrder)
+	orders.Get("/", h.Orders.GetUserOrders)
+	orders.Get("/store/:storeId", middleware.RequirePermission(rbac.PermissionStoreOrders), h.Orders.GetStoreOrders)
+	orders.Get("/:id", h.Orders.GetOrder)
+	orders.Get("/:id/history", h.Orders.GetOrderHistory)
+	orders.Put("/:id/status", h.Orders.UpdateOrderStatus)
+	orders.Post("/:id/pay", h.Payments.PayOrder)
+	orders.Get("/:id/whatsapp", h.Whatsapp.GetOrderWhatsappLink)
+	orders.Get("/:id/whatsapp/qr", h.Whatsapp.GetOrderWhatsappQR)
 }
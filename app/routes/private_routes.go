@@ -2,32 +2,43 @@ package routes
 
 import (
 	"github.com/gofiber/fiber/v2"
-	"github.com/theHoracle/whatstore-api/app/controllers"
 	"github.com/theHoracle/whatstore-api/app/middleware"
+	"github.com/theHoracle/whatstore-api/app/rbac"
 	"gorm.io/gorm"
 )
 
-func PrivateRoutes(app *fiber.App, db *gorm.DB) {
+func PrivateRoutes(app *fiber.App, db *gorm.DB, h *Handlers) {
 	// API group with version and auth middleware
 	api := app.Group("/api/v1", middleware.AuthMiddleware(db))
 
 	// Setup route groups
-	VendorRoutes(api)
-	StoreRoutes(api)
-	OrderRoutes(api)
+	VendorRoutes(api, h)
+	StoreRoutes(api, h)
+	OrderRoutes(api, h)
+	CheckoutRoutes(api, h)
+	BookingRoutes(api, h)
 
 	// User Management Routes
 	users := api.Group("/users")
 	{
-		users.Get("/me", controllers.GetUserProfile)
-		users.Put("/me", controllers.UpdateUserProfile)
+		users.Get("/me", h.Users.GetUserProfile)
+		users.Put("/me", h.Users.UpdateUserProfile)
+		users.Get("/me/memberships", h.Members.GetUserMemberships)
+		users.Post("/me/memberships/:id/accept", h.Members.AcceptStoreInvite)
+		users.Delete("/me/memberships/:id", h.Members.LeaveStoreMembership)
 	}
 
 	// Admin Routes
-	admin := api.Group("/admin", middleware.AuthMiddleware(db))
+	admin := api.Group("/admin")
 	{
-		admin.Get("/stats", controllers.GetStats)
-		admin.Get("/orders", controllers.GetAllOrders)
-		admin.Put("/orders/:id/status", controllers.UpdateOrderStatus)
+		categories := middleware.RequirePermission(rbac.PermissionCategoryManage)
+
+		admin.Get("/stats", middleware.RequirePermission(rbac.PermissionAdminStats), h.Admin.GetStats)
+		admin.Get("/orders", middleware.RequirePermission(rbac.PermissionOrdersReadAll), h.Admin.GetAllOrders)
+		admin.Put("/orders/:id/status", middleware.RequirePermission(rbac.PermissionOrdersManageAll), h.Admin.UpdateOrderStatusAdmin)
+		admin.Get("/escrow/reconcile", middleware.RequirePermission(rbac.PermissionEscrowReconcile), h.Admin.ReconcileEscrow)
+		admin.Post("/categories", categories, h.Categories.CreateCategory)
+		admin.Put("/categories/:id", categories, h.Categories.UpdateCategory)
+		admin.Delete("/categories/:id", categories, h.Categories.DeleteCategory)
 	}
 }
@@ -2,14 +2,21 @@ package routes
 
 import (
 	"github.com/gofiber/fiber/v2"
-	"github.com/theHoracle/whatstore-api/app/controllers"
+	"github.com/theHoracle/whatstore-api/app/middleware"
+	"github.com/theHoracle/whatstore-api/app/rbac"
 )
 
-func ProductRoutes(app fiber.Router) {
-	products := app.Group("/stores/:storeId/products")
+func ProductRoutes(app fiber.Router, h *Handlers) {
+	products := app.Group("/stores/:storeId/products", middleware.RequirePermission(rbac.PermissionStoreManage))
 
 	// Product CRUD operations
-	products.Post("/", controllers.CreateProduct)
-	products.Put("/:id", controllers.UpdateProduct)
-	products.Delete("/:id", controllers.DeleteProduct)
+	products.Post("/", h.Products.CreateProduct)
+	products.Put("/:id", h.Products.UpdateProduct)
+	products.Delete("/:id", h.Products.DeleteProduct)
+
+	// Options and variants
+	products.Put("/:id/options", h.Variants.SetProductOptions)
+	products.Post("/:id/variants", h.Variants.CreateProductVariant)
+	products.Put("/:id/variants/:variantId", h.Variants.UpdateProductVariant)
+	products.Delete("/:id/variants/:variantId", h.Variants.DeleteProductVariant)
 }
@@ -2,27 +2,60 @@ package routes
 
 import (
 	"github.com/gofiber/fiber/v2"
-	"github.com/theHoracle/whatstore-api/app/controllers"
+	"github.com/theHoracle/whatstore-api/app/middleware"
+	"gorm.io/gorm"
 )
 
-func PublicRoutes(app *fiber.App) {
+func PublicRoutes(app *fiber.App, db *gorm.DB, h *Handlers) {
 	// API group with version
 	api := app.Group("/api/v1")
 
+	// Reads that are personalized when a signed-in user asks. It is added to
+	// routes rather than groups, whose middleware would also run in front of
+	// the private routes sharing their prefix.
+	optional := middleware.OptionalAuthMiddleware(db)
+
 	// Products endpoints
 	products := api.Group("/products")
 	{
-		products.Get("/", controllers.GetAllProducts)       // List all products
-		products.Get("/:id", controllers.GetProduct)        // Get single product
-		products.Get("/search", controllers.SearchProducts) // Search products
+		products.Get("/", optional, h.Products.GetAllProducts)       // List all products
+		products.Get("/search", optional, h.Products.SearchProducts) // Search products
+		products.Get("/:id", optional, h.Products.GetProduct)        // Get single product
 	}
 
+	// Services endpoints
+	services := api.Group("/services")
+	{
+		services.Get("/", optional, h.Services.GetAllServices)       // List all services
+		services.Get("/search", optional, h.Services.SearchServices) // Search services
+		services.Get("/:id", optional, h.Services.GetService)        // Get single service
+
+		services.Get("/:id/availability", h.Bookings.GetServiceAvailability) // Weekly booking windows
+		services.Get("/:id/slots", h.Bookings.GetServiceSlots)               // Open booking slots
+	}
+
+	// Services offered by a store
+	api.Get("/stores/:storeId/services", optional, h.Services.GetStoreServices)
+
+	// Storefronts by store URL
+	api.Get("/storefronts/:slug", optional, h.Storefronts.GetStorefront)
+
+	// Storefront of the custom domain the request was made to
+	app.Get("/", optional, h.Storefronts.GetDomainStorefront)
+	api.Get("/storefront", optional, h.Storefronts.GetDomainStorefront)
+
 	// Categories endpoints
-	// categories := api.Group("/categories")
-	// {
-	// 	categories.Get("/", controllers.GetCategories)   // List all categories
-	// 	categories.Get("/:id", controllers.GetCategory)  // Get single category
-	// }
+	categories := api.Group("/categories")
+	{
+		categories.Get("/", h.Categories.GetCategories)  // List all categories
+		categories.Get("/:id", h.Categories.GetCategory) // Get single category
+	}
+
+	// Search endpoints
+	search := api.Group("/search")
+	{
+		search.Get("/suggest", h.Search.SearchSuggest) // Autocomplete names
+	}
 
 	// Health check
 	api.Get("/health", func(c *fiber.Ctx) error {
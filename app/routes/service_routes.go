@@ -2,14 +2,19 @@ package routes
 
 import (
 	"github.com/gofiber/fiber/v2"
-	"github.com/theHoracle/whatstore-api/app/controllers"
+	"github.com/theHoracle/whatstore-api/app/middleware"
+	"github.com/theHoracle/whatstore-api/app/rbac"
 )
 
-func ServiceRoutes(app fiber.Router) {
-	services := app.Group("/stores/:storeId/services")
+func ServiceRoutes(app fiber.Router, h *Handlers) {
+	services := app.Group("/stores/:storeId/services", middleware.RequirePermission(rbac.PermissionStoreManage))
 
-	services.Post("/", controllers.CreateService)
-	services.Put("/:id", controllers.UpdateService)
-	services.Delete("/:id", controllers.DeleteService)
-	services.Get("/", controllers.GetStoreServices)
+	services.Post("/", h.Services.CreateService)
+	services.Put("/:id", h.Services.UpdateService)
+	services.Delete("/:id", h.Services.DeleteService)
+
+	// Booking availability
+	services.Put("/:id/availability", h.Bookings.SetServiceAvailability)
+	services.Post("/:id/blackouts", h.Bookings.AddServiceBlackout)
+	services.Delete("/:id/blackouts/:blackoutId", h.Bookings.DeleteServiceBlackout)
 }
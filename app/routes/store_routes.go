@@ -2,26 +2,62 @@ package routes
 
 import (
 	"github.com/gofiber/fiber/v2"
-	"github.com/theHoracle/whatstore-api/app/controllers"
+	"github.com/theHoracle/whatstore-api/app/media"
+	"github.com/theHoracle/whatstore-api/app/middleware"
+	"github.com/theHoracle/whatstore-api/app/rbac"
 )
 
-func StoreRoutes(app fiber.Router) {
+func StoreRoutes(app fiber.Router, h *Handlers) {
 	stores := app.Group("/stores")
+	owner := middleware.RequirePermission(rbac.PermissionVendorManage)
+	manage := middleware.RequirePermission(rbac.PermissionStoreManage)
+	orders := middleware.RequirePermission(rbac.PermissionStoreOrders)
+	upload := middleware.LimitBody(media.MaxRequestSize)
 
 	// URL availability check
-	stores.Get("/check-url", controllers.CheckStoreUrlAvailability)
+	stores.Get("/check-url", h.Stores.CheckStoreUrlAvailability)
 
 	// Store CRUD operations
-	stores.Post("/", controllers.CreateStore)
-	stores.Put("/:id", controllers.UpdateStore)
-	stores.Delete("/:id", controllers.DeleteStore)
-	stores.Get("/:id", controllers.GetStore)
-	stores.Get("/vendor/:vendorId", controllers.GetAllStores)
+	stores.Post("/", owner, h.Stores.CreateStore)
+	stores.Put("/:id", manage, h.Stores.UpdateStore)
+	stores.Delete("/:id", owner, h.Stores.DeleteStore)
+	stores.Get("/:id", h.Stores.GetStore)
+	stores.Get("/vendor/:vendorId", h.Stores.GetAllStores)
+	stores.Put("/:id/whatsapp-template", manage, h.Whatsapp.UpdateStoreWhatsappTemplate)
+
+	// WhatsApp catalog sync
+	stores.Get("/:id/catalog", manage, h.Catalogs.GetStoreCatalog)
+	stores.Put("/:id/catalog", manage, h.Catalogs.ConnectStoreCatalog)
+	stores.Post("/:id/catalog/sync", manage, h.Catalogs.SyncStoreCatalog)
+
+	// Uploaded images
+	stores.Get("/:id/media", manage, h.Media.GetStoreMedia)
+	stores.Post("/:id/media", manage, upload, h.Media.UploadStoreMedia)
+	stores.Delete("/:id/media/:mediaId", manage, h.Media.DeleteStoreMedia)
+	stores.Put("/:id/logo", manage, upload, h.Media.UploadStoreLogo)
+
+	// Custom domains
+	stores.Get("/:id/domains", manage, h.Domains.GetStoreDomains)
+	stores.Post("/:id/domains", manage, h.Domains.AddStoreDomain)
+	stores.Post("/:id/domains/:domainId/verify", manage, h.Domains.VerifyStoreDomain)
+	stores.Delete("/:id/domains/:domainId", manage, h.Domains.DeleteStoreDomain)
+
+	// Store members
+	stores.Get("/:id/members", owner, h.Members.GetStoreMembers)
+	stores.Post("/:id/members", owner, h.Members.InviteStoreMember)
+	stores.Put("/:id/members/:memberId", owner, h.Members.UpdateStoreMember)
+	stores.Delete("/:id/members/:memberId", owner, h.Members.RemoveStoreMember)
 
 	// Store orders
-	stores.Get("/:storeId/orders", controllers.GetStoreOrders)
+	stores.Get("/:storeId/orders", orders, h.Orders.GetStoreOrders)
+
+	// Store bookings
+	stores.Get("/:storeId/bookings", orders, h.Bookings.GetStoreBookings)
+
+	// Store inventory
+	stores.Get("/:storeId/inventory", orders, h.Inventory.GetStoreInventoryLedger)
 
 	// Setup sub-routes
-	ServiceRoutes(app)
-	ProductRoutes(app)
+	ServiceRoutes(app, h)
+	ProductRoutes(app, h)
 }
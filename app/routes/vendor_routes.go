@@ -2,14 +2,18 @@ package routes
 
 import (
 	"github.com/gofiber/fiber/v2"
-	"github.com/theHoracle/whatstore-api/app/controllers"
+	"github.com/theHoracle/whatstore-api/app/middleware"
+	"github.com/theHoracle/whatstore-api/app/rbac"
 )
 
-func VendorRoutes(app fiber.Router) {
-	app.Post("/vendors", controllers.CreateVendor)
-	app.Put("/vendors/:id", controllers.UpdateVendor)
-	app.Delete("/vendors/:id", controllers.DeleteVendor)
-	app.Get("/vendors/:id", controllers.GetVendor)
-	app.Get("/vendors", controllers.GetAllVendors) // Enable get all vendors
+func VendorRoutes(app fiber.Router, h *Handlers) {
+	manage := middleware.RequirePermission(rbac.PermissionVendorManage)
+
+	app.Post("/vendors", middleware.RequirePermission(rbac.PermissionVendorCreate), h.Vendors.CreateVendor)
+	app.Put("/vendors/:id", manage, h.Vendors.UpdateVendor)
+	app.Delete("/vendors/:id", manage, h.Vendors.DeleteVendor)
+	app.Get("/vendors/:id", h.Vendors.GetVendor)
+	app.Get("/vendors/:id/balance", manage, h.Vendors.GetVendorBalance)
+	app.Get("/vendors", h.Vendors.GetAllVendors) // Enable get all vendors
 
 }
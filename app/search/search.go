@@ -0,0 +1,383 @@
+package search
+
+import (
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Sort orders
+const (
+	SortRelevance = "relevance"
+	SortPriceAsc  = "price_asc"
+	SortPriceDesc = "price_desc"
+	SortNewest    = "newest"
+)
+
+// DefaultPriceBounds split prices into the buckets counted by the price facet
+var DefaultPriceBounds = []float64{1000, 5000, 10000, 50000, 100000}
+
+// MinResults is the number of full-text matches below which names that
+// merely look like the search text are also matched
+const MinResults = 5
+
+// FuzzyThreshold is the trigram word similarity a name needs to the search
+// text to count as a fuzzy match
+const FuzzyThreshold = 0.4
+
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2"
+
+// Index describes a searchable table. Columns left empty are not
+// filterable or faceted.
+type Index struct {
+	Kind           string
+	Table          string
+	PriceColumn    string
+	StockColumn    string
+	CategoryColumn string
+}
+
+// Products searches products by name and description
+var Products = Index{Kind: "product", Table: "products", PriceColumn: "price", StockColumn: "stock", CategoryColumn: "category_id"}
+
+// Services searches services by name and description
+var Services = Index{Kind: "service", Table: "services", PriceColumn: "rate"}
+
+// Query is a search request. Text is matched against the full-text index and
+// the other fields narrow the results down.
+type Query struct {
+	Text        string
+	CategoryIDs []uint
+	StoreID     *uint
+	MinPrice    *float64
+	MaxPrice    *float64
+	Currency    string
+	InStock     bool
+	Sort        string
+	PriceBounds []float64
+	Page        int
+	PerPage     int
+
+	// fuzzy also matches names by trigram similarity
+	fuzzy bool
+}
+
+// Hit is a matching row with its rank and a highlighted description snippet
+type Hit struct {
+	ID        uint    `json:"id"`
+	Rank      float64 `json:"rank"`
+	Highlight string  `json:"highlight"`
+}
+
+// FacetCount is the number of results for one category or store
+type FacetCount struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Slug  string `json:"slug,omitempty"`
+	Count int64  `json:"count"`
+}
+
+// PriceBucket is the number of results priced from Min up to, but not
+// including, Max. The last bucket has no Max.
+type PriceBucket struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max,omitempty"`
+	Count int64    `json:"count"`
+}
+
+// Facets count the results by category, price and store. Each facet ignores
+// its own filter so the other choices stay visible.
+type Facets struct {
+	Categories []FacetCount  `json:"categories,omitempty"`
+	Prices     []PriceBucket `json:"prices"`
+	Stores     []FacetCount  `json:"stores"`
+}
+
+// Result is a page of hits with the total and facets of the whole result
+// set. Fuzzy is set when the hits include names similar to a misspelt
+// search, and Suggestions then holds the closest names.
+type Result struct {
+	Hits        []Hit
+	Total       int64
+	Facets      Facets
+	Fuzzy       bool
+	Suggestions []string
+}
+
+// facet names a filter to leave out when counting a facet
+type facet int
+
+const (
+	noFacet facet = iota
+	categoryFacet
+	priceFacet
+	storeFacet
+)
+
+// Search runs q against the index. When the text has fewer than MinResults
+// full-text matches the search is repeated matching names by trigram
+// similarity as well.
+func (idx Index) Search(db *gorm.DB, q Query) (*Result, error) {
+	result, err := idx.search(db, q)
+	if err != nil || q.Text == "" || result.Total >= MinResults {
+		return result, err
+	}
+
+	q.fuzzy = true
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// The threshold used by the <% operator, for this transaction only
+		err := tx.Exec("SELECT set_config('pg_trgm.word_similarity_threshold', ?, true)",
+			strconv.FormatFloat(FuzzyThreshold, 'f', -1, 64)).Error
+		if err != nil {
+			return err
+		}
+
+		fuzzy, err := idx.search(tx, q)
+		if err != nil {
+			return err
+		}
+		if fuzzy.Total > result.Total {
+			result = fuzzy
+			result.Fuzzy = true
+		}
+
+		result.Suggestions, err = idx.suggestions(tx, q.Text)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// List runs q against the index like Search but without facets or the
+// fuzzy fallback, for browsing
+func (idx Index) List(db *gorm.DB, q Query) (*Result, error) {
+	result := &Result{}
+
+	if err := idx.filtered(db, q, noFacet).Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+
+	if err := idx.hits(db, q).Scan(&result.Hits).Error; err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (idx Index) search(db *gorm.DB, q Query) (*Result, error) {
+	result, err := idx.List(db, q)
+	if err != nil {
+		return nil, err
+	}
+
+	if idx.CategoryColumn != "" {
+		if result.Facets.Categories, err = idx.categoryFacet(db, q); err != nil {
+			return nil, err
+		}
+	}
+	if result.Facets.Prices, err = idx.priceFacet(db, q); err != nil {
+		return nil, err
+	}
+	if result.Facets.Stores, err = idx.storeFacet(db, q); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (idx Index) column(name string) string {
+	return idx.Table + "." + name
+}
+
+// filtered applies every filter in q except the one belonging to skip
+func (idx Index) filtered(db *gorm.DB, q Query, skip facet) *gorm.DB {
+	tx := db.Table(idx.Table)
+
+	if q.fuzzy {
+		tx = tx.Where("("+idx.column("search_vector")+" @@ plainto_tsquery('english', ?) OR ? <% "+idx.column("name")+")", q.Text, q.Text)
+	} else if q.Text != "" {
+		tx = tx.Where(idx.column("search_vector")+" @@ plainto_tsquery('english', ?)", q.Text)
+	}
+	if idx.CategoryColumn != "" && len(q.CategoryIDs) > 0 && skip != categoryFacet {
+		tx = tx.Where(idx.column(idx.CategoryColumn)+" IN ?", q.CategoryIDs)
+	}
+	if q.StoreID != nil && skip != storeFacet {
+		tx = tx.Where(idx.column("store_id")+" = ?", *q.StoreID)
+	}
+	if skip != priceFacet {
+		if q.MinPrice != nil {
+			tx = tx.Where(idx.column(idx.PriceColumn)+" >= ?", *q.MinPrice)
+		}
+		if q.MaxPrice != nil {
+			tx = tx.Where(idx.column(idx.PriceColumn)+" <= ?", *q.MaxPrice)
+		}
+	}
+	if q.Currency != "" {
+		tx = tx.Where(idx.column("currency")+" = ?", q.Currency)
+	}
+	if idx.StockColumn != "" && q.InStock {
+		tx = tx.Where(idx.column(idx.StockColumn) + " > 0")
+	}
+
+	return tx
+}
+
+func (idx Index) hits(db *gorm.DB, q Query) *gorm.DB {
+	tx := idx.filtered(db, q, noFacet)
+
+	rank := "ts_rank(" + idx.column("search_vector") + ", plainto_tsquery('english', ?))"
+	rankArgs := []interface{}{q.Text}
+	if q.fuzzy {
+		rank = "GREATEST(" + rank + ", word_similarity(?, " + idx.column("name") + "))"
+		rankArgs = append(rankArgs, q.Text)
+	}
+
+	if q.Text != "" {
+		tx = tx.Select(
+			idx.column("id")+` AS id,
+			`+rank+` AS rank,
+			ts_headline('english', COALESCE(`+idx.column("description")+`, ''), plainto_tsquery('english', ?), ?) AS highlight`,
+			append(rankArgs, q.Text, headlineOptions)...,
+		)
+	} else {
+		tx = tx.Select(idx.column("id") + " AS id, 0 AS rank, '' AS highlight")
+	}
+
+	id := idx.column("id")
+	order := clause.Expr{SQL: "rank DESC, " + id + " DESC"}
+	switch q.Sort {
+	case SortPriceAsc:
+		order = clause.Expr{SQL: idx.column(idx.PriceColumn) + ", " + id}
+	case SortPriceDesc:
+		order = clause.Expr{SQL: idx.column(idx.PriceColumn) + " DESC, " + id + " DESC"}
+	case SortNewest:
+		order = clause.Expr{SQL: idx.column("created_at") + " DESC, " + id + " DESC"}
+	}
+
+	return tx.Order(clause.OrderBy{Expression: order}).
+		Offset((q.Page - 1) * q.PerPage).
+		Limit(q.PerPage)
+}
+
+func (idx Index) categoryFacet(db *gorm.DB, q Query) ([]FacetCount, error) {
+	var counts []FacetCount
+	err := idx.filtered(db, q, categoryFacet).
+		Select("categories.id, categories.name, categories.slug, count(*) AS count").
+		Joins("JOIN categories ON categories.id = " + idx.column(idx.CategoryColumn)).
+		Group("categories.id, categories.name, categories.slug").
+		Order("count DESC, categories.name").
+		Scan(&counts).Error
+	return counts, err
+}
+
+func (idx Index) storeFacet(db *gorm.DB, q Query) ([]FacetCount, error) {
+	var counts []FacetCount
+	err := idx.filtered(db, q, storeFacet).
+		Select("stores.id, stores.name, count(*) AS count").
+		Joins("JOIN stores ON stores.id = " + idx.column("store_id")).
+		Group("stores.id, stores.name").
+		Order("count DESC, stores.name").
+		Limit(20).
+		Scan(&counts).Error
+	return counts, err
+}
+
+func (idx Index) priceFacet(db *gorm.DB, q Query) ([]PriceBucket, error) {
+	bounds := q.PriceBounds
+	if len(bounds) == 0 {
+		bounds = DefaultPriceBounds
+	}
+
+	// CASE WHEN price < ? THEN 0 WHEN price < ? THEN 1 ... ELSE n END, with
+	// the bounds and bucket numbers passed as parameters
+	var bucket strings.Builder
+	args := make([]interface{}, 0, 2*len(bounds)+1)
+	bucket.WriteString("CASE")
+	for i, bound := range bounds {
+		bucket.WriteString(" WHEN " + idx.column(idx.PriceColumn) + " < ? THEN ?::int")
+		args = append(args, bound, i)
+	}
+	bucket.WriteString(" ELSE ?::int END")
+	args = append(args, len(bounds))
+
+	var counts []struct {
+		Bucket int
+		Count  int64
+	}
+	err := idx.filtered(db, q, priceFacet).
+		Select("("+bucket.String()+") AS bucket, count(*) AS count", args...).
+		Group("bucket").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]PriceBucket, len(bounds)+1)
+	for i := range buckets {
+		if i > 0 {
+			buckets[i].Min = bounds[i-1]
+		}
+		if i < len(bounds) {
+			buckets[i].Max = &bounds[i]
+		}
+	}
+	for _, count := range counts {
+		if count.Bucket >= 0 && count.Bucket < len(buckets) {
+			buckets[count.Bucket].Count = count.Count
+		}
+	}
+	return buckets, nil
+}
+
+// suggestions returns up to three names that look like text, closest first
+func (idx Index) suggestions(db *gorm.DB, text string) ([]string, error) {
+	var names []string
+	err := db.Table(idx.Table).
+		Select(idx.column("name")).
+		Where("? <% "+idx.column("name"), text).
+		Group(idx.column("name")).
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:  "max(word_similarity(?, " + idx.column("name") + ")) DESC, " + idx.column("name"),
+			Vars: []interface{}{text},
+		}}).
+		Limit(3).
+		Pluck(idx.column("name"), &names).Error
+	return names, err
+}
+
+// Suggestion is a name offered while the user types
+type Suggestion struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+// Suggest completes partly typed text with the names in indexes that contain
+// it or look like it, names starting with the text first
+func Suggest(db *gorm.DB, text string, limit int, indexes ...Index) ([]Suggestion, error) {
+	escaped := likeEscaper.Replace(text)
+
+	var parts []string
+	var args []interface{}
+	for _, idx := range indexes {
+		name := idx.column("name")
+		parts = append(parts, `SELECT `+name+` AS text, ?::text AS type,
+			word_similarity(?, `+name+`) + CASE WHEN `+name+` ILIKE ? THEN 1 ELSE 0 END AS score
+			FROM `+idx.Table+`
+			WHERE `+name+` ILIKE ? OR ? <% `+name)
+		args = append(args, idx.Kind, text, escaped+"%", "%"+escaped+"%", text)
+	}
+	args = append(args, limit)
+
+	var suggestions []Suggestion
+	err := db.Raw(`SELECT text, type FROM (`+strings.Join(parts, " UNION ALL ")+`) matches
+		GROUP BY text, type
+		ORDER BY max(score) DESC, text
+		LIMIT ?`, args...).Scan(&suggestions).Error
+	return suggestions, err
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/rbac"
+	"github.com/theHoracle/whatstore-api/app/repository"
+)
+
+// Services manages the services stores offer
+type Services struct {
+	services repository.ServiceRepository
+	stores   repository.StoreRepository
+}
+
+// NewServices returns the service offering service
+func NewServices(services repository.ServiceRepository, stores repository.StoreRepository) *Services {
+	return &Services{services: services, stores: stores}
+}
+
+// Get loads a service
+func (s *Services) Get(ctx context.Context, id uint) (*models.Service, error) {
+	service, err := s.services.Get(ctx, id)
+	if err != nil {
+		return nil, missing(err, "Service not found")
+	}
+	return service, nil
+}
+
+// ListByStore lists a store's services by name
+func (s *Services) ListByStore(ctx context.Context, storeID uint) ([]models.Service, error) {
+	if _, err := s.stores.Get(ctx, storeID); err != nil {
+		return nil, missing(err, "Store not found")
+	}
+	return s.services.ListByStore(ctx, storeID)
+}
+
+// Create adds a service to the store
+func (s *Services) Create(ctx context.Context, user *models.User, storeID uint, input models.CreateServiceRequest) (*models.Service, error) {
+	if err := AuthorizeStore(ctx, s.stores, user, storeID, rbac.StorePermissionCatalog); err != nil {
+		return nil, err
+	}
+
+	service := models.Service{
+		Name:        input.Name,
+		Description: input.Description,
+		Rate:        input.Rate,
+		Currency:    input.Currency,
+		StoreID:     storeID,
+		ImageURL:    input.ImageURL,
+	}
+	if err := s.services.Create(ctx, &service); err != nil {
+		return nil, err
+	}
+	return &service, nil
+}
+
+// Update changes a service
+func (s *Services) Update(ctx context.Context, user *models.User, id uint, changes models.UpdateServiceRequest) (*models.Service, error) {
+	service, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := AuthorizeStore(ctx, s.stores, user, service.StoreID, rbac.StorePermissionCatalog); err != nil {
+		return nil, err
+	}
+	if err := s.services.Update(ctx, service, changes); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, service.ID)
+}
+
+// Delete removes a service from its store
+func (s *Services) Delete(ctx context.Context, user *models.User, id uint) error {
+	service, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := AuthorizeStore(ctx, s.stores, user, service.StoreID, rbac.StorePermissionCatalog); err != nil {
+		return err
+	}
+	return s.services.Delete(ctx, service)
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/orderflow"
+	"github.com/theHoracle/whatstore-api/app/rbac"
+	"github.com/theHoracle/whatstore-api/app/repository"
+)
+
+// Orders places orders and moves them through their lifecycle
+type Orders struct {
+	orders   repository.OrderRepository
+	products repository.ProductRepository
+	stores   repository.StoreRepository
+}
+
+// NewOrders returns the order service
+func NewOrders(orders repository.OrderRepository, products repository.ProductRepository, stores repository.StoreRepository) *Orders {
+	return &Orders{orders: orders, products: products, stores: stores}
+}
+
+// Place creates a pending order for products of a single store, reserving
+// stock for every item at the current product price. Items short of stock
+// are reported together in a *repository.StockError.
+func (s *Orders) Place(ctx context.Context, userID uint, requested []models.OrderItem) (*models.Order, error) {
+	if len(requested) == 0 {
+		return nil, invalid("Order has no items")
+	}
+
+	// Reserve products in a consistent order so concurrent checkouts cannot deadlock
+	items := append([]models.OrderItem(nil), requested...)
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].ProductID != items[j].ProductID {
+			return items[i].ProductID < items[j].ProductID
+		}
+		return variantKey(items[i].VariantID) < variantKey(items[j].VariantID)
+	})
+
+	order := models.Order{
+		UserID: userID,
+		Status: models.OrderStatusPending,
+	}
+	for _, item := range items {
+		productID := strconv.Itoa(int(item.ProductID))
+		if item.Quantity <= 0 {
+			return nil, invalid("Invalid quantity for product: " + productID)
+		}
+
+		product, err := s.products.Get(ctx, item.ProductID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, invalid("Product not found: " + productID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if order.StoreID == 0 {
+			order.StoreID = product.StoreID
+		} else if order.StoreID != product.StoreID {
+			return nil, invalid("All products must be from the same store, use a checkout to order from several stores")
+		}
+
+		variant, err := s.ItemVariant(ctx, product, item.VariantID)
+		if err != nil {
+			return nil, err
+		}
+
+		price := product.PriceFor(variant)
+		order.Items = append(order.Items, models.OrderItem{
+			ProductID: product.ID,
+			VariantID: item.VariantID,
+			Quantity:  item.Quantity,
+			Price:     price, // Store current price
+		})
+		order.TotalAmount += price * float64(item.Quantity)
+	}
+
+	if err := s.orders.Create(ctx, &order, &userID); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func variantKey(variantID *uint) uint {
+	if variantID == nil {
+		return 0
+	}
+	return *variantID
+}
+
+// ItemVariant loads the variant an order or cart item asks for. Products
+// with variants must be ordered by variant, products without cannot be.
+func (s *Orders) ItemVariant(ctx context.Context, product *models.Product, variantID *uint) (*models.ProductVariant, error) {
+	productID := strconv.Itoa(int(product.ID))
+	if variantID == nil {
+		count, err := s.products.CountVariants(ctx, product.ID)
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, invalid("Choose a variant for product: " + productID)
+		}
+		return nil, nil
+	}
+
+	variant, err := s.products.Variant(ctx, product.ID, *variantID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, invalid("Variant not found for product: " + productID)
+		}
+		return nil, err
+	}
+	return variant, nil
+}
+
+// Create places an order and returns it with its items' products and variants
+func (s *Orders) Create(ctx context.Context, userID uint, items []models.OrderItem) (*models.Order, error) {
+	order, err := s.Place(ctx, userID, items)
+	if err != nil {
+		return nil, err
+	}
+	return s.orders.GetWithItems(ctx, order.ID)
+}
+
+// ListByUser returns a page of the orders the user placed and how many there are
+func (s *Orders) ListByUser(ctx context.Context, userID uint, page, perPage int) ([]models.Order, int64, error) {
+	return s.orders.ListByUser(ctx, userID, page, perPage)
+}
+
+// Get loads an order the user placed
+func (s *Orders) Get(ctx context.Context, user *models.User, id uint) (*models.Order, error) {
+	order, err := s.orders.Get(ctx, id)
+	if err != nil {
+		return nil, missing(err, "Order not found")
+	}
+	if order.UserID != user.ID {
+		return nil, notFound("Order not found")
+	}
+	return order, nil
+}
+
+// ListByStore lists the orders placed with a store, for its members who
+// handle orders
+func (s *Orders) ListByStore(ctx context.Context, user *models.User, storeID uint) ([]models.Order, error) {
+	if err := AuthorizeStore(ctx, s.stores, user, storeID, rbac.StorePermissionOrders); err != nil {
+		return nil, err
+	}
+	return s.orders.ListByStore(ctx, storeID)
+}
+
+// UpdateStatus moves an order to a new status. Buyers and the store's
+// members can only make the transitions allowed for their role.
+func (s *Orders) UpdateStatus(ctx context.Context, user *models.User, id uint, status models.OrderStatus, note string) (*models.Order, error) {
+	if !orderflow.IsValidStatus(status) {
+		return nil, invalid("Invalid order status")
+	}
+
+	order, actors, err := s.involved(ctx, user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// A vendor buying from their own store acts as both buyer and vendor
+	actor := actors[0]
+	for _, a := range actors {
+		if orderflow.CanTransition(order.Status, status, a) == nil {
+			actor = a
+			break
+		}
+	}
+
+	err = s.orders.Transition(ctx, order, status, actor, &user.ID, note)
+	switch {
+	case errors.Is(err, orderflow.ErrInvalidTransition):
+		return nil, conflict(err.Error())
+	case errors.Is(err, orderflow.ErrActorNotAllowed):
+		return nil, forbidden(err.Error())
+	case err != nil:
+		return nil, err
+	}
+	return order, nil
+}
+
+// History lists every status change of an order the user is involved in,
+// oldest first
+func (s *Orders) History(ctx context.Context, user *models.User, id uint) ([]models.OrderStatusHistory, error) {
+	order, _, err := s.involved(ctx, user, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.orders.History(ctx, order.ID)
+}
+
+// involved loads an order with the roles the user holds on it, reporting it
+// missing to users who hold none
+func (s *Orders) involved(ctx context.Context, user *models.User, id uint) (*models.Order, []models.OrderActor, error) {
+	order, err := s.orders.Get(ctx, id)
+	if err != nil {
+		return nil, nil, missing(err, "Order not found")
+	}
+	actors := OrderActors(ctx, s.stores, user, order)
+	if len(actors) == 0 {
+		return nil, nil, notFound("Order not found")
+	}
+	return order, actors, nil
+}
+
+// OrderActors returns the roles the user holds on an order: the buyer who
+// placed it and/or the vendor, a member of the store it was placed with who
+// handles its orders
+func OrderActors(ctx context.Context, stores repository.StoreRepository, user *models.User, order *models.Order) []models.OrderActor {
+	var actors []models.OrderActor
+
+	if err := AuthorizeStore(ctx, stores, user, order.StoreID, rbac.StorePermissionOrders); err == nil {
+		actors = append(actors, models.OrderActorVendor)
+	}
+	if order.UserID == user.ID {
+		actors = append(actors, models.OrderActorBuyer)
+	}
+
+	return actors
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/rbac"
+	"github.com/theHoracle/whatstore-api/app/repository"
+)
+
+// Products manages the products stores sell
+type Products struct {
+	products repository.ProductRepository
+	stores   repository.StoreRepository
+}
+
+// NewProducts returns the product service
+func NewProducts(products repository.ProductRepository, stores repository.StoreRepository) *Products {
+	return &Products{products: products, stores: stores}
+}
+
+// Get loads a product with its category, options and variants
+func (s *Products) Get(ctx context.Context, id uint) (*models.Product, error) {
+	product, err := s.products.Get(ctx, id)
+	if err != nil {
+		return nil, missing(err, "Product not found")
+	}
+	return product, nil
+}
+
+// Create adds a product to the store's catalog
+func (s *Products) Create(ctx context.Context, user *models.User, storeID uint, input models.CreateProductRequest) (*models.Product, error) {
+	if err := AuthorizeStore(ctx, s.stores, user, storeID, rbac.StorePermissionCatalog); err != nil {
+		return nil, err
+	}
+	if err := s.checkCategory(ctx, input.CategoryID); err != nil {
+		return nil, err
+	}
+
+	product := models.Product{
+		Name:        input.Name,
+		Description: input.Description,
+		Price:       input.Price,
+		StoreID:     storeID,
+		Images:      input.Images,
+		Stock:       input.Stock,
+		CategoryID:  input.CategoryID,
+	}
+	if err := s.products.Create(ctx, &product, &user.ID); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// Update changes a product. The stock of a product with variants is the sum
+// of theirs, so it cannot be set here.
+func (s *Products) Update(ctx context.Context, user *models.User, id uint, changes models.UpdateProductRequest) (*models.Product, error) {
+	product, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := AuthorizeStore(ctx, s.stores, user, product.StoreID, rbac.StorePermissionCatalog); err != nil {
+		return nil, err
+	}
+	if err := s.checkCategory(ctx, changes.CategoryID); err != nil {
+		return nil, err
+	}
+
+	if changes.Stock != nil && *changes.Stock != product.Stock {
+		variants, err := s.products.CountVariants(ctx, product.ID)
+		if err != nil {
+			return nil, err
+		}
+		if variants > 0 {
+			return nil, conflict("Product stock is the sum of its variants, update the variants instead")
+		}
+	}
+
+	if err := s.products.Update(ctx, product, changes, &user.ID); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, product.ID)
+}
+
+// Delete removes a product from its store's catalog
+func (s *Products) Delete(ctx context.Context, user *models.User, id uint) error {
+	product, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := AuthorizeStore(ctx, s.stores, user, product.StoreID, rbac.StorePermissionCatalog); err != nil {
+		return err
+	}
+	return s.products.Delete(ctx, product)
+}
+
+// checkCategory checks a product's category exists, when it has one
+func (s *Products) checkCategory(ctx context.Context, categoryID *uint) error {
+	if categoryID == nil {
+		return nil
+	}
+	exists, err := s.products.CategoryExists(ctx, *categoryID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return invalid("Category not found")
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+// Package services holds the business rules of stores, products, services,
+// orders and users: who may change what, and how orders are priced. Services
+// read and save data through the repository interfaces and know nothing of
+// HTTP, so they run against the in-memory repositories in tests.
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/rbac"
+	"github.com/theHoracle/whatstore-api/app/repository"
+)
+
+// Kind is the sort of rule a request broke
+type Kind int
+
+const (
+	KindInvalid   Kind = iota + 1 // Something in the request needs fixing
+	KindForbidden                 // The user may not do this
+	KindNotFound                  // What the request is about does not exist
+	KindConflict                  // The request clashes with the current state
+)
+
+// Error is a broken business rule. Its message is meant for the caller.
+type Error struct {
+	Kind    Kind
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func invalid(message string) error   { return &Error{KindInvalid, message} }
+func forbidden(message string) error { return &Error{KindForbidden, message} }
+func notFound(message string) error  { return &Error{KindNotFound, message} }
+func conflict(message string) error  { return &Error{KindConflict, message} }
+
+// missing reports a record the repository could not find as message, and
+// passes any other error through
+func missing(err error, message string) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return notFound(message)
+	}
+	return err
+}
+
+const (
+	errNotMember      = "store not found or not authorized"
+	errRoleNotAllowed = "your role in this store does not allow this"
+)
+
+// AuthorizeStore checks the user is an active member of the store whose role
+// allows permission
+func AuthorizeStore(ctx context.Context, stores repository.StoreRepository, user *models.User, storeID uint, permission rbac.StorePermission) error {
+	member, err := stores.ActiveMember(ctx, storeID, user.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return forbidden(errNotMember)
+	}
+	if err != nil {
+		return err
+	}
+	if !rbac.StoreCan(member.Role, permission) {
+		return forbidden(errRoleNotAllowed)
+	}
+	return nil
+}
+
+var e164Regex = regexp.MustCompile(`^\+[1-9]\d{1,14}$`)
+
+// ValidatePhoneNumber checks a phone number is in E.164 format, e.g. +2348012345678
+func ValidatePhoneNumber(phone string) error {
+	if !e164Regex.MatchString(phone) {
+		return invalid("Invalid phone number format")
+	}
+	return nil
+}
+
+// ValidateTimezone checks that name is an IANA time zone such as Africa/Lagos
+func ValidateTimezone(name string) error {
+	if _, err := time.LoadLocation(name); err != nil || name == "Local" {
+		return invalid(fmt.Sprintf("unknown time zone %q, use an IANA name such as Africa/Lagos", name))
+	}
+	return nil
+}
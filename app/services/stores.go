@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/theHoracle/whatstore-api/app/booking"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/rbac"
+	"github.com/theHoracle/whatstore-api/app/repository"
+	"github.com/theHoracle/whatstore-api/app/slug"
+)
+
+// Stores manages vendors' stores
+type Stores struct {
+	stores repository.StoreRepository
+	users  repository.UserRepository
+}
+
+// NewStores returns the store service
+func NewStores(stores repository.StoreRepository, users repository.UserRepository) *Stores {
+	return &Stores{stores: stores, users: users}
+}
+
+// Authorize checks the user's membership of the store allows permission
+func (s *Stores) Authorize(ctx context.Context, user *models.User, storeID uint, permission rbac.StorePermission) error {
+	return AuthorizeStore(ctx, s.stores, user, storeID, permission)
+}
+
+// Get loads a store with its products, services and catalog sync
+func (s *Stores) Get(ctx context.Context, id uint) (*models.Store, error) {
+	store, err := s.stores.GetDetails(ctx, id)
+	if err != nil {
+		return nil, missing(err, "Store not found")
+	}
+	return store, nil
+}
+
+// ListByVendor lists a vendor's stores
+func (s *Stores) ListByVendor(ctx context.Context, vendorID uint) ([]models.Store, error) {
+	return s.stores.ListByVendor(ctx, vendorID)
+}
+
+// CheckURL normalizes a store URL and checks a new store could use it. The
+// normalized URL is returned even when it cannot be used.
+func (s *Stores) CheckURL(ctx context.Context, raw string) (string, error) {
+	return s.checkURL(ctx, raw, 0)
+}
+
+func (s *Stores) checkURL(ctx context.Context, raw string, storeID uint) (string, error) {
+	storeURL, err := slug.StoreURL(raw)
+	if err != nil {
+		return storeURL, invalid(err.Error())
+	}
+	taken, err := s.stores.URLTaken(ctx, storeURL, storeID)
+	if err != nil {
+		return storeURL, err
+	}
+	if taken {
+		return storeURL, invalid("Store URL already taken")
+	}
+	return storeURL, nil
+}
+
+// Create opens a new store for the user's vendor profile, with the user as
+// its owner
+func (s *Stores) Create(ctx context.Context, user *models.User, input models.CreateStoreRequest) (*models.Store, error) {
+	vendor, err := s.users.VendorByUser(ctx, user.ID)
+	if err != nil {
+		return nil, missing(err, "Vendor account not found")
+	}
+
+	if err := ValidatePhoneNumber(input.StoreWhatsappContact); err != nil {
+		return nil, err
+	}
+	if input.Timezone == "" {
+		input.Timezone = booking.DefaultTimezone
+	} else if err := ValidateTimezone(input.Timezone); err != nil {
+		return nil, err
+	}
+	storeURL, err := s.CheckURL(ctx, input.StoreUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	store := models.Store{
+		VendorID:             vendor.ID,
+		Name:                 input.StoreName,
+		Description:          input.StoreDescription,
+		StoreLogo:            input.StoreLogo,
+		StoreUrl:             storeURL,
+		StoreAddress:         input.StoreAddress,
+		StoreWhatsappContact: input.StoreWhatsappContact,
+		Timezone:             input.Timezone,
+		CreatedAt:            now,
+		UpdatedAt:            now,
+	}
+	owner := models.StoreMember{
+		Email:      strings.ToLower(user.Email),
+		UserID:     &user.ID,
+		Role:       models.StoreRoleOwner,
+		Status:     models.MemberStatusActive,
+		AcceptedAt: &now,
+	}
+	if err := s.stores.Create(ctx, &store, &owner); err != nil {
+		return nil, err
+	}
+	return &store, nil
+}
+
+// Update changes a store's profile. When the store URL changes the old URL
+// keeps redirecting to the store.
+func (s *Stores) Update(ctx context.Context, user *models.User, storeID uint, input models.UpdateStoreRequest) (*models.Store, error) {
+	if err := s.Authorize(ctx, user, storeID, rbac.StorePermissionSettings); err != nil {
+		return nil, err
+	}
+	store, err := s.stores.Get(ctx, storeID)
+	if err != nil {
+		return nil, missing(err, "Store not found")
+	}
+
+	if input.Timezone != "" {
+		if err := ValidateTimezone(input.Timezone); err != nil {
+			return nil, err
+		}
+		store.Timezone = input.Timezone
+	}
+
+	storeURL, err := slug.StoreURL(input.StoreUrl)
+	if err != nil {
+		return nil, invalid(err.Error())
+	}
+	previousURL := store.StoreUrl
+	if storeURL != previousURL {
+		if _, err := s.checkURL(ctx, storeURL, store.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	store.Name = input.Name
+	store.Description = input.Description
+	store.StoreLogo = input.StoreLogo
+	store.StoreUrl = storeURL
+	store.StoreAddress = input.StoreAddress
+	store.StoreWhatsappContact = input.StoreWhatsappContact
+	store.UpdatedAt = time.Now()
+
+	if err := s.stores.Update(ctx, store, previousURL); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Delete closes a store and removes its products. Only its owner can.
+func (s *Stores) Delete(ctx context.Context, user *models.User, storeID uint) error {
+	if err := s.Authorize(ctx, user, storeID, rbac.StorePermissionClose); err != nil {
+		return err
+	}
+	store, err := s.stores.Get(ctx, storeID)
+	if err != nil {
+		return missing(err, "Store not found")
+	}
+	return s.stores.Delete(ctx, store)
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"context"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/repository"
+)
+
+// Users manages users' own profiles
+type Users struct {
+	users repository.UserRepository
+}
+
+// NewUsers returns the user service
+func NewUsers(users repository.UserRepository) *Users {
+	return &Users{users: users}
+}
+
+// UpdateProfile changes the user's name, phone number and shipping address.
+// Fields left empty are kept.
+func (s *Users) UpdateProfile(ctx context.Context, user *models.User, input models.UpdateUserRequest) (*models.User, error) {
+	if input.Phone != "" {
+		if err := ValidatePhoneNumber(input.Phone); err != nil {
+			return nil, err
+		}
+		user.PhoneNumber = input.Phone
+	}
+	if input.Name != "" {
+		user.Name = input.Name
+	}
+
+	if err := s.users.UpdateProfile(ctx, user, input.Address); err != nil {
+		return nil, err
+	}
+	return s.users.Get(ctx, user.ID)
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/rbac"
+	"github.com/theHoracle/whatstore-api/app/repository"
+)
+
+// Viewers works out how what a signed-in user reads on the public endpoints
+// relates to them, so responses can be personalized
+type Viewers struct {
+	stores   repository.StoreRepository
+	orders   repository.OrderRepository
+	services repository.ServiceRepository
+}
+
+// NewViewers returns the viewer rules over the given repositories
+func NewViewers(stores repository.StoreRepository, orders repository.OrderRepository, services repository.ServiceRepository) *Viewers {
+	return &Viewers{stores: stores, orders: orders, services: services}
+}
+
+// Product relates a product to user
+func (v *Viewers) Product(ctx context.Context, user *models.User, product *models.Product) (*models.ProductViewer, error) {
+	viewers, err := v.Products(ctx, user, []models.Product{*product})
+	if err != nil {
+		return nil, err
+	}
+	return viewers[0], nil
+}
+
+// Products relates each of products, a page of a listing, to user
+func (v *Viewers) Products(ctx context.Context, user *models.User, products []models.Product) ([]*models.ProductViewer, error) {
+	ids, storeIDs := make([]uint, len(products)), make([]uint, len(products))
+	for i, product := range products {
+		ids[i], storeIDs[i] = product.ID, product.StoreID
+	}
+	roles, err := v.members(ctx, user, storeIDs)
+	if err != nil {
+		return nil, err
+	}
+	purchased, err := v.orders.Purchased(ctx, user.ID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	viewers := make([]*models.ProductViewer, len(products))
+	for i, product := range products {
+		role := roles[product.StoreID]
+		viewers[i] = &models.ProductViewer{StoreRole: role.role, CanEdit: role.canEdit, Purchased: slices.Contains(purchased, product.ID)}
+	}
+	return viewers, nil
+}
+
+// Service relates a service to user
+func (v *Viewers) Service(ctx context.Context, user *models.User, service *models.Service) (*models.ServiceViewer, error) {
+	viewers, err := v.Services(ctx, user, []models.Service{*service})
+	if err != nil {
+		return nil, err
+	}
+	return viewers[0], nil
+}
+
+// Services relates each of services, a page of a listing, to user
+func (v *Viewers) Services(ctx context.Context, user *models.User, services []models.Service) ([]*models.ServiceViewer, error) {
+	ids, storeIDs := make([]uint, len(services)), make([]uint, len(services))
+	for i, service := range services {
+		ids[i], storeIDs[i] = service.ID, service.StoreID
+	}
+	roles, err := v.members(ctx, user, storeIDs)
+	if err != nil {
+		return nil, err
+	}
+	booked, err := v.services.Booked(ctx, user.ID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	viewers := make([]*models.ServiceViewer, len(services))
+	for i, service := range services {
+		role := roles[service.StoreID]
+		viewers[i] = &models.ServiceViewer{StoreRole: role.role, CanEdit: role.canEdit, Booked: slices.Contains(booked, service.ID)}
+	}
+	return viewers, nil
+}
+
+// Store relates a store to user, and which of products, the storefront
+// page shown, they have bought
+func (v *Viewers) Store(ctx context.Context, user *models.User, storeID uint, products []models.Product) (*models.StoreViewer, error) {
+	role, canEdit, err := v.member(ctx, user, storeID)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := v.orders.CountByUserInStore(ctx, user.ID, storeID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+	purchased, err := v.orders.Purchased(ctx, user.ID, ids)
+	if err != nil {
+		return nil, err
+	}
+	return &models.StoreViewer{StoreRole: role, CanEdit: canEdit, Orders: orders, PurchasedProductIDs: purchased}, nil
+}
+
+// storeRole is a user's role in a store, empty when they are not an active
+// member, and whether it lets them edit the store's catalog
+type storeRole struct {
+	role    models.StoreRole
+	canEdit bool
+}
+
+// members looks up the user's role in each of storeIDs once
+func (v *Viewers) members(ctx context.Context, user *models.User, storeIDs []uint) (map[uint]storeRole, error) {
+	roles := map[uint]storeRole{}
+	for _, storeID := range storeIDs {
+		if _, ok := roles[storeID]; ok {
+			continue
+		}
+		role, canEdit, err := v.member(ctx, user, storeID)
+		if err != nil {
+			return nil, err
+		}
+		roles[storeID] = storeRole{role: role, canEdit: canEdit}
+	}
+	return roles, nil
+}
+
+// member returns the user's role in the store, empty when they are not an
+// active member, and whether it lets them edit the store's catalog
+func (v *Viewers) member(ctx context.Context, user *models.User, storeID uint) (models.StoreRole, bool, error) {
+	member, err := v.stores.ActiveMember(ctx, storeID, user.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return member.Role, rbac.StoreCan(member.Role, rbac.StorePermissionCatalog), nil
+}
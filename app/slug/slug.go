@@ -0,0 +1,28 @@
+package slug
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Make turns a name into a lowercase, hyphen separated URL slug, e.g.
+// "Men's Shoes & Bags" becomes "mens-shoes-bags"
+func Make(name string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r == '\'' || r == '’':
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+		default:
+			hyphen = true
+		}
+	}
+	return b.String()
+}
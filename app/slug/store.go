@@ -0,0 +1,43 @@
+package slug
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+const (
+	MinStoreURLLength = 3
+	MaxStoreURLLength = 50
+)
+
+// reserved store URLs clash with pages and routes of the app itself
+var reserved = map[string]bool{
+	"about": true, "account": true, "admin": true, "api": true, "app": true,
+	"auth": true, "bookings": true, "cart": true, "categories": true, "checkout": true,
+	"dashboard": true, "docs": true, "help": true, "login": true, "logout": true,
+	"orders": true, "privacy": true, "products": true, "register": true, "search": true,
+	"services": true, "settings": true, "signin": true, "signup": true, "static": true,
+	"storefronts": true, "stores": true, "support": true, "swagger": true, "terms": true,
+	"users": true, "vendor": true, "vendors": true, "webhooks": true, "whatsapp": true,
+	"www": true,
+}
+
+var storeURLPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// StoreURL normalizes a store URL by trimming it and lowercasing it, then
+// checks it is 3 to 50 letters, digits and single hyphens that neither start
+// nor end with a hyphen, and not a word reserved for the app's own pages
+func StoreURL(raw string) (string, error) {
+	url := strings.ToLower(strings.TrimSpace(raw))
+
+	switch {
+	case len(url) < MinStoreURLLength || len(url) > MaxStoreURLLength:
+		return url, errors.New("store URL must be 3 to 50 characters long")
+	case !storeURLPattern.MatchString(url):
+		return url, errors.New("store URL may only contain letters, digits and single hyphens, and cannot start or end with a hyphen")
+	case reserved[url]:
+		return url, errors.New("this store URL is reserved")
+	}
+	return url, nil
+}
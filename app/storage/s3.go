@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	s3DefaultEndpoint = "https://s3.amazonaws.com"
+	s3DefaultRegion   = "us-east-1"
+	amzDateLayout     = "20060102T150405Z"
+)
+
+// S3 stores files in a bucket of an S3 compatible service such as AWS S3,
+// MinIO, Cloudflare R2 or DigitalOcean Spaces. Buckets are addressed by path
+// so any endpoint works, and objects must be made readable by a bucket
+// policy. PublicURL is where objects are served from, the bucket's URL on the
+// endpoint when empty.
+type S3 struct {
+	Endpoint   string
+	Region     string
+	Bucket     string
+	AccessKey  string
+	SecretKey  string
+	PublicURL  string
+	HTTPClient *http.Client
+}
+
+// NewS3 creates an S3 storage. An empty endpoint uses AWS and an empty
+// region us-east-1.
+func NewS3(endpoint, region, bucket, accessKey, secretKey, publicURL string) *S3 {
+	if endpoint == "" {
+		endpoint = s3DefaultEndpoint
+	}
+	if region == "" {
+		region = s3DefaultRegion
+	}
+	endpoint = strings.TrimRight(endpoint, "/")
+	if publicURL == "" {
+		publicURL = endpoint + "/" + bucket
+	}
+	return &S3{
+		Endpoint:   endpoint,
+		Region:     region,
+		Bucket:     bucket,
+		AccessKey:  accessKey,
+		SecretKey:  secretKey,
+		PublicURL:  publicURL,
+		HTTPClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+func (s *S3) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	return s.do(ctx, http.MethodPut, key, data, contentType)
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	return s.do(ctx, http.MethodDelete, key, nil, "")
+}
+
+func (s *S3) URL(key string) string {
+	return joinURL(s.PublicURL, escapePath(key))
+}
+
+func (s *S3) do(ctx context.Context, method, key string, body []byte, contentType string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+
+	endpoint, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return err
+	}
+	objectPath := strings.TrimRight(endpoint.Path, "/") + "/" + s.Bucket + "/" + key
+	target := endpoint.Scheme + "://" + endpoint.Host + escapePath(objectPath)
+
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s.sign(req, body, time.Now().UTC())
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s %s: %d %s", method, key, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to req
+func (s *S3) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256Hex(body)
+	amzDate := now.Format(amzDateLayout)
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// Headers are signed in sorted order, host comes from the request URL
+	headers := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	values := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		headers = append([]string{"content-type"}, headers...)
+		values["content-type"] = contentType
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range headers {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(values[name]) + "\n")
+	}
+	signedHeaders := strings.Join(headers, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature))
+}
+
+// escapePath percent-encodes every byte of path except unreserved
+// characters and slashes, as Signature Version 4 expects
+func escapePath(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c == '/' || c == '-' || c == '_' || c == '.' || c == '~' ||
+			('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
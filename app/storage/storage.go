@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrInvalidKey is returned for keys that are empty, absolute or climb out
+// of the storage root
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Storage keeps uploaded files under slash separated keys such as
+// stores/1/4f9c/thumb.webp and serves them from public URLs
+type Storage interface {
+	// Put stores data under key, replacing what was there
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Delete removes key. Deleting a key that does not exist is not an error.
+	Delete(ctx context.Context, key string) error
+	// URL is the public URL of key
+	URL(key string) string
+}
+
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return ErrInvalidKey
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
+
+func joinURL(base, key string) string {
+	return strings.TrimRight(base, "/") + "/" + key
+}
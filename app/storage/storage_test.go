@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLocalPutAndDelete(t *testing.T) {
+	dir := t.TempDir()
+	local := NewLocal(dir, "/media/")
+	ctx := context.Background()
+
+	if err := local.Put(ctx, "stores/1/abc/thumb.jpg", []byte("image"), "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "stores", "1", "abc", "thumb.jpg"))
+	if err != nil || string(data) != "image" {
+		t.Fatalf("stored file = %q, %v", data, err)
+	}
+	if got := local.URL("stores/1/abc/thumb.jpg"); got != "/media/stores/1/abc/thumb.jpg" {
+		t.Errorf("URL = %q", got)
+	}
+
+	if err := local.Delete(ctx, "stores/1/abc/thumb.jpg"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "stores", "1", "abc", "thumb.jpg")); !os.IsNotExist(err) {
+		t.Errorf("file still exists after Delete: %v", err)
+	}
+	if err := local.Delete(ctx, "stores/1/abc/thumb.jpg"); err != nil {
+		t.Errorf("deleting a missing key: %v", err)
+	}
+}
+
+func TestLocalRejectsKeysOutsideDir(t *testing.T) {
+	local := NewLocal(t.TempDir(), "/media")
+	for _, key := range []string{"", "/etc/passwd", "../secret", "stores/../../secret", "stores//x", `stores\x`} {
+		if err := local.Put(context.Background(), key, []byte("x"), ""); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) = %v, want ErrInvalidKey", key, err)
+		}
+	}
+}
+
+// s3Stub is a minimal S3 compatible server, in the spirit of a local MinIO,
+// that keeps objects in memory
+type s3Stub struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func (s *s3Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=minio/") || !strings.Contains(auth, "/us-east-1/s3/aws4_request") ||
+		!strings.Contains(auth, "SignedHeaders=") || !strings.Contains(auth, "Signature=") {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	sum := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		http.Error(w, "XAmzContentSHA256Mismatch", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		s.objects[r.URL.Path] = body
+		s.types[r.URL.Path] = r.Header.Get("Content-Type")
+	case http.MethodDelete:
+		delete(s.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3PutAndDelete(t *testing.T) {
+	stub := &s3Stub{objects: map[string][]byte{}, types: map[string]string{}}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	s3 := NewS3(server.URL, "", "media", "minio", "minio-secret", "")
+	ctx := context.Background()
+
+	if err := s3.Put(ctx, "stores/1/abc/thumb.webp", []byte("webp"), "image/webp"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got := string(stub.objects["/media/stores/1/abc/thumb.webp"]); got != "webp" {
+		t.Errorf("stored object = %q", got)
+	}
+	if got := stub.types["/media/stores/1/abc/thumb.webp"]; got != "image/webp" {
+		t.Errorf("stored content type = %q", got)
+	}
+	if got := s3.URL("stores/1/abc/thumb.webp"); got != server.URL+"/media/stores/1/abc/thumb.webp" {
+		t.Errorf("URL = %q", got)
+	}
+
+	if err := s3.Delete(ctx, "stores/1/abc/thumb.webp"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok := stub.objects["/media/stores/1/abc/thumb.webp"]; ok {
+		t.Error("object still exists after Delete")
+	}
+}
+
+func TestS3ReportsErrors(t *testing.T) {
+	stub := &s3Stub{objects: map[string][]byte{}, types: map[string]string{}}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	s3 := NewS3(server.URL, "", "media", "someone-else", "secret", "https://cdn.example.com")
+	if err := s3.Put(context.Background(), "stores/1/a.jpg", []byte("x"), "image/jpeg"); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Put with the wrong credentials = %v, want a 403 error", err)
+	}
+	if got := s3.URL("stores/1/a b.jpg"); got != "https://cdn.example.com/stores/1/a%20b.jpg" {
+		t.Errorf("URL = %q", got)
+	}
+}
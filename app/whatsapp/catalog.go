@@ -0,0 +1,112 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// CatalogItem is a product as the Meta catalog batch API expects it. ID is
+// our own retailer ID for the product.
+type CatalogItem struct {
+	ID                  string   `json:"id"`
+	Title               string   `json:"title,omitempty"`
+	Description         string   `json:"description,omitempty"`
+	Availability        string   `json:"availability,omitempty"` // "in stock" or "out of stock"
+	Condition           string   `json:"condition,omitempty"`
+	Price               string   `json:"price,omitempty"` // e.g. "2500.00 NGN"
+	Link                string   `json:"link,omitempty"`
+	ImageLink           string   `json:"image_link,omitempty"`
+	AdditionalImageLink []string `json:"additional_image_link,omitempty"`
+	Brand               string   `json:"brand,omitempty"`
+}
+
+// Catalog batch request methods
+const (
+	CatalogUpdate = "UPDATE" // Creates the item if it does not exist yet
+	CatalogDelete = "DELETE"
+)
+
+// CatalogRequest is one change in a catalog batch
+type CatalogRequest struct {
+	Method string      `json:"method"`
+	Data   CatalogItem `json:"data"`
+}
+
+// CatalogBatchStatus reports how far a catalog batch has got. Errors are
+// keyed by retailer ID.
+type CatalogBatchStatus struct {
+	Finished bool
+	Errors   map[string]string
+}
+
+// CatalogBatch submits product changes to a catalog and returns the batch
+// handles to check their outcome with
+func (c *Client) CatalogBatch(ctx context.Context, catalogID string, requests []CatalogRequest) ([]string, error) {
+	var result struct {
+		Handles []string `json:"handles"`
+	}
+	err := c.do(ctx, http.MethodPost, "/"+catalogID+"/items_batch", map[string]interface{}{
+		"item_type":    "PRODUCT_ITEM",
+		"allow_upsert": true,
+		"requests":     requests,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result.Handles, nil
+}
+
+// CatalogBatchStatus checks on a batch submitted with CatalogBatch
+func (c *Client) CatalogBatchStatus(ctx context.Context, catalogID, handle string) (*CatalogBatchStatus, error) {
+	var result struct {
+		Data []struct {
+			Status string `json:"status"`
+			Errors []struct {
+				ID      string `json:"id"`
+				Message string `json:"message"`
+			} `json:"errors"`
+		} `json:"data"`
+	}
+	path := "/" + catalogID + "/check_batch_request_status?handle=" + url.QueryEscape(handle)
+	if err := c.do(ctx, http.MethodGet, path, nil, &result); err != nil {
+		return nil, err
+	}
+
+	status := &CatalogBatchStatus{Errors: map[string]string{}}
+	for _, data := range result.Data {
+		status.Finished = data.Status == "finished"
+		for _, itemErr := range data.Errors {
+			status.Errors[itemErr.ID] = itemErr.Message
+		}
+	}
+	return status, nil
+}
+
+// CatalogProductIDs looks up the catalog's own IDs for products by retailer ID
+func (c *Client) CatalogProductIDs(ctx context.Context, catalogID string, retailerIDs []string) (map[string]string, error) {
+	filter, err := json.Marshal(map[string]interface{}{
+		"retailer_id": map[string]interface{}{"is_any": retailerIDs},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Data []struct {
+			ID         string `json:"id"`
+			RetailerID string `json:"retailer_id"`
+		} `json:"data"`
+	}
+	path := "/" + catalogID + "/products?fields=id,retailer_id&limit=1000&filter=" + url.QueryEscape(string(filter))
+	if err := c.do(ctx, http.MethodGet, path, nil, &result); err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]string, len(result.Data))
+	for _, product := range result.Data {
+		ids[product.RetailerID] = product.ID
+	}
+	return ids, nil
+}
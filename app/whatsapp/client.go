@@ -0,0 +1,184 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const graphBaseURL = "https://graph.facebook.com/v19.0"
+
+// ErrInvalidSignature is returned when an inbound webhook signature does not match
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Client sends messages through the WhatsApp Cloud API
+type Client struct {
+	AccessToken   string
+	PhoneNumberID string
+	AppSecret     string
+	BaseURL       string
+	HTTPClient    *http.Client
+}
+
+// NewClient creates a Cloud API client that sends from phoneNumberID. An
+// empty baseURL uses the live Graph API.
+func NewClient(accessToken, phoneNumberID, appSecret, baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = graphBaseURL
+	}
+	return &Client{
+		AccessToken:   accessToken,
+		PhoneNumberID: phoneNumberID,
+		AppSecret:     appSecret,
+		BaseURL:       strings.TrimRight(baseURL, "/"),
+		HTTPClient:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Template is a pre-approved message template with its body parameters
+type Template struct {
+	Name     string
+	Language string
+	Params   []string
+}
+
+// SendText sends a free-form text message. WhatsApp only delivers these
+// within 24 hours of the recipient's last message.
+func (c *Client) SendText(ctx context.Context, to, body string) (string, error) {
+	return c.send(ctx, map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"recipient_type":    "individual",
+		"to":                PhoneDigits(to),
+		"type":              "text",
+		"text":              map[string]interface{}{"body": body},
+	})
+}
+
+// SendTemplate sends a template message, which can start a conversation
+func (c *Client) SendTemplate(ctx context.Context, to string, template Template) (string, error) {
+	parameters := make([]map[string]string, 0, len(template.Params))
+	for _, param := range template.Params {
+		parameters = append(parameters, map[string]string{"type": "text", "text": param})
+	}
+
+	tmpl := map[string]interface{}{
+		"name":     template.Name,
+		"language": map[string]string{"code": template.Language},
+	}
+	if len(parameters) > 0 {
+		tmpl["components"] = []map[string]interface{}{
+			{"type": "body", "parameters": parameters},
+		}
+	}
+
+	return c.send(ctx, map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"recipient_type":    "individual",
+		"to":                PhoneDigits(to),
+		"type":              "template",
+		"template":          tmpl,
+	})
+}
+
+// send posts a message and returns its WhatsApp message ID
+func (c *Client) send(ctx context.Context, body interface{}) (string, error) {
+	var result struct {
+		Messages []struct {
+			ID string `json:"id"`
+		} `json:"messages"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/"+c.PhoneNumberID+"/messages", body, &result); err != nil {
+		return "", err
+	}
+	if len(result.Messages) == 0 {
+		return "", errors.New("no message ID in response")
+	}
+	return result.Messages[0].ID, nil
+}
+
+// do sends a request to the Graph API and decodes the JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	url := c.BaseURL + path
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.AccessToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		_ = json.Unmarshal(respBody, &apiErr)
+		if apiErr.Error.Message == "" {
+			apiErr.Error.Message = http.StatusText(resp.StatusCode)
+		}
+		return fmt.Errorf("%s %s: %d %s", method, url, resp.StatusCode, apiErr.Error.Message)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+// VerifySignature checks the X-Hub-Signature-256 header of an inbound
+// webhook, an HMAC-SHA256 of the raw body keyed with the app secret
+func (c *Client) VerifySignature(signature string, body []byte) error {
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || c.AppSecret == "" {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(c.AppSecret))
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// PhoneDigits strips a phone number down to its digits, the form WhatsApp
+// uses for recipient and sender IDs
+func PhoneDigits(phone string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+}
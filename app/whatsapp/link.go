@@ -0,0 +1,142 @@
+package whatsapp
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+	"github.com/theHoracle/whatstore-api/app/models"
+)
+
+// DefaultOrderTemplate is used for stores that have not set their own message template
+const DefaultOrderTemplate = `Hello {{store_name}}, I'd like to place an order.
+
+Order: {{order_number}}
+{{items}}
+
+Total: {{currency}} {{total}}
+
+Name: {{buyer_name}}
+Delivery address: {{delivery_address}}`
+
+// Placeholders lists what can be used in an order message template
+var Placeholders = []string{
+	"order_number",
+	"store_name",
+	"buyer_name",
+	"delivery_address",
+	"items",
+	"total",
+	"currency",
+}
+
+var placeholderRegex = regexp.MustCompile(`{{\s*([a-z_]+)\s*}}`)
+
+// LineItem is one product line of an order message
+type LineItem struct {
+	Name      string
+	Quantity  int
+	UnitPrice float64
+}
+
+// OrderMessage holds the values substituted into an order message template
+type OrderMessage struct {
+	OrderNumber     string
+	StoreName       string
+	BuyerName       string
+	DeliveryAddress string
+	Currency        string
+	Items           []LineItem
+}
+
+// ItemName names a product line, with the variant's option values if any
+func ItemName(product *models.Product, variant *models.ProductVariant) string {
+	if variant == nil || variant.Title == "" {
+		return product.Name
+	}
+	return product.Name + " (" + variant.Title + ")"
+}
+
+func (m OrderMessage) total() float64 {
+	var total float64
+	for _, item := range m.Items {
+		total += item.UnitPrice * float64(item.Quantity)
+	}
+	return total
+}
+
+func (m OrderMessage) values() map[string]string {
+	lines := make([]string, 0, len(m.Items))
+	for _, item := range m.Items {
+		lines = append(lines, fmt.Sprintf("- %d x %s @ %s %.2f", item.Quantity, item.Name, m.Currency, item.UnitPrice))
+	}
+
+	return map[string]string{
+		"order_number":     m.OrderNumber,
+		"store_name":       m.StoreName,
+		"buyer_name":       m.BuyerName,
+		"delivery_address": m.DeliveryAddress,
+		"items":            strings.Join(lines, "\n"),
+		"total":            fmt.Sprintf("%.2f", m.total()),
+		"currency":         m.Currency,
+	}
+}
+
+// ValidateTemplate checks that a template only uses known placeholders
+func ValidateTemplate(template string) error {
+	known := map[string]bool{}
+	for _, p := range Placeholders {
+		known[p] = true
+	}
+
+	for _, match := range placeholderRegex.FindAllStringSubmatch(template, -1) {
+		if !known[match[1]] {
+			return fmt.Errorf("unknown placeholder {{%s}}", match[1])
+		}
+	}
+	return nil
+}
+
+// RenderMessage fills a template's placeholders from msg. An empty template
+// falls back to DefaultOrderTemplate.
+func RenderMessage(template string, msg OrderMessage) string {
+	if strings.TrimSpace(template) == "" {
+		template = DefaultOrderTemplate
+	}
+
+	values := msg.values()
+	return placeholderRegex.ReplaceAllStringFunc(template, func(placeholder string) string {
+		name := placeholderRegex.FindStringSubmatch(placeholder)[1]
+		if value, ok := values[name]; ok {
+			return value
+		}
+		return placeholder
+	})
+}
+
+// ClickToChatURL builds a wa.me link that opens a chat with phone, an E.164
+// number, with message pre-filled
+func ClickToChatURL(phone, message string) string {
+	number := strings.TrimPrefix(phone, "+")
+	// WhatsApp does not read "+" as a space, so escape spaces as %20
+	text := strings.ReplaceAll(url.QueryEscape(message), "+", "%20")
+	return "https://wa.me/" + number + "?text=" + text
+}
+
+// qrCapacity is the most bytes the largest QR code holds at the Medium
+// recovery level
+const qrCapacity = 2331
+
+// ErrLinkTooLong is returned for links too long to fit in a QR code
+var ErrLinkTooLong = errors.New("link is too long for a QR code")
+
+// QRCode renders link as a square PNG QR code of size pixels
+func QRCode(link string, size int) ([]byte, error) {
+	if len(link) > qrCapacity {
+		return nil, ErrLinkTooLong
+	}
+	return qrcode.Encode(link, qrcode.Medium, size)
+}
@@ -0,0 +1,22 @@
+package whatsapp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestQRCode(t *testing.T) {
+	link := ClickToChatURL("2348012345678", "Hello")
+	if png, err := QRCode(link, 256); err != nil || len(png) == 0 {
+		t.Fatalf("QR code of a short link: %d bytes, %v", len(png), err)
+	}
+
+	// The largest code holds the longest link, and nothing longer
+	if _, err := QRCode(strings.Repeat("a", qrCapacity), 256); err != nil {
+		t.Errorf("QR code at capacity: %v", err)
+	}
+	if _, err := QRCode(strings.Repeat("a", qrCapacity+1), 256); !errors.Is(err, ErrLinkTooLong) {
+		t.Errorf("QR code over capacity: %v", err)
+	}
+}
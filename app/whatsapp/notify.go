@@ -0,0 +1,265 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/app/orderflow"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxSendAttempts = 5
+
+// ErrUnknownSender is returned when a command comes from a number that is
+// neither the order's store nor its buyer
+var ErrUnknownSender = errors.New("sender is not a party to the order")
+
+const vendorOrderTemplate = `New order {{order_number}} from {{buyer_name}}
+{{items}}
+
+Total: {{currency}} {{total}}
+Delivery address: {{delivery_address}}`
+
+// Notifier queues WhatsApp messages for order events. Vendors hear about new
+// orders and both sides hear about status changes the other side made.
+//
+// Business-initiated messages must use approved templates outside the 24 hour
+// customer service window. When a template name is set it is sent with these
+// body parameters, otherwise the message goes out as free text:
+//   - NewOrderTemplate: order number, buyer name, items, total
+//   - StatusTemplate: order number, store name, status
+type Notifier struct {
+	NewOrderTemplate string
+	StatusTemplate   string
+	Language         string
+}
+
+// OrderCreated is an orderflow hook that tells the store about a new order
+func (n *Notifier) OrderCreated(tx *gorm.DB, order *models.Order, change *models.OrderStatusHistory) error {
+	var full models.Order
+	if err := tx.Preload("Items.Product").Preload("Items.Variant").Preload("Store").Preload("User.UserDetails").First(&full, order.ID).Error; err != nil {
+		return err
+	}
+
+	msg := OrderMessage{
+		OrderNumber: fmt.Sprintf("#%d", full.ID),
+		StoreName:   full.Store.Name,
+		BuyerName:   full.User.Name,
+		Currency:    "NGN",
+	}
+	if full.User.UserDetails != nil {
+		msg.DeliveryAddress = full.User.UserDetails.ShippingAddress
+	}
+	for _, item := range full.Items {
+		if item.Product.Currency != "" {
+			msg.Currency = item.Product.Currency
+		}
+		msg.Items = append(msg.Items, LineItem{
+			Name:      ItemName(&item.Product, item.Variant),
+			Quantity:  item.Quantity,
+			UnitPrice: item.Price,
+		})
+	}
+
+	values := msg.values()
+	body := RenderMessage(vendorOrderTemplate, msg) +
+		fmt.Sprintf("\n\nReply CONFIRM %d to accept or REJECT %d to decline.", full.ID, full.ID)
+
+	return n.queue(tx, &full.ID, full.Store.StoreWhatsappContact, n.NewOrderTemplate,
+		[]string{values["order_number"], values["buyer_name"], values["items"], values["currency"] + " " + values["total"]}, body)
+}
+
+// OrderChanged is an orderflow hook that tells the buyer and the store about
+// a status change unless they made it themselves
+func (n *Notifier) OrderChanged(tx *gorm.DB, order *models.Order, change *models.OrderStatusHistory) error {
+	var full models.Order
+	if err := tx.Preload("Store").Preload("User").First(&full, order.ID).Error; err != nil {
+		return err
+	}
+
+	number := fmt.Sprintf("#%d", full.ID)
+	params := []string{number, full.Store.Name, string(change.ToStatus)}
+	note := ""
+	if change.Note != "" {
+		note = "\n" + change.Note
+	}
+
+	if change.ActorRole != models.OrderActorBuyer {
+		body := fmt.Sprintf("Your order %s from %s is now %s.%s", number, full.Store.Name, change.ToStatus, note)
+		if change.ToStatus == models.OrderStatusShipped {
+			body += fmt.Sprintf("\n\nReply RECEIVED %d once it arrives.", full.ID)
+		}
+		if err := n.queue(tx, &full.ID, full.User.PhoneNumber, n.StatusTemplate, params, body); err != nil {
+			return err
+		}
+	}
+
+	if change.ActorRole != models.OrderActorVendor {
+		body := fmt.Sprintf("Order %s is now %s.%s", number, change.ToStatus, note)
+		if err := n.queue(tx, &full.ID, full.Store.StoreWhatsappContact, n.StatusTemplate, params, body); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (n *Notifier) queue(tx *gorm.DB, orderID *uint, to, template string, params []string, body string) error {
+	if PhoneDigits(to) == "" {
+		return nil
+	}
+
+	msg := models.WhatsappMessage{
+		OrderID: orderID,
+		To:      to,
+		Body:    body,
+		Status:  models.WhatsappMessageQueued,
+	}
+	if template != "" {
+		msg.Template = template
+		msg.Language = n.Language
+		msg.Params = params
+	}
+	return tx.Create(&msg).Error
+}
+
+// Reply queues a free text message, for answering someone who has just
+// written to the business number
+func Reply(db *gorm.DB, orderID *uint, to, body string) error {
+	return (&Notifier{}).queue(db, orderID, to, "", nil, body)
+}
+
+// Receive records an inbound message by its WhatsApp ID and reports whether
+// it is new. A message already received, from an earlier delivery of the
+// same webhook, reports false and must not be acted on again.
+func Receive(db *gorm.DB, msg InboundMessage) (bool, error) {
+	if msg.ID == "" {
+		return true, nil
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.WhatsappInboundMessage{MessageID: msg.ID, From: msg.From})
+	return result.RowsAffected == 1, result.Error
+}
+
+// ApplyCommand carries out an order command sent from the phone number from.
+// The store's number acts as the vendor and the buyer's number as the buyer.
+func ApplyCommand(db *gorm.DB, from string, cmd *Command) (*models.Order, error) {
+	var order models.Order
+	if err := db.Preload("Store").Preload("User").First(&order, cmd.OrderID).Error; err != nil {
+		return nil, err
+	}
+
+	var actor models.OrderActor
+	var actorID uint
+	switch PhoneDigits(from) {
+	case PhoneDigits(order.Store.StoreWhatsappContact):
+		var vendor models.Vendor
+		if err := db.First(&vendor, order.Store.VendorID).Error; err != nil {
+			return nil, err
+		}
+		actor, actorID = models.OrderActorVendor, vendor.UserID
+	case PhoneDigits(order.User.PhoneNumber):
+		actor, actorID = models.OrderActorBuyer, order.UserID
+	default:
+		return nil, ErrUnknownSender
+	}
+
+	if err := orderflow.Transition(db, &order, cmd.Status, actor, &actorID, "Sent over WhatsApp"); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// UpdateStatuses records delivery updates for sent messages
+func UpdateStatuses(db *gorm.DB, statuses []MessageStatus) error {
+	for _, status := range statuses {
+		updates := map[string]interface{}{}
+		switch status.Status {
+		case "sent":
+			continue
+		case "delivered":
+			updates["status"] = models.WhatsappMessageDelivered
+		case "read":
+			updates["status"] = models.WhatsappMessageRead
+		case "failed":
+			updates["status"] = models.WhatsappMessageFailed
+			updates["error"] = status.Error
+		default:
+			continue
+		}
+
+		err := db.Model(&models.WhatsappMessage{}).
+			Where("provider_message_id = ?", status.MessageID).
+			Updates(updates).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run periodically sends queued messages through client
+func Run(db *gorm.DB, client *Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		sendQueued(db, client)
+	}
+}
+
+func sendQueued(db *gorm.DB, client *Client) {
+	var messages []models.WhatsappMessage
+	err := db.Where("status = ?", models.WhatsappMessageQueued).Order("id").Limit(100).Find(&messages).Error
+	if err != nil {
+		log.Printf("Warning: could not fetch queued WhatsApp messages: %v", err)
+		return
+	}
+
+	for i := range messages {
+		if err := send(db, client, &messages[i]); err != nil {
+			log.Printf("Warning: could not send WhatsApp message %d: %v", messages[i].ID, err)
+		}
+	}
+}
+
+func send(db *gorm.DB, client *Client, msg *models.WhatsappMessage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var providerID string
+	var sendErr error
+	if msg.Template != "" {
+		providerID, sendErr = client.SendTemplate(ctx, msg.To, Template{
+			Name:     msg.Template,
+			Language: msg.Language,
+			Params:   msg.Params,
+		})
+	} else {
+		providerID, sendErr = client.SendText(ctx, msg.To, msg.Body)
+	}
+
+	msg.Attempts++
+	if sendErr != nil {
+		msg.Error = sendErr.Error()
+		if msg.Attempts >= maxSendAttempts {
+			msg.Status = models.WhatsappMessageFailed
+		}
+	} else {
+		now := time.Now()
+		msg.Status = models.WhatsappMessageSent
+		msg.ProviderMessageID = providerID
+		msg.Error = ""
+		msg.SentAt = &now
+	}
+
+	if err := db.Save(msg).Error; err != nil {
+		return err
+	}
+	return sendErr
+}
@@ -0,0 +1,125 @@
+package whatsapp
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/theHoracle/whatstore-api/app/models"
+)
+
+// InboundMessage is a text message sent to the business number
+type InboundMessage struct {
+	ID   string
+	From string
+	Text string
+}
+
+// MessageStatus is a delivery update for a message the business number sent
+type MessageStatus struct {
+	MessageID string
+	Status    string
+	Error     string
+}
+
+// WebhookEvent is what a Cloud API webhook delivery carries
+type WebhookEvent struct {
+	Messages []InboundMessage
+	Statuses []MessageStatus
+}
+
+// ParseWebhook reads the messages and delivery statuses out of a Cloud API
+// webhook body. Messages other than text are skipped.
+func ParseWebhook(body []byte) (*WebhookEvent, error) {
+	var payload struct {
+		Entry []struct {
+			Changes []struct {
+				Field string `json:"field"`
+				Value struct {
+					Messages []struct {
+						ID   string `json:"id"`
+						From string `json:"from"`
+						Type string `json:"type"`
+						Text struct {
+							Body string `json:"body"`
+						} `json:"text"`
+					} `json:"messages"`
+					Statuses []struct {
+						ID     string `json:"id"`
+						Status string `json:"status"`
+						Errors []struct {
+							Title string `json:"title"`
+						} `json:"errors"`
+					} `json:"statuses"`
+				} `json:"value"`
+			} `json:"changes"`
+		} `json:"entry"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	event := &WebhookEvent{}
+	for _, entry := range payload.Entry {
+		for _, change := range entry.Changes {
+			if change.Field != "messages" {
+				continue
+			}
+			for _, msg := range change.Value.Messages {
+				if msg.Type != "text" {
+					continue
+				}
+				event.Messages = append(event.Messages, InboundMessage{
+					ID:   msg.ID,
+					From: msg.From,
+					Text: msg.Text.Body,
+				})
+			}
+			for _, status := range change.Value.Statuses {
+				update := MessageStatus{MessageID: status.ID, Status: status.Status}
+				if len(status.Errors) > 0 {
+					update.Error = status.Errors[0].Title
+				}
+				event.Statuses = append(event.Statuses, update)
+			}
+		}
+	}
+	return event, nil
+}
+
+// commands maps the keyword of an order command to the status it moves the order to
+var commands = map[string]models.OrderStatus{
+	"CONFIRM":  models.OrderStatusConfirmed,
+	"REJECT":   models.OrderStatusRejected,
+	"PROCESS":  models.OrderStatusProcessing,
+	"SHIP":     models.OrderStatusShipped,
+	"RECEIVED": models.OrderStatusDelivered,
+	"CANCEL":   models.OrderStatusCancelled,
+}
+
+// Command is an order status change requested over WhatsApp, e.g. "CONFIRM 123"
+type Command struct {
+	OrderID uint
+	Status  models.OrderStatus
+}
+
+// ParseCommand reads an order command from a message. Order numbers may be
+// written with a leading "#".
+func ParseCommand(text string) (*Command, bool) {
+	fields := strings.Fields(text)
+	if len(fields) != 2 {
+		return nil, false
+	}
+
+	status, ok := commands[strings.ToUpper(fields[0])]
+	if !ok {
+		return nil, false
+	}
+
+	id, err := strconv.ParseUint(strings.TrimPrefix(fields[1], "#"), 10, 64)
+	if err != nil || id == 0 {
+		return nil, false
+	}
+
+	return &Command{OrderID: uint(id), Status: status}, true
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/theHoracle/whatstore-api/app/auth"
+	"github.com/theHoracle/whatstore-api/app/models"
+	"github.com/theHoracle/whatstore-api/db/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const tokenUsage = `usage: whatstore-api token <user-id> [ttl]
+
+Prints a token signing requests in as the user whose clerk_id is user-id,
+valid for ttl (default 24h). The user is created as a buyer when missing.
+Needs AUTH_PROVIDER=local with AUTH_LOCAL_SECRET or AUTH_LOCAL_PRIVATE_KEY_FILE.`
+
+// authenticator checks bearer tokens the way AUTH_PROVIDER says: clerk,
+// oidc or local. Without it, Clerk is used when CLERK_SECRET_KEY is set and
+// locally issued tokens when a local secret or key is.
+func authenticator() auth.Authenticator {
+	provider := os.Getenv("AUTH_PROVIDER")
+	if provider == "" {
+		switch {
+		case os.Getenv("CLERK_SECRET_KEY") != "":
+			provider = "clerk"
+		case os.Getenv("AUTH_LOCAL_SECRET") != "", os.Getenv("AUTH_LOCAL_PUBLIC_KEY_FILE") != "", os.Getenv("AUTH_LOCAL_PRIVATE_KEY_FILE") != "":
+			provider = "local"
+		default:
+			log.Fatal("No authentication configured, set CLERK_SECRET_KEY, AUTH_PROVIDER=oidc with AUTH_OIDC_ISSUER and AUTH_OIDC_AUDIENCE, or AUTH_PROVIDER=local with AUTH_LOCAL_SECRET")
+		}
+	}
+
+	switch provider {
+	case "clerk":
+		key := os.Getenv("CLERK_SECRET_KEY")
+		if key == "" {
+			log.Fatal("Clerk secret key not set")
+		}
+		log.Println("Authenticating with Clerk")
+		return auth.NewClerk(key)
+
+	case "oidc":
+		issuer, audience := os.Getenv("AUTH_OIDC_ISSUER"), os.Getenv("AUTH_OIDC_AUDIENCE")
+		if issuer == "" || audience == "" {
+			log.Fatal("AUTH_OIDC_ISSUER and AUTH_OIDC_AUDIENCE must both be set")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		oidc, err := auth.NewOIDC(ctx, issuer, audience, os.Getenv("AUTH_OIDC_JWKS_URL"))
+		if err != nil {
+			log.Fatalf("Could not set up OIDC authentication: %v", err)
+		}
+		log.Println("Authenticating with OIDC tokens from " + issuer)
+		return oidc
+
+	case "local":
+		local, err := localAuthenticator()
+		if err != nil {
+			log.Fatalf("Could not set up local authentication: %v", err)
+		}
+		log.Println("Authenticating with locally issued tokens, run `whatstore-api token <user-id>` to get one")
+		return local
+	}
+
+	log.Fatalf("Unknown AUTH_PROVIDER %q, use clerk, oidc or local", provider)
+	return nil
+}
+
+// localAuthenticator signs with AUTH_LOCAL_SECRET, or with the RSA keys in
+// AUTH_LOCAL_PRIVATE_KEY_FILE and AUTH_LOCAL_PUBLIC_KEY_FILE
+func localAuthenticator() (*auth.Local, error) {
+	issuer := os.Getenv("AUTH_LOCAL_ISSUER")
+	if secret := os.Getenv("AUTH_LOCAL_SECRET"); secret != "" {
+		return auth.NewLocalHS256(issuer, []byte(secret))
+	}
+
+	publicFile, privateFile := os.Getenv("AUTH_LOCAL_PUBLIC_KEY_FILE"), os.Getenv("AUTH_LOCAL_PRIVATE_KEY_FILE")
+	if publicFile == "" && privateFile == "" {
+		return nil, errors.New("set AUTH_LOCAL_SECRET, AUTH_LOCAL_PRIVATE_KEY_FILE or AUTH_LOCAL_PUBLIC_KEY_FILE")
+	}
+	var err error
+	var public *rsa.PublicKey
+	if publicFile != "" {
+		if public, err = readKey(publicFile, auth.ParseRSAPublicKey); err != nil {
+			return nil, err
+		}
+	}
+	var private *rsa.PrivateKey
+	if privateFile != "" {
+		if private, err = readKey(privateFile, auth.ParseRSAPrivateKey); err != nil {
+			return nil, err
+		}
+	}
+	return auth.NewLocalRS256(issuer, public, private)
+}
+
+func readKey[K any](path string, parse func([]byte) (K, error)) (K, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		var none K
+		return none, err
+	}
+	key, err := parse(data)
+	if err != nil {
+		return key, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// tokenCommand runs the token subcommand with the arguments after "token"
+func tokenCommand(args []string) {
+	if len(args) == 0 || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, tokenUsage)
+		os.Exit(2)
+	}
+	local, err := localAuthenticator()
+	if err != nil {
+		log.Fatalf("Could not set up local authentication: %v", err)
+	}
+	ttl := 24 * time.Hour
+	if len(args) == 2 {
+		if ttl, err = time.ParseDuration(args[1]); err != nil || ttl <= 0 {
+			log.Fatalf("Invalid token lifetime: %s", args[1])
+		}
+	}
+
+	subject := args[0]
+	database.ConnectDB()
+	checkSchema()
+	// Keep the token alone on stdout, e.g. for TOKEN=$(whatstore-api token dev_ada)
+	db := database.DB.Db.Session(&gorm.Session{Logger: logger.Discard})
+	var user models.User
+	err = db.Where("clerk_id = ?", subject).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		username := strings.ToLower(subject)
+		user = models.User{ClerkID: subject, Name: subject, Email: username + "@localhost", Username: username, Role: models.RoleBuyer}
+		if err := db.Create(&user).Error; err != nil {
+			log.Fatalf("Could not create user %s: %v", subject, err)
+		}
+		fmt.Fprintf(os.Stderr, "Created buyer %s (id %d)\n", subject, user.ID)
+	} else if err != nil {
+		log.Fatalf("Could not look up user %s: %v", subject, err)
+	}
+
+	token, err := local.Issue(subject, ttl)
+	if err != nil {
+		log.Fatalf("Could not issue token: %v", err)
+	}
+	fmt.Println(token)
+}
@@ -0,0 +1,39 @@
+-- Columns added to tables after they were first created. Databases set up
+-- before migrations may have those tables without them, which 0001 cannot
+-- adopt as it only creates what is missing, so they are added first. On a
+-- new database there are no tables yet and this changes nothing. The foreign
+-- keys of these columns are added by 0007, once the tables they point at
+-- exist.
+
+ALTER TABLE IF EXISTS users
+    ADD COLUMN IF NOT EXISTS phone_number text,
+    ADD COLUMN IF NOT EXISTS role varchar(20) DEFAULT 'buyer';
+
+ALTER TABLE IF EXISTS stores
+    ADD COLUMN IF NOT EXISTS whatsapp_template text,
+    ADD COLUMN IF NOT EXISTS whatsapp_catalog_id text,
+    ADD COLUMN IF NOT EXISTS timezone text DEFAULT 'Africa/Lagos';
+
+ALTER TABLE IF EXISTS products
+    ADD COLUMN IF NOT EXISTS category_id bigint,
+    ADD COLUMN IF NOT EXISTS search_vector tsvector;
+
+ALTER TABLE IF EXISTS services
+    ADD COLUMN IF NOT EXISTS slot_minutes bigint DEFAULT 60,
+    ADD COLUMN IF NOT EXISTS search_vector tsvector;
+
+ALTER TABLE IF EXISTS orders
+    ADD COLUMN IF NOT EXISTS checkout_group_id bigint;
+
+ALTER TABLE IF EXISTS order_items
+    ADD COLUMN IF NOT EXISTS variant_id bigint;
+
+ALTER TABLE IF EXISTS inventory_ledger
+    ADD COLUMN IF NOT EXISTS variant_id bigint;
+
+ALTER TABLE IF EXISTS payments
+    ADD COLUMN IF NOT EXISTS checkout_group_id bigint,
+    ADD COLUMN IF NOT EXISTS booking_id bigint;
+
+ALTER TABLE IF EXISTS cart_items
+    ADD COLUMN IF NOT EXISTS variant_id bigint;
@@ -0,0 +1,34 @@
+DROP TABLE IF EXISTS
+    media_variants,
+    media,
+    catalog_product_syncs,
+    store_catalog_syncs,
+    whatsapp_messages,
+    cart_items,
+    carts,
+    escrow_ledger_entries,
+    escrow_holds,
+    payments,
+    inventory_ledger,
+    order_status_history,
+    order_items,
+    orders,
+    checkout_groups,
+    bookings,
+    service_blackouts,
+    service_availabilities,
+    services,
+    product_variants,
+    product_options,
+    products,
+    categories,
+    store_domains,
+    store_members,
+    store_url_redirects,
+    stores,
+    vendors,
+    user_details,
+    users;
+
+DROP FUNCTION IF EXISTS products_trigger();
+DROP FUNCTION IF EXISTS services_trigger();
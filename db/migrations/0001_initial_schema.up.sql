@@ -0,0 +1,494 @@
+-- The schema as AutoMigrate and the startup DDL left it. Everything is
+-- created only when missing, so databases set up before migrations adopt it.
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+-- Users and vendors
+
+CREATE TABLE IF NOT EXISTS users (
+    id bigserial PRIMARY KEY,
+    clerk_id text,
+    name text,
+    email text,
+    username text,
+    avatar_url text,
+    phone_number text,
+    role varchar(20) DEFAULT 'buyer',
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_clerk_id ON users (clerk_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (username);
+CREATE INDEX IF NOT EXISTS idx_users_role ON users (role);
+
+CREATE TABLE IF NOT EXISTS user_details (
+    id bigserial PRIMARY KEY,
+    user_id bigint CONSTRAINT fk_users_user_details REFERENCES users (id) ON DELETE SET NULL,
+    preferred_payment text,
+    shipping_address text,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_details_user_id ON user_details (user_id);
+
+CREATE TABLE IF NOT EXISTS vendors (
+    id bigserial PRIMARY KEY,
+    user_id bigint CONSTRAINT fk_users_vendor REFERENCES users (id) ON DELETE SET NULL,
+    is_active boolean,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_vendors_user_id ON vendors (user_id);
+
+-- Stores
+
+CREATE TABLE IF NOT EXISTS stores (
+    id bigserial PRIMARY KEY,
+    vendor_id bigint CONSTRAINT fk_vendors_stores REFERENCES vendors (id),
+    name text,
+    description text,
+    store_logo text,
+    store_url text,
+    store_address text,
+    store_whatsapp_contact text,
+    whatsapp_template text,
+    whatsapp_catalog_id text,
+    timezone text DEFAULT 'Africa/Lagos',
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_stores_vendor_id ON stores (vendor_id);
+
+CREATE TABLE IF NOT EXISTS store_url_redirects (
+    id bigserial PRIMARY KEY,
+    store_url text,
+    store_id bigint CONSTRAINT fk_store_url_redirects_store REFERENCES stores (id) ON DELETE CASCADE,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_store_url_redirects_store_url ON store_url_redirects (store_url);
+CREATE INDEX IF NOT EXISTS idx_store_url_redirects_store_id ON store_url_redirects (store_id);
+
+CREATE TABLE IF NOT EXISTS store_members (
+    id bigserial PRIMARY KEY,
+    store_id bigint CONSTRAINT fk_store_members_store REFERENCES stores (id) ON DELETE CASCADE,
+    email text,
+    user_id bigint CONSTRAINT fk_store_members_user REFERENCES users (id) ON DELETE CASCADE,
+    role varchar(20),
+    status varchar(20),
+    invited_by_id bigint,
+    accepted_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_store_members_email ON store_members (store_id, email);
+CREATE INDEX IF NOT EXISTS idx_store_members_user_id ON store_members (user_id);
+CREATE INDEX IF NOT EXISTS idx_store_members_status ON store_members (status);
+
+CREATE TABLE IF NOT EXISTS store_domains (
+    id bigserial PRIMARY KEY,
+    store_id bigint CONSTRAINT fk_store_domains_store REFERENCES stores (id) ON DELETE CASCADE,
+    domain text,
+    token text,
+    status varchar(20) DEFAULT 'pending',
+    failures bigint,
+    last_error text,
+    last_checked_at timestamptz,
+    verified_at timestamptz,
+    next_check_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_store_domains_store_id ON store_domains (store_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_store_domains_domain ON store_domains (domain);
+CREATE INDEX IF NOT EXISTS idx_store_domains_status ON store_domains (status);
+CREATE INDEX IF NOT EXISTS idx_store_domains_next_check_at ON store_domains (next_check_at);
+
+-- Catalog
+
+CREATE TABLE IF NOT EXISTS categories (
+    id bigserial PRIMARY KEY,
+    parent_id bigint CONSTRAINT fk_categories_parent REFERENCES categories (id) ON DELETE RESTRICT,
+    name text,
+    slug text,
+    icon text,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories (parent_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_slug ON categories (slug);
+
+CREATE TABLE IF NOT EXISTS products (
+    id bigserial PRIMARY KEY,
+    store_id bigint CONSTRAINT fk_stores_products REFERENCES stores (id),
+    name text,
+    description text,
+    images text[],
+    price decimal,
+    currency text DEFAULT 'NGN',
+    stock bigint,
+    category_id bigint CONSTRAINT fk_products_category REFERENCES categories (id) ON DELETE SET NULL,
+    search_vector tsvector,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_products_store_id ON products (store_id);
+CREATE INDEX IF NOT EXISTS idx_products_category_id ON products (category_id);
+CREATE INDEX IF NOT EXISTS idx_products_search ON products USING gin (search_vector);
+CREATE INDEX IF NOT EXISTS products_name_trgm_idx ON products USING gin (name gin_trgm_ops);
+
+CREATE TABLE IF NOT EXISTS product_options (
+    id bigserial PRIMARY KEY,
+    product_id bigint CONSTRAINT fk_products_options REFERENCES products (id) ON DELETE CASCADE,
+    name text,
+    position bigint,
+    "values" text[]
+);
+CREATE INDEX IF NOT EXISTS idx_product_options_product_id ON product_options (product_id);
+
+CREATE TABLE IF NOT EXISTS product_variants (
+    id bigserial PRIMARY KEY,
+    product_id bigint CONSTRAINT fk_products_variants REFERENCES products (id) ON DELETE CASCADE,
+    sku text,
+    title text,
+    option_values text,
+    price decimal,
+    stock bigint,
+    images text[],
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_product_variants_product_id ON product_variants (product_id);
+CREATE INDEX IF NOT EXISTS idx_product_variants_sku ON product_variants (sku);
+
+CREATE TABLE IF NOT EXISTS services (
+    id bigserial PRIMARY KEY,
+    store_id bigint CONSTRAINT fk_stores_services REFERENCES stores (id),
+    name text,
+    description text,
+    image_url text,
+    rate decimal,
+    currency text DEFAULT 'NGN',
+    slot_minutes bigint DEFAULT 60,
+    search_vector tsvector,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_services_store_id ON services (store_id);
+CREATE INDEX IF NOT EXISTS idx_services_search ON services USING gin (search_vector);
+CREATE INDEX IF NOT EXISTS services_name_trgm_idx ON services USING gin (name gin_trgm_ops);
+
+-- Full-text search vectors are kept up to date by triggers
+
+CREATE OR REPLACE FUNCTION products_trigger() RETURNS trigger AS $$
+BEGIN
+    NEW.search_vector = to_tsvector('english', NEW.name || ' ' || COALESCE(NEW.description, ''));
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS products_vector_update ON products;
+CREATE TRIGGER products_vector_update BEFORE INSERT OR UPDATE ON products
+    FOR EACH ROW EXECUTE FUNCTION products_trigger();
+
+CREATE OR REPLACE FUNCTION services_trigger() RETURNS trigger AS $$
+BEGIN
+    NEW.search_vector = to_tsvector('english', NEW.name || ' ' || COALESCE(NEW.description, ''));
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS services_vector_update ON services;
+CREATE TRIGGER services_vector_update BEFORE INSERT OR UPDATE ON services
+    FOR EACH ROW EXECUTE FUNCTION services_trigger();
+
+-- Bookings
+
+CREATE TABLE IF NOT EXISTS service_availabilities (
+    id bigserial PRIMARY KEY,
+    service_id bigint,
+    weekday bigint,
+    start_time varchar(5),
+    end_time varchar(5)
+);
+CREATE INDEX IF NOT EXISTS idx_service_availabilities_service_id ON service_availabilities (service_id);
+
+CREATE TABLE IF NOT EXISTS service_blackouts (
+    id bigserial PRIMARY KEY,
+    service_id bigint,
+    date varchar(10),
+    reason text,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_service_blackouts_service_date ON service_blackouts (service_id, date);
+
+CREATE TABLE IF NOT EXISTS bookings (
+    id bigserial PRIMARY KEY,
+    service_id bigint CONSTRAINT fk_bookings_service REFERENCES services (id),
+    store_id bigint,
+    user_id bigint,
+    starts_at timestamptz,
+    ends_at timestamptz,
+    status text DEFAULT 'requested',
+    price decimal,
+    currency text,
+    note text,
+    payment_id text,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_bookings_service_id ON bookings (service_id);
+CREATE INDEX IF NOT EXISTS idx_bookings_store_id ON bookings (store_id);
+CREATE INDEX IF NOT EXISTS idx_bookings_user_id ON bookings (user_id);
+CREATE INDEX IF NOT EXISTS idx_bookings_starts_at ON bookings (starts_at);
+
+-- Active bookings of a service may not overlap
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'bookings_no_overlap') THEN
+        ALTER TABLE bookings ADD CONSTRAINT bookings_no_overlap EXCLUDE USING gist (
+            service_id WITH =, tstzrange(starts_at, ends_at) WITH &&
+        ) WHERE (status IN ('requested', 'confirmed'));
+    END IF;
+END
+$$;
+
+-- Orders
+
+CREATE TABLE IF NOT EXISTS checkout_groups (
+    id bigserial PRIMARY KEY,
+    user_id bigint,
+    total_amount decimal,
+    currency text,
+    payment_id text,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_checkout_groups_user_id ON checkout_groups (user_id);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id bigserial PRIMARY KEY,
+    user_id bigint CONSTRAINT fk_orders_user REFERENCES users (id),
+    store_id bigint CONSTRAINT fk_orders_store REFERENCES stores (id),
+    status text DEFAULT 'pending',
+    total_amount decimal,
+    payment_id text,
+    checkout_group_id bigint CONSTRAINT fk_checkout_groups_orders REFERENCES checkout_groups (id),
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_orders_user_id ON orders (user_id);
+CREATE INDEX IF NOT EXISTS idx_orders_store_id ON orders (store_id);
+CREATE INDEX IF NOT EXISTS idx_orders_status ON orders (status);
+CREATE INDEX IF NOT EXISTS idx_orders_checkout_group_id ON orders (checkout_group_id);
+
+CREATE TABLE IF NOT EXISTS order_items (
+    id bigserial PRIMARY KEY,
+    order_id bigint CONSTRAINT fk_orders_items REFERENCES orders (id),
+    product_id bigint CONSTRAINT fk_order_items_product REFERENCES products (id),
+    variant_id bigint CONSTRAINT fk_order_items_variant REFERENCES product_variants (id),
+    quantity bigint,
+    price decimal
+);
+
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id bigserial PRIMARY KEY,
+    order_id bigint CONSTRAINT fk_orders_status_history REFERENCES orders (id),
+    from_status text,
+    to_status text,
+    actor_id bigint,
+    actor_role text,
+    note text,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_order_status_history_order_id ON order_status_history (order_id);
+
+CREATE TABLE IF NOT EXISTS inventory_ledger (
+    id bigserial PRIMARY KEY,
+    product_id bigint,
+    variant_id bigint,
+    store_id bigint,
+    order_id bigint,
+    change bigint,
+    stock_after bigint,
+    reason text,
+    actor_id bigint,
+    note text,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_inventory_ledger_product_id ON inventory_ledger (product_id);
+CREATE INDEX IF NOT EXISTS idx_inventory_ledger_variant_id ON inventory_ledger (variant_id);
+CREATE INDEX IF NOT EXISTS idx_inventory_ledger_store_id ON inventory_ledger (store_id);
+CREATE INDEX IF NOT EXISTS idx_inventory_ledger_order_id ON inventory_ledger (order_id);
+
+-- Payments and escrow
+
+CREATE TABLE IF NOT EXISTS payments (
+    id bigserial PRIMARY KEY,
+    order_id bigint,
+    checkout_group_id bigint,
+    booking_id bigint,
+    user_id bigint,
+    provider text,
+    reference text,
+    provider_transaction_id text,
+    amount decimal,
+    currency text,
+    status text DEFAULT 'pending',
+    authorization_url text,
+    paid_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_payments_order_id ON payments (order_id);
+CREATE INDEX IF NOT EXISTS idx_payments_checkout_group_id ON payments (checkout_group_id);
+CREATE INDEX IF NOT EXISTS idx_payments_booking_id ON payments (booking_id);
+CREATE INDEX IF NOT EXISTS idx_payments_user_id ON payments (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_reference ON payments (reference);
+
+CREATE TABLE IF NOT EXISTS escrow_holds (
+    id bigserial PRIMARY KEY,
+    order_id bigint,
+    payment_id bigint,
+    store_id bigint,
+    user_id bigint,
+    amount decimal,
+    currency text,
+    status text DEFAULT 'held',
+    release_after timestamptz,
+    released_at timestamptz,
+    refunded_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_escrow_holds_order_id ON escrow_holds (order_id);
+CREATE INDEX IF NOT EXISTS idx_escrow_holds_store_id ON escrow_holds (store_id);
+CREATE INDEX IF NOT EXISTS idx_escrow_holds_user_id ON escrow_holds (user_id);
+
+CREATE TABLE IF NOT EXISTS escrow_ledger_entries (
+    id bigserial PRIMARY KEY,
+    transaction_id text,
+    hold_id bigint,
+    order_id bigint,
+    account text,
+    store_id bigint,
+    user_id bigint,
+    amount bigint,
+    currency text,
+    description text,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_escrow_ledger_entries_transaction_id ON escrow_ledger_entries (transaction_id);
+CREATE INDEX IF NOT EXISTS idx_escrow_ledger_entries_hold_id ON escrow_ledger_entries (hold_id);
+CREATE INDEX IF NOT EXISTS idx_escrow_ledger_entries_order_id ON escrow_ledger_entries (order_id);
+CREATE INDEX IF NOT EXISTS idx_escrow_ledger_entries_store_id ON escrow_ledger_entries (store_id);
+
+-- Carts
+
+CREATE TABLE IF NOT EXISTS carts (
+    id bigserial PRIMARY KEY,
+    user_id bigint,
+    token text,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_carts_user_id ON carts (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_carts_token ON carts (token);
+
+CREATE TABLE IF NOT EXISTS cart_items (
+    id bigserial PRIMARY KEY,
+    cart_id bigint CONSTRAINT fk_carts_items REFERENCES carts (id) ON DELETE CASCADE,
+    product_id bigint CONSTRAINT fk_cart_items_product REFERENCES products (id),
+    variant_id bigint CONSTRAINT fk_cart_items_variant REFERENCES product_variants (id),
+    quantity bigint,
+    unit_price decimal,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_cart_items_cart_id ON cart_items (cart_id);
+-- One row per product variant. Products without variants have a NULL
+-- variant, which a plain unique index would not compare.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cart_items_cart_product_variant ON cart_items (cart_id, product_id, COALESCE(variant_id, 0));
+
+-- WhatsApp
+
+CREATE TABLE IF NOT EXISTS whatsapp_messages (
+    id bigserial PRIMARY KEY,
+    order_id bigint,
+    "to" text,
+    template text,
+    language text,
+    params text[],
+    body text,
+    status text,
+    attempts bigint,
+    error text,
+    provider_message_id text,
+    sent_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_whatsapp_messages_order_id ON whatsapp_messages (order_id);
+CREATE INDEX IF NOT EXISTS idx_whatsapp_messages_status ON whatsapp_messages (status);
+CREATE INDEX IF NOT EXISTS idx_whatsapp_messages_provider_message_id ON whatsapp_messages (provider_message_id);
+
+CREATE TABLE IF NOT EXISTS store_catalog_syncs (
+    id bigserial PRIMARY KEY,
+    store_id bigint CONSTRAINT fk_stores_catalog_sync REFERENCES stores (id),
+    status text,
+    synced_products bigint,
+    pending_changes bigint,
+    failed_products bigint,
+    last_error text,
+    last_synced_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_store_catalog_syncs_store_id ON store_catalog_syncs (store_id);
+
+CREATE TABLE IF NOT EXISTS catalog_product_syncs (
+    id bigserial PRIMARY KEY,
+    store_id bigint,
+    product_id bigint,
+    retailer_id text,
+    remote_id text,
+    hash text,
+    action text,
+    status text,
+    batch_handle text,
+    last_error text,
+    synced_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_catalog_product_syncs_store_id ON catalog_product_syncs (store_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_catalog_product_syncs_product_id ON catalog_product_syncs (product_id);
+
+-- Media, with no foreign key to stores so media of deleted stores is collected
+
+CREATE TABLE IF NOT EXISTS media (
+    id bigserial PRIMARY KEY,
+    store_id bigint,
+    filename text,
+    key text,
+    url text,
+    content_type text,
+    width bigint,
+    height bigint,
+    size bigint,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_media_store_id ON media (store_id);
+CREATE INDEX IF NOT EXISTS idx_media_created_at ON media (created_at);
+
+CREATE TABLE IF NOT EXISTS media_variants (
+    id bigserial PRIMARY KEY,
+    media_id bigint CONSTRAINT fk_media_variants REFERENCES media (id) ON DELETE CASCADE,
+    name text,
+    key text,
+    url text,
+    content_type text,
+    width bigint,
+    height bigint,
+    size bigint
+);
+CREATE INDEX IF NOT EXISTS idx_media_variants_media_id ON media_variants (media_id);
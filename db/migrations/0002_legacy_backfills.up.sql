@@ -0,0 +1,62 @@
+-- Data fixes that used to run on every boot, for databases that have not had
+-- them yet. They change nothing on a new database.
+
+-- Move the old free-form products.category strings into the taxonomy, file
+-- each product under its category, then drop the old column. Slugs are made
+-- the way slug.Make makes them.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'products' AND column_name = 'category'
+    ) THEN
+        EXECUTE $sql$
+            CREATE TEMPORARY TABLE legacy_categories ON COMMIT DROP AS
+            SELECT DISTINCT TRIM(category) AS name,
+                TRIM(BOTH '-' FROM regexp_replace(
+                    regexp_replace(LOWER(TRIM(category)), '[''’]', '', 'g'),
+                    '[^[:alnum:]]+', '-', 'g')) AS slug
+            FROM products
+            WHERE TRIM(COALESCE(category, '')) <> ''
+        $sql$;
+
+        EXECUTE $sql$
+            INSERT INTO categories (name, slug, created_at, updated_at)
+            SELECT DISTINCT ON (slug) name, slug, NOW(), NOW()
+            FROM legacy_categories
+            WHERE slug <> ''
+            ORDER BY slug, name
+            ON CONFLICT (slug) DO NOTHING
+        $sql$;
+
+        EXECUTE $sql$
+            UPDATE products SET category_id = categories.id
+            FROM legacy_categories
+            JOIN categories ON categories.slug = legacy_categories.slug
+            WHERE TRIM(products.category) = legacy_categories.name AND products.category_id IS NULL
+        $sql$;
+
+        ALTER TABLE products DROP COLUMN category;
+    END IF;
+END
+$$;
+
+-- Users who set up a vendor before roles existed are vendors
+UPDATE users SET role = 'vendor' WHERE role = 'buyer' AND id IN (SELECT user_id FROM vendors);
+
+-- Stores created before memberships existed are owned by their vendor
+INSERT INTO store_members (store_id, email, user_id, role, status, accepted_at, created_at, updated_at)
+SELECT stores.id, LOWER(users.email), users.id, 'owner', 'active', NOW(), NOW(), NOW()
+FROM stores
+JOIN vendors ON vendors.id = stores.vendor_id
+JOIN users ON users.id = vendors.user_id
+WHERE NOT EXISTS (
+    SELECT 1 FROM store_members WHERE store_members.store_id = stores.id AND store_members.role = 'owner'
+)
+ON CONFLICT (store_id, email) DO UPDATE SET user_id = EXCLUDED.user_id, role = EXCLUDED.role,
+    status = EXCLUDED.status, accepted_at = EXCLUDED.accepted_at;
+
+-- Indexes the startup DDL made that duplicate ones above or were replaced
+DROP INDEX IF EXISTS products_search_idx;
+DROP INDEX IF EXISTS services_search_idx;
+DROP INDEX IF EXISTS idx_cart_items_cart_product;
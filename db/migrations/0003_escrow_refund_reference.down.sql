@@ -0,0 +1 @@
+ALTER TABLE escrow_holds DROP COLUMN IF EXISTS refund_reference;
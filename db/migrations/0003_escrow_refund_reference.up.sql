@@ -0,0 +1,3 @@
+-- The provider's ID for an escrow refund, recorded as soon as the provider
+-- accepts it so the refund is never sent twice
+ALTER TABLE escrow_holds ADD COLUMN IF NOT EXISTS refund_reference text;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_escrow_ledger_entries_booking_id;
+ALTER TABLE escrow_ledger_entries DROP COLUMN IF EXISTS booking_id;
+
+DROP INDEX IF EXISTS idx_escrow_holds_booking_id;
+ALTER TABLE escrow_holds DROP COLUMN IF EXISTS booking_id;
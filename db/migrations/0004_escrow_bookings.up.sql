@@ -0,0 +1,6 @@
+-- Service bookings are paid into escrow like orders
+ALTER TABLE escrow_holds ADD COLUMN IF NOT EXISTS booking_id bigint;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_escrow_holds_booking_id ON escrow_holds (booking_id);
+
+ALTER TABLE escrow_ledger_entries ADD COLUMN IF NOT EXISTS booking_id bigint;
+CREATE INDEX IF NOT EXISTS idx_escrow_ledger_entries_booking_id ON escrow_ledger_entries (booking_id);
@@ -0,0 +1,4 @@
+-- Fails while more than one store claims a domain
+DROP INDEX IF EXISTS idx_store_domains_verified_domain;
+DROP INDEX IF EXISTS idx_store_domains_store_domain;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_store_domains_domain ON store_domains (domain);
@@ -0,0 +1,4 @@
+-- Several stores may claim a domain, but only one can have it verified
+DROP INDEX IF EXISTS idx_store_domains_domain;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_store_domains_store_domain ON store_domains (store_id, domain);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_store_domains_verified_domain ON store_domains (domain) WHERE status = 'verified';
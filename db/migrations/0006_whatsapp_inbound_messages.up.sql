@@ -0,0 +1,9 @@
+-- Messages received on the business number, so a webhook delivered twice
+-- never applies the same order command twice
+CREATE TABLE IF NOT EXISTS whatsapp_inbound_messages (
+    id bigserial PRIMARY KEY,
+    message_id text NOT NULL,
+    "from" text,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_whatsapp_inbound_messages_message_id ON whatsapp_inbound_messages (message_id);
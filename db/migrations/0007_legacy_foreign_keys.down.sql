@@ -0,0 +1 @@
+-- The foreign keys are part of the schema 0001 creates, reverting 0001 drops them
@@ -0,0 +1,23 @@
+-- Foreign keys of the columns 0000 adds to databases set up before
+-- migrations. New databases have them from 0001.
+DO $$
+DECLARE
+    fk record;
+BEGIN
+    FOR fk IN
+        SELECT * FROM (VALUES
+            ('products', 'fk_products_category', 'FOREIGN KEY (category_id) REFERENCES categories (id) ON DELETE SET NULL'),
+            ('orders', 'fk_checkout_groups_orders', 'FOREIGN KEY (checkout_group_id) REFERENCES checkout_groups (id)'),
+            ('order_items', 'fk_order_items_variant', 'FOREIGN KEY (variant_id) REFERENCES product_variants (id)'),
+            ('cart_items', 'fk_cart_items_variant', 'FOREIGN KEY (variant_id) REFERENCES product_variants (id)')
+        ) AS keys (table_name, constraint_name, definition)
+    LOOP
+        IF NOT EXISTS (
+            SELECT 1 FROM pg_constraint
+            WHERE conname = fk.constraint_name AND conrelid = to_regclass(fk.table_name)
+        ) THEN
+            EXECUTE format('ALTER TABLE %I ADD CONSTRAINT %I %s', fk.table_name, fk.constraint_name, fk.definition);
+        END IF;
+    END LOOP;
+END
+$$;
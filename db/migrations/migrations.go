@@ -0,0 +1,365 @@
+// Package migrations versions the database schema. Migrations are numbered
+// SQL files embedded in the binary, NNNN_name.up.sql to apply a change and
+// NNNN_name.down.sql to revert it, and the versions applied to a database are
+// recorded in its schema_migrations table.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dir is where new migrations are created, relative to the repository root
+const Dir = "db/migrations"
+
+// lockID names the advisory lock held while migrating, so replicas started
+// together apply migrations one at a time. Any constant shared by every
+// replica will do.
+const lockID int64 = 0x77687473746f7265 // "whtstore"
+
+//go:embed *.sql
+var files embed.FS
+
+var filePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+var namePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+var (
+	ErrSchemaBehind   = errors.New("database schema is behind")
+	ErrUnknownVersion = errors.New("applied migration is not known to this build")
+	ErrInvalidName    = errors.New("migration names may only hold lowercase letters, digits and underscores")
+)
+
+// Migration is one numbered schema change
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration and when it was applied, nil while pending.
+// Migrations applied by a newer build have no name or SQL.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Load reads the embedded migrations in version order
+func Load() ([]Migration, error) {
+	return load(files)
+}
+
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := filePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %w", entry.Name(), err)
+		}
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up applies pending migrations in version order, at most n of them when n
+// is positive, and returns the ones applied. Each migration runs in its own
+// transaction together with recording its version.
+func Up(ctx context.Context, db *sql.DB, n int) ([]Migration, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	err = withLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if n > 0 && len(done) == n {
+				break
+			}
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if err := run(ctx, conn, m.Up, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name); err != nil {
+				return fmt.Errorf("applying %04d_%s: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the n most recently applied migrations, newest first, and
+// returns the ones reverted
+func Down(ctx context.Context, db *sql.DB, n int) ([]Migration, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[int64]Migration, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = m
+	}
+
+	var done []Migration
+	err = withLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		versions := make([]int64, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+		for _, version := range versions {
+			if len(done) == n {
+				break
+			}
+			m, ok := known[version]
+			if !ok {
+				return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+			}
+			if err := run(ctx, conn, m.Down, `DELETE FROM schema_migrations WHERE version = $1`, m.Version); err != nil {
+				return fmt.Errorf("reverting %04d_%s: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Statuses lists every migration, known to this build or applied to the
+// database, in version order
+func Statuses(ctx context.Context, db *sql.DB) ([]Status, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := readApplied(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(migrations))
+	for _, m := range migrations {
+		status := Status{Migration: m}
+		if at, ok := applied[m.Version]; ok {
+			status.AppliedAt = &at
+			delete(applied, m.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for version, at := range applied {
+		at := at
+		statuses = append(statuses, Status{Migration: Migration{Version: version}, AppliedAt: &at})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Pending lists the migrations of this build not yet applied to the database
+func Pending(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	statuses, err := Statuses(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending = append(pending, status.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Check returns ErrSchemaBehind when the database is missing migrations of
+// this build
+func Check(ctx context.Context, db *sql.DB) error {
+	pending, err := Pending(ctx, db)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		first := pending[0]
+		return fmt.Errorf("%w: %d migrations pending from %04d_%s", ErrSchemaBehind, len(pending), first.Version, first.Name)
+	}
+	return nil
+}
+
+// Create writes an empty up and down migration named name to dir, numbered
+// after the newest migration there or in the build, and returns their paths
+func Create(dir, name string) (string, string, error) {
+	if !namePattern.MatchString(name) {
+		return "", "", ErrInvalidName
+	}
+
+	migrations, err := Load()
+	if err != nil {
+		return "", "", err
+	}
+	onDisk, err := load(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+	var latest int64
+	for _, m := range append(migrations, onDisk...) {
+		if m.Version > latest {
+			latest = m.Version
+		}
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("%04d_%s", latest+1, name))
+	up, down := base+".up.sql", base+".down.sql"
+	if err := os.WriteFile(up, []byte("-- Write the schema change here\n"), 0o644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(down, []byte("-- Write how to revert the schema change here\n"), 0o644); err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
+
+// withLock runs fn on a single connection holding the migration lock, with
+// the schema_migrations table in place
+func withLock(ctx context.Context, db *sql.DB, fn func(conn *sql.Conn) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("taking the migration lock: %w", err)
+	}
+	// Released on a fresh context so a cancelled migration still lets go
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID)
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT NOW()
+	)`); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// run executes a migration's SQL and records it in one transaction
+func run(ctx context.Context, conn *sql.Conn, body, record string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if !isEmpty(body) {
+		if _, err := tx.ExecContext(ctx, body); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// isEmpty reports whether SQL holds nothing but comments and whitespace
+func isEmpty(body string) bool {
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// readApplied returns the applied versions, none when the database has never
+// been migrated
+func readApplied(ctx context.Context, q querier) (map[int64]time.Time, error) {
+	var exists bool
+	if err := q.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return map[int64]time.Time{}, nil
+	}
+	return appliedVersions(ctx, q)
+}
+
+func appliedVersions(ctx context.Context, q querier) (map[int64]time.Time, error) {
+	rows, err := q.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
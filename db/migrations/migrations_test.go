@@ -0,0 +1,272 @@
+package migrations
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestLoadEmbedded(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	// Versions start at 0, which adds columns to databases older than 1
+	for i, m := range migrations {
+		if m.Version != int64(i) {
+			t.Errorf("migration %d is version %d, want %d", i, m.Version, i)
+		}
+		if isEmpty(m.Up) || m.Down == "" {
+			t.Errorf("%04d_%s has nothing to apply or no down file", m.Version, m.Name)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	file := func(body string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(body)} }
+
+	migrations, err := load(fstest.MapFS{
+		"0010_later.up.sql":     file("up 10"),
+		"0002_second.up.sql":    file("up 2"),
+		"0002_second.down.sql":  file("down 2"),
+		"0001_first.up.sql":     file("up 1"),
+		"0001_first.down.sql":   file("down 1"),
+		"README.md":             file("not a migration"),
+		"0003_Upper.up.sql":     file("not a migration either"),
+		"migrations.go":         file("package migrations"),
+		"0004_no_direction.sql": file("nor this"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range migrations {
+		got = append(got, fmt.Sprintf("%d %s %q %q", m.Version, m.Name, m.Up, m.Down))
+	}
+	want := []string{`1 first "up 1" "down 1"`, `2 second "up 2" "down 2"`, `10 later "up 10" ""`}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("loaded\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// A down file needs its up file
+	if _, err := load(fstest.MapFS{"0001_first.down.sql": file("down 1")}); err == nil || !strings.Contains(err.Error(), "no up file") {
+		t.Errorf("missing up file: %v", err)
+	}
+
+	// Two migrations may not share a version
+	_, err = load(fstest.MapFS{"0001_first.up.sql": file("up 1"), "0001_other.up.sql": file("up 1")})
+	if err == nil || !strings.Contains(err.Error(), "version 1 is used by") {
+		t.Errorf("clashing versions: %v", err)
+	}
+}
+
+func TestIsEmpty(t *testing.T) {
+	for body, want := range map[string]bool{
+		"":                                  true,
+		"\n  \n":                            true,
+		"-- Write the schema change here\n": true,
+		"-- a comment\n\n  -- another\n":    true,
+		"SELECT 1;":                         false,
+		"-- a comment\nDROP TABLE users;":   false,
+	} {
+		if got := isEmpty(body); got != want {
+			t.Errorf("isEmpty(%q) = %v", body, got)
+		}
+	}
+}
+
+// baseline is the schema AutoMigrate made before any migration existed, with
+// a vendor, a store and a product filed under a free-form category
+const baseline = `
+CREATE TABLE users (id bigserial PRIMARY KEY, clerk_id text, name text, email text, username text,
+    avatar_url text, created_at timestamptz, updated_at timestamptz);
+CREATE UNIQUE INDEX idx_users_clerk_id ON users (clerk_id);
+CREATE UNIQUE INDEX idx_users_email ON users (email);
+CREATE UNIQUE INDEX idx_users_username ON users (username);
+CREATE TABLE user_details (id bigserial PRIMARY KEY, user_id bigint CONSTRAINT fk_users_user_details REFERENCES users (id) ON DELETE SET NULL,
+    preferred_payment text, shipping_address text, created_at timestamptz, updated_at timestamptz);
+CREATE UNIQUE INDEX idx_user_details_user_id ON user_details (user_id);
+CREATE TABLE vendors (id bigserial PRIMARY KEY, user_id bigint CONSTRAINT fk_users_vendor REFERENCES users (id) ON DELETE SET NULL,
+    is_active boolean, created_at timestamptz, updated_at timestamptz);
+CREATE UNIQUE INDEX idx_vendors_user_id ON vendors (user_id);
+CREATE TABLE stores (id bigserial PRIMARY KEY, vendor_id bigint CONSTRAINT fk_vendors_stores REFERENCES vendors (id),
+    name text, description text, store_logo text, store_url text, store_address text, store_whatsapp_contact text,
+    created_at timestamptz, updated_at timestamptz);
+CREATE TABLE products (id bigserial PRIMARY KEY, store_id bigint CONSTRAINT fk_stores_products REFERENCES stores (id),
+    name text, description text, images text[], price decimal, currency text DEFAULT 'NGN', stock bigint, category text,
+    search_vector tsvector, created_at timestamptz, updated_at timestamptz);
+CREATE INDEX products_search_idx ON products USING gin (search_vector);
+CREATE TABLE services (id bigserial PRIMARY KEY, store_id bigint CONSTRAINT fk_stores_services REFERENCES stores (id),
+    name text, description text, image_url text, rate decimal, currency text DEFAULT 'NGN',
+    search_vector tsvector, created_at timestamptz, updated_at timestamptz);
+CREATE INDEX services_search_idx ON services USING gin (search_vector);
+
+INSERT INTO users (clerk_id, name, email, username) VALUES ('user_ada', 'Ada', 'Ada@example.com', 'ada');
+INSERT INTO vendors (user_id, is_active) VALUES (1, true);
+INSERT INTO stores (vendor_id, name, store_url) VALUES (1, 'Ada''s Shoes', 'ada-shoes');
+INSERT INTO products (store_id, name, price, stock, category) VALUES (1, 'Sneakers', 100, 5, ' Men''s Shoes ');
+`
+
+func TestUpAdoptsBaselineAndDownReverts(t *testing.T) {
+	db := database(t)
+	ctx := context.Background()
+	migrations, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := migrations[len(migrations)-1]
+
+	if _, err := db.ExecContext(ctx, baseline); err != nil {
+		t.Fatal(err)
+	}
+	if err := Check(ctx, db); !errors.Is(err, ErrSchemaBehind) {
+		t.Fatal("unmigrated database passes the check")
+	}
+
+	// One at a time, in version order
+	applied, err := Up(ctx, db, 1)
+	if err != nil || len(applied) != 1 || applied[0].Version != 0 {
+		t.Fatalf("applying one migration: %v, %v", versions(applied), err)
+	}
+	applied, err = Up(ctx, db, 0)
+	if err != nil || len(applied) != len(migrations)-1 || applied[0].Version != 1 || applied[len(applied)-1].Version != latest.Version {
+		t.Fatalf("applying the rest: %v, %v", versions(applied), err)
+	}
+	if err := Check(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	if applied, err := Up(ctx, db, 0); err != nil || len(applied) != 0 {
+		t.Errorf("applying again: %v, %v", versions(applied), err)
+	}
+
+	// The baseline data was carried over
+	var role, category string
+	var owners int
+	err = db.QueryRowContext(ctx, `SELECT users.role, categories.slug,
+		(SELECT COUNT(*) FROM store_members WHERE role = 'owner' AND user_id = users.id)
+		FROM users, products JOIN categories ON categories.id = products.category_id`).Scan(&role, &category, &owners)
+	if err != nil || role != "vendor" || category != "mens-shoes" || owners != 1 {
+		t.Errorf("adopted data: role %q, category %q, %d owners, %v", role, category, owners, err)
+	}
+
+	// and the columns added since got their foreign keys
+	var keys int
+	err = db.QueryRowContext(ctx, `SELECT COUNT(*) FROM pg_constraint WHERE conname IN
+		('fk_products_category', 'fk_checkout_groups_orders', 'fk_order_items_variant', 'fk_cart_items_variant')
+		AND connamespace = current_schema()::regnamespace`).Scan(&keys)
+	if err != nil || keys != 4 {
+		t.Errorf("%d foreign keys of added columns, want 4: %v", keys, err)
+	}
+
+	// Reverting goes newest first
+	reverted, err := Down(ctx, db, 1)
+	if err != nil || len(reverted) != 1 || reverted[0].Version != latest.Version {
+		t.Fatalf("reverting one migration: %v, %v", versions(reverted), err)
+	}
+	pending, err := Pending(ctx, db)
+	if err != nil || len(pending) != 1 || pending[0].Version != latest.Version {
+		t.Fatalf("pending after reverting: %v, %v", versions(pending), err)
+	}
+	if err := Check(ctx, db); !errors.Is(err, ErrSchemaBehind) {
+		t.Error("database missing a migration passes the check")
+	}
+
+	reverted, err = Down(ctx, db, len(migrations))
+	if err != nil || len(reverted) != len(migrations)-1 || reverted[0].Version != latest.Version-1 || reverted[len(reverted)-1].Version != 0 {
+		t.Fatalf("reverting the rest: %v, %v", versions(reverted), err)
+	}
+	statuses, err := Statuses(ctx, db)
+	if err != nil || len(statuses) != len(migrations) {
+		t.Fatalf("statuses %+v, %v", statuses, err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt != nil {
+			t.Errorf("%04d_%s still applied", status.Version, status.Name)
+		}
+	}
+
+	// A version this build does not know stops Down
+	if _, err := db.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES (9999, 'future')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Down(ctx, db, 1); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("reverting an unknown version: %v", err)
+	}
+}
+
+func versions(migrations []Migration) []int64 {
+	var versions []int64
+	for _, m := range migrations {
+		versions = append(versions, m.Version)
+	}
+	return versions
+}
+
+// database connects to a schema of its own in the Postgres in
+// TEST_DATABASE_URL, dropped when the test ends. The test is skipped when it
+// is not set.
+func database(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("connecting to the test database: %v", err)
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	schema := fmt.Sprintf("migrationstest_%d_%s", time.Now().Unix(), hex.EncodeToString(suffix))
+	for _, statement := range []string{
+		"CREATE EXTENSION IF NOT EXISTS pg_trgm SCHEMA public",
+		"CREATE EXTENSION IF NOT EXISTS btree_gist SCHEMA public",
+		"CREATE SCHEMA " + schema,
+	} {
+		if err := admin.Exec(statement).Error; err != nil {
+			t.Fatalf("preparing the test schema: %v", err)
+		}
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	path := "search_path=" + schema + ",public"
+	switch {
+	case !strings.Contains(dsn, "://"):
+		dsn += " " + path
+	case strings.Contains(dsn, "?"):
+		dsn += "&" + path
+	default:
+		dsn += "?" + path
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("connecting to the test schema: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return sqlDB
+}
@@ -1,17 +1,33 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net"
 	"os"
+	"os/exec"
+	"strconv"
+	"strings"
 	"time"
+	_ "time/tzdata" // Store time zones must resolve on hosts without zoneinfo
 
-	"github.com/clerk/clerk-sdk-go/v2"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/limiter"
 	"github.com/joho/godotenv"
 	swagger "github.com/swaggo/fiber-swagger"
+	"github.com/theHoracle/whatstore-api/app/booking"
+	"github.com/theHoracle/whatstore-api/app/catalog"
+	"github.com/theHoracle/whatstore-api/app/domains"
+	"github.com/theHoracle/whatstore-api/app/escrow"
 	"github.com/theHoracle/whatstore-api/app/handlers"
+	"github.com/theHoracle/whatstore-api/app/inventory"
+	"github.com/theHoracle/whatstore-api/app/media"
+	"github.com/theHoracle/whatstore-api/app/middleware"
+	"github.com/theHoracle/whatstore-api/app/orderflow"
+	"github.com/theHoracle/whatstore-api/app/payments"
 	"github.com/theHoracle/whatstore-api/app/routes"
+	"github.com/theHoracle/whatstore-api/app/storage"
+	"github.com/theHoracle/whatstore-api/app/whatsapp"
 	"github.com/theHoracle/whatstore-api/db/database"
 	_ "github.com/theHoracle/whatstore-api/docs" // This will import the generated docs
 )
@@ -21,27 +37,140 @@ func main() {
 		log.Println("NO ENV FILE FOUND!")
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrateCommand(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		tokenCommand(os.Args[2:])
+		return
+	}
+
 	database.ConnectDB()
+	checkSchema()
 
-	// init clerk
-	clerk.SetKey(os.Getenv("CLERK_SECRET_KEY"))
-	if os.Getenv("CLERK_SECRET_KEY") == "" {
-		log.Fatal("Clerk secret key not set")
+	// Release stock held by cancelled, rejected and unpaid orders
+	reservationTimeout := 30 * time.Minute
+	if timeout := os.Getenv("ORDER_RESERVATION_TIMEOUT"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			log.Fatalf("Invalid ORDER_RESERVATION_TIMEOUT: %v", err)
+		}
+		reservationTimeout = d
 	}
+	orderflow.OnTransition(inventory.ReleaseOnCancel)
+	go inventory.ExpireUnpaidOrders(database.DB.Db, reservationTimeout)
+
+	// Hold paid orders in escrow until delivery is confirmed, and paid
+	// bookings until the service is completed
+	escrowReleaseDays := 7
+	if days := os.Getenv("ESCROW_RELEASE_DAYS"); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 {
+			log.Fatalf("Invalid ESCROW_RELEASE_DAYS: %s", days)
+		}
+		escrowReleaseDays = n
+	}
+	payments.OnPaid(escrow.HoldPayment)
+	orderflow.OnTransition(escrow.OrderHook(time.Duration(escrowReleaseDays) * 24 * time.Hour))
+	booking.OnTransition(escrow.BookingHook)
+	go escrow.Run(database.DB.Db, 10*time.Minute)
+
+	// Cancel booking requests the vendor never confirmed before they were due
+	go booking.ExpireRequests(database.DB.Db, 10*time.Minute)
 
-	clerkSigningSecret := os.Getenv("CLERK_SIGNING_SECRET")
-	log.Println("Clerk signing secret: " + clerkSigningSecret)
-	if clerkSigningSecret == "" {
-		log.Fatal("Clerk signing secret not set")
+	// Verify vendors' custom domains and re-check them daily
+	if hosts := os.Getenv("PLATFORM_HOSTS"); hosts != "" {
+		domains.UsePlatformHosts(strings.Split(hosts, ","))
+	}
+	if server := os.Getenv("DOMAIN_DNS_SERVER"); server != "" {
+		// Ask a public resolver directly so new records are not hidden by a stale local cache
+		domains.Use(&net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, server)
+			},
+		})
+	}
+	go domains.Run(database.DB.Db, time.Minute)
+
+	// Bearer tokens are checked by Clerk, an OIDC provider or the API itself
+	middleware.UseAuthenticator(authenticator())
+
+	// Payment providers
+	if key := os.Getenv("PAYSTACK_SECRET_KEY"); key != "" {
+		payments.Register(payments.NewPaystack(key, os.Getenv("PAYSTACK_BASE_URL")))
+	}
+	if key := os.Getenv("FLUTTERWAVE_SECRET_KEY"); key != "" {
+		payments.Register(payments.NewFlutterwave(key, os.Getenv("FLUTTERWAVE_SECRET_HASH"), os.Getenv("FLUTTERWAVE_BASE_URL")))
 	}
 
-	app := fiber.New()
+	// WhatsApp order notifications
+	var whatsappClient *whatsapp.Client
+	if token := os.Getenv("WHATSAPP_ACCESS_TOKEN"); token != "" {
+		whatsappClient = whatsapp.NewClient(token, os.Getenv("WHATSAPP_PHONE_NUMBER_ID"), os.Getenv("WHATSAPP_APP_SECRET"), os.Getenv("WHATSAPP_API_BASE_URL"))
+
+		language := os.Getenv("WHATSAPP_TEMPLATE_LANGUAGE")
+		if language == "" {
+			language = "en_US"
+		}
+		notifier := &whatsapp.Notifier{
+			NewOrderTemplate: os.Getenv("WHATSAPP_NEW_ORDER_TEMPLATE"),
+			StatusTemplate:   os.Getenv("WHATSAPP_ORDER_STATUS_TEMPLATE"),
+			Language:         language,
+		}
+		orderflow.OnCreated(notifier.OrderCreated)
+		orderflow.OnTransition(notifier.OrderChanged)
+		go whatsapp.Run(database.DB.Db, whatsappClient, 15*time.Second)
+
+		// Keep stores' WhatsApp Business catalogs in step with their products
+		syncer := &catalog.Syncer{
+			Client:            whatsappClient,
+			StorefrontBaseURL: os.Getenv("STOREFRONT_BASE_URL"),
+			PublicBaseURL:     os.Getenv("PUBLIC_BASE_URL"),
+		}
+		catalog.Use(syncer)
+		go syncer.Run(database.DB.Db, 15*time.Minute)
+	}
+
+	app := fiber.New(fiber.Config{
+		// Bodies over the default limit are streamed rather than refused so
+		// the image upload routes can take them; every other route is held
+		// to the default limit, see routes.API
+		StreamRequestBody: true,
+	})
+
+	// Uploaded images, kept in an S3 compatible bucket when one is set and on
+	// local disk otherwise. Uploads nothing uses are deleted after a day.
+	mediaPublicURL := os.Getenv("MEDIA_PUBLIC_URL")
+	if bucket := os.Getenv("MEDIA_S3_BUCKET"); bucket != "" {
+		media.Use(storage.NewS3(os.Getenv("MEDIA_S3_ENDPOINT"), os.Getenv("MEDIA_S3_REGION"), bucket,
+			os.Getenv("MEDIA_S3_ACCESS_KEY"), os.Getenv("MEDIA_S3_SECRET_KEY"), mediaPublicURL))
+	} else {
+		mediaDir := os.Getenv("MEDIA_DIR")
+		if mediaDir == "" {
+			mediaDir = "uploads"
+		}
+		if mediaPublicURL == "" {
+			mediaPublicURL = "/media"
+		}
+		media.Use(storage.NewLocal(mediaDir, mediaPublicURL))
+		app.Static("/media", mediaDir, fiber.Static{MaxAge: 31536000})
+	}
+	// WebP variants are made with cwebp from libwebp when it is installed
+	if cwebp, err := exec.LookPath("cwebp"); err == nil {
+		media.UseWebP(media.Cwebp(cwebp, 80))
+	} else {
+		log.Println("cwebp not found, uploads get no WebP variants")
+	}
+	go media.CollectOrphans(database.DB.Db, time.Hour, 24*time.Hour)
 
 	// Add rate limiter middleware
 	app.Use(limiter.New(limiter.Config{
 		Next: func(c *fiber.Ctx) bool {
-			// skip if localhost or clerk webhook endpoint
-			return c.IP() == "127.0.0.1" || c.Path() == "/webhooks/clerk"
+			// skip if localhost or a webhook endpoint
+			return c.IP() == "127.0.0.1" || strings.HasPrefix(c.Path(), "/webhooks/")
 		},
 		Max:        100,             // max number of requests
 		Expiration: 1 * time.Minute, // per 1 minute
@@ -55,24 +184,26 @@ func main() {
 		},
 	}))
 
-	// Global middleware
-	app.Use(func(c *fiber.Ctx) error {
-		c.Locals("db", database.DB.Db)
-		return c.Next()
-	})
-
 	// Documentation routes
 	app.Get("/swagger/*", swagger.WrapHandler)
 
 	// Webhooks
 	webhooks := app.Group("/webhooks")
 	{
-		webhooks.Post("/clerk", handlers.ClerkWebhookHandler(database.DB.Db, clerkSigningSecret))
+		// Clerk keeps users in step through its webhook, other providers
+		// need users created some other way
+		if secret := os.Getenv("CLERK_SIGNING_SECRET"); secret != "" {
+			webhooks.Post("/clerk", handlers.ClerkWebhookHandler(database.DB.Db, secret))
+		}
+		webhooks.Post("/payments/:provider", handlers.PaymentWebhookHandler(database.DB.Db))
+		if whatsappClient != nil {
+			webhooks.Get("/whatsapp", handlers.WhatsappVerifyHandler(os.Getenv("WHATSAPP_VERIFY_TOKEN")))
+			webhooks.Post("/whatsapp", handlers.WhatsappWebhookHandler(database.DB.Db, whatsappClient))
+		}
 	}
 
 	// Setup API routes
-	routes.PublicRoutes(app)
-	routes.PrivateRoutes(app, database.DB.Db)
+	routes.API(app, database.DB.Db, routes.NewHandlers(database.DB.Db))
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/theHoracle/whatstore-api/db/database"
+	"github.com/theHoracle/whatstore-api/db/migrations"
+)
+
+const migrateUsage = `usage: whatstore-api migrate <command>
+
+commands:
+  up [n]         apply pending migrations, or only the next n
+  down [n]       revert the last migration, or the last n
+  status         list migrations and when they were applied
+  create <name>  add empty up and down migration files to ` + migrations.Dir
+
+// migrateCommand runs the migrate subcommand with the arguments after "migrate"
+func migrateCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+
+	if args[0] == "create" {
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			os.Exit(2)
+		}
+		up, down, err := migrations.Create(migrations.Dir, args[1])
+		if err != nil {
+			log.Fatalf("Could not create migration: %v", err)
+		}
+		fmt.Println("Created", up)
+		fmt.Println("Created", down)
+		return
+	}
+
+	ctx := context.Background()
+	db := sqlDB()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrations.Up(ctx, db, migrateCount(args, 0))
+		for _, m := range applied {
+			fmt.Printf("Applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("Schema is up to date")
+		}
+
+	case "down":
+		reverted, err := migrations.Down(ctx, db, migrateCount(args, 1))
+		for _, m := range reverted {
+			fmt.Printf("Reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+
+	case "status":
+		statuses, err := migrations.Statuses(ctx, db)
+		if err != nil {
+			log.Fatalf("Could not read migration status: %v", err)
+		}
+		for _, status := range statuses {
+			name := status.Name
+			if name == "" {
+				name = "(unknown to this build)"
+			}
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d  %-40s  %s\n", status.Version, name, applied)
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
+
+// migrateCount reads the optional number of migrations to run
+func migrateCount(args []string, fallback int) int {
+	if len(args) < 2 {
+		return fallback
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n < 1 {
+		log.Fatalf("Invalid number of migrations: %s", args[1])
+	}
+	return n
+}
+
+// checkSchema stops the server from starting on a database that is missing
+// migrations the code depends on
+func checkSchema() {
+	if err := migrations.Check(context.Background(), sqlDB()); err != nil {
+		log.Fatalf("%v. Run `whatstore-api migrate up` first.", err)
+	}
+}
+
+func sqlDB() *sql.DB {
+	if database.DB.Db == nil {
+		database.ConnectDB()
+	}
+	db, err := database.DB.Db.DB()
+	if err != nil {
+		log.Fatalf("Could not get database connection: %v", err)
+	}
+	return db
+}